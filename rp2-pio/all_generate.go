@@ -0,0 +1,3 @@
+package pio
+
+//go:generate go run ./internal/stubgen -o stub.go -err "pio: PIO not available on this target"
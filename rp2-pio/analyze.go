@@ -0,0 +1,139 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"device/rp"
+	"strconv"
+)
+
+// Warning is a likely bug found by AnalyzeProgram.
+type Warning struct {
+	// Addr is the index of the offending instruction in the program.
+	Addr uint8
+	Msg  string
+}
+
+// String returns the warning with the instruction index, i.e: "pio: instr 3: jump outside program".
+func (w Warning) String() string {
+	return "pio: instr " + strconv.Itoa(int(w.Addr)) + ": " + w.Msg
+}
+
+// AnalyzeProgram checks a program for common mistakes in the program itself or in
+// the state machine configuration it is meant to run with. instrs is the program as
+// assembled, with jump targets relative to its start, i.e. before AddProgram
+// relocates it. Checks are heuristics: a warning is worth a second look, not
+// necessarily a bug. The checks are:
+//   - jumps outside the program
+//   - side-set values without side-set bits configured with SetSidesetParams
+//   - OUT bit counts that do not add up to the autopull threshold
+//   - OUT or IN without any PULL or PUSH while autopull or autopush is disabled
+//   - OUT pins and SET pins with a pin count of zero
+//   - WAIT pin past the last GPIO when added to the IN pin base
+//
+// AnalyzeProgram is meant to be called at init time in debug builds to catch
+// these issues before they show up as misbehaving hardware.
+func AnalyzeProgram(instrs []uint16, cfg StateMachineConfig) []Warning {
+	var warnings []Warning
+	warn := func(addr int, msg string) {
+		warnings = append(warnings, Warning{Addr: uint8(addr), Msg: msg})
+	}
+	sidesetCount := regField(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_SIDESET_COUNT_Msk, rp.PIO0_SM0_PINCTRL_SIDESET_COUNT_Pos)
+	sidesetBase := regField(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_SIDESET_BASE_Msk, rp.PIO0_SM0_PINCTRL_SIDESET_BASE_Pos)
+	sidesetOpt := cfg.ExecCtrl&rp.PIO0_SM0_EXECCTRL_SIDE_EN_Msk != 0
+	outCount := regField(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_OUT_COUNT_Msk, rp.PIO0_SM0_PINCTRL_OUT_COUNT_Pos)
+	setCount := regField(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_SET_COUNT_Msk, rp.PIO0_SM0_PINCTRL_SET_COUNT_Pos)
+	inBase := regField(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_IN_BASE_Msk, rp.PIO0_SM0_PINCTRL_IN_BASE_Pos)
+	autopull := cfg.ShiftCtrl&rp.PIO0_SM0_SHIFTCTRL_AUTOPULL_Msk != 0
+	autopush := cfg.ShiftCtrl&rp.PIO0_SM0_SHIFTCTRL_AUTOPUSH_Msk != 0
+	pullThresh := regField(cfg.ShiftCtrl, rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Msk, rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Pos)
+	if pullThresh == 0 {
+		pullThresh = 32
+	}
+
+	if len(instrs) > instrMemCapacity {
+		warn(instrMemCapacity, "program longer than instruction memory")
+	}
+	if sidesetOpt && sidesetCount == 1 {
+		warn(0, "optional side-set count must include the enable bit")
+	}
+	if sidesetCount == 0 && sidesetBase != 0 {
+		warn(0, "side-set pins configured without side-set bits")
+	}
+	var (
+		firstOut, firstIn = -1, -1
+		hasPull, hasPush  bool
+		outBits           uint8 // Bit count of the first OUT instruction.
+		outBitsAddr       int
+		outMixed          bool // Set if OUT instructions use different bit counts.
+	)
+	for addr, instr := range instrs {
+		arg1 := uint8(instr>>5) & 7
+		arg2 := uint8(instr) & 0x1f
+		if sidesetCount == 0 && instr>>8&0x10 != 0 {
+			warn(addr, "delay of 16 cycles or more, side-set without SetSidesetParams?")
+		}
+		switch majorInstrBits(instr) {
+		case _INSTR_BITS_JMP:
+			if int(arg2) >= len(instrs) {
+				warn(addr, "jump outside program")
+			}
+		case _INSTR_BITS_WAIT:
+			if arg1&3 == 1 && inBase+uint32(arg2) >= 30 {
+				warn(addr, "wait pin "+strconv.Itoa(int(arg2))+" past last GPIO from IN pin base "+strconv.Itoa(int(inBase)))
+			}
+		case _INSTR_BITS_IN:
+			if firstIn < 0 {
+				firstIn = addr
+			}
+		case _INSTR_BITS_OUT:
+			if firstOut < 0 {
+				firstOut = addr
+			}
+			dest := SrcDest(arg1)
+			if dest == SrcDestPins && outCount == 0 {
+				warn(addr, "out pins with an OUT pin count of zero")
+			}
+			if dest == SrcDestNull {
+				break // Discarding bits is a common way to realign.
+			}
+			bits := arg2
+			if bits == 0 {
+				bits = 32
+			}
+			if outBits == 0 {
+				outBits, outBitsAddr = bits, addr
+			} else if bits != outBits {
+				outMixed = true
+			}
+		case _INSTR_BITS_PUSH:
+			if arg1&4 != 0 {
+				hasPull = true
+			} else {
+				hasPush = true
+			}
+		case _INSTR_BITS_MOV:
+			// Moves into OSR or out of ISR replace explicit pulls and pushes in some programs.
+			hasPull = hasPull || SrcDest(arg1) == SrcDestOSR
+			hasPush = hasPush || SrcDest(arg2&7) == SrcDestISR
+		case _INSTR_BITS_SET:
+			if dest := SrcDest(arg1); (dest == SrcDestPins || dest == SrcDestPinDirs) && setCount == 0 {
+				warn(addr, "set pins or pindirs with a SET pin count of zero")
+			}
+		}
+	}
+	if firstOut >= 0 && !autopull && !hasPull {
+		warn(firstOut, "out without pull while autopull is disabled")
+	}
+	if firstIn >= 0 && !autopush && !hasPush {
+		warn(firstIn, "in without push while autopush is disabled")
+	}
+	if autopull && outBits != 0 && !outMixed && pullThresh%uint32(outBits) != 0 {
+		warn(outBitsAddr, "out of "+strconv.Itoa(int(outBits))+" bits does not divide autopull threshold "+strconv.Itoa(int(pullThresh)))
+	}
+	return warnings
+}
+
+func regField(reg, msk, pos uint32) uint32 {
+	return (reg & msk) >> pos
+}
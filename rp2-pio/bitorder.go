@@ -0,0 +1,44 @@
+package pio
+
+// BitOrder is the order in which the bits of a word are shifted out to or in from
+// the pins, as set by StateMachineConfig.ConfigureTx and ConfigureRx.
+//
+// The OSR shifts out its top bit first when shifting left, and its bottom bit when
+// shifting right, so a word narrower than 32 bits must be written to the TX FIFO
+// left-aligned when sent MSB first and right-aligned when sent LSB first. The ISR
+// shifts bits in at the other end, so a received word comes right-aligned when
+// received MSB first and left-aligned when received LSB first. TxWord and RxWord
+// do the alignment.
+type BitOrder uint8
+
+const (
+	// MSBFirst shifts the most significant bit first, as SPI, I2C and WS2812B LEDs
+	// do. Multi-byte words are thus sent big-endian.
+	MSBFirst BitOrder = iota
+	// LSBFirst shifts the least significant bit first, as UARTs do. Multi-byte
+	// words are thus sent little-endian.
+	LSBFirst
+)
+
+// shiftRight returns the shift direction of the OSR and ISR for the bit order.
+func (order BitOrder) shiftRight() bool {
+	return order == LSBFirst
+}
+
+// TxWord aligns the low bitsPerWord bits of v for the TX FIFO of a state machine
+// shifting them out in order.
+func (order BitOrder) TxWord(bitsPerWord uint8, v uint32) uint32 {
+	if order == MSBFirst && bitsPerWord < 32 {
+		return v << (32 - bitsPerWord)
+	}
+	return v
+}
+
+// RxWord returns the bitsPerWord bits of a word read from the RX FIFO of a state
+// machine shifting them in order, right-aligned.
+func (order BitOrder) RxWord(bitsPerWord uint8, w uint32) uint32 {
+	if order == LSBFirst && bitsPerWord < 32 {
+		return w >> (32 - bitsPerWord)
+	}
+	return w
+}
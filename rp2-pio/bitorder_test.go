@@ -0,0 +1,132 @@
+//go:build rp2040
+
+package pio
+
+import "testing"
+
+var bitOrderSizes = []uint8{1, 8, 24, 32}
+
+// shiftOut returns the bits of the OSR loaded with w, in the order an OUT
+// instruction shifts them to the pins.
+func shiftOut(w uint32, shiftRight bool, bits uint8) []uint32 {
+	out := make([]uint32, bits)
+	for i := range out {
+		if shiftRight {
+			out[i] = w & 1
+			w >>= 1
+		} else {
+			out[i] = w >> 31
+			w <<= 1
+		}
+	}
+	return out
+}
+
+// shiftIn returns the ISR after IN instructions shifted in the bits from the pins,
+// starting from an empty ISR.
+func shiftIn(in []uint32, shiftRight bool) uint32 {
+	var w uint32
+	for _, b := range in {
+		if shiftRight {
+			w = w>>1 | b<<31
+		} else {
+			w = w<<1 | b
+		}
+	}
+	return w
+}
+
+// wireBits returns the low bits of v in order.
+func wireBits(order BitOrder, v uint32, bits uint8) []uint32 {
+	wire := make([]uint32, bits)
+	for i := range wire {
+		shift := i
+		if order == MSBFirst {
+			shift = int(bits) - 1 - i
+		}
+		wire[i] = v >> shift & 1
+	}
+	return wire
+}
+
+func TestBitOrderTxWord(t *testing.T) {
+	const v = 0xA5C3E1F7
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		for _, bits := range bitOrderSizes {
+			word := v & uint32(1<<bits-1)
+			got := shiftOut(order.TxWord(bits, word), order.shiftRight(), bits)
+			want := wireBits(order, word, bits)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("order %d, %d bits: bit %d on the wire is %d, want %d", order, bits, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestBitOrderRxWord(t *testing.T) {
+	const v = 0x5A3C1E8F
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		for _, bits := range bitOrderSizes {
+			word := v & uint32(1<<bits-1)
+			isr := shiftIn(wireBits(order, word, bits), order.shiftRight())
+			if got := order.RxWord(bits, isr); got != word {
+				t.Errorf("order %d, %d bits: ISR %#x read as %#x, want %#x", order, bits, isr, got, word)
+			}
+		}
+	}
+}
+
+func TestConfigureShift(t *testing.T) {
+	// SHIFTCTRL fields per the RP2040 and RP2350 datasheets.
+	const (
+		autoPush      = 1 << 16
+		autoPull      = 1 << 17
+		inShiftRight  = 1 << 18
+		outShiftRight = 1 << 19
+		pushThreshP   = 20
+		pullThreshP   = 25
+	)
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		for _, bits := range bitOrderSizes {
+			// A threshold of 32 is written as 0.
+			thresh := uint32(bits) & 0x1f
+
+			var cfg StateMachineConfig
+			cfg.ConfigureTx(order, bits, true)
+			want := autoPull | thresh<<pullThreshP
+			if order == LSBFirst {
+				want |= outShiftRight
+			}
+			if cfg.ShiftCtrl != want {
+				t.Errorf("ConfigureTx(%d, %d): SHIFTCTRL %#x, want %#x", order, bits, cfg.ShiftCtrl, want)
+			}
+
+			cfg = StateMachineConfig{}
+			cfg.ConfigureRx(order, bits, true)
+			want = autoPush | thresh<<pushThreshP
+			if order == LSBFirst {
+				want |= inShiftRight
+			}
+			if cfg.ShiftCtrl != want {
+				t.Errorf("ConfigureRx(%d, %d): SHIFTCTRL %#x, want %#x", order, bits, cfg.ShiftCtrl, want)
+			}
+		}
+	}
+}
+
+func TestConfigureShiftInvalid(t *testing.T) {
+	for _, bits := range []uint8{0, 33} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic on %d bits per word", bits)
+				}
+			}()
+			var cfg StateMachineConfig
+			cfg.ConfigureTx(MSBFirst, bits, false)
+		}()
+	}
+}
@@ -0,0 +1,173 @@
+package pio
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ProgramBuilder errors.
+var (
+	errProgramTooLong  = errors.New("pio: program exceeds " + strconv.Itoa(instrMemCapacity) + " instructions")
+	errProgramEmpty    = errors.New("pio: program has no instructions")
+	errNoInstruction   = errors.New("pio: side-set or delay without instruction")
+	errDelayTooLong    = errors.New("pio: delay exceeds available delay bits")
+	errSidesetValue    = errors.New("pio: side-set value exceeds side-set bits")
+	errMacroRegister   = errors.New("pio: macro register must be X or Y")
+	errMacroBadBitSize = errors.New("pio: macro bit count out of range")
+)
+
+// ProgramBuilder assembles a Program at runtime. Instructions are added with Add
+// and the side-set and delay of the last added instruction are set with Side and
+// Delay, i.e:
+//
+//	b.Add(EncodeSet(SrcDestPins, 1)).Side(1).Delay(3)
+//
+// Jump addresses are relative to the start of the program, as with pioasm.
+// The first error encountered is returned by Build.
+type ProgramBuilder struct {
+	prog    Program
+	wrapSet bool
+	err     error
+}
+
+// NewProgramBuilder returns a builder for a program named name using sidesetPins
+// side-set pins. If sidesetOptional is set instructions without side-set keep the
+// pins unchanged at the cost of a delay bit. If sidesetPindirs is set side-set
+// drives pin directions instead of values.
+func NewProgramBuilder(name string, sidesetPins uint8, sidesetOptional, sidesetPindirs bool) *ProgramBuilder {
+	b := &ProgramBuilder{prog: Program{
+		Name:            name,
+		Origin:          -1,
+		SidesetBits:     sidesetPins,
+		SidesetOptional: sidesetOptional && sidesetPins > 0,
+		SidesetPindirs:  sidesetPindirs,
+	}}
+	if b.prog.SidesetOptional {
+		b.prog.SidesetBits++
+	}
+	if b.prog.SidesetBits > 5 {
+		b.err = errSidesetValue
+	}
+	return b
+}
+
+// Add appends instructions to the program.
+func (b *ProgramBuilder) Add(instrs ...uint16) *ProgramBuilder {
+	if len(b.prog.Instructions)+len(instrs) > instrMemCapacity {
+		b.setErr(errProgramTooLong)
+		return b
+	}
+	b.prog.Instructions = append(b.prog.Instructions, instrs...)
+	return b
+}
+
+// Side sets the side-set value of the last added instruction.
+func (b *ProgramBuilder) Side(value uint8) *ProgramBuilder {
+	n := len(b.prog.Instructions)
+	if n == 0 {
+		b.setErr(errNoInstruction)
+		return b
+	}
+	pins := b.prog.SidesetBits
+	if b.prog.SidesetOptional {
+		pins--
+	}
+	if pins == 0 || value>>pins != 0 {
+		b.setErr(errSidesetValue)
+		return b
+	}
+	const sidesetMask = 0x1f << 8
+	var field uint16
+	if b.prog.SidesetOptional {
+		field = EncodeSetSetOpt(pins, value)
+	} else {
+		field = EncodeSideSet(pins, value)
+	}
+	instr := &b.prog.Instructions[n-1]
+	*instr = *instr&^sidesetMask | field | *instr&b.delayMask()
+	return b
+}
+
+// Delay sets the number of idle cycles after the last added instruction.
+func (b *ProgramBuilder) Delay(cycles uint8) *ProgramBuilder {
+	n := len(b.prog.Instructions)
+	if n == 0 {
+		b.setErr(errNoInstruction)
+		return b
+	}
+	mask := b.delayMask()
+	if uint16(cycles)<<8&^mask != 0 {
+		b.setErr(errDelayTooLong)
+		return b
+	}
+	instr := &b.prog.Instructions[n-1]
+	*instr = *instr&^mask | uint16(cycles)<<8
+	return b
+}
+
+// Addr returns the address of the next instruction relative to the start of the
+// program, for use as a jump target.
+func (b *ProgramBuilder) Addr() uint8 {
+	return uint8(len(b.prog.Instructions))
+}
+
+// Label marks the next instruction with a public label.
+func (b *ProgramBuilder) Label(name string) *ProgramBuilder {
+	if b.prog.PublicLabels == nil {
+		b.prog.PublicLabels = make(map[string]uint8)
+	}
+	b.prog.PublicLabels[name] = b.Addr()
+	return b
+}
+
+// SetOrigin sets the instruction memory offset the program must be loaded at.
+func (b *ProgramBuilder) SetOrigin(origin int8) *ProgramBuilder {
+	b.prog.Origin = origin
+	return b
+}
+
+// WrapTarget marks the next instruction as the wrap target. The program
+// wraps to its start if WrapTarget is not called.
+func (b *ProgramBuilder) WrapTarget() *ProgramBuilder {
+	b.prog.WrapTarget = b.Addr()
+	return b
+}
+
+// Wrap marks the last added instruction as the wrap source. The program
+// wraps after its last instruction if Wrap is not called.
+func (b *ProgramBuilder) Wrap() *ProgramBuilder {
+	if b.Addr() == 0 {
+		b.setErr(errNoInstruction)
+		return b
+	}
+	b.prog.Wrap = b.Addr() - 1
+	b.wrapSet = true
+	return b
+}
+
+// Build returns the assembled program or the first error encountered while building it.
+func (b *ProgramBuilder) Build() (Program, error) {
+	if b.err != nil {
+		return Program{}, b.err
+	}
+	if len(b.prog.Instructions) == 0 {
+		return Program{}, errProgramEmpty
+	}
+	prog := b.prog
+	prog.Instructions = append([]uint16(nil), b.prog.Instructions...)
+	if !b.wrapSet {
+		prog.Wrap = uint8(len(prog.Instructions) - 1)
+	}
+	return prog, nil
+}
+
+// delayMask returns the bits of the delay/side-set field available for delay.
+func (b *ProgramBuilder) delayMask() uint16 {
+	return (1<<(5-b.prog.SidesetBits) - 1) << 8
+}
+
+func (b *ProgramBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -23,6 +23,17 @@ func DefaultStateMachineConfig() StateMachineConfig {
 	return cfg
 }
 
+// DefaultConfig returns the default state machine configuration for the program
+// loaded at offset, equivalent to the ProgramDefaultConfig function pioasm generates.
+func (p *Program) DefaultConfig(offset uint8) StateMachineConfig {
+	cfg := DefaultStateMachineConfig()
+	cfg.SetWrap(offset+p.WrapTarget, offset+p.Wrap)
+	if p.SidesetBits > 0 {
+		cfg.SetSidesetParams(p.SidesetBits, p.SidesetOptional, p.SidesetPindirs)
+	}
+	return cfg
+}
+
 // StateMachineConfig holds the configuration for a PIO state
 // machine.
 //
@@ -37,6 +48,15 @@ type StateMachineConfig struct {
 	ShiftCtrl uint32
 	// State machine pin control.
 	PinCtrl uint32
+	// minVersion is the PIO version required by the configuration, checked when it
+	// is applied to a state machine.
+	minVersion uint8
+	// Selector and parameter of SetMovStatus, if hasMovStatus. They are encoded in
+	// ExecCtrl when the configuration is applied, the layout of their fields
+	// depending on the PIO version.
+	movStatusSel MovStatus
+	movStatusN   uint32
+	hasMovStatus bool
 }
 
 // SetClkDivIntFrac sets the clock divider for the state
@@ -90,6 +110,28 @@ func (cfg *StateMachineConfig) SetOutShift(shiftRight bool, autoPull bool, pushT
 		(uint32(pushThreshold&0x1f) << rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Pos)
 }
 
+// ConfigureTx sets the 'out' shifting parameters for words of bitsPerWord bits, 1
+// to 32, shifted out in order. With autoPull the OSR is refilled from the TX FIFO
+// once a whole word has been shifted out. Words are written to the FIFO aligned by
+// order.TxWord.
+func (cfg *StateMachineConfig) ConfigureTx(order BitOrder, bitsPerWord uint8, autoPull bool) {
+	if bitsPerWord == 0 || bitsPerWord > 32 {
+		panic("ConfigureTx: bitsPerWord")
+	}
+	cfg.SetOutShift(order.shiftRight(), autoPull, uint16(bitsPerWord))
+}
+
+// ConfigureRx sets the 'in' shifting parameters for words of bitsPerWord bits, 1
+// to 32, shifted in in order. With autoPush the ISR is pushed to the RX FIFO once
+// a whole word has been shifted in. Words read from the FIFO are aligned by
+// order.RxWord.
+func (cfg *StateMachineConfig) ConfigureRx(order BitOrder, bitsPerWord uint8, autoPush bool) {
+	if bitsPerWord == 0 || bitsPerWord > 32 {
+		panic("ConfigureRx: bitsPerWord")
+	}
+	cfg.SetInShift(order.shiftRight(), autoPush, uint16(bitsPerWord))
+}
+
 // SetSidesetParams sets the side-set parameters in a state machine configuration.
 //   - bitcount is number of bits to steal from delay field in the instruction for use of side set (max 5).
 //   - optional is true if the topmost side set bit is used as a flag for whether to apply side set on that instruction.
@@ -179,12 +221,38 @@ func (cfg *StateMachineConfig) SetOutSpecial(sticky, hasEnablePin bool, enable m
 
 // SetMovStatus sets source for 'mov status' in a state machine configuration.
 //   - statusSel is the status operation selector.
-//   - statusN parameter for the mov status operation (currently a bit count).
+//   - statusN parameter for the mov status operation: the FIFO level to compare
+//     against or, for MovStatusIRQ, the IRQ flag.
+//
+// The fields are encoded with the layout of the PIO version of the block the
+// configuration is applied to: ExecCtrl holds them in the layout of the first
+// version supporting the selector until then. A configuration with a selector not
+// supported by the PIO version of the block, see PIO.Version, makes
+// StateMachine.Init and SetConfig panic.
 func (cfg *StateMachineConfig) SetMovStatus(statusSel MovStatus, statusN uint32) {
-	cfg.ExecCtrl = (cfg.ExecCtrl &
-		^uint32(rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Msk|rp.PIO0_SM0_EXECCTRL_STATUS_N_Msk)) |
-		((uint32(statusSel) << rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Pos) & rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Msk) |
-		((statusN << rp.PIO0_SM0_EXECCTRL_STATUS_N_Pos) & rp.PIO0_SM0_EXECCTRL_STATUS_N_Msk)
+	if statusSel.minVersion() > cfg.minVersion {
+		cfg.minVersion = statusSel.minVersion()
+	}
+	cfg.movStatusSel, cfg.movStatusN, cfg.hasMovStatus = statusSel, statusN, true
+	cfg.ExecCtrl = cfg.execCtrl(statusSel.minVersion())
+}
+
+// execCtrl returns ExecCtrl with the fields set by SetMovStatus encoded for PIO
+// version.
+func (cfg *StateMachineConfig) execCtrl(version uint8) uint32 {
+	if !cfg.hasMovStatus {
+		return cfg.ExecCtrl
+	}
+	// Both fields are wider on PIO version 1 and overlap the RP2040 ones, so all
+	// of them are cleared.
+	execctrl := cfg.ExecCtrl &^ (rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Msk | rp.PIO0_SM0_EXECCTRL_STATUS_N_Msk |
+		execctrlStatusSelMskV1 | execctrlStatusNMskV1)
+	sel, n := uint32(cfg.movStatusSel), cfg.movStatusN
+	if version >= 1 {
+		return execctrl | sel<<execctrlStatusSelPosV1&execctrlStatusSelMskV1 | n&execctrlStatusNMskV1
+	}
+	return execctrl | ((sel << rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Pos) & rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Msk) |
+		((n << rp.PIO0_SM0_EXECCTRL_STATUS_N_Pos) & rp.PIO0_SM0_EXECCTRL_STATUS_N_Msk)
 }
 
 func checkPinBaseAndCount(base machine.Pin, count uint8) {
@@ -195,6 +263,7 @@ func checkPinBaseAndCount(base machine.Pin, count uint8) {
 	}
 }
 
+// FifoJoin selects how the RX and TX FIFOs of a state machine share their storage.
 type FifoJoin uint8
 
 const (
@@ -204,23 +273,103 @@ const (
 	FifoJoinTx
 	// FifoJoinRx joins the RX and TX FIFOs into a single RX FIFO of depth 8.
 	FifoJoinRx
+	// FifoJoinTxGet disables the RX FIFO and makes its 4 words registers written by
+	// the CPU and read by the state machine with 'mov osr, rxfifo[i]', i.e. for
+	// parameters a program reads without consuming them. It requires PIO version 1
+	// (RP2350).
+	FifoJoinTxGet
+	// FifoJoinTxPut disables the RX FIFO and makes its 4 words registers written by
+	// the state machine with 'mov rxfifo[i], isr' and read by the CPU, i.e. for
+	// status a program updates in place. It requires PIO version 1 (RP2350).
+	FifoJoinTxPut
+	// FifoJoinPutGet disables the RX FIFO and makes its 4 words scratch registers
+	// read and written by the state machine only. It requires PIO version 1 (RP2350).
+	FifoJoinPutGet
 )
 
+// SHIFTCTRL bits of the RX FIFO random access modes of PIO version 1, missing
+// from the RP2040 register definitions.
+const (
+	shiftctrlFJoinRxGet = 1 << 14
+	shiftctrlFJoinRxPut = 1 << 15
+
+	// EXECCTRL status fields of PIO version 1: a 2 bit selector taking the IRQ
+	// selector and a 5 bit parameter taking the IRQ flag and block.
+	execctrlStatusSelPosV1 = 5
+	execctrlStatusSelMskV1 = 0x3 << execctrlStatusSelPosV1
+	execctrlStatusNMskV1   = 0x1f
+)
+
+// shiftCtrl returns the SHIFTCTRL bits selecting the join mode.
+func (join FifoJoin) shiftCtrl() uint32 {
+	switch join {
+	case FifoJoinNone:
+		return 0
+	case FifoJoinTx:
+		return rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk
+	case FifoJoinRx:
+		return rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk
+	case FifoJoinTxGet:
+		return shiftctrlFJoinRxGet
+	case FifoJoinTxPut:
+		return shiftctrlFJoinRxPut
+	case FifoJoinPutGet:
+		return shiftctrlFJoinRxGet | shiftctrlFJoinRxPut
+	}
+	panic("SetFIFOJoin: join")
+}
+
+// minVersion returns the first PIO version supporting the join mode.
+func (join FifoJoin) minVersion() uint8 {
+	if join >= FifoJoinTxGet {
+		return 1
+	}
+	return 0
+}
+
 // MOV status types.
 type MovStatus uint8
 
 const (
+	// MovStatusTxLessthan sets status to all ones if the TX FIFO level is below statusN, zero otherwise.
 	MovStatusTxLessthan MovStatus = iota
+	// MovStatusRxLessthan sets status to all ones if the RX FIFO level is below statusN, zero otherwise.
 	MovStatusRxLessthan
+	// MovStatusIRQ sets status to all ones if an IRQ flag is set and zero otherwise,
+	// letting a program branch on a flag raised by another state machine without
+	// stalling on it as WAIT IRQ does:
+	//
+	//	mov x, status
+	//	jmp !x flag_clear
+	//
+	// Bits 0..2 of statusN select the flag and bits 3..4 the PIO block raising it:
+	// 0 for the same block, 1 for the previous and 2 for the next one.
+	// It requires PIO version 1 (RP2350).
+	MovStatusIRQ
 )
 
-// SetFIFOJoin Setup the FIFO joining in a state machine configuration.
+// minVersion returns the first PIO version supporting the selector.
+func (sel MovStatus) minVersion() uint8 {
+	if sel >= MovStatusIRQ {
+		return 1
+	}
+	return 0
+}
+
+// SetFIFOJoin sets the FIFO joining in a state machine configuration. Each mode
+// sets its own bits of SHIFTCTRL and clears those of the other modes: FJOIN_TX or
+// FJOIN_RX to give the storage of one FIFO to the other, and on PIO version 1
+// FJOIN_RX_GET and FJOIN_RX_PUT to turn the RX FIFO into random access registers.
+//
+// A configuration with a join mode not supported by the PIO version of the block it
+// is used with, see PIO.Version, makes StateMachine.Init and SetConfig panic.
 func (cfg *StateMachineConfig) SetFIFOJoin(join FifoJoin) {
-	if join > FifoJoinRx {
-		panic("SetFIFOJoin: join")
+	bits := join.shiftCtrl()
+	if join.minVersion() > cfg.minVersion {
+		cfg.minVersion = join.minVersion()
 	}
-	cfg.ShiftCtrl = (cfg.ShiftCtrl & ^uint32(rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk|rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)) |
-		(uint32(join) << rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Pos)
+	cfg.ShiftCtrl = cfg.ShiftCtrl&^uint32(rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk|rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk|
+		shiftctrlFJoinRxGet|shiftctrlFJoinRxPut) | bits
 }
 
 func boolToBit(b bool) uint32 {
@@ -0,0 +1,100 @@
+//go:build rp2040
+
+package pio
+
+import "testing"
+
+func TestSetFIFOJoin(t *testing.T) {
+	// SHIFTCTRL join bits per the RP2040 and RP2350 datasheets.
+	const (
+		fjoinRx    = 1 << 31
+		fjoinTx    = 1 << 30
+		fjoinRxPut = 1 << 15
+		fjoinRxGet = 1 << 14
+		joinBits   = fjoinRx | fjoinTx | fjoinRxPut | fjoinRxGet
+		// Autopull, threshold 8, shift right: bits a join must keep.
+		other = 1<<17 | 8<<25 | 1<<19
+	)
+	tests := []struct {
+		join       FifoJoin
+		bits       uint32
+		minVersion uint8
+	}{
+		{FifoJoinNone, 0, 0},
+		{FifoJoinTx, fjoinTx, 0},
+		{FifoJoinRx, fjoinRx, 0},
+		{FifoJoinTxGet, fjoinRxGet, 1},
+		{FifoJoinTxPut, fjoinRxPut, 1},
+		{FifoJoinPutGet, fjoinRxGet | fjoinRxPut, 1},
+	}
+	for _, tt := range tests {
+		// Each mode must clear the bits of every other mode.
+		for _, prev := range tests {
+			var cfg StateMachineConfig
+			cfg.ShiftCtrl = other
+			cfg.SetFIFOJoin(prev.join)
+			cfg.SetFIFOJoin(tt.join)
+			if got := cfg.ShiftCtrl & joinBits; got != tt.bits {
+				t.Errorf("join %d after %d: join bits %#x, want %#x", tt.join, prev.join, got, tt.bits)
+			}
+			if got := cfg.ShiftCtrl &^ joinBits; got != other {
+				t.Errorf("join %d after %d: other bits %#x, want %#x", tt.join, prev.join, got, other)
+			}
+		}
+		var cfg StateMachineConfig
+		cfg.SetFIFOJoin(tt.join)
+		if cfg.minVersion != tt.minVersion {
+			t.Errorf("join %d: min version %d, want %d", tt.join, cfg.minVersion, tt.minVersion)
+		}
+	}
+}
+
+func TestSetFIFOJoinInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic on invalid join")
+		}
+	}()
+	var cfg StateMachineConfig
+	cfg.SetFIFOJoin(FifoJoinPutGet + 1)
+}
+
+func TestSetMovStatus(t *testing.T) {
+	// EXECCTRL status fields per the RP2040 and RP2350 datasheets: STATUS_SEL is
+	// bit 4 and STATUS_N bits 0..3 on the RP2040, bits 5..6 and 0..4 on the RP2350.
+	const (
+		statusBits = 0x7f
+		// Wrap top 31 and jmp pin 7: bits the status fields must keep.
+		other = 31<<12 | 7<<24
+	)
+	tests := []struct {
+		sel        MovStatus
+		n          uint32
+		version    uint8
+		bits       uint32
+		minVersion uint8
+	}{
+		{MovStatusTxLessthan, 3, 0, 3, 0},
+		{MovStatusTxLessthan, 3, 1, 3, 0},
+		{MovStatusRxLessthan, 2, 0, 1<<4 | 2, 0},
+		{MovStatusRxLessthan, 2, 1, 1<<5 | 2, 0},
+		{MovStatusIRQ, 1<<3 | 6, 1, 2<<5 | 1<<3 | 6, 1},
+	}
+	for _, tt := range tests {
+		// Start from the fields of another selector to check they are cleared.
+		cfg := StateMachineConfig{ExecCtrl: other}
+		cfg.SetMovStatus(MovStatusIRQ, 0x1f)
+		cfg = StateMachineConfig{ExecCtrl: cfg.ExecCtrl&statusBits | other}
+		cfg.SetMovStatus(tt.sel, tt.n)
+		execctrl := cfg.execCtrl(tt.version)
+		if got := execctrl & statusBits; got != tt.bits {
+			t.Errorf("selector %d on version %d: status bits %#x, want %#x", tt.sel, tt.version, got, tt.bits)
+		}
+		if got := execctrl &^ statusBits; got != other {
+			t.Errorf("selector %d on version %d: other bits %#x, want %#x", tt.sel, tt.version, got, other)
+		}
+		if cfg.minVersion != tt.minVersion {
+			t.Errorf("selector %d: min version %d, want %d", tt.sel, cfg.minVersion, tt.minVersion)
+		}
+	}
+}
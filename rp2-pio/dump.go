@@ -0,0 +1,163 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"device/rp"
+	"io"
+	"strconv"
+)
+
+// regFieldDesc names a field of a register for DumpState.
+type regFieldDesc struct {
+	name string
+	msk  uint32
+	pos  uint32
+}
+
+var (
+	clkdivFields = []regFieldDesc{
+		{"int", rp.PIO0_SM0_CLKDIV_INT_Msk, rp.PIO0_SM0_CLKDIV_INT_Pos},
+		{"frac", rp.PIO0_SM0_CLKDIV_FRAC_Msk, rp.PIO0_SM0_CLKDIV_FRAC_Pos},
+	}
+	execctrlFields = []regFieldDesc{
+		{"exec_stalled", rp.PIO0_SM0_EXECCTRL_EXEC_STALLED_Msk, rp.PIO0_SM0_EXECCTRL_EXEC_STALLED_Pos},
+		{"side_en", rp.PIO0_SM0_EXECCTRL_SIDE_EN_Msk, rp.PIO0_SM0_EXECCTRL_SIDE_EN_Pos},
+		{"side_pindir", rp.PIO0_SM0_EXECCTRL_SIDE_PINDIR_Msk, rp.PIO0_SM0_EXECCTRL_SIDE_PINDIR_Pos},
+		{"jmp_pin", rp.PIO0_SM0_EXECCTRL_JMP_PIN_Msk, rp.PIO0_SM0_EXECCTRL_JMP_PIN_Pos},
+		{"out_en_sel", rp.PIO0_SM0_EXECCTRL_OUT_EN_SEL_Msk, rp.PIO0_SM0_EXECCTRL_OUT_EN_SEL_Pos},
+		{"inline_out_en", rp.PIO0_SM0_EXECCTRL_INLINE_OUT_EN_Msk, rp.PIO0_SM0_EXECCTRL_INLINE_OUT_EN_Pos},
+		{"out_sticky", rp.PIO0_SM0_EXECCTRL_OUT_STICKY_Msk, rp.PIO0_SM0_EXECCTRL_OUT_STICKY_Pos},
+		{"wrap_top", rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Msk, rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Pos},
+		{"wrap_bottom", rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Msk, rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Pos},
+		{"status_sel", rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Msk, rp.PIO0_SM0_EXECCTRL_STATUS_SEL_Pos},
+		{"status_n", rp.PIO0_SM0_EXECCTRL_STATUS_N_Msk, rp.PIO0_SM0_EXECCTRL_STATUS_N_Pos},
+	}
+	shiftctrlFields = []regFieldDesc{
+		{"fjoin_rx", rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk, rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Pos},
+		{"fjoin_tx", rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk, rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Pos},
+		{"pull_thresh", rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Msk, rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Pos},
+		{"push_thresh", rp.PIO0_SM0_SHIFTCTRL_PUSH_THRESH_Msk, rp.PIO0_SM0_SHIFTCTRL_PUSH_THRESH_Pos},
+		{"out_shiftdir", rp.PIO0_SM0_SHIFTCTRL_OUT_SHIFTDIR_Msk, rp.PIO0_SM0_SHIFTCTRL_OUT_SHIFTDIR_Pos},
+		{"in_shiftdir", rp.PIO0_SM0_SHIFTCTRL_IN_SHIFTDIR_Msk, rp.PIO0_SM0_SHIFTCTRL_IN_SHIFTDIR_Pos},
+		{"autopull", rp.PIO0_SM0_SHIFTCTRL_AUTOPULL_Msk, rp.PIO0_SM0_SHIFTCTRL_AUTOPULL_Pos},
+		{"autopush", rp.PIO0_SM0_SHIFTCTRL_AUTOPUSH_Msk, rp.PIO0_SM0_SHIFTCTRL_AUTOPUSH_Pos},
+	}
+	pinctrlFields = []regFieldDesc{
+		{"sideset_count", rp.PIO0_SM0_PINCTRL_SIDESET_COUNT_Msk, rp.PIO0_SM0_PINCTRL_SIDESET_COUNT_Pos},
+		{"set_count", rp.PIO0_SM0_PINCTRL_SET_COUNT_Msk, rp.PIO0_SM0_PINCTRL_SET_COUNT_Pos},
+		{"out_count", rp.PIO0_SM0_PINCTRL_OUT_COUNT_Msk, rp.PIO0_SM0_PINCTRL_OUT_COUNT_Pos},
+		{"in_base", rp.PIO0_SM0_PINCTRL_IN_BASE_Msk, rp.PIO0_SM0_PINCTRL_IN_BASE_Pos},
+		{"sideset_base", rp.PIO0_SM0_PINCTRL_SIDESET_BASE_Msk, rp.PIO0_SM0_PINCTRL_SIDESET_BASE_Pos},
+		{"set_base", rp.PIO0_SM0_PINCTRL_SET_BASE_Msk, rp.PIO0_SM0_PINCTRL_SET_BASE_Pos},
+		{"out_base", rp.PIO0_SM0_PINCTRL_OUT_BASE_Msk, rp.PIO0_SM0_PINCTRL_OUT_BASE_Pos},
+	}
+)
+
+// DumpState writes a human readable report of the state of the PIO block to w,
+// i.e. a serial port, for debugging drivers on hardware. It shows the block
+// registers with a bit per state machine, then for each state machine its
+// program counter, FIFO levels and configuration registers decoded field by
+// field, and finally the instruction memory map: '#' for slots in use by loaded
+// programs, '.' for free slots, followed by the reservations.
+//
+// Registers are read while state machines run, so fields change between reads.
+// Reading the registers has no side effects, sticky FDEBUG flags are not cleared.
+func (pio *PIO) DumpState(w io.Writer) error {
+	hw := pio.HW()
+	b := append([]byte("PIO"), '0'+pio.BlockIndex())
+	b = append(b, " version "...)
+	b = strconv.AppendUint(b, uint64(pio.Version()), 10)
+	b = append(b, '\n')
+	ctrl := hw.CTRL.Get()
+	b = appendSMBits(b, "CTRL sm_enable=", ctrl>>rp.PIO0_CTRL_SM_ENABLE_Pos)
+	fstat := hw.FSTAT.Get()
+	b = appendSMBits(b, "FSTAT rxfull=", fstat>>rp.PIO0_FSTAT_RXFULL_Pos)
+	b = appendSMBits(b, " rxempty=", fstat>>rp.PIO0_FSTAT_RXEMPTY_Pos)
+	b = appendSMBits(b, " txfull=", fstat>>rp.PIO0_FSTAT_TXFULL_Pos)
+	b = appendSMBits(b, " txempty=", fstat>>rp.PIO0_FSTAT_TXEMPTY_Pos)
+	fdebug := hw.FDEBUG.Get()
+	b = appendSMBits(b, "\nFDEBUG rxstall=", fdebug>>rp.PIO0_FDEBUG_RXSTALL_Pos)
+	b = appendSMBits(b, " rxunder=", fdebug>>rp.PIO0_FDEBUG_RXUNDER_Pos)
+	b = appendSMBits(b, " txover=", fdebug>>rp.PIO0_FDEBUG_TXOVER_Pos)
+	b = appendSMBits(b, " txstall=", fdebug>>rp.PIO0_FDEBUG_TXSTALL_Pos)
+	b = append(b, "\nIRQ "...)
+	b = strconv.AppendUint(b, uint64(hw.IRQ.Get()), 2)
+	b = append(b, '\n')
+
+	flevel := hw.FLEVEL.Get()
+	for i := range hw.SM {
+		smHW := &hw.SM[i]
+		b = append(b, "SM"...)
+		b = append(b, '0'+byte(i))
+		if ctrl&(1<<(rp.PIO0_CTRL_SM_ENABLE_Pos+i)) != 0 {
+			b = append(b, " enabled"...)
+		} else {
+			b = append(b, " disabled"...)
+		}
+		if pio.claimedSMMask&(1<<i) != 0 {
+			b = append(b, " claimed"...)
+		}
+		b = append(b, " pc="...)
+		b = strconv.AppendUint(b, uint64(smHW.ADDR.Get()), 10)
+		b = append(b, " instr=0x"...)
+		b = strconv.AppendUint(b, uint64(smHW.INSTR.Get()), 16)
+		level := flevel >> (8 * i)
+		b = append(b, " txlevel="...)
+		b = strconv.AppendUint(b, uint64(level&0xf), 10)
+		b = append(b, " rxlevel="...)
+		b = strconv.AppendUint(b, uint64(level>>4&0xf), 10)
+		b = appendRegFields(b, "\n  CLKDIV", smHW.CLKDIV.Get(), clkdivFields)
+		b = appendRegFields(b, "\n  EXECCTRL", smHW.EXECCTRL.Get(), execctrlFields)
+		b = appendRegFields(b, "\n  SHIFTCTRL", smHW.SHIFTCTRL.Get(), shiftctrlFields)
+		b = appendRegFields(b, "\n  PINCTRL", smHW.PINCTRL.Get(), pinctrlFields)
+		b = append(b, '\n')
+	}
+
+	b = append(b, "INSTR_MEM 0 "...)
+	size := int(pio.InstructionMemSize())
+	for i := 0; i < size; i++ {
+		if pio.usedSpaceMask&(1<<i) != 0 {
+			b = append(b, '#')
+		} else {
+			b = append(b, '.')
+		}
+	}
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(size-1), 10)
+	b = append(b, '\n')
+	for _, r := range pio.reservations {
+		b = append(b, "reserved by "...)
+		b = append(b, r.Owner...)
+		b = appendSMBits(b, ": sm=", uint32(r.SMMask))
+		b = append(b, " instr="...)
+		b = strconv.AppendUint(b, uint64(r.InstrBudget), 10)
+		b = append(b, '\n')
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// appendSMBits appends label and the low 4 bits of v, one per state machine
+// from SM0 to SM3.
+func appendSMBits(b []byte, label string, v uint32) []byte {
+	b = append(b, label...)
+	for i := 0; i < 4; i++ {
+		b = append(b, '0'+byte(v>>i&1))
+	}
+	return b
+}
+
+// appendRegFields appends name, the raw register value in hex and its fields.
+func appendRegFields(b []byte, name string, reg uint32, fields []regFieldDesc) []byte {
+	b = append(b, name...)
+	b = append(b, " 0x"...)
+	b = strconv.AppendUint(b, uint64(reg), 16)
+	for _, f := range fields {
+		b = append(b, ' ')
+		b = append(b, f.name...)
+		b = append(b, '=')
+		b = strconv.AppendUint(b, uint64(regField(reg, f.msk, f.pos)), 10)
+	}
+	return b
+}
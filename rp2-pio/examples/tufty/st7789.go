@@ -48,6 +48,12 @@ func (st *ST7789) SetBacklight(on bool) {
 	println("no backlight pin defined")
 }
 
+// EnableVSync synchronizes framebuffer writes with the display's tearing effect
+// output on tePin so that animations do not tear. Use machine.NoPin to disable.
+func (st *ST7789) EnableVSync(tePin machine.Pin) {
+	st.pl.EnableVSync(tePin)
+}
+
 func (st *ST7789) CommonInit() {
 	st.dc.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	st.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
@@ -159,6 +165,16 @@ func (st *ST7789) command(command byte, data []byte) {
 	st.cs.High()
 }
 
+// writeRAM writes pixel data to the display RAM, synchronized to the TE signal if enabled.
+func (st *ST7789) writeRAM(data []byte) {
+	st.dc.Low()
+	st.cs.Low()
+	st.pl.Write([]byte{RAMWR})
+	st.dc.High()
+	st.pl.WriteSync(data)
+	st.cs.High()
+}
+
 func RGBATo565(c color.RGBA) uint16 {
 	r, g, b, _ := c.RGBA()
 	return uint16((r & 0xF800) +
@@ -196,6 +212,6 @@ func (st *ST7789) FillRectangle(x, y, width, height int16, c color.RGBA) error {
 		fb[i*2] = c1
 		fb[i*2+1] = c2
 	}
-	st.command(RAMWR, fb)
+	st.writeRAM(fb)
 	return nil
 }
@@ -93,7 +93,7 @@ func encodeInstrAndSrcDest(instr uint16, dest SrcDest, value uint8) uint16 {
 }
 
 func EncodeDelay(cycles uint8) uint16 {
-	return 0b11111 & (uint16(cycles) << 8)
+	return uint16(cycles&0b11111) << 8
 }
 
 func EncodeSideSet(bitCount, value uint8) uint16 {
@@ -109,7 +109,7 @@ func EncodeJmp(addr uint8, condition JmpCond) uint16 {
 }
 
 func encodeIRQ(relative bool, irq uint8) uint8 {
-	return boolAsU8(relative) << 4
+	return boolAsU8(relative)<<4 | irq&7
 }
 
 func EncodeWaitGPIO(polarity bool, pin uint8) uint16 {
@@ -167,6 +167,45 @@ func EncodeIRQClear(relative bool, irq uint8) uint16 {
 	return encodeInstrAndArgs(_INSTR_BITS_IRQ, 2, encodeIRQ(relative, irq))
 }
 
+// IRQIndexMode selects the PIO block and state machine offset applied to the flag
+// index of IRQ and WAIT IRQ instructions.
+type IRQIndexMode uint8
+
+const (
+	// IRQDirect addresses flag irq of the block running the instruction.
+	IRQDirect IRQIndexMode = iota
+	// IRQPrev addresses flag irq of the previous PIO block, i.e. PIO0 from PIO1.
+	// It requires PIO version 1 (RP2350).
+	IRQPrev
+	// IRQRel adds the state machine index to the 2 low bits of irq, modulo 4.
+	IRQRel
+	// IRQNext addresses flag irq of the next PIO block, i.e. PIO1 from PIO0.
+	// It requires PIO version 1 (RP2350).
+	IRQNext
+)
+
+// EncodeIRQSetIndexed encodes an IRQ instruction setting flag irq with the index mode,
+// such as IRQNext to signal a state machine of the next block.
+func EncodeIRQSetIndexed(mode IRQIndexMode, irq uint8) uint16 {
+	return encodeInstrAndArgs(_INSTR_BITS_IRQ, 0, encodeIRQIndex(mode, irq))
+}
+
+// EncodeIRQClearIndexed encodes an IRQ instruction clearing flag irq with the index mode.
+func EncodeIRQClearIndexed(mode IRQIndexMode, irq uint8) uint16 {
+	return encodeInstrAndArgs(_INSTR_BITS_IRQ, 2, encodeIRQIndex(mode, irq))
+}
+
+// EncodeWaitIRQIndexed encodes a WAIT IRQ instruction on flag irq with the index mode.
+// Waiting for a flag to be set clears it, also in the block it belongs to.
+func EncodeWaitIRQIndexed(polarity bool, mode IRQIndexMode, irq uint8) uint16 {
+	flag := boolAsU8(polarity) << 2
+	return encodeInstrAndArgs(_INSTR_BITS_WAIT, 2|flag, encodeIRQIndex(mode, irq))
+}
+
+func encodeIRQIndex(mode IRQIndexMode, irq uint8) uint8 {
+	return uint8(mode&3)<<3 | irq&7
+}
+
 func EncodeSet(dest SrcDest, value uint8) uint16 {
 	return encodeInstrAndSrcDest(_INSTR_BITS_SET, dest, value)
 }
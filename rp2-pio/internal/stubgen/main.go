@@ -0,0 +1,617 @@
+// Command stubgen generates the stub implementations that let the pio and piolib
+// packages compile on targets without PIO hardware, so application code using
+// them can be type checked and built off-device. The stubs simulate nothing: unit
+// tests of application logic must fake the drivers behind interfaces of their own.
+//
+// It reads the files of the package in the current directory that only build on
+// the rp2040 and emits a file declaring the same exported API: exported types
+// keep their exported fields, constants and error variables are copied and every
+// exported function and method returns zero values. Constructors return a zero
+// value of their type and other functions returning an error return errStub.
+// Doc comments are dropped except those of deprecated API.
+//
+//	stubgen -o stub.go -err "piolib:PIO not available on this target"
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hardwareTags are the build tags of the targets with PIO hardware.
+var hardwareTags = map[string]bool{"rp2040": true, "rp2350": true}
+
+// zeroSelectors holds the zero values of the types of other packages that are not
+// structs, which are written as T{}.
+var zeroSelectors = map[string]string{
+	"time.Duration":   "0",
+	"machine.Pin":     "0",
+	"machine.PinMode": "0",
+	"io.Reader":       "nil",
+	"io.Writer":       "nil",
+}
+
+func main() {
+	out := flag.String("o", "stub.go", "output file")
+	errMsg := flag.String("err", "", "message of the error returned by stubs")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("stubgen: ")
+
+	g := &generator{
+		fset:     token.NewFileSet(),
+		imports:  make(map[string]string),
+		decls:    make(map[string]ast.Spec),
+		consts:   make(map[string]bool),
+		portable: make(map[string]bool),
+		used:     make(map[string]bool),
+		emitted:  make(map[string]bool),
+		body:     new(bytes.Buffer),
+	}
+	if err := g.parse(filepath.Base(*out)); err != nil {
+		log.Fatal(err)
+	}
+	src, err := g.generate(*errMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type generator struct {
+	fset    *token.FileSet
+	pkgName string
+	// hardware holds the files only built on PIO targets, in name order.
+	hardware []*ast.File
+	// imports maps package names to import paths.
+	imports map[string]string
+	// decls holds the type, const and var specs of the hardware files by name.
+	decls map[string]ast.Spec
+	// consts holds the names of the constants of the hardware files.
+	consts map[string]bool
+	// portable holds the names declared by files built on all targets, and
+	// portableFiles the files.
+	portable      map[string]bool
+	portableFiles []*ast.File
+	// used holds the package names referenced by the output.
+	used    map[string]bool
+	emitted map[string]bool
+	// needErr is set when a stub returns errStub.
+	needErr bool
+	body    *bytes.Buffer
+	// pending holds unexported names referenced by the output, to be emitted.
+	pending []string
+}
+
+func (g *generator) parse(out string) error {
+	names, err := filepath.Glob("*.go")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == out || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		g.pkgName = f.Name.Name
+		switch buildsOn(f) {
+		case buildsAlways:
+			g.portableFiles = append(g.portableFiles, f)
+			for _, d := range f.Decls {
+				for _, name := range declNames(d) {
+					g.portable[name] = true
+				}
+			}
+		case buildsOnHardware:
+			g.hardware = append(g.hardware, f)
+			for _, imp := range f.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				name := path[strings.LastIndex(path, "/")+1:]
+				if imp.Name != nil {
+					name = imp.Name.Name
+				}
+				g.imports[name] = path
+			}
+			for _, d := range f.Decls {
+				if gd, ok := d.(*ast.GenDecl); ok {
+					for _, spec := range gd.Specs {
+						for _, name := range specNames(spec) {
+							g.decls[name] = spec
+							g.consts[name] = gd.Tok == token.CONST
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	buildsAlways = iota
+	buildsOnHardware
+	buildsOther
+)
+
+// buildsOn classifies a file by its build constraint. Files with variants selected
+// by other tags, such as debug builds, are left out.
+func buildsOn(f *ast.File) int {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				return buildsOther
+			}
+			onHost := expr.Eval(func(string) bool { return false })
+			onHardware := expr.Eval(func(tag string) bool { return hardwareTags[tag] })
+			switch {
+			case onHost:
+				return buildsAlways
+			case onHardware:
+				return buildsOnHardware
+			default:
+				return buildsOther
+			}
+		}
+	}
+	return buildsAlways
+}
+
+func (g *generator) generate(errMsg string) ([]byte, error) {
+	// Exported declarations in source order, then the unexported ones they need.
+	for _, f := range g.hardware {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				g.genDecl(d)
+			case *ast.FuncDecl:
+				g.funcDecl(d)
+			}
+		}
+	}
+	for len(g.pending) > 0 {
+		name := g.pending[0]
+		g.pending = g.pending[1:]
+		if g.emitted[name] {
+			continue
+		}
+		switch spec := g.decls[name].(type) {
+		case *ast.TypeSpec:
+			g.typeSpec(spec)
+		case *ast.ValueSpec:
+			if !g.consts[name] || spec.Values == nil {
+				return nil, fmt.Errorf("%s referenced by the API can not be declared by stubs", name)
+			}
+			for _, n := range spec.Names {
+				g.emitted[n.Name] = true
+			}
+			g.print(&ast.GenDecl{Tok: token.CONST, Specs: []ast.Spec{spec}})
+			g.body.WriteString("\n\n")
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by stubgen; DO NOT EDIT.\n\n//go:build !rp2040 && !rp2350\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkgName)
+	var paths []string
+	if g.needErr {
+		if errMsg == "" {
+			return nil, fmt.Errorf("functions return errors, set -err")
+		}
+		g.used["errors"] = true
+		g.imports["errors"] = "errors"
+	}
+	for name := range g.used {
+		path := g.imports[name]
+		if strings.HasPrefix(path, "device/") {
+			return nil, fmt.Errorf("stubs can not depend on %s", path)
+		}
+		imp := strconv.Quote(path)
+		if path[strings.LastIndex(path, "/")+1:] != name {
+			imp = name + " " + imp
+		}
+		paths = append(paths, imp)
+	}
+	// Standard library first, as goimports groups them.
+	sort.Slice(paths, func(i, j int) bool {
+		si, sj := strings.Contains(paths[i], "."), strings.Contains(paths[j], ".")
+		if si != sj {
+			return sj
+		}
+		return paths[i] < paths[j]
+	})
+	buf.WriteString("import (\n")
+	for i, p := range paths {
+		if i > 0 && strings.Contains(p, ".") && !strings.Contains(paths[i-1], ".") {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\t" + p + "\n")
+	}
+	buf.WriteString(")\n\n")
+	if g.needErr {
+		fmt.Fprintf(&buf, "var errStub = errors.New(%q)\n\n", errMsg)
+	}
+	buf.Write(g.body.Bytes())
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.Bytes(), err
+	}
+	return src, nil
+}
+
+func (g *generator) genDecl(d *ast.GenDecl) {
+	switch d.Tok {
+	case token.TYPE:
+		for _, spec := range d.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.IsExported() {
+				doc := ts.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				g.deprecated(doc)
+				g.typeSpec(ts)
+			}
+		}
+	case token.CONST:
+		// Keep iota groups whole.
+		exported := false
+		for _, spec := range d.Specs {
+			for _, name := range specNames(spec) {
+				exported = exported || ast.IsExported(name)
+			}
+		}
+		if !exported {
+			return
+		}
+		for _, spec := range d.Specs {
+			for _, name := range specNames(spec) {
+				g.emitted[name] = true
+			}
+		}
+		g.print(&ast.GenDecl{Tok: token.CONST, Lparen: 1, Specs: d.Specs})
+		g.body.WriteString("\n\n")
+	case token.VAR:
+		for _, spec := range d.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !name.IsExported() || name.Name == "_" {
+					continue
+				}
+				g.emitted[name.Name] = true
+				g.body.WriteString("var " + name.Name)
+				if vs.Type != nil {
+					g.body.WriteString(" ")
+					g.print(vs.Type)
+				}
+				if i < len(vs.Values) {
+					g.body.WriteString(" = ")
+					g.print(g.stripLiteral(vs.Values[i]))
+				}
+				g.body.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+// deprecated writes doc if it marks the declaration as deprecated, so tools report
+// it when building with stubs.
+func (g *generator) deprecated(doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	if text := doc.Text(); !strings.HasPrefix(text, "Deprecated:") && !strings.Contains(text, "\nDeprecated:") {
+		return
+	}
+	for _, c := range doc.List {
+		g.body.WriteString(c.Text + "\n")
+	}
+}
+
+// stripLiteral drops the unexported fields of composite literals.
+func (g *generator) stripLiteral(x ast.Expr) ast.Expr {
+	switch x := x.(type) {
+	case *ast.UnaryExpr:
+		return &ast.UnaryExpr{Op: x.Op, X: g.stripLiteral(x.X)}
+	case *ast.CompositeLit:
+		lit := &ast.CompositeLit{Type: x.Type, Lbrace: x.Lbrace, Rbrace: x.Rbrace}
+		for _, elt := range x.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok && !key.IsExported() {
+					continue
+				}
+			}
+			lit.Elts = append(lit.Elts, elt)
+		}
+		return lit
+	}
+	return x
+}
+
+func (g *generator) typeSpec(ts *ast.TypeSpec) {
+	if g.emitted[ts.Name.Name] {
+		return
+	}
+	g.emitted[ts.Name.Name] = true
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		g.print(&ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{ts}})
+		g.body.WriteString("\n\n")
+		return
+	}
+	// Structs are written field by field, as the positions of the dropped fields
+	// and comments would leave blank lines.
+	g.body.WriteString("type " + ts.Name.Name)
+	if ts.TypeParams != nil {
+		g.body.WriteString("[")
+		for i, f := range ts.TypeParams.List {
+			if i > 0 {
+				g.body.WriteString(", ")
+			}
+			for j, n := range f.Names {
+				if j > 0 {
+					g.body.WriteString(", ")
+				}
+				g.body.WriteString(n.Name)
+			}
+			g.body.WriteString(" ")
+			g.print(f.Type)
+		}
+		g.body.WriteString("]")
+	}
+	body := g.body
+	g.body = new(bytes.Buffer)
+	for _, f := range st.Fields.List {
+		var names []string
+		for _, n := range f.Names {
+			if n.IsExported() {
+				names = append(names, n.Name)
+			}
+		}
+		switch {
+		case len(names) > 0:
+			g.body.WriteString("\t" + strings.Join(names, ", ") + " ")
+		case len(f.Names) > 0 || !ast.IsExported(baseTypeName(f.Type)):
+			continue
+		default:
+			g.body.WriteString("\t") // Embedded type.
+		}
+		g.print(f.Type)
+		g.body.WriteString("\n")
+	}
+	fields := g.body
+	g.body = body
+	if fields.Len() == 0 {
+		g.body.WriteString(" struct{}\n\n")
+		return
+	}
+	g.body.WriteString(" struct {\n")
+	g.body.Write(fields.Bytes())
+	g.body.WriteString("}\n\n")
+}
+
+func (g *generator) funcDecl(fd *ast.FuncDecl) {
+	if !fd.Name.IsExported() {
+		return
+	}
+	if fd.Recv != nil && !ast.IsExported(baseTypeName(fd.Recv.List[0].Type)) {
+		return
+	}
+	g.deprecated(fd.Doc)
+	g.print(&ast.FuncDecl{Recv: fd.Recv, Name: fd.Name, Type: fd.Type})
+	if fd.Type.Results == nil {
+		g.body.WriteString(" {}\n\n")
+		return
+	}
+	var zeros []string
+	for _, f := range fd.Type.Results.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			zeros = append(zeros, g.zero(f.Type, fd.Recv == nil && strings.HasPrefix(fd.Name.Name, "New")))
+		}
+	}
+	g.body.WriteString(" {\n\treturn " + strings.Join(zeros, ", ") + "\n}\n\n")
+}
+
+// zero returns the zero value of a type, or errStub for errors. Constructors return
+// pointers to zero values of package types and no error.
+func (g *generator) zero(typ ast.Expr, constructor bool) string {
+	switch t := typ.(type) {
+	case *ast.StarExpr:
+		if name := baseTypeName(t.X); constructor && name != "" && g.isStruct(name) {
+			return "&" + g.exprString(t.X) + "{}"
+		}
+		return "nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "nil"
+		}
+		return g.exprString(t) + "{}"
+	case *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.SelectorExpr:
+		s := g.exprString(t)
+		if z, ok := zeroSelectors[s]; ok {
+			return z
+		}
+		return s + "{}"
+	case *ast.IndexExpr:
+		if spec, ok := g.declSpec(baseTypeName(t)).(*ast.TypeSpec); ok {
+			if _, ok := spec.Type.(*ast.InterfaceType); ok {
+				return "nil"
+			}
+		}
+	case *ast.Ident:
+		switch t.Name {
+		case "error":
+			if constructor {
+				return "nil"
+			}
+			g.needErr = true
+			return "errStub"
+		case "bool":
+			return "false"
+		case "string":
+			return `""`
+		case "any":
+			return "nil"
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32",
+			"uint64", "uintptr", "byte", "rune", "float32", "float64":
+			return "0"
+		}
+		if spec, ok := g.declSpec(t.Name).(*ast.TypeSpec); ok {
+			switch u := spec.Type.(type) {
+			case *ast.StructType:
+				return t.Name + "{}"
+			case *ast.InterfaceType:
+				return "nil"
+			case *ast.ArrayType:
+				if u.Len != nil {
+					return t.Name + "{}"
+				}
+			case *ast.Ident:
+				if z := g.zero(u, false); z != "nil" && z != "errStub" {
+					return z
+				}
+			}
+		}
+	}
+	return "*new(" + g.exprString(typ) + ")"
+}
+
+// declSpec returns the spec declaring name in any file of the package.
+func (g *generator) declSpec(name string) ast.Spec {
+	if spec, ok := g.decls[name]; ok {
+		return spec
+	}
+	for _, f := range g.portableFiles {
+		for _, d := range f.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok {
+				for _, spec := range gd.Specs {
+					for _, n := range specNames(spec) {
+						if n == name {
+							return spec
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) isStruct(name string) bool {
+	spec, ok := g.declSpec(name).(*ast.TypeSpec)
+	if !ok {
+		return false
+	}
+	_, ok = spec.Type.(*ast.StructType)
+	return ok
+}
+
+// print writes a node to the output and records the names it references.
+func (g *generator) print(node ast.Node) {
+	var inspect func(n ast.Node) bool
+	inspect = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			// Skip the names of parameters and fields.
+			ast.Inspect(n.Type, inspect)
+			return false
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && id.Obj == nil {
+				if _, ok := g.imports[id.Name]; ok {
+					g.used[id.Name] = true
+					return false
+				}
+			}
+		case *ast.Ident:
+			if !n.IsExported() && !g.emitted[n.Name] && !g.portable[n.Name] {
+				if _, ok := g.decls[n.Name]; ok {
+					g.pending = append(g.pending, n.Name)
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(node, inspect)
+	printer.Fprint(g.body, g.fset, node)
+}
+
+func (g *generator) exprString(x ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, x)
+	return buf.String()
+}
+
+func baseTypeName(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.StarExpr:
+		return baseTypeName(t.X)
+	case *ast.IndexExpr:
+		return baseTypeName(t.X)
+	case *ast.IndexListExpr:
+		return baseTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func declNames(d ast.Decl) []string {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			return []string{d.Name.Name}
+		}
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			names = append(names, specNames(spec)...)
+		}
+		return names
+	}
+	return nil
+}
+
+func specNames(spec ast.Spec) []string {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return []string{s.Name.Name}
+	case *ast.ValueSpec:
+		var names []string
+		for _, n := range s.Names {
+			names = append(names, n.Name)
+		}
+		return names
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build rp2040
+
+package pio
+
+import "errors"
+
+var errNoLabel = errors.New("pio: program has no such public label")
+
+// entryPointLabel is the public label marking where a program starts if it
+// doesn't start at its first instruction, as named in the pico-examples programs.
+const entryPointLabel = "entry_point"
+
+// LoadedProgram is a Program loaded in the instruction memory of a PIO block. Its
+// methods return instruction memory addresses with the load offset applied, so
+// drivers don't add the offset to wrap targets, entry points and labels by hand.
+type LoadedProgram struct {
+	Program
+	pio    *PIO
+	offset uint8
+}
+
+// LoadProgram loads prog in instruction memory, at its origin if it has one.
+func (pio *PIO) LoadProgram(prog Program) (LoadedProgram, error) {
+	offset, err := pio.AddProgram(prog.Instructions, prog.Origin)
+	if err != nil {
+		return LoadedProgram{}, err
+	}
+	return LoadedProgram{Program: prog, pio: pio, offset: offset}, nil
+}
+
+// PIO returns the PIO block the program is loaded in.
+func (lp LoadedProgram) PIO() *PIO { return lp.pio }
+
+// Offset returns the instruction memory address of the first instruction of the program.
+func (lp LoadedProgram) Offset() uint8 { return lp.offset }
+
+// DefaultConfig returns the default state machine configuration for the program
+// with its wrap and side-set settings, as the ProgramDefaultConfig functions
+// generated by pioasm do for their offset argument.
+func (lp LoadedProgram) DefaultConfig() StateMachineConfig {
+	cfg := DefaultStateMachineConfig()
+	cfg.SetWrap(lp.offset+lp.WrapTarget, lp.offset+lp.Wrap)
+	if lp.SidesetBits > 0 {
+		cfg.SetSidesetParams(lp.SidesetBits, lp.SidesetOptional, lp.SidesetPindirs)
+	}
+	return cfg
+}
+
+// Label returns the instruction memory address of a public label of the program.
+func (lp LoadedProgram) Label(name string) (addr uint8, err error) {
+	rel, ok := lp.PublicLabels[name]
+	if !ok {
+		return 0, errNoLabel
+	}
+	return lp.offset + rel, nil
+}
+
+// EntryPoint returns the instruction memory address the program starts at: its
+// entry_point public label if it has one, its first instruction otherwise.
+func (lp LoadedProgram) EntryPoint() uint8 {
+	if rel, ok := lp.PublicLabels[entryPointLabel]; ok {
+		return lp.offset + rel
+	}
+	return lp.offset
+}
+
+// Init initializes sm with cfg to start at the entry point of the program.
+// The zero value of cfg selects DefaultConfig.
+func (lp LoadedProgram) Init(sm StateMachine, cfg StateMachineConfig) {
+	if sm.pio != lp.pio {
+		panic(badPIO)
+	}
+	if cfg == (StateMachineConfig{}) {
+		cfg = lp.DefaultConfig()
+	}
+	sm.Init(lp.EntryPoint(), cfg)
+}
+
+// JmpLabel makes sm jump to a public label of the program, i.e. to reset a driver
+// after a timeout.
+func (lp LoadedProgram) JmpLabel(sm StateMachine, name string) error {
+	addr, err := lp.Label(name)
+	if err != nil {
+		return err
+	}
+	sm.Jmp(addr, JmpAlways)
+	return nil
+}
+
+// Unload frees the instruction memory of the program. It returns ErrProgramInUse
+// if an enabled state machine still runs it, see PIO.ClearProgramSection.
+func (lp LoadedProgram) Unload() error {
+	return lp.pio.ClearProgramSection(lp.offset, uint8(len(lp.Instructions)))
+}
@@ -0,0 +1,61 @@
+package pio
+
+// This file contains ProgramBuilder macros which expand to canonical instruction
+// sequences for common PIO idioms.
+
+// ClockedOut adds a loop shifting bits bits out of the OSR to the OUT pins, pins
+// bits per clock, with a clock on side-set. Data is output with side-set 0 and
+// the clock is asserted with side-set value clkSide, so a receiver samples data
+// on the clock edge given by clkSide. Each clock takes 2 cycles. The X register
+// is used as loop counter. bits must be a multiple of pins and at most 32, and the
+// OSR must hold the data beforehand, i.e. after a pull or with autopull enabled:
+//
+//	    set x, bits/pins-1  side 0
+//	loop:
+//	    out pins, pins      side 0
+//	    jmp x-- loop        side clkSide
+func (b *ProgramBuilder) ClockedOut(pins, bits, clkSide uint8) *ProgramBuilder {
+	if pins == 0 || bits == 0 || bits > 32 || bits%pins != 0 {
+		b.setErr(errMacroBadBitSize)
+		return b
+	}
+	b.Add(EncodeSet(SrcDestX, bits/pins-1)).Side(0)
+	loop := b.Addr()
+	b.Add(EncodeOut(SrcDestPins, pins&0x1f)).Side(0)
+	return b.Add(EncodeJmp(loop, JmpXNZeroDec)).Side(clkSide)
+}
+
+// WaitEdge adds instructions stalling until a rising or falling edge on pin, which is
+// relative to the IN pin base. The pin is first waited on to reach the level before
+// the edge so a pin already at the final level does not satisfy the wait:
+//
+//	wait !rising pin, pin
+//	wait rising pin, pin
+func (b *ProgramBuilder) WaitEdge(pin uint8, rising bool) *ProgramBuilder {
+	return b.Add(EncodeWaitPin(!rising, pin), EncodeWaitPin(rising, pin))
+}
+
+// CountedLoop adds the instructions added by body followed by a jump back to them
+// while reg, which must be X or Y, is not zero, decrementing it. The body runs
+// reg+1 times, with the register loaded beforehand by the program:
+//
+//	loop:
+//	    body
+//	    jmp reg-- loop
+//
+// body must not modify reg.
+func (b *ProgramBuilder) CountedLoop(reg SrcDest, body func(b *ProgramBuilder)) *ProgramBuilder {
+	var cond JmpCond
+	switch reg {
+	case SrcDestX:
+		cond = JmpXNZeroDec
+	case SrcDestY:
+		cond = JmpYNZeroDec
+	default:
+		b.setErr(errMacroRegister)
+		return b
+	}
+	loop := b.Addr()
+	body(b)
+	return b.Add(EncodeJmp(loop, cond))
+}
@@ -0,0 +1,9 @@
+//go:build rp2040 && !piodebug
+
+package pio
+
+// Ownership tracking is only done in builds with the piodebug tag, see owner_debug.go.
+
+func (sm StateMachine) setOwner()   {}
+func (sm StateMachine) clearOwner() {}
+func (sm StateMachine) checkOwner() {}
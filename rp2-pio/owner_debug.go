@@ -0,0 +1,28 @@
+//go:build rp2040 && piodebug
+
+package pio
+
+// Building with the piodebug tag records the core which claimed each state machine
+// and panics when a claimed state machine is reconfigured from the other core.
+// The checks are compiled out otherwise, see owner.go.
+
+// smOwner holds the claiming core plus one for each state machine, or 0 if unowned.
+var smOwner [2][4]uint8
+
+// setOwner records the current core as owner of sm. Must be called with the claim lock held.
+func (sm StateMachine) setOwner() {
+	smOwner[sm.pio.BlockIndex()][sm.index] = CurrentCore() + 1
+}
+
+// clearOwner forgets the owner of sm. Must be called with the claim lock held.
+func (sm StateMachine) clearOwner() {
+	smOwner[sm.pio.BlockIndex()][sm.index] = 0
+}
+
+// checkOwner panics if sm was claimed by a core other than the current one.
+func (sm StateMachine) checkOwner() {
+	owner := smOwner[sm.pio.BlockIndex()][sm.index]
+	if owner != 0 && owner-1 != CurrentCore() {
+		panic("pio: state machine claimed on core " + string('0'+rune(owner-1)) + " mutated from core " + string('0'+rune(CurrentCore())))
+	}
+}
@@ -0,0 +1,52 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"device/rp"
+	"machine"
+)
+
+// armedEdges holds the edges each pin was armed on by ArmOnPinEdge, needed to
+// disable its interrupt, indexed by GPIO number.
+var armedEdges [48]machine.PinChange
+
+// ArmOnPinEdge disables the state machine and enables it from the interrupt handler
+// of pin on the next edge, the alternative to a WAIT GPIO at the start of a program
+// when the program has no instruction to spare or must not run until the edge.
+// The state machine starts within a few microseconds of the edge, set by interrupt
+// latency, so capture drivers get a consistent start without polling the pin.
+//
+// The pin interrupt is disabled once fired. It replaces any interrupt callback of
+// pin, as machine.Pin.SetInterrupt supports only one per pin.
+func (sm StateMachine) ArmOnPinEdge(pin machine.Pin, edge machine.PinChange) error {
+	return sm.pio.ArmOnPinEdge(1<<sm.index, pin, edge)
+}
+
+// ArmOnPinEdge disables the state machines in smMask and enables them together
+// from the interrupt handler of pin on the next edge, with their clock dividers
+// restarted so they run in lockstep. See StateMachine.ArmOnPinEdge.
+func (pio *PIO) ArmOnPinEdge(smMask uint8, pin machine.Pin, edge machine.PinChange) error {
+	if smMask > 0xf {
+		panic(badStateMachineIndex)
+	}
+	mask := uint32(smMask) << rp.PIO0_CTRL_SM_ENABLE_Pos
+	clearBits(&pio.hw.CTRL, mask)
+	armedEdges[pin] = edge
+	return pin.SetInterrupt(edge, func(machine.Pin) {
+		setBits(&pio.hw.CTRL, mask|uint32(smMask)<<rp.PIO0_CTRL_CLKDIV_RESTART_Pos)
+		pin.SetInterrupt(edge, nil)
+		armedEdges[pin] = 0
+	})
+}
+
+// DisarmPinEdge cancels ArmOnPinEdge if the edge has not fired yet. The state
+// machines are left disabled.
+func DisarmPinEdge(pin machine.Pin) error {
+	edge := armedEdges[pin]
+	if edge == 0 {
+		return nil
+	}
+	armedEdges[pin] = 0
+	return pin.SetInterrupt(edge, nil)
+}
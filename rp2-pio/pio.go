@@ -6,7 +6,9 @@ import (
 	"device/rp"
 	"errors"
 	"machine"
+	"runtime/interrupt"
 	"runtime/volatile"
+	"strconv"
 	"unsafe"
 )
 
@@ -24,9 +26,35 @@ var (
 var (
 	ErrOutOfProgramSpace   = errors.New("pio: out of program space")
 	ErrNoSpaceAtOffset     = errors.New("pio: program space unavailable at offset")
+	ErrTxOverflow          = errors.New("pio: TX FIFO overflow")
+	ErrRxUnderflow         = errors.New("pio: RX FIFO underflow")
+	ErrProgramInUse        = errors.New("pio: program memory in use by an enabled state machine")
 	errStateMachineClaimed = errors.New("pio: state machine already claimed")
 )
 
+// FIFOError reports words lost by a state machine FIFO, as found by the FIFO guard
+// of drivers. errors.Is matches it with ErrTxOverflow or ErrRxUnderflow.
+type FIFOError struct {
+	// Err is ErrTxOverflow or ErrRxUnderflow.
+	Err error
+	// Words is the number of words the driver transferred in the batch after
+	// which the loss was found. The hardware flags that words were lost, not how
+	// many.
+	Words int
+	// Batches is the number of batches of the driver found to lose words since its
+	// guard was enabled, this one included.
+	Batches uint32
+}
+
+func (e *FIFOError) Error() string {
+	return e.Err.Error() + " in a batch of " + strconv.Itoa(e.Words) + " words"
+}
+
+// Unwrap returns Err.
+func (e *FIFOError) Unwrap() error {
+	return e.Err
+}
+
 const (
 	badStateMachineIndex = "invalid state machine index"
 	badPIO               = "invalid PIO"
@@ -37,11 +65,19 @@ const (
 type PIO struct {
 	// hw points to the PIO hardware registers.
 	hw *rp.PIO0_Type
-	// Bitmask of used instruction space. Each PIO has 32 slots for instructions.
-	usedSpaceMask uint32
+	// Bitmask of used instruction space, see InstructionMemSize.
+	usedSpaceMask instrMask
 	// Bitmask of used state machines. Each PIO has 4 state machines.
 	claimedSMMask uint8
-	nc            noCopy
+	// Bitmask of state machines and number of instructions reserved with Reserve.
+	reservedSMMask uint8
+	reservedInstr  uint8
+	reservations   []Reservation
+	// claimReserved lets ClaimStateMachine return reserved state machines, see SetClaimReserved.
+	claimReserved bool
+	// instrMem mirrors the instruction memory, which is write-only.
+	instrMem [instrMemCapacity]uint16
+	nc       noCopy
 }
 
 // BlockIndex returns 0 or 1 depending on whether the underlying device is PIO0 or PIO1.
@@ -55,6 +91,31 @@ func (pio *PIO) BlockIndex() uint8 {
 	panic(badPIO)
 }
 
+// Version returns the PIO hardware version: 0 on the RP2040 and 1 on the RP2350,
+// which adds features such as IRQ based mov status. It is read from DBG_CFGINFO,
+// whose version field reads as zero on the RP2040.
+func (pio *PIO) Version() uint8 {
+	return uint8(pio.hw.DBG_CFGINFO.Get() >> 28)
+}
+
+// InstructionMemSize returns the number of instruction slots of the block, 32 on
+// the RP2040 and RP2350. It is read from DBG_CFGINFO, so that parts or emulations
+// with a different size need no change of the program allocator and bounds
+// checks, which all rely on it. A size beyond what the allocator can track is
+// limited to it.
+func (pio *PIO) InstructionMemSize() uint8 {
+	size := (pio.hw.DBG_CFGINFO.Get() & rp.PIO0_DBG_CFGINFO_IMEM_SIZE_Msk) >> rp.PIO0_DBG_CFGINFO_IMEM_SIZE_Pos
+	if size == 0 || size > instrMemCapacity {
+		return instrMemCapacity
+	}
+	return uint8(size)
+}
+
+// inBounds returns true if a section of length slots at offset fits in instruction memory.
+func (pio *PIO) inBounds(offset uint8, length int) bool {
+	return int(offset)+length <= int(pio.InstructionMemSize())
+}
+
 // StateMachine returns a state machine by index.
 func (pio *PIO) StateMachine(index uint8) StateMachine {
 	if index > 3 {
@@ -68,13 +129,19 @@ func (pio *PIO) StateMachine(index uint8) StateMachine {
 
 // ClaimtateMachine returns an unused state machine
 // or an error if all state machines on this PIO are claimed.
+// State machines reserved with Reserve are never returned, unless allowed with
+// SetClaimReserved once all others are claimed.
+// It is safe to call from interrupt handlers and from either core.
 func (pio *PIO) ClaimStateMachine() (sm StateMachine, err error) {
 	for i := uint8(0); i < 4; i++ {
 		sm = pio.StateMachine(i)
-		if sm.TryClaim() {
+		if !sm.IsReserved() && sm.TryClaim() {
 			return sm, nil
 		}
 	}
+	if pio.claimReserved {
+		return pio.claimReservedStateMachine(pio.reservedSMMask)
+	}
 	return StateMachine{}, errStateMachineClaimed
 }
 
@@ -104,18 +171,11 @@ func (pio *PIO) AddProgramAtOffset(instructions []uint16, origin int8, offset ui
 
 	programLen := uint8(len(instructions))
 	for i := uint8(0); i < programLen; i++ {
-		instr := instructions[i]
-
-		// Patch jump instructions with relative offset
-		if _INSTR_BITS_JMP == instr&_INSTR_BITS_Msk {
-			pio.writeInstructionMemory(offset+i, instr+uint16(offset))
-		} else {
-			pio.writeInstructionMemory(offset+i, instr)
-		}
+		pio.writeInstructionMemory(offset+i, relocate(instructions[i], offset))
 	}
 
 	// Mark the instruction space as in-use
-	programMask := uint32((1 << programLen) - 1)
+	programMask := instrMask((1 << programLen) - 1)
 	pio.usedSpaceMask |= programMask << uint32(offset)
 	return nil
 }
@@ -126,8 +186,11 @@ func (pio *PIO) CanAddProgramAtOffset(instructions []uint16, origin int8, offset
 	if origin >= 0 && origin != int8(offset) {
 		return false
 	}
+	if !pio.inBounds(offset, len(instructions)) {
+		return false
+	}
 
-	programMask := uint32((1 << len(instructions)) - 1)
+	programMask := instrMask((1 << len(instructions)) - 1)
 	return pio.usedSpaceMask&(programMask<<offset) == 0
 }
 
@@ -139,15 +202,29 @@ func (pio *PIO) writeInstructionMemory(offset uint8, value uint16) {
 	// Instruction Memory registers are 32-bit, with only lower 16 used
 	reg := (*volatile.Register32)(unsafe.Pointer(uintptr(start) + uintptr(offset)*4))
 	reg.Set(uint32(value))
+	pio.instrMem[offset] = value
+}
+
+// relocate returns instr as loaded at offset.
+func relocate(instr uint16, offset uint8) uint16 {
+	// Patch jump instructions with relative offset
+	if _INSTR_BITS_JMP == instr&_INSTR_BITS_Msk {
+		return instr + uint16(offset)
+	}
+	return instr
 }
 
 func (pio *PIO) findOffsetForProgram(instructions []uint16, origin int8) int8 {
+	size := uint32(pio.InstructionMemSize())
 	programLen := uint32(len(instructions))
-	programMask := uint32((1 << programLen) - 1)
+	programMask := instrMask((1 << programLen) - 1)
+	if programLen > size {
+		return -1
+	}
 
 	// Program has fixed offset (not relocatable)
 	if origin >= 0 {
-		if uint32(origin) > 32-programLen {
+		if uint32(origin) > size-programLen {
 			return -1
 		}
 
@@ -159,7 +236,7 @@ func (pio *PIO) findOffsetForProgram(instructions []uint16, origin int8) int8 {
 	}
 
 	// work down from the top always
-	for i := int8(32 - programLen); i >= 0; i-- {
+	for i := int8(size - programLen); i >= 0; i-- {
 		if pio.usedSpaceMask&(programMask<<uint32(i)) == 0 {
 			return i
 		}
@@ -169,27 +246,92 @@ func (pio *PIO) findOffsetForProgram(instructions []uint16, origin int8) int8 {
 }
 
 // ClearProgramSection clears a contiguous section of the PIO's program memory.
-// To clear all program memory use ClearProgramSection(0, 32).
-func (pio *PIO) ClearProgramSection(offset, len uint8) {
-	if offset+len > 32 { // 32 instructions max
+// To clear all program memory use ClearProgramSection(0, pio.InstructionMemSize()).
+//
+// The section is left untouched and ErrProgramInUse returned if an enabled state
+// machine of the block may be executing from it: its current instruction is in
+// the section or its wrap range intersects it. Disabled state machines are not
+// checked. The state machines of the other PIO block are never affected, each
+// block having its own instruction memory, and may keep running. The check is not
+// atomic with the clearing: the caller must own the state machines of the block,
+// so none is enabled by another core or an interrupt handler meanwhile.
+func (pio *PIO) ClearProgramSection(offset, len uint8) error {
+	if !pio.inBounds(offset, int(len)) {
+		panic(badProgramBounds)
+	}
+	var extents [4]smExtent
+	n := 0
+	for i := uint8(0); i < 4; i++ {
+		sm := pio.StateMachine(i)
+		if !sm.IsEnabled() {
+			continue
+		}
+		hw := sm.HW()
+		execctrl := hw.EXECCTRL.Get()
+		extents[n] = smExtent{
+			addr:       uint8(hw.ADDR.Get()),
+			wrapBottom: uint8((execctrl & rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Msk) >> rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Pos),
+			wrapTop:    uint8((execctrl & rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Msk) >> rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Pos),
+		}
+		n++
+	}
+	if sectionInUse(offset, len, extents[:n]) {
+		return ErrProgramInUse
+	}
+	pio.ForceClearProgramSection(offset, len)
+	return nil
+}
+
+// ForceClearProgramSection clears a contiguous section of the PIO's program memory
+// like ClearProgramSection, whether state machines execute from it or not. Each
+// instruction is replaced by a trap, a jump to the start of the section, with a
+// single write: a state machine executing from the section runs either its old
+// instruction or a trap, never a partly written one, and then parks at the start
+// of the section. Jumps into the section from outside land on traps as well.
+func (pio *PIO) ForceClearProgramSection(offset, len uint8) {
+	if !pio.inBounds(offset, int(len)) {
 		panic(badProgramBounds)
 	}
-	hw := pio.HW()
 	for i := offset; i < offset+len; i++ {
 		// We encode trap instructions to prevent undefined behaviour if
 		// a state machine is currently using the program memory.
-		hw.INSTR_MEM[i].Set(uint32(encodeTRAP(offset)))
+		pio.writeInstructionMemory(i, encodeTRAP(offset))
 	}
-	pio.usedSpaceMask &^= uint32((1<<len)-1) << offset
+	pio.usedSpaceMask &^= instrMask((1<<len)-1) << offset
 }
 
 type statemachineHW struct {
-	CLKDIV    volatile.Register32 // 0xC8 for SM0
-	EXECCTRL  volatile.Register32 // 0xCC for SM0
-	SHIFTCTRL volatile.Register32 // 0xD0 for SM0
-	ADDR      volatile.Register32 // 0xD4 for SM0
-	INSTR     volatile.Register32 // 0xD8 for SM0
-	PINCTRL   volatile.Register32 // 0xDC for SM0
+	CLKDIV    AtomicRegister32 // 0xC8 for SM0
+	EXECCTRL  AtomicRegister32 // 0xCC for SM0
+	SHIFTCTRL AtomicRegister32 // 0xD0 for SM0
+	ADDR      AtomicRegister32 // 0xD4 for SM0
+	INSTR     AtomicRegister32 // 0xD8 for SM0
+	PINCTRL   AtomicRegister32 // 0xDC for SM0
+}
+
+// AtomicRegister32 is a peripheral register whose bit manipulation methods use the
+// atomic set, clear and XOR register aliases. Read-modify-write sequences on
+// different bits of the register are thus safe from interrupt handlers and from
+// either core without locking. Get, Set and HasBits behave as in volatile.Register32.
+type AtomicRegister32 struct {
+	volatile.Register32
+}
+
+// SetBits atomically sets the bits set in value.
+func (r *AtomicRegister32) SetBits(value uint32) { setBits(&r.Register32, value) }
+
+// ClearBits atomically clears the bits set in value.
+func (r *AtomicRegister32) ClearBits(value uint32) { clearBits(&r.Register32, value) }
+
+// XorBits atomically toggles the bits set in value.
+func (r *AtomicRegister32) XorBits(value uint32) { xorBits(&r.Register32, value) }
+
+// ReplaceBits replaces the bits in mask shifted by pos with value shifted by pos.
+// Only the bits which differ are toggled with an atomic XOR, so concurrent
+// modifications of bits outside of mask are preserved.
+func (r *AtomicRegister32) ReplaceBits(value, mask uint32, pos uint8) {
+	mask <<= pos
+	xorBits(&r.Register32, (r.Get()^value<<pos)&mask)
 }
 
 func (pio *PIO) smHW(index uint8) *statemachineHW {
@@ -229,6 +371,14 @@ func (pio *PIO) ClearIRQ(irqMask uint8) {
 	pio.hw.SetIRQ(uint32(irqMask))
 }
 
+// ForceIRQ sets the IRQ flags in irqMask as if raised by an IRQ instruction, to
+// release state machines waiting on them. On the RP2040, where state machines
+// can't reach the flags of the other block, it lets the CPU relay a flag between
+// blocks.
+func (pio *PIO) ForceIRQ(irqMask uint8) {
+	pio.hw.IRQ_FORCE.Set(uint32(irqMask))
+}
+
 // SetInputSyncBypassMasked sets the pinMask bits of the INPUT_SYNC_BYPASS register
 // with the values in the corresponding bypassMask bits.
 //
@@ -237,7 +387,10 @@ func (pio *PIO) ClearIRQ(irqMask uint8) {
 // fast synchronous IO (e.g. SPI) these synchronizers may need to be bypassed.
 // If bit set the corresponding synchronizer is bypassed. If in doubt leave as zeros.
 func (pio *PIO) SetInputSyncBypassMasked(bypassMask, pinMask uint32) {
-	pio.hw.INPUT_SYNC_BYPASS.ReplaceBits(bypassMask, pinMask, 0)
+	// Atomic set and clear so that drivers on other cores or interrupts
+	// configuring different pins do not race with us.
+	setBits(&pio.hw.INPUT_SYNC_BYPASS, bypassMask&pinMask)
+	clearBits(&pio.hw.INPUT_SYNC_BYPASS, ^bypassMask&pinMask)
 }
 
 // HW returns a pointer to the PIO's hardware registers.
@@ -273,6 +426,62 @@ const (
 	sizeOK = unsafe.Sizeof(rp.PIO0_Type{}) == unsafe.Sizeof(pioHW{})
 )
 
+// claimSpinlock is the hardware spinlock used to guard claim bitmasks.
+// The pico-sdk reserves this spinlock for hardware claiming as well.
+const claimSpinlock = 11
+
+// claimLock makes claim bitmask modifications atomic. It disables interrupts on the
+// current core and takes a hardware spinlock to exclude the other core. Critical
+// sections must be short and must not yield.
+func claimLock() interrupt.State {
+	state := interrupt.Disable()
+	lock := spinlock(claimSpinlock)
+	for lock.Get() == 0 {
+		// Reading a spinlock register returns zero if already taken by the other core.
+	}
+	return state
+}
+
+// claimUnlock releases the lock taken by claimLock.
+func claimUnlock(state interrupt.State) {
+	spinlock(claimSpinlock).Set(0) // Any write releases the spinlock.
+	interrupt.Restore(state)
+}
+
+// ClaimState is the interrupt state saved by ClaimLock.
+type ClaimState uintptr
+
+// ClaimLock takes the lock guarding state machine claims, for packages keeping
+// claims of other resources under the same lock, such as the DMA channels of
+// piolib. Critical sections must be short and must not yield. The lock is
+// released with ClaimUnlock.
+func ClaimLock() ClaimState {
+	return ClaimState(claimLock())
+}
+
+// ClaimUnlock releases the lock taken by ClaimLock.
+func ClaimUnlock(state ClaimState) {
+	claimUnlock(interrupt.State(state))
+}
+
+// unclaimHooks are called by StateMachine.Unclaim, see OnUnclaim.
+var unclaimHooks []func(sm StateMachine)
+
+// OnUnclaim registers fn to be called each time a state machine is unclaimed, for
+// packages keeping per state machine state such as piolib. fn is called after the
+// claim lock is released but possibly from an interrupt handler, so it must be
+// short and must not allocate. Hooks should be registered at initialization.
+func OnUnclaim(fn func(sm StateMachine)) {
+	unclaimHooks = append(unclaimHooks, fn)
+}
+
+// CurrentCore returns the index of the core executing the call.
+func CurrentCore() uint8 { return uint8(rp.SIO.CPUID.Get()) }
+
+func spinlock(n uintptr) *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rp.SIO.SPINLOCK0)) + n*4))
+}
+
 // noCopy may be embedded into structs which must not be copied
 // after the first use.
 //
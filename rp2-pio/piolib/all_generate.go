@@ -14,6 +14,9 @@ var (
 	errContentionTimeout = errors.New("piolib:contention timeout")
 	errBusy              = errors.New("piolib:busy")
 
+	// ErrCanceled is returned by operations canceled before they were done.
+	ErrCanceled = errors.New("piolib:canceled")
+
 	errDMAUnavail = errors.New("piolib:DMA channel unavailable")
 )
 
@@ -24,6 +27,43 @@ var (
 //go:generate pioasm -o go ws2812b.pio     ws2812b_pio.go
 //go:generate pioasm -o go i2s.pio        i2s_pio.go
 //go:generate pioasm -o go spi3w.pio       spi3w_pio.go
+//go:generate pioasm -o go sent.pio        sent_pio.go
+//go:generate pioasm -o go charlieplex.pio charlieplex_pio.go
+//go:generate pioasm -o go bldc.pio        bldc_pio.go
+//go:generate pioasm -o go clockgen.pio    clockgen_pio.go
+//go:generate pioasm -o go mdio.pio        mdio_pio.go
+//go:generate pioasm -o go pulsewidth.pio  pulsewidth_pio.go
+//go:generate pioasm -o go uart.pio        uart_pio.go
+//go:generate pioasm -o go tm1637.pio      tm1637_pio.go
+//go:generate pioasm -o go tm1638.pio      tm1638_pio.go
+//go:generate pioasm -o go ir.pio          ir_pio.go
+//go:generate pioasm -o go wheelspeed.pio  wheelspeed_pio.go
+//go:generate pioasm -o go i2c.pio         i2c_pio.go
+//go:generate pioasm -o go spiadc.pio      spiadc_pio.go
+//go:generate pioasm -o go keymatrix.pio   keymatrix_pio.go
+//go:generate pioasm -o go s0counter.pio   s0counter_pio.go
+//go:generate pioasm -o go spi9.pio        spi9_pio.go
+//go:generate pioasm -o go triac.pio       triac_pio.go
+//go:generate pioasm -o go vumeter.pio     vumeter_pio.go
+//go:generate pioasm -o go logicanalyzer.pio logicanalyzer_pio.go
+//go:generate pioasm -o go pinexerciser.pio pinexerciser_pio.go
+//go:generate pioasm -o go pulsedelay.pio pulsedelay_pio.go
+//go:generate pioasm -o go gpib.pio gpib_pio.go
+//go:generate pioasm -o go thermalprinter.pio thermalprinter_pio.go
+//go:generate pioasm -o go dcc.pio dcc_pio.go
+//go:generate pioasm -o go rs485.pio rs485_pio.go
+//go:generate pioasm -o go dutyscan.pio dutyscan_pio.go
+//go:generate pioasm -o go fsk.pio fsk_pio.go
+//go:generate pioasm -o go servofb.pio servofb_pio.go
+//go:generate pioasm -o go prbs.pio prbs_pio.go
+//go:generate pioasm -o go captouch.pio captouch_pio.go
+//go:generate pioasm -o go kline.pio kline_pio.go
+//go:generate pioasm -o go ltc.pio ltc_pio.go
+//go:generate pioasm -o go manchester.pio manchester_pio.go
+//go:generate pioasm -o go ledstrip.pio ledstrip_pio.go
+
+//go:generate go run ../internal/stubgen -o stub.go -err "piolib:PIO not available on this target"
+
 func gosched() {
 	runtime.Gosched()
 }
@@ -0,0 +1,331 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errBarcodeUnreadable = errors.New("piolib:barcode not recognized")
+	errBarcodeOverrun    = errors.New("piolib:barcode bar measurement lost")
+)
+
+const (
+	// Default time without edges after which a swipe is considered complete.
+	barcodeDefaultGap = 50 * time.Millisecond
+	// Bars and spaces kept per swipe, enough for 24 Code 39 characters.
+	barcodeMaxElements = 255
+	// A quiet zone is a space at least this many times as wide as the bar next to it.
+	barcodeQuietZone = 5
+)
+
+// BarcodeSymbology is the encoding of a barcode.
+type BarcodeSymbology uint8
+
+const (
+	BarcodeCode39 BarcodeSymbology = iota + 1
+	BarcodeEAN13
+)
+
+// Barcode is a decoded barcode. Text holds the digits of EAN-13 codes, check digit
+// included, and the characters of Code 39 codes without the start and stop characters.
+type Barcode struct {
+	Symbology BarcodeSymbology
+	Text      string
+}
+
+// BarcodeScanner decodes the output of TTL barcode wands and laser scanner engines
+// that output the bars as they are scanned, with no decoder of their own. The state
+// machine measures the width of each bar and space at the CPU frequency and Code 39
+// and EAN-13 codes are decoded from the ratios of the widths, in either direction
+// of the swipe, between quiet zones.
+type BarcodeScanner struct {
+	sm        pio.StateMachine
+	offset    uint8
+	dl        deadliner
+	blackHigh bool
+	gap       time.Duration
+	// elements holds the widths in cycles of the bars and spaces of a swipe,
+	// alternating and starting with a bar.
+	elements [barcodeMaxElements]uint32
+}
+
+// NewBarcodeScanner returns a decoder of the scanner output on pin. blackHigh is
+// set for scanners whose output is high over bars, the usual polarity of wands.
+func NewBarcodeScanner(sm pio.StateMachine, pin machine.Pin, blackHigh bool) (*BarcodeScanner, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	return &BarcodeScanner{sm: sm, offset: offset, blackHigh: blackHigh, gap: barcodeDefaultGap}, nil
+}
+
+// SetTimeout sets the time Scan waits for a swipe to start. Use 0 as argument to
+// disable timeouts.
+func (bs *BarcodeScanner) SetTimeout(timeout time.Duration) {
+	bs.dl.setTimeout(timeout)
+}
+
+// SetGap sets the time without edges after which a swipe is complete. It defaults
+// to 50ms and must be longer than the widest bar at the slowest swipe speed.
+func (bs *BarcodeScanner) SetGap(gap time.Duration) {
+	bs.gap = gap
+}
+
+// Scan waits for the next swipe and decodes it.
+func (bs *BarcodeScanner) Scan() (Barcode, error) {
+	n, err := bs.capture()
+	if err != nil {
+		return Barcode{}, err
+	}
+	el := bs.elements[:n]
+	for pass := 0; pass < 2; pass++ {
+		if pass == 1 {
+			// Swiped from the end of the code.
+			for i, j := 0, len(el)-1; i < j; i, j = i+1, j-1 {
+				el[i], el[j] = el[j], el[i]
+			}
+		}
+		for i := 0; i < len(el); i += 2 {
+			if i > 0 && el[i-1] < barcodeQuietZone*el[i] {
+				continue
+			}
+			if text, ok := decodeEAN13(el[i:]); ok {
+				return Barcode{Symbology: BarcodeEAN13, Text: text}, nil
+			}
+			if text, ok := decodeCode39(el[i:]); ok {
+				return Barcode{Symbology: BarcodeCode39, Text: text}, nil
+			}
+		}
+	}
+	return Barcode{}, errBarcodeUnreadable
+}
+
+// capture stores the widths of the bars and spaces of the next swipe and returns
+// their number, always odd as the swipe starts and ends with a bar.
+func (bs *BarcodeScanner) capture() (n int, err error) {
+	for !bs.sm.IsRxFIFOEmpty() {
+		bs.sm.RxGet() // Discard measurements preceding the call.
+	}
+	dl := bs.dl.newDeadline()
+	last := time.Now()
+	for n < len(bs.elements) {
+		if bs.sm.IsRxFIFOEmpty() {
+			if n > 0 && time.Since(last) > bs.gap {
+				break
+			}
+			if n == 0 && dl.expired() {
+				return 0, errTimeout
+			}
+			gosched()
+			continue
+		}
+		last = time.Now()
+		cycles, high := pulsewidthDecode(bs.sm.RxGet())
+		black := high == bs.blackHigh
+		switch {
+		case n == 0 && !black:
+			// Space before the swipe.
+		case black != (n%2 == 0):
+			// A measurement was dropped because the FIFO was full.
+			return 0, errBarcodeOverrun
+		default:
+			bs.elements[n] = uint32(cycles)
+			n++
+		}
+	}
+	if n%2 == 0 && n > 0 {
+		n-- // Drop a trailing space.
+	}
+	return n, nil
+}
+
+// code39Patterns holds the wide elements of each Code 39 character, bit 8 being
+// the first bar, in the order of code39Chars.
+var code39Patterns = [44]uint16{
+	0x034, 0x121, 0x061, 0x160, 0x031, 0x130, 0x070, 0x025, 0x124, 0x064,
+	0x109, 0x049, 0x148, 0x019, 0x118, 0x058, 0x00d, 0x10c, 0x04c, 0x01c,
+	0x103, 0x043, 0x142, 0x013, 0x112, 0x052, 0x007, 0x106, 0x046, 0x016,
+	0x181, 0x0c1, 0x1c0, 0x091, 0x190, 0x0d0, 0x085, 0x184, 0x0c4, 0x094,
+	0x0a8, 0x0a2, 0x08a, 0x02a,
+}
+
+const code39Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-. *$/+%"
+
+// decodeCode39 decodes a Code 39 symbol starting at el[0] with the start character
+// and ending with the stop character followed by a quiet zone.
+func decodeCode39(el []uint32) (string, bool) {
+	var text []byte
+	for pos := 0; pos+9 <= len(el); pos += 10 {
+		c, narrow, ok := code39Char(el[pos : pos+9])
+		if !ok || (pos == 0 && c != '*') {
+			return "", false
+		}
+		if c != '*' {
+			text = append(text, c)
+			continue
+		}
+		if pos == 0 {
+			continue // Start character.
+		}
+		if end := pos + 9; end < len(el) && el[end] < barcodeQuietZone*narrow {
+			return "", false
+		}
+		return string(text), len(text) > 0
+	}
+	return "", false
+}
+
+// code39Char decodes the 5 bars and 4 spaces of a character and returns it with
+// the width of its narrowest element.
+func code39Char(el []uint32) (c byte, narrow uint32, ok bool) {
+	// The 3 widest elements are wide, and must be clearly wider than the others.
+	var sorted [9]uint32
+	copy(sorted[:], el)
+	for i := 1; i < 9; i++ {
+		for j := i; j > 0 && sorted[j] < sorted[j-1]; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	if 2*sorted[6] < 3*sorted[5] {
+		return 0, 0, false
+	}
+	var pattern uint16
+	for _, w := range el {
+		pattern <<= 1
+		if w >= sorted[6] {
+			pattern |= 1
+		}
+	}
+	for i, p := range code39Patterns {
+		if p == pattern {
+			return code39Chars[i], sorted[0], true
+		}
+	}
+	return 0, 0, false
+}
+
+// eanPatterns holds the module widths of the L code of each digit, alternating
+// space and bar. R codes have the same widths starting with a bar, and G codes
+// the widths reversed.
+var eanPatterns = [10][4]uint8{
+	{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+	{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+}
+
+// eanFirstDigit holds the parity pattern, G codes as set bits, of the left half
+// digits encoding the first digit, bit 5 for the leftmost.
+var eanFirstDigit = [10]uint8{0x00, 0x0b, 0x0d, 0x0e, 0x13, 0x19, 0x1c, 0x15, 0x16, 0x1a}
+
+// decodeEAN13 decodes an EAN-13 symbol starting at el[0] with the left guard bars
+// and checks its check digit and trailing quiet zone.
+func decodeEAN13(el []uint32) (string, bool) {
+	const elements = 59 // Guards, center bars and 12 digits of 4 elements.
+	if len(el) < elements {
+		return "", false
+	}
+	var total uint32
+	for _, w := range el[:elements] {
+		total += w
+	}
+	module := total / 95
+	if module == 0 || (len(el) > elements && el[elements] < barcodeQuietZone*module) {
+		return "", false
+	}
+	for _, i := range [...]int{0, 1, 2, 27, 28, 29, 30, 31, 56, 57, 58} {
+		if el[i] > 2*module {
+			return "", false // Guard element wider than a module.
+		}
+	}
+	var digits [13]byte
+	var parity uint8
+	for d := 0; d < 12; d++ {
+		start := 3 + 4*d
+		if d >= 6 {
+			start = 32 + 4*(d-6)
+		}
+		digit, g, ok := eanDigit(el[start:start+4], d < 6)
+		if !ok {
+			return "", false
+		}
+		digits[d+1] = '0' + digit
+		if g {
+			parity |= 1 << (5 - d)
+		}
+	}
+	first := -1
+	for i, p := range eanFirstDigit {
+		if p == parity {
+			first = i
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	digits[0] = '0' + byte(first)
+	sum := 0
+	for i, c := range digits[:12] {
+		w := 1
+		if i%2 == 1 {
+			w = 3
+		}
+		sum += w * int(c-'0')
+	}
+	if byte((10-sum%10)%10)+'0' != digits[12] {
+		return "", false
+	}
+	return string(digits[:]), true
+}
+
+// eanDigit matches the widths of a digit against the digit patterns and returns the
+// closest one, and whether it is a G code. G codes are only allowed in the left half.
+func eanDigit(el []uint32, left bool) (digit byte, g bool, ok bool) {
+	total := el[0] + el[1] + el[2] + el[3]
+	if total == 0 {
+		return 0, false, false
+	}
+	// Widths in sixteenths of a module.
+	var w [4]int
+	for i := range w {
+		w[i] = int(el[i] * 7 * 16 / total)
+	}
+	best := 1 << 30
+	for d, p := range eanPatterns {
+		for reversed := 0; reversed < 2; reversed++ {
+			if reversed == 1 && !left {
+				break
+			}
+			dist := 0
+			for i := range w {
+				m := p[i]
+				if reversed == 1 {
+					m = p[3-i]
+				}
+				diff := w[i] - 16*int(m)
+				if diff < 0 {
+					diff = -diff
+				}
+				dist += diff
+			}
+			if dist < best {
+				best, digit, g = dist, byte(d), reversed == 1
+			}
+		}
+	}
+	// Reject digits more than a module and a half away from any pattern.
+	return digit, g, best <= 24
+}
+
+// Placement returns the state machine and program used by the scanner.
+func (bs *BarcodeScanner) Placement() Placement {
+	var p Placement
+	p.addSM(bs.sm, bs.offset, pulsewidthInstructions)
+	return p
+}
@@ -0,0 +1,254 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"math"
+	"time"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// PWM period in state machine cycles, including dead time.
+	bldcPeriodCycles = 1000
+	// Cycles per period in which all gates are off, see bldc.pio.
+	bldcDeadCycles = 18
+	// Minimum on or off phase duration in cycles, see bldc.pio.
+	bldcMinPhaseCycles = 3
+)
+
+// bldcSectors maps a hall state (hall pin C, B, A as bits 2, 1, 0) to the rotor
+// sector. States 0 and 7 are invalid with 120° spaced sensors.
+var bldcSectors = [8]int8{-1, 0, 2, 1, 4, 5, 3, -1}
+
+// bldcSectorPhases holds the phase driven high and the phase driven low in each sector.
+var bldcSectorPhases = [6][2]uint8{
+	{0, 1}, // A+ B-
+	{0, 2}, // A+ C-
+	{1, 2}, // B+ C-
+	{1, 0}, // B+ A-
+	{2, 0}, // C+ A-
+	{2, 1}, // C+ B-
+}
+
+// BLDC commutates a brushless DC motor with 3 hall sensors through a three phase
+// bridge. One state machine tracks the hall sensors while another generates the PWM
+// pattern for the 6 gates. On every hall state change DMA looks up the pattern of the
+// new sector in a table and passes it to the PWM state machine, so commutation takes
+// no CPU time. The PWM is complementary: the low side gate of the phase driven high
+// is on during the off phase of the PWM period.
+//
+// The commutation table assumes the common hall alignment where the hall states
+// 1, 3, 2, 6, 4, 5 follow each other when the motor turns forward. If the motor
+// does not turn, swap the hall sensor or phase wiring.
+type BLDC struct {
+	hall       pio.StateMachine
+	pwm        pio.StateMachine
+	hallOffset uint8
+	pwmOffset  uint8
+	dmaAddr    dmaChannel
+	dmaLookup  dmaChannel
+	polePairs  uint8
+	duty       uint16
+	// table is the 32 byte aligned commutation table in tableBuf indexed by hall state.
+	table    []uint32
+	tableBuf [15]uint32
+	// Sector change count and time of the last RPM call.
+	lastChanges uint32
+	lastTime    time.Time
+}
+
+// NewBLDC creates a motor commutator with the hall sensors A, B and C on 3 consecutive
+// pins starting at hallBase and the bridge gates on 6 consecutive pins starting at
+// gateBase in the order A high, A low, B high, B low, C high, C low. freq is the PWM
+// frequency, at most the CPU frequency divided by 1000. The gates are turned off for
+// 8 to 10 PWM clock cycles when switching, a PWM clock cycle being a thousandth of
+// the PWM period. polePairs is the number of magnetic pole pairs of the motor used
+// to calculate RPM. The motor starts with zero duty cycle. Two DMA channels are claimed.
+func NewBLDC(hall, pwm pio.StateMachine, hallBase, gateBase machine.Pin, freq uint32, polePairs uint8) (*BLDC, error) {
+	hall.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	pwm.TryClaim()
+	if polePairs == 0 {
+		return nil, errors.New("piolib:BLDC pole pairs must be at least 1")
+	}
+	whole, frac, err := pio.ClkDivFromFrequency(freq*bldcPeriodCycles, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	dmaAddr, ok := _DMA.ClaimChannelFor("BLDC")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	dmaLookup, ok := _DMA.ClaimChannelFor("BLDC")
+	if !ok {
+		dmaAddr.Unclaim()
+		return nil, errDMAUnavail
+	}
+	hallPio := hall.PIO()
+	hallOffset, err := hallPio.AddProgram(bldc_hallInstructions, bldc_hallOrigin)
+	if err != nil {
+		dmaAddr.Unclaim()
+		dmaLookup.Unclaim()
+		return nil, err
+	}
+	pwmPio := pwm.PIO()
+	pwmOffset, err := pwmPio.AddProgram(bldc_pwmInstructions, bldc_pwmOrigin)
+	if err != nil {
+		freeProgram(hallPio, hallOffset, bldc_hallInstructions)
+		dmaAddr.Unclaim()
+		dmaLookup.Unclaim()
+		return nil, err
+	}
+
+	b := &BLDC{
+		hall:       hall,
+		pwm:        pwm,
+		hallOffset: hallOffset,
+		pwmOffset:  pwmOffset,
+		dmaAddr:    dmaAddr,
+		dmaLookup:  dmaLookup,
+		polePairs:  polePairs,
+	}
+	// Align table to 32 bytes as required by the hall program.
+	align := (32 - uintptr(unsafe.Pointer(&b.tableBuf[0]))%32) % 32 / 4
+	b.table = b.tableBuf[align : align+8]
+	b.updateTable()
+
+	// Gates start off until the first pattern arrives.
+	pinCfg := machine.PinConfig{Mode: pwmPio.PinMode()}
+	for i := gateBase; i < gateBase+6; i++ {
+		i.Configure(pinCfg)
+	}
+	pwm.SetPinsConsecutive(gateBase, 6, false)
+	pwm.SetPindirsConsecutive(gateBase, 6, true)
+	cfg := bldc_pwmProgramDefaultConfig(pwmOffset)
+	cfg.SetOutPins(gateBase, 6)
+	cfg.SetOutShift(true, false, 32)
+	cfg.SetClkDivIntFrac(whole, frac)
+	pwm.Init(pwmOffset, cfg)
+	trackClock(pwm, freq*bldcPeriodCycles)
+
+	pinCfg = machine.PinConfig{Mode: hallPio.PinMode()}
+	for i := hallBase; i < hallBase+3; i++ {
+		i.Configure(pinCfg)
+	}
+	hall.SetPindirsConsecutive(hallBase, 3, false)
+	cfg = bldc_hallProgramDefaultConfig(hallOffset)
+	cfg.SetInPins(hallBase)
+	cfg.SetInShift(false, false, 32)
+	hall.Init(hallOffset, cfg)
+	hall.TxPut(ptrAs(&b.table[0]) >> 5)
+
+	dmaAddr.lookupLoop32(dmaLookup, &pwm.TxReg().Reg, &hall.RxReg().Reg, ptrAs(&b.table[0]),
+		dmaPIO_RxDREQ(hall), dmaPIO_TxDREQ(pwm))
+	pwm.SetEnabled(true)
+	hall.SetEnabled(true)
+	return b, nil
+}
+
+// SetDuty sets the PWM duty cycle, 0 letting the motor coast and math.MaxUint16
+// being full power. The new duty cycle takes effect within a PWM period.
+func (b *BLDC) SetDuty(duty uint16) {
+	b.duty = duty
+	b.updateTable()
+	// The PWM state machine repeats the last pattern until the next sector change,
+	// so pass it the current sector's pattern. Repeat if the sector changed meanwhile
+	// since DMA may have passed the old pattern before ours.
+	for {
+		state := b.hallState()
+		if state < 0 {
+			return
+		}
+		b.pwm.TxPut(b.table[state])
+		if b.hallState() == state {
+			return
+		}
+	}
+}
+
+// Duty returns the PWM duty cycle set by SetDuty.
+func (b *BLDC) Duty() uint16 {
+	return b.duty
+}
+
+// Sector returns the current rotor sector in the range 0..5 as decoded from the hall
+// sensors, or -1 if the hall state is invalid or no hall state was read yet.
+func (b *BLDC) Sector() int {
+	state := b.hallState()
+	if state < 0 {
+		return -1
+	}
+	return int(bldcSectors[state])
+}
+
+// RPM returns the mechanical revolutions per minute of the motor averaged over the
+// sector changes since the last call to RPM. The first call returns 0. Call it at
+// regular intervals, at least every few seconds.
+func (b *BLDC) RPM() uint32 {
+	changes := b.dmaAddr.lookupCount()
+	now := time.Now()
+	elapsed := now.Sub(b.lastTime)
+	first := b.lastTime.IsZero()
+	delta := changes - b.lastChanges
+	b.lastChanges = changes
+	b.lastTime = now
+	if first || elapsed <= 0 {
+		return 0
+	}
+	// 6 sector changes per electrical revolution.
+	rpm := uint64(delta) * uint64(time.Minute) / (6 * uint64(b.polePairs) * uint64(elapsed))
+	if rpm > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(rpm)
+}
+
+// hallState returns the hall state of the last pattern looked up by DMA, or -1 if none.
+func (b *BLDC) hallState() int {
+	if b.dmaAddr.lookupCount() == 0 {
+		return -1
+	}
+	// The lookup channel reads without incrementing: its read address is left on the
+	// entry it last copied.
+	offset := int(b.dmaLookup.HW().READ_ADDR.Get()) - int(ptrAs(&b.table[0]))
+	state := offset / 4
+	if offset < 0 || state >= len(b.table) {
+		return -1
+	}
+	return state
+}
+
+// updateTable recalculates the PWM pattern of every hall state for the current duty
+// cycle. The words are written in place since DMA reads them atomically.
+func (b *BLDC) updateTable() {
+	const phaseCycles = bldcPeriodCycles - bldcDeadCycles
+	on := uint32(phaseCycles * uint64(b.duty) / math.MaxUint16)
+	if on < bldcMinPhaseCycles {
+		on = bldcMinPhaseCycles
+	} else if on > phaseCycles-bldcMinPhaseCycles {
+		on = phaseCycles - bldcMinPhaseCycles
+	}
+	off := phaseCycles - on
+	for state, sector := range bldcSectors {
+		var onPins, offPins uint32
+		if sector >= 0 && b.duty != 0 {
+			high, low := bldcSectorPhases[sector][0], bldcSectorPhases[sector][1]
+			onPins = 1<<(2*high) | 1<<(2*low+1)
+			offPins = 1<<(2*high+1) | 1<<(2*low+1)
+		}
+		b.table[state] = onPins | (on-bldcMinPhaseCycles)<<6 | offPins<<16 | (off-bldcMinPhaseCycles)<<22
+	}
+}
+
+// Placement returns the state machines, programs and DMA channels used by the commutation.
+func (b *BLDC) Placement() Placement {
+	var p Placement
+	p.addSM(b.hall, b.hallOffset, bldc_hallInstructions)
+	p.addSM(b.pwm, b.pwmOffset, bldc_pwmInstructions)
+	p.addDMA(b.dmaAddr, b.dmaLookup)
+	return p
+}
@@ -0,0 +1,196 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errCapTouchPads = errors.New("piolib:cap touch needs 1 to 30 pads")
+	errCapTouchPad  = errors.New("piolib:no such cap touch pad")
+)
+
+const (
+	// Measurements averaged per pad by Update.
+	capTouchSamples = 4
+	// Baselines are kept in 1/16th of a count, so slow drift isn't rounded away.
+	capTouchBaselineShift = 4
+	// Untouched pads move their baseline by 1/64th of the difference per Update.
+	capTouchDriftShift = 6
+	// Default increase of the charge time over the baseline that is a touch, in
+	// percent. A touch is released when it falls below 3/4 of this.
+	capTouchDefaultThreshold = 15
+)
+
+// CapTouch senses touches of bare copper pads by the time they take to discharge
+// through a pull-down resistor after being charged: a finger adds capacitance to
+// the pad, lengthening it. Each pad needs a pull-down of around 1MΩ to ground and
+// the pads must be consecutive pins. The pads are measured one after the other by
+// a single state machine counting at the CPU frequency.
+//
+// Each pad has a baseline, its untouched charge time, set by Calibrate and
+// updated by Update while the pad isn't touched to follow slow drift with
+// temperature and humidity.
+type CapTouch struct {
+	sm        pio.StateMachine
+	offset    uint8
+	first     machine.Pin
+	threshold uint32
+	dl        deadliner
+	raw       []uint32
+	baseline  []uint32 // In 1/16th of a count.
+	touched   []bool
+}
+
+// NewCapTouch creates a sensor for numPads pads starting at first, and calibrates
+// their baselines: the pads should not be touched.
+func NewCapTouch(sm pio.StateMachine, first machine.Pin, numPads uint8) (*CapTouch, error) {
+	if numPads == 0 || int(first)+int(numPads) > 30 {
+		return nil, errCapTouchPads
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(captouchInstructions, captouchOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for pin := first; pin < first+machine.Pin(numPads); pin++ {
+		pin.Configure(pinCfg)
+	}
+	sm.SetPindirsConsecutive(first, numPads, false)
+	cfg := captouchProgramDefaultConfig(offset)
+	cfg.SetOutPins(first, numPads)
+	cfg.SetJmpPin(first)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	c := &CapTouch{
+		sm:        sm,
+		offset:    offset,
+		first:     first,
+		threshold: capTouchDefaultThreshold,
+		raw:       make([]uint32, numPads),
+		baseline:  make([]uint32, numPads),
+		touched:   make([]bool, numPads),
+	}
+	if err := c.Calibrate(); err != nil {
+		sm.SetEnabled(false)
+		freeProgram(Pio, offset, captouchInstructions)
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetTimeout sets the timeout of the measurement of a pad, which only expires if
+// a pad stays high, i.e. is shorted to a supply or lacks its pull-down. Use 0 as
+// argument to disable timeouts.
+func (c *CapTouch) SetTimeout(timeout time.Duration) {
+	c.dl.setTimeout(timeout)
+}
+
+// SetThreshold sets the increase of the charge time over the baseline, in percent
+// of the baseline, at which a pad is touched. It defaults to 15%.
+func (c *CapTouch) SetThreshold(percent uint8) {
+	c.threshold = uint32(percent)
+}
+
+// Calibrate measures the pads and sets their baselines. The pads should not be
+// touched.
+func (c *CapTouch) Calibrate() error {
+	if err := c.measure(); err != nil {
+		return err
+	}
+	for i, raw := range c.raw {
+		c.baseline[i] = raw << capTouchBaselineShift
+		c.touched[i] = false
+	}
+	return nil
+}
+
+// Update measures the pads, updates their touch state and the baselines of the
+// pads not touched. It should be called regularly, every 10 to 100ms, for the
+// baselines to follow drift.
+func (c *CapTouch) Update() error {
+	if err := c.measure(); err != nil {
+		return err
+	}
+	for i, raw := range c.raw {
+		base := c.baseline[i] >> capTouchBaselineShift
+		on := base + base*c.threshold/100
+		off := base + base*c.threshold*3/400
+		switch {
+		case raw > on:
+			c.touched[i] = true
+		case raw < off:
+			c.touched[i] = false
+		}
+		if !c.touched[i] {
+			diff := int32(raw<<capTouchBaselineShift - c.baseline[i])
+			c.baseline[i] = uint32(int32(c.baseline[i]) + diff>>capTouchDriftShift)
+		}
+	}
+	return nil
+}
+
+// measure stores the average charge time of each pad to raw.
+func (c *CapTouch) measure() error {
+	dl := c.dl.newDeadline()
+	for i := range c.raw {
+		// The state machine is stalled waiting for the next pad.
+		c.sm.SetJmpPin(c.first + machine.Pin(i))
+		var sum uint32
+		for n := 0; n < capTouchSamples; n++ {
+			c.sm.TxPut(1 << i)
+			for c.sm.IsRxFIFOEmpty() {
+				if dl.expired() {
+					c.restart()
+					return errTimeout
+				}
+				gosched()
+			}
+			sum += c.sm.RxGet()
+		}
+		c.raw[i] = sum / capTouchSamples
+	}
+	return nil
+}
+
+// restart aborts the measurement in progress and releases the pads.
+func (c *CapTouch) restart() {
+	c.sm.SetEnabled(false)
+	c.sm.ClearFIFOs()
+	c.sm.Restart()
+	c.sm.SetPindirsConsecutive(c.first, uint8(len(c.raw)), false)
+	c.sm.Jmp(c.offset, pio.JmpAlways)
+	c.sm.SetEnabled(true)
+}
+
+// IsTouched returns true if pad, counted from the first pad, was touched at the
+// last Update.
+func (c *CapTouch) IsTouched(pad int) bool {
+	if pad < 0 || pad >= len(c.touched) {
+		return false
+	}
+	return c.touched[pad]
+}
+
+// Raw returns the charge time of pad in units of 2 CPU cycles measured at the last
+// Update, and its baseline.
+func (c *CapTouch) Raw(pad int) (raw, baseline uint32, err error) {
+	if pad < 0 || pad >= len(c.raw) {
+		return 0, 0, errCapTouchPad
+	}
+	return c.raw[pad], c.baseline[pad] >> capTouchBaselineShift, nil
+}
+
+// Placement returns the state machine and program used by the sensor.
+func (c *CapTouch) Placement() Placement {
+	var p Placement
+	p.addSM(c.sm, c.offset, captouchInstructions)
+	return p
+}
@@ -0,0 +1,160 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Each slot loop iteration takes 1µs.
+	charlieplexFreq = 1_000_000
+	// Frame rate of the whole LED matrix.
+	charlieplexFrameHz = 500
+	// Cycles taken by the program to set up a slot, see charlieplex.pio.
+	charlieplexSlotOverhead = 4
+)
+
+// Charlieplex drives N*(N-1) LEDs on N consecutive pins. Every LED with its anode on
+// the same pin is lit in the same time slot, so a frame consists of N slots. The
+// frame pattern is streamed to the state machine in a loop by DMA so refreshing the
+// LEDs takes no CPU time. Brightness is set by the ratio of on-time to off-time of a slot.
+type Charlieplex struct {
+	sm         pio.StateMachine
+	offset     uint8
+	dma        dmaChannel
+	dmaCtrl    dmaChannel
+	n          uint8
+	brightness uint8
+	sinks      [8]uint8 // Cathode pin mask of lit LEDs indexed by anode pin.
+	// pattern holds an on slot and an off slot per anode pin.
+	pattern []uint32
+	// patternAddr holds the address of pattern, read by DMA to restart the loop.
+	patternAddr uint32
+}
+
+// NewCharlieplex creates a charlieplexed LED driver on the n consecutive pins starting at base.
+// n must be in the range 2..8. All LEDs start off with full brightness.
+// Two DMA channels are claimed to refresh the LEDs.
+func NewCharlieplex(sm pio.StateMachine, base machine.Pin, n uint8) (*Charlieplex, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if n < 2 || n > 8 {
+		return nil, errors.New("piolib:charlieplex pin count must be 2..8")
+	}
+	whole, frac, err := pio.ClkDivFromFrequency(charlieplexFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	dma, ok := _DMA.ClaimChannelFor("Charlieplex")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	dmaCtrl, ok := _DMA.ClaimChannelFor("Charlieplex")
+	if !ok {
+		dma.Unclaim()
+		return nil, errDMAUnavail
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(charlieplexInstructions, charlieplexOrigin)
+	if err != nil {
+		dma.Unclaim()
+		dmaCtrl.Unclaim()
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for i := base; i < base+machine.Pin(n); i++ {
+		i.Configure(pinCfg)
+	}
+	// Start with all pins floating.
+	sm.SetPindirsConsecutive(base, n, false)
+
+	cfg := charlieplexProgramDefaultConfig(offset)
+	cfg.SetOutPins(base, n)
+	cfg.SetOutShift(true, true, 32)
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, charlieplexFreq)
+
+	c := &Charlieplex{
+		sm:         sm,
+		offset:     offset,
+		dma:        dma,
+		dmaCtrl:    dmaCtrl,
+		n:          n,
+		brightness: 255,
+		pattern:    make([]uint32, 2*int(n)),
+	}
+	for anode := uint8(0); anode < n; anode++ {
+		c.updateSlots(anode)
+	}
+	c.patternAddr = ptrAs(&c.pattern[0])
+	sm.SetEnabled(true)
+	dma.pushLoop32(dmaCtrl, &sm.TxReg().Reg, c.pattern, &c.patternAddr, dmaPIO_TxDREQ(sm))
+	return c, nil
+}
+
+// NumLEDs returns the number of LEDs that can be driven, N*(N-1) for N pins.
+func (c *Charlieplex) NumLEDs() int {
+	return int(c.n) * int(c.n-1)
+}
+
+// SetLED turns LED i on or off. LED i has its anode on pin i/(N-1) and its cathode
+// on the i%(N-1)'th of the remaining pins, counted from base and skipping the anode pin.
+func (c *Charlieplex) SetLED(i int, on bool) {
+	if i < 0 || i >= c.NumLEDs() {
+		panic("piolib:charlieplex LED index out of range")
+	}
+	anode := uint8(i / int(c.n-1))
+	cathode := uint8(i % int(c.n-1))
+	if cathode >= anode {
+		cathode++ // Skip the anode pin.
+	}
+	if on {
+		c.sinks[anode] |= 1 << cathode
+	} else {
+		c.sinks[anode] &^= 1 << cathode
+	}
+	c.updateSlots(anode)
+}
+
+// SetBrightness sets the brightness of all LEDs, 0 being off and 255 fully on.
+func (c *Charlieplex) SetBrightness(brightness uint8) {
+	c.brightness = brightness
+	for anode := uint8(0); anode < c.n; anode++ {
+		c.updateSlots(anode)
+	}
+}
+
+// updateSlots recalculates the on and off slot words of an anode pin. The words are
+// written in place since DMA reads them atomically.
+func (c *Charlieplex) updateSlots(anode uint8) {
+	const slotCycles = charlieplexFreq / charlieplexFrameHz / 8 // Slot pair period for 8 pins.
+	onCycles := slotCycles * uint32(c.brightness) / 255
+	offCycles := slotCycles - onCycles
+	var pins, pindirs uint32
+	if c.sinks[anode] != 0 && onCycles > 0 {
+		pins = 1 << anode
+		pindirs = pins | uint32(c.sinks[anode])
+	}
+	c.pattern[2*anode] = pins | pindirs<<8 | charlieplexSlotDelay(onCycles)<<16
+	c.pattern[2*anode+1] = charlieplexSlotDelay(offCycles) << 16 // All pins floating.
+}
+
+func charlieplexSlotDelay(cycles uint32) uint32 {
+	if cycles <= charlieplexSlotOverhead {
+		return 0
+	}
+	return cycles - charlieplexSlotOverhead
+}
+
+// Placement returns the state machines, programs and DMA channels used by the LED refresh.
+func (c *Charlieplex) Placement() Placement {
+	var p Placement
+	p.addSM(c.sm, c.offset, charlieplexInstructions)
+	p.addDMA(c.dma, c.dmaCtrl)
+	return p
+}
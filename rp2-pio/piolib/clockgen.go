@@ -0,0 +1,161 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Fixed cycles per period of the clockgen program besides the 2*X loop cycles.
+	clockgenOverhead = 8
+	// Maximum number of periods tried when searching the closest divider.
+	clockgenSearchLimit = 256
+)
+
+var errClockGenFreq = errors.New("piolib:clock generator frequency out of range")
+
+// ClockGen is a square wave clock generator for clocking external chips and test
+// benches. It runs continuously and can be retuned while running without restarting
+// the output, i.e: for frequency sweeps.
+//
+// The frequency is generated as close as possible to the requested one by combining
+// the period in state machine cycles with the fractional clock divider. Note that
+// a fractional divider adds up to one system clock cycle of jitter to each edge.
+type ClockGen struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	// Current clock divider in 1/256 units and period in state machine cycles.
+	div    uint32
+	cycles uint32
+}
+
+// NewClockGen creates a clock generator outputting freq Hz on pin.
+// The maximum frequency is the CPU frequency divided by 8.
+func NewClockGen(sm pio.StateMachine, pin machine.Pin, freq uint32) (*ClockGen, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	div, cycles, err := clockgenDivider(freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(clockgenInstructions, clockgenOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPindirsConsecutive(pin, 1, true)
+	cfg := clockgenProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(pin)
+	cfg.SetClkDivIntFrac(uint16(div>>8), uint8(div))
+	sm.Init(offset, cfg)
+	trackClock(sm, 0) // Divider is part of the frequency setting, see SetFrequency.
+	sm.TxPut(cycles/2 - clockgenOverhead/2)
+	sm.SetEnabled(true)
+	return &ClockGen{sm: sm, offset: offset, div: div, cycles: cycles}, nil
+}
+
+// SetTimeout sets the timeout for SetFrequency. Use 0 as argument to disable timeouts.
+func (c *ClockGen) SetTimeout(timeout time.Duration) {
+	c.dl.setTimeout(timeout)
+}
+
+// SetFrequency changes the output frequency while running. The output is not
+// interrupted: the new frequency takes effect at the start of the next period.
+// If the clock divider must change SetFrequency blocks until then so the divider
+// is updated in step with the period, so it may take up to one period to return.
+func (c *ClockGen) SetFrequency(freq uint32) error {
+	div, cycles, err := clockgenDivider(freq, machine.CPUFrequency())
+	if err != nil {
+		return err
+	}
+	running := c.sm.IsEnabled()
+	// Newest frequency wins over any not yet taken by the state machine.
+	c.sm.ClearFIFOs()
+	c.sm.TxPut(cycles/2 - clockgenOverhead/2)
+	if div != c.div && running {
+		dl := c.dl.newDeadline()
+		for !c.sm.IsTxFIFOEmpty() {
+			if dl.expired() {
+				return errTimeout
+			}
+			gosched()
+		}
+	}
+	c.sm.SetClkDiv(uint16(div>>8), uint8(div))
+	c.div = div
+	c.cycles = cycles
+	return nil
+}
+
+// Frequency returns the actual output frequency in Hz, which may differ slightly
+// from the requested frequency if it can't be generated exactly.
+func (c *ClockGen) Frequency() uint32 {
+	return uint32(256 * uint64(machine.CPUFrequency()) / (uint64(c.div) * uint64(c.cycles)))
+}
+
+// Sweep changes the frequency linearly from start to end Hz in steps, waiting dwell
+// at each step. The output ends at end Hz.
+func (c *ClockGen) Sweep(start, end uint32, steps int, dwell time.Duration) error {
+	if steps < 1 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		freq := int64(start) + (int64(end)-int64(start))*int64(i)/int64(steps)
+		err := c.SetFrequency(uint32(freq))
+		if err != nil {
+			return err
+		}
+		if i < steps {
+			time.Sleep(dwell)
+		}
+	}
+	return nil
+}
+
+// Enable starts or stops the clock output. When stopped the output holds its level.
+func (c *ClockGen) Enable(enabled bool) {
+	c.sm.SetEnabled(enabled)
+}
+
+// clockgenDivider returns the clock divider in 1/256 units and the even period in
+// state machine cycles that generate the frequency closest to freq. Exact
+// frequencies with the smallest divider, and so the least jitter, are preferred.
+func clockgenDivider(freq, cpuFreq uint32) (div, cycles uint32, err error) {
+	if freq == 0 || freq > cpuFreq/clockgenOverhead {
+		return 0, 0, errClockGenFreq
+	}
+	total := 256 * uint64(cpuFreq) // Divider times period for 1Hz.
+	maxCycles := cpuFreq / freq &^ 1
+	var bestErr uint64
+	for i, n := 0, maxCycles; i < clockgenSearchLimit && n >= clockgenOverhead && n >= maxCycles/2; i, n = i+1, n-2 {
+		period := uint64(freq) * uint64(n)
+		d := (total + period/2) / period
+		if d > 256*0xffff {
+			return 0, 0, errClockGenFreq
+		}
+		e := total - d*period
+		if d*period > total {
+			e = d*period - total
+		}
+		if i == 0 || e < bestErr {
+			div, cycles, bestErr = uint32(d), n, e
+		}
+		if e == 0 {
+			break
+		}
+	}
+	return div, cycles, nil
+}
+
+// Placement returns the state machine and program used by the clock generator.
+func (c *ClockGen) Placement() Placement {
+	var p Placement
+	p.addSM(c.sm, c.offset, clockgenInstructions)
+	return p
+}
@@ -0,0 +1,345 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errDCCPacket = errors.New("piolib:DCC packet too long or address out of range")
+
+const (
+	// State machine frequency of the DCC generator, 1 cycle per microsecond.
+	dccFreq = 1_000_000
+	// Preamble bits sent before packets, more than the 14 required of command stations.
+	dccPreamble = 14
+	// Minimum preamble bits accepted by the receiver, as for decoders.
+	dccMinPreamble = 10
+	// Longest packet, address and error byte included.
+	dccMaxPacket = 6
+	// Half-bit durations accepted by the receiver in microseconds, NMRA S-9.1 decoder limits.
+	dccOneMin  = 52
+	dccOneMax  = 64
+	dccZeroMin = 90
+	dccZeroMax = 10000
+	// Highest short and long multi-function decoder address.
+	dccMaxShortAddr = 127
+	dccMaxLongAddr  = 10239
+)
+
+// DCCTransmitter generates the Digital Command Control signal of a model railway
+// command station on a pin driving a booster, such as the DIR input of an H-bridge.
+// When no packet is queued the line is held low: command stations keep the
+// waveform on the track by sending packets continuously, idle packets when there
+// is nothing else to send.
+type DCCTransmitter struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	words  [4]uint32
+}
+
+// NewDCCTransmitter returns a DCC generator outputting the signal on pin.
+func NewDCCTransmitter(sm pio.StateMachine, pin machine.Pin) (*DCCTransmitter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	whole, frac, err := pio.ClkDivFromFrequency(dccFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(dcc_txInstructions, dcc_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPinsConsecutive(pin, 1, false)
+	sm.SetPindirsConsecutive(pin, 1, true)
+
+	cfg := dcc_txProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(pin)
+	cfg.SetOutShift(false, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, dccFreq)
+	sm.SetEnabled(true)
+	return &DCCTransmitter{sm: sm, offset: offset}, nil
+}
+
+// SetTimeout sets the timeout for packets to be queued. Use 0 as argument to
+// disable timeouts.
+func (d *DCCTransmitter) SetTimeout(timeout time.Duration) {
+	d.dl.setTimeout(timeout)
+}
+
+// SendPacket queues a packet for the decoder at addr with the instruction bytes of
+// cmd, i.e. {0x3f, 0x80 | speed} for a 128 speed step command. Addresses up to 127
+// are sent as short addresses, others up to 10239 as long addresses. The error
+// detection byte is appended. Packets are sent once: command stations repeat
+// speed and function packets periodically.
+func (d *DCCTransmitter) SendPacket(addr uint16, cmd []byte) error {
+	var packet [dccMaxPacket]byte
+	n := 0
+	switch {
+	case addr <= dccMaxShortAddr:
+		packet[0] = byte(addr)
+		n = 1
+	case addr <= dccMaxLongAddr:
+		packet[0] = 0xc0 | byte(addr>>8)
+		packet[1] = byte(addr)
+		n = 2
+	default:
+		return errDCCPacket
+	}
+	if n+len(cmd) >= dccMaxPacket {
+		return errDCCPacket
+	}
+	n += copy(packet[n:], cmd)
+	return d.SendRaw(packet[:n])
+}
+
+// SendIdle queues an idle packet, sent by command stations when there is nothing
+// else to send.
+func (d *DCCTransmitter) SendIdle() error {
+	return d.SendRaw([]byte{0xff, 0x00})
+}
+
+// SendReset queues a broadcast reset packet, which stops all locomotives and
+// clears their volatile state.
+func (d *DCCTransmitter) SendReset() error {
+	return d.SendRaw([]byte{0x00, 0x00})
+}
+
+// SendRaw queues a packet made of data followed by its error detection byte.
+func (d *DCCTransmitter) SendRaw(data []byte) error {
+	if len(data) == 0 || len(data) >= dccMaxPacket {
+		return errDCCPacket
+	}
+	// Packet bits: a start bit and 8 data bits per byte, the error byte, the end bit.
+	bits := 9*(len(data)+1) + 1
+	// Pad the preamble so the packet fills whole words.
+	pre := dccPreamble + (32-(dccPreamble+bits)%32)%32
+	d.words = [4]uint32{}
+	pos := pre // Leading ones are set after packing.
+	var xor byte
+	for i := 0; i <= len(data); i++ {
+		b := xor
+		if i < len(data) {
+			b = data[i]
+			xor ^= b
+		}
+		pos++ // Start bit, zero.
+		for bit := 7; bit >= 0; bit-- {
+			if b&(1<<bit) != 0 {
+				d.words[pos/32] |= 1 << (31 - pos%32)
+			}
+			pos++
+		}
+	}
+	d.words[pos/32] |= 1 << (31 - pos%32) // End bit.
+	pos++
+	for i := 0; i < pre; i++ {
+		d.words[i/32] |= 1 << (31 - i%32)
+	}
+	// Queue the packet at once so the state machine can't stall in the middle of
+	// it, which would stretch a half-bit.
+	words := d.words[:pos/32]
+	dl := d.dl.newDeadline()
+	for 8-d.sm.TxFIFOLevel() < uint32(len(words)) {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	for _, w := range words {
+		d.sm.TxPut(w)
+	}
+	return nil
+}
+
+// Placement returns the state machine and program used by the generator.
+func (d *DCCTransmitter) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, dcc_txInstructions)
+	return p
+}
+
+// DCCPacket is a packet received from a DCC signal, without its error detection byte.
+type DCCPacket struct {
+	Data [dccMaxPacket - 1]byte
+	Len  uint8
+}
+
+// Bytes returns the address and instruction bytes of the packet.
+func (p *DCCPacket) Bytes() []byte {
+	return p.Data[:p.Len]
+}
+
+// Address returns the multi-function decoder address of the packet and whether it
+// has one: broadcast packets have address 0, idle packets and accessory decoder
+// packets have none.
+func (p *DCCPacket) Address() (addr uint16, ok bool) {
+	switch a := p.Data[0]; {
+	case a <= dccMaxShortAddr:
+		return uint16(a), true
+	case a >= 0xc0 && a <= 0xe7 && p.Len > 1:
+		return uint16(a&0x3f)<<8 | uint16(p.Data[1]), true
+	}
+	return 0, false
+}
+
+// DCCReceiver decodes packets from a DCC track signal, for sniffers and feedback
+// applications, by measuring the duration of each half-bit. The signal must be
+// brought to logic levels, i.e. through an optocoupler across the rails; its
+// polarity doesn't matter. The state machine runs at the CPU frequency.
+type DCCReceiver struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	// Decoder state: the kind of an unpaired half-bit, 0 if none, 1 or 2 for a half
+	// of a one or a zero, the count of preamble ones, -1 inside a packet, and the
+	// bits of the packet being received.
+	half     uint8
+	preamble int
+	bits     uint8
+	cur      byte
+	packet   [dccMaxPacket]byte
+	n        uint8
+}
+
+// NewDCCReceiver returns a DCC decoder of the signal on pin.
+func NewDCCReceiver(sm pio.StateMachine, pin machine.Pin) (*DCCReceiver, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	return &DCCReceiver{sm: sm, offset: offset}, nil
+}
+
+// SetTimeout sets the time ReadPacket waits for a packet. Use 0 as argument to
+// disable timeouts.
+func (r *DCCReceiver) SetTimeout(timeout time.Duration) {
+	r.dl.setTimeout(timeout)
+}
+
+// ReadPacket waits for the next packet with a valid error detection byte.
+func (r *DCCReceiver) ReadPacket() (DCCPacket, error) {
+	dl := r.dl.newDeadline()
+	for {
+		if p, ok := r.poll(); ok {
+			return p, nil
+		}
+		if dl.expired() {
+			return DCCPacket{}, errTimeout
+		}
+		gosched()
+	}
+}
+
+// Run decodes packets and sends them on packets until stop is closed. Packets are
+// dropped if packets is full. It is meant to run in its own goroutine.
+func (r *DCCReceiver) Run(packets chan<- DCCPacket, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if p, ok := r.poll(); ok {
+			select {
+			case packets <- p:
+			default:
+			}
+			continue
+		}
+		gosched()
+	}
+}
+
+// poll decodes the measured half-bits until a packet completes or the FIFO is empty.
+func (r *DCCReceiver) poll() (p DCCPacket, ok bool) {
+	cpufreq := uint64(machine.CPUFrequency())
+	for !r.sm.IsRxFIFOEmpty() {
+		cycles, _ := pulsewidthDecode(r.sm.RxGet())
+		us := cycles * 1_000_000 / cpufreq
+		var half uint8
+		switch {
+		case us >= dccOneMin && us <= dccOneMax:
+			half = 1
+		case us >= dccZeroMin && us <= dccZeroMax:
+			half = 2
+		default:
+			r.half = 0
+			r.preamble = 0 // Noise, wait for the next preamble.
+			continue
+		}
+		if r.half != half {
+			// First half, or a mismatch: pair the halves one later.
+			r.half = half
+			continue
+		}
+		r.half = 0
+		if r.bit(half == 1) {
+			p.Len = r.n - 1
+			copy(p.Data[:], r.packet[:p.Len])
+			return p, true
+		}
+	}
+	return p, false
+}
+
+// bit advances the packet decoder by a bit and returns true when a valid packet ended.
+func (r *DCCReceiver) bit(one bool) bool {
+	if r.preamble >= 0 {
+		switch {
+		case one:
+			r.preamble++
+		case r.preamble >= dccMinPreamble:
+			// Packet start bit.
+			r.preamble, r.bits, r.n = -1, 0, 0
+		default:
+			r.preamble = 0
+		}
+		return false
+	}
+	if r.bits < 8 {
+		r.cur <<= 1
+		if one {
+			r.cur |= 1
+		}
+		r.bits++
+		return false
+	}
+	// Byte complete, this is the data start bit or the packet end bit.
+	if r.n == dccMaxPacket {
+		r.preamble = 0
+		return false
+	}
+	r.packet[r.n] = r.cur
+	r.n++
+	r.bits = 0
+	if !one {
+		return false
+	}
+	// The end bit may count as the first preamble bit of the next packet.
+	r.preamble = 1
+	var xor byte
+	for _, b := range r.packet[:r.n] {
+		xor ^= b
+	}
+	return r.n >= 3 && xor == 0
+}
+
+// Placement returns the state machine and program used by the decoder.
+func (r *DCCReceiver) Placement() Placement {
+	var p Placement
+	p.addSM(r.sm, r.offset, pulsewidthInstructions)
+	return p
+}
@@ -3,7 +3,11 @@
 package piolib
 
 import (
+	"device/arm"
 	"device/rp"
+	"errors"
+	"math"
+	"runtime"
 	"runtime/volatile"
 	"unsafe"
 
@@ -17,6 +21,7 @@ type dmaArbiter struct {
 }
 
 // ClaimChannel returns a DMA channel that can be used for DMA transfers.
+// It is safe to call from interrupt handlers and from either core.
 func (arb *dmaArbiter) ClaimChannel() (channel dmaChannel, ok bool) {
 	for i := uint8(0); i < 12; i++ {
 		ch := arb.Channel(i)
@@ -27,6 +32,18 @@ func (arb *dmaArbiter) ClaimChannel() (channel dmaChannel, ok bool) {
 	return dmaChannel{}, false
 }
 
+// ClaimChannelFor is like ClaimChannel and records owner as the owner of the
+// channel, as listed by DMAClaims. Drivers use their type name.
+func (arb *dmaArbiter) ClaimChannelFor(owner string) (channel dmaChannel, ok bool) {
+	channel, ok = arb.ClaimChannel()
+	if ok {
+		state := claimLock()
+		dmaClaimOwners[channel.idx] = owner
+		claimUnlock(state)
+	}
+	return channel, ok
+}
+
 func (arb *dmaArbiter) Channel(channel uint8) dmaChannel {
 	if channel > 11 {
 		panic("invalid DMA channel")
@@ -48,22 +65,37 @@ type dmaChannel struct {
 }
 
 // TryClaim claims the DMA channel for use by a peripheral and returns if it succeeded in claiming the channel.
+// It is safe to call from interrupt handlers and from either core.
 func (ch dmaChannel) TryClaim() bool {
 	ch.mustValid()
-	if ch.IsClaimed() {
-		return false
+	state := claimLock()
+	claimed := ch.IsClaimed()
+	if !claimed {
+		ch.arb.claimedChannels |= 1 << ch.idx
+		ch.setOwner()
 	}
-	ch.arb.claimedChannels |= 1 << ch.idx
-	return true
+	claimUnlock(state)
+	return !claimed
 }
 
 // Unclaim releases the DMA channel so it can be used by other peripherals.
 // It does not check if the channel is currently claimed; it force-unclaims the channel.
 func (ch dmaChannel) Unclaim() {
 	ch.mustValid()
+	state := claimLock()
 	ch.arb.claimedChannels &^= 1 << ch.idx
+	dmaClaimOwners[ch.idx] = ""
+	ch.clearOwner()
+	claimUnlock(state)
 }
 
+// claimLock makes claim bitmask modifications atomic with respect to interrupts
+// on the current core and to the other core, under the lock of the pio package.
+func claimLock() pio.ClaimState { return pio.ClaimLock() }
+
+// claimUnlock releases the lock taken by claimLock.
+func claimUnlock(state pio.ClaimState) { pio.ClaimUnlock(state) }
+
 // IsClaimed returns true if the DMA channel is currently claimed through software.
 func (ch dmaChannel) IsClaimed() bool {
 	ch.mustValid()
@@ -83,6 +115,7 @@ func (ch dmaChannel) HW() *dmaChannelHW { return ch.hw }
 
 func (ch dmaChannel) Init(cfg dmaChannelConfig) {
 	ch.mustValid()
+	ch.checkOwner()
 	ch.HW().CTRL_TRIG.Set(cfg.CTRL)
 }
 
@@ -100,11 +133,18 @@ func (ch dmaChannel) mustValid() {
 
 // Single DMA channel. See rp.DMA_Type.
 type dmaChannelHW struct {
-	READ_ADDR   volatile.Register32
-	WRITE_ADDR  volatile.Register32
-	TRANS_COUNT volatile.Register32
-	CTRL_TRIG   volatile.Register32
-	_           [12]volatile.Register32 // aliases
+	READ_ADDR            volatile.Register32
+	WRITE_ADDR           volatile.Register32
+	TRANS_COUNT          volatile.Register32
+	CTRL_TRIG            volatile.Register32
+	AL1_CTRL             volatile.Register32    // CTRL alias which does not trigger the channel.
+	_                    [2]volatile.Register32 // aliases
+	AL1_TRANS_COUNT_TRIG volatile.Register32    // TRANS_COUNT alias which triggers the channel.
+	_                    [3]volatile.Register32 // aliases
+	AL2_WRITE_ADDR_TRIG  volatile.Register32    // WRITE_ADDR alias which triggers the channel.
+	_                    [2]volatile.Register32 // aliases
+	AL3_TRANS_COUNT      volatile.Register32    // TRANS_COUNT alias followed by AL3_READ_ADDR_TRIG.
+	AL3_READ_ADDR_TRIG   volatile.Register32    // READ_ADDR alias which triggers the channel.
 }
 
 // Static assignment of DMA channels to peripherals.
@@ -186,46 +226,15 @@ func (ch dmaChannel) Push8(dst *byte, src []byte, dreq uint32) error {
 
 // Push32 writes each element of src slice into the memory location at dst.
 func dmaPush[T uint8 | uint16 | uint32](ch dmaChannel, dst *T, src []T, dreq uint32) error {
-	// If currently busy we wait until safe to edit hardware registers.
-	deadline := ch.dl.newDeadline()
-	for ch.busy() {
-		if deadline.expired() {
-			return errContentionTimeout
-		}
-		gosched()
-	}
-
-	hw := ch.HW()
-	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
-	srcPtr := uint32(uintptr(unsafe.Pointer(&src[0])))
-	dstPtr := uint32(uintptr(unsafe.Pointer(dst)))
-	hw.READ_ADDR.Set(srcPtr)
-	hw.WRITE_ADDR.Set(dstPtr)
-	hw.TRANS_COUNT.Set(uint32(len(src)))
-
-	// memfence
-
-	cc := ch.CurrentConfig()
-	cc.setTREQ_SEL(dreq)
-	cc.setTransferDataSize(dmaSize[T]())
-	cc.setChainTo(ch.idx)
-	cc.setReadIncrement(true)
-	cc.setWriteIncrement(false)
-	cc.setEnable(true)
-
-	// We begin our DMA transfer here!
-	hw.CTRL_TRIG.Set(cc.CTRL)
+	op := dmaStartPush(ch, dst, src, dreq)
+	return op.Wait()
+}
 
-	deadline = ch.dl.newDeadline()
-	for ch.busy() {
-		if deadline.expired() {
-			ch.abort()
-			return errTimeout
-		}
-		gosched()
-	}
-	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
-	return nil
+// dmaPushAddr writes each element of src slice to the bus address dst, such as the
+// TX FIFO address of a state machine.
+func dmaPushAddr[T uint8 | uint16 | uint32](ch dmaChannel, dst uint32, src []T, dreq uint32) error {
+	op := dmaStartPushAddr(ch, dst, src, dreq)
+	return op.Wait()
 }
 
 // Pull32 reads the memory location at src into dst slice, incrementing dst pointer but not src.
@@ -245,47 +254,201 @@ func (ch dmaChannel) Pull8(dst []byte, src *byte, dreq uint32) error {
 
 // Pull32 reads the memory location at src into dst slice, incrementing dst pointer but not src.
 func dmaPull[T uint8 | uint16 | uint32](ch dmaChannel, dst []T, src *T, dreq uint32) error {
+	op := dmaStartPull(ch, dst, src, dreq)
+	return op.Wait()
+}
+
+// dmaPullAddr reads the bus address src, such as the RX FIFO address of a state
+// machine, into dst slice.
+func dmaPullAddr[T uint8 | uint16 | uint32](ch dmaChannel, dst []T, src uint32, dreq uint32) error {
+	op := dmaStartPullAddr(ch, dst, src, dreq)
+	return op.Wait()
+}
+
+// PushVector32 writes the elements of each slice in bufs in order into the memory
+// location at dst, gathering a frame from several buffers without copying them.
+// A second DMA channel is claimed for the duration of the transfer to feed the
+// buffer addresses and lengths to ch as control blocks.
+func (ch dmaChannel) PushVector32(dst *uint32, bufs [][]uint32, dreq uint32) error {
+	ch.checkOwner()
 	// If currently busy we wait until safe to edit hardware registers.
 	deadline := ch.dl.newDeadline()
 	for ch.busy() {
 		if deadline.expired() {
-			return errContentionTimeout
+			return ch.fail(DMATimeout, errContentionTimeout)
 		}
 		gosched()
 	}
+	dstPtr, err := dmaAddr(unsafe.Pointer(dst), unsafe.Sizeof(*dst), true)
+	if err != nil {
+		return ch.fail(DMAError, err)
+	}
+	// Control blocks of transfer count and read address, ended by a null trigger.
+	blocks := make([]uint32, 0, 2*len(bufs)+2)
+	for _, buf := range bufs {
+		if len(buf) == 0 {
+			continue
+		}
+		srcPtr, err := dmaAddr(unsafe.Pointer(&buf[0]), uintptr(len(buf))*4, false)
+		if err != nil {
+			return ch.fail(DMAError, err)
+		}
+		blocks = append(blocks, uint32(len(buf)), srcPtr)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	blocks = append(blocks, 0, 0)
+	ctrl, ok := _DMA.ClaimChannelFor("PushVector32")
+	if !ok {
+		return errDMAUnavail
+	}
+	defer ctrl.Unclaim()
 
 	hw := ch.HW()
 	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
-	srcPtr := uint32(uintptr(unsafe.Pointer(src)))
-	dstPtr := uint32(uintptr(unsafe.Pointer(&dst[0])))
-	hw.READ_ADDR.Set(srcPtr)
 	hw.WRITE_ADDR.Set(dstPtr)
-	hw.TRANS_COUNT.Set(uint32(len(dst)))
-
-	// memfence
-
-	cc := ch.CurrentConfig()
-	cc.setTREQ_SEL(dreq)
-	cc.setTransferDataSize(dmaSize[T]())
-	cc.setChainTo(ch.idx)
-	cc.setReadIncrement(false)
+	cc := dmaStreamTx(ch, dmaTxSize32, dreq)
+	cc.setChainTo(ctrl.idx)
+	hw.AL1_CTRL.Set(cc.CTRL) // Configure without triggering.
+
+	// Each control block is written to AL3_TRANS_COUNT and AL3_READ_ADDR_TRIG,
+	// the 8 byte write ring wraps back to AL3_TRANS_COUNT for the next block.
+	ctrlHW := ctrl.HW()
+	ctrlHW.READ_ADDR.Set(ptrAs(&blocks[0]))
+	ctrlHW.WRITE_ADDR.Set(ptrAs(&hw.AL3_TRANS_COUNT.Reg))
+	ctrlHW.TRANS_COUNT.Set(2)
+	cc = dmaDefaultConfig(ctrl.idx)
 	cc.setWriteIncrement(true)
+	cc.setRing(true, 3)
 	cc.setEnable(true)
+	dmaFence()
+	ch.record(DMAStarted, nil)
+	ctrlHW.CTRL_TRIG.Set(cc.CTRL)
 
-	// We begin our DMA transfer here!
-	hw.CTRL_TRIG.Set(cc.CTRL)
-
+	// Done once the null trigger has been loaded and both channels stopped.
+	end := ptrAs(&blocks[len(blocks)-1]) + 4
 	deadline = ch.dl.newDeadline()
-	for ch.busy() {
+	for ctrlHW.READ_ADDR.Get() != end || ctrl.busy() || ch.busy() {
 		if deadline.expired() {
+			ctrl.abort()
 			ch.abort()
-			return errTimeout
+			return ch.fail(DMATimeout, errTimeout)
 		}
 		gosched()
 	}
+	runtime.KeepAlive(blocks) // Read by DMA until here.
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	ch.record(DMACompleted, nil)
 	return nil
 }
 
+// pushLoop32 starts an endless transfer writing src to dst over and over without CPU
+// intervention. ch performs the data transfer and chains to ctrl, which rewinds ch's
+// read address to the value stored at srcAddr and retriggers ch.
+// srcAddr must hold the address of src[0] and both must remain valid while looping.
+func (ch dmaChannel) pushLoop32(ctrl dmaChannel, dst *uint32, src []uint32, srcAddr *uint32, dreq uint32) {
+	ch.checkOwner()
+	ctrlHW := ctrl.HW()
+	ctrlHW.READ_ADDR.Set(ptrAs(srcAddr))
+	ctrlHW.WRITE_ADDR.Set(ptrAs(&ch.HW().AL3_READ_ADDR_TRIG.Reg))
+	ctrlHW.TRANS_COUNT.Set(1)
+	ctrlHW.AL1_CTRL.Set(dmaControl(ctrl).CTRL) // Configure without triggering.
+
+	hw := ch.HW()
+	hw.READ_ADDR.Set(ptrAs(&src[0]))
+	hw.WRITE_ADDR.Set(ptrAs(dst))
+	hw.TRANS_COUNT.Set(uint32(len(src))) // Reloaded on every trigger.
+	cc := dmaStreamTx(ch, dmaTxSize32, dreq)
+	cc.setChainTo(ctrl.idx)
+	dmaFence()
+	ch.record(DMAStarted, nil)
+	hw.CTRL_TRIG.Set(cc.CTRL)
+}
+
+// pullLoop32 starts an endless transfer reading src into dst over and over without CPU
+// intervention, making dst a ring buffer. ch performs the data transfer and chains to
+// ctrl, which rewinds ch's write address to the value stored at dstAddr and retriggers ch.
+// dstAddr must hold the address of dst[0] and both must remain valid while looping.
+func (ch dmaChannel) pullLoop32(ctrl dmaChannel, dst []uint32, dstAddr *uint32, src *uint32, dreq uint32) {
+	ch.checkOwner()
+	ctrlHW := ctrl.HW()
+	ctrlHW.READ_ADDR.Set(ptrAs(dstAddr))
+	ctrlHW.WRITE_ADDR.Set(ptrAs(&ch.HW().AL2_WRITE_ADDR_TRIG.Reg))
+	ctrlHW.TRANS_COUNT.Set(1)
+	ctrlHW.AL1_CTRL.Set(dmaControl(ctrl).CTRL) // Configure without triggering.
+
+	hw := ch.HW()
+	hw.READ_ADDR.Set(ptrAs(src))
+	hw.WRITE_ADDR.Set(ptrAs(&dst[0]))
+	hw.TRANS_COUNT.Set(uint32(len(dst))) // Reloaded on every trigger.
+	cc := dmaStreamRx(ch, dmaTxSize32, dreq)
+	cc.setChainTo(ctrl.idx)
+	dmaFence()
+	ch.record(DMAStarted, nil)
+	hw.CTRL_TRIG.Set(cc.CTRL)
+}
+
+var (
+	errDMAReadAddr  = errors.New("piolib:DMA read address not in DMA accessible memory")
+	errDMAWriteAddr = errors.New("piolib:DMA write address not in DMA writable memory")
+)
+
+// dmaRegion is a range of the RP2040 address map accessible by the DMA.
+type dmaRegion struct {
+	start, end uint32
+	writable   bool
+}
+
+// dmaRegions lists the bus regions the DMA can access. Notably the SIO and the Cortex-M0+
+// private peripherals are core-local and can't be reached by the DMA.
+var dmaRegions = [...]dmaRegion{
+	{start: 0x0000_0000, end: 0x0000_4000},                 // Boot ROM.
+	{start: 0x1000_0000, end: 0x1400_0000},                 // XIP flash. Cache misses stall the DMA for a long time.
+	{start: 0x1500_0000, end: 0x1500_4000, writable: true}, // XIP cache as SRAM, only if cache is disabled.
+	{start: 0x2000_0000, end: 0x2004_2000, writable: true}, // Striped SRAM0-3 and SRAM4-5.
+	{start: 0x2100_0000, end: 0x2104_0000, writable: true}, // Non-striped SRAM0-3.
+	{start: 0x4000_0000, end: 0x4007_0000, writable: true}, // APB peripherals.
+	{start: 0x5000_0000, end: 0x5050_0000, writable: true}, // AHB-Lite peripherals (DMA, USB, PIO, XIP aux).
+}
+
+// dmaAddr returns the 32 bit bus address of ptr after checking the size bytes starting
+// at ptr are within a single memory region the DMA can read, or write if write is true.
+// This catches pointers that would be silently truncated or would make the DMA
+// corrupt memory or raise a bus error instead of transferring data.
+func dmaAddr(ptr unsafe.Pointer, size uintptr, write bool) (uint32, error) {
+	err := errDMAReadAddr
+	if write {
+		err = errDMAWriteAddr
+	}
+	addr := uint64(uintptr(ptr))
+	if addr+uint64(size) > math.MaxUint32 {
+		return 0, err
+	}
+	start, end := uint32(addr), uint32(addr)+uint32(size)
+	for _, r := range dmaRegions {
+		if start >= r.start && end <= r.end {
+			if write && !r.writable {
+				return 0, err
+			}
+			return start, nil
+		}
+	}
+	return 0, err
+}
+
+// dmaFence is a data memory barrier: memory accesses before it complete before
+// those after it. Call it between filling a buffer and triggering the DMA channel
+// reading it, and between seeing a channel finish and reading the buffer it wrote.
+// Volatile register accesses are not reordered by the compiler, but normal memory
+// accesses may be reordered around them; the barrier instruction also keeps the
+// core from doing so on cores with write buffers, such as the Cortex-M33.
+//
+//go:inline
+func dmaFence() {
+	arm.Asm("dmb")
+}
+
 func dmaSize[T uint8 | uint16 | uint32]() dmaTxSize {
 	var a T
 	switch unsafe.Sizeof(a) {
@@ -302,24 +465,96 @@ func dmaSize[T uint8 | uint16 | uint32]() dmaTxSize {
 
 // abort aborts the current transfer sequence on the channel and blocks until
 // all in-flight transfers have been flushed through the address and data FIFOs.
-// After this, it is safe to restart the channel.
+// After this, it is safe to restart the channel. Channels chained to or from
+// the channel are aborted too, otherwise an abort could trigger them.
 func (ch dmaChannel) abort() {
+	chMask := uint32(1 << ch.idx)
+	for i := uint8(0); i < 12; i++ {
+		other := ch.arb.Channel(i)
+		enabled := other.HW().CTRL_TRIG.Get()&rp.DMA_CH0_CTRL_TRIG_EN_Msk != 0
+		if enabled && other.chainTo() == ch.idx || ch.chainTo() == i {
+			chMask |= 1 << i
+		}
+	}
+	if !dmaAbort(chMask, ch.dl.newDeadline()) {
+		println("DMA abort timeout")
+	}
+}
+
+// chainTo returns the channel triggered on completion of ch, which is ch itself if not chained.
+func (ch dmaChannel) chainTo() uint8 {
+	return uint8((ch.HW().CTRL_TRIG.Get() & rp.DMA_CH0_CTRL_TRIG_CHAIN_TO_Msk) >> rp.DMA_CH0_CTRL_TRIG_CHAIN_TO_Pos)
+}
+
+// dmaAbort aborts the transfer sequences of the channels in chMask and blocks until
+// they are idle or the deadline expires, returning false in that case.
+//
+// Per RP2040-E13 the abort bit of a channel with in-flight transfers clears before
+// they complete, which then raise the completion interrupt. Interrupts of the
+// channels are disabled during the abort and the spurious ones cleared afterwards.
+func dmaAbort(chMask uint32, deadline deadline) bool {
+	inte0 := rp.DMA.INTE0.Get() & chMask
+	inte1 := rp.DMA.INTE1.Get() & chMask
+	rp.DMA.INTE0.ClearBits(chMask)
+	rp.DMA.INTE1.ClearBits(chMask)
 	// Each bit corresponds to a channel. Writing a 1 aborts whatever transfer
 	// sequence is in progress on that channel. The bit will remain high until
 	// any in-flight transfers have been flushed through the address and data FIFOs.
 	// After writing, this register must be polled until it returns all-zero.
 	// Until this point, it is unsafe to restart the channel.
-	chMask := uint32(1 << ch.idx)
 	rp.DMA.CHAN_ABORT.Set(chMask)
-
-	deadline := ch.dl.newDeadline()
-	for rp.DMA.CHAN_ABORT.Get()&chMask != 0 {
-		if deadline.expired() {
-			println("DMA abort timeout")
-			break
+	for i := uint8(0); i < 12; i++ {
+		if chMask&(1<<i) != 0 {
+			_DMA.Channel(i).record(DMAAborted, nil)
 		}
+	}
+	ok := true
+	for ok && (rp.DMA.CHAN_ABORT.Get()&chMask != 0 || dmaBusyMask()&chMask != 0) {
+		ok = !deadline.expired()
 		gosched()
 	}
+	rp.DMA.INTR.Set(chMask) // Writing 1 clears the raw interrupt flags.
+	rp.DMA.INTE0.SetBits(inte0)
+	rp.DMA.INTE1.SetBits(inte1)
+	return ok
+}
+
+// stopStreaming tears down a state machine fed or drained by DMA channels in the
+// one order that can't leave the FIFO/DREQ handshake wedged:
+//  1. The state machine is paused so it stops consuming and producing FIFO words,
+//     freezing the DREQ level seen by the channels.
+//  2. The channels, and those chained to them, are aborted, flushing in-flight
+//     transfers. Aborting first could let the running state machine raise a DREQ
+//     for a transfer that never comes and stall mid-word on resume.
+//  3. The FIFOs are cleared, dropping the words left by the aborted transfers.
+//  4. The channels are disabled in CTRL_TRIG so a DREQ counted before the stop
+//     can't start the next transfer early.
+//
+// The state machine is left disabled at its current instruction, callers restart it
+// as needed. Invalid channels are skipped.
+func stopStreaming(sm pio.StateMachine, chs ...dmaChannel) {
+	sm.SetEnabled(false)
+	for _, ch := range chs {
+		if ch.IsValid() {
+			ch.abort()
+		}
+	}
+	sm.ClearFIFOs()
+	for _, ch := range chs {
+		if ch.IsValid() {
+			ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+		}
+	}
+}
+
+// dmaBusyMask returns a mask of the channels with a transfer in progress.
+func dmaBusyMask() (mask uint32) {
+	for i := uint8(0); i < 12; i++ {
+		if _DMA.Channel(i).busy() {
+			mask |= 1 << i
+		}
+	}
+	return mask
 }
 
 func (ch dmaChannel) busy() bool {
@@ -423,3 +658,37 @@ func setBitPos(cc *uint32, pos uint32, bit bool) {
 func ptrAs[T ~uint32](ptr *T) uint32 {
 	return uint32(uintptr(unsafe.Pointer(ptr)))
 }
+
+// lookupLoop32 starts an endless table lookup without CPU intervention. ch reads
+// addresses from src and writes them to lookup's read address, triggering lookup to
+// copy the word at that address to dst. srcDREQ paces reading addresses and dstDREQ
+// paces writing to dst. ch stops after 2^32-1 addresses, see lookupCount.
+func (ch dmaChannel) lookupLoop32(lookup dmaChannel, dst, src *uint32, initAddr, srcDREQ, dstDREQ uint32) {
+	ch.checkOwner()
+	lookupHW := lookup.HW()
+	lookupHW.READ_ADDR.Set(initAddr)
+	lookupHW.WRITE_ADDR.Set(ptrAs(dst))
+	lookupHW.TRANS_COUNT.Set(1) // Reloaded on every trigger.
+	cc := dmaDefaultConfig(lookup.idx)
+	cc.setTREQ_SEL(dstDREQ)
+	cc.setReadIncrement(false)
+	cc.setEnable(true)
+	lookupHW.AL1_CTRL.Set(cc.CTRL) // Configure without triggering.
+
+	hw := ch.HW()
+	hw.READ_ADDR.Set(ptrAs(src))
+	hw.WRITE_ADDR.Set(ptrAs(&lookupHW.AL3_READ_ADDR_TRIG.Reg))
+	hw.TRANS_COUNT.Set(math.MaxUint32)
+	cc = dmaDefaultConfig(ch.idx)
+	cc.setTREQ_SEL(srcDREQ)
+	cc.setReadIncrement(false)
+	cc.setEnable(true)
+	dmaFence()
+	ch.record(DMAStarted, nil)
+	hw.CTRL_TRIG.Set(cc.CTRL)
+}
+
+// lookupCount returns the number of lookups performed since lookupLoop32 was called on ch.
+func (ch dmaChannel) lookupCount() uint32 {
+	return math.MaxUint32 - ch.HW().TRANS_COUNT.Get()
+}
@@ -0,0 +1,9 @@
+//go:build rp2040 && !piodebug
+
+package piolib
+
+// Ownership tracking is only done in builds with the piodebug tag, see dma_owner_debug.go.
+
+func (ch dmaChannel) setOwner()   {}
+func (ch dmaChannel) clearOwner() {}
+func (ch dmaChannel) checkOwner() {}
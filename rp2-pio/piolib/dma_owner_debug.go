@@ -0,0 +1,25 @@
+//go:build rp2040 && piodebug
+
+package piolib
+
+import pio "github.com/tinygo-org/pio/rp2-pio"
+
+// Building with the piodebug tag records the core which claimed each DMA channel
+// and panics when a claimed channel is configured or started from the other core.
+
+// dmaOwner holds the claiming core plus one for each DMA channel, or 0 if unowned.
+var dmaOwner [12]uint8
+
+// setOwner records the current core as owner of ch. Must be called with the claim lock held.
+func (ch dmaChannel) setOwner() { dmaOwner[ch.idx] = pio.CurrentCore() + 1 }
+
+// clearOwner forgets the owner of ch. Must be called with the claim lock held.
+func (ch dmaChannel) clearOwner() { dmaOwner[ch.idx] = 0 }
+
+// checkOwner panics if ch was claimed by a core other than the current one.
+func (ch dmaChannel) checkOwner() {
+	owner := dmaOwner[ch.idx]
+	if owner != 0 && owner-1 != pio.CurrentCore() {
+		panic("piolib: DMA channel claimed on core " + string('0'+rune(owner-1)) + " used from core " + string('0'+rune(pio.CurrentCore())))
+	}
+}
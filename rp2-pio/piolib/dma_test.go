@@ -0,0 +1,91 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"device/rp"
+	"testing"
+)
+
+// The DMA tests run on the device with tinygo test -target=pico. They transfer
+// between memory buffers paced by the permanent DREQ, so the buffers are written
+// by the CPU right before each transfer starts and read right after it completes,
+// which is where missing fences show stale data.
+
+const dmaTestWords = 256
+
+var dmaTestSrc, dmaTestDst [dmaTestWords]uint32
+
+func claimTestChannel(tb testing.TB) dmaChannel {
+	ch, ok := _DMA.ClaimChannelFor("test")
+	if !ok {
+		tb.Fatal(errDMAUnavail)
+	}
+	tb.Cleanup(ch.Unclaim)
+	return ch
+}
+
+func TestDMAPushFence(t *testing.T) {
+	ch := claimTestChannel(t)
+	for i := uint32(1); i <= 1000; i++ {
+		for j := range dmaTestSrc {
+			dmaTestSrc[j] = i<<16 | uint32(j)
+		}
+		// Without increment the destination word ends with the last element.
+		if err := dmaPush(ch, &dmaTestDst[0], dmaTestSrc[:], rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_PERMANENT); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dmaTestDst[0], dmaTestSrc[dmaTestWords-1]; got != want {
+			t.Fatalf("iteration %d: got %#x, want %#x", i, got, want)
+		}
+	}
+}
+
+func TestDMAPullFence(t *testing.T) {
+	ch := claimTestChannel(t)
+	for i := uint32(1); i <= 1000; i++ {
+		dmaTestSrc[0] = i
+		if err := dmaPull(ch, dmaTestDst[:], &dmaTestSrc[0], rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_PERMANENT); err != nil {
+			t.Fatal(err)
+		}
+		for j, got := range dmaTestDst {
+			if got != i {
+				t.Fatalf("iteration %d: word %d is %#x", i, j, got)
+			}
+		}
+	}
+}
+
+func TestDMASelfTest(t *testing.T) {
+	report := DMASelfTest()
+	if !report.OK() {
+		t.Fatal(report.String())
+	}
+}
+
+func BenchmarkDMAPush32(b *testing.B) {
+	ch := claimTestChannel(b)
+	b.SetBytes(4 * dmaTestWords)
+	for i := 0; i < b.N; i++ {
+		if err := dmaPush(ch, &dmaTestDst[0], dmaTestSrc[:], rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_PERMANENT); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDMAPull32(b *testing.B) {
+	ch := claimTestChannel(b)
+	b.SetBytes(4 * dmaTestWords)
+	for i := 0; i < b.N; i++ {
+		if err := dmaPull(ch, dmaTestDst[:], &dmaTestSrc[0], rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_PERMANENT); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDMAFence measures the cost of the barrier added to each transfer.
+func BenchmarkDMAFence(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		dmaFence()
+	}
+}
@@ -0,0 +1,54 @@
+//go:build rp2040
+
+package piolib
+
+// dmaClaimOwners holds the owner of each claimed DMA channel, empty if claimed
+// without one. Guarded by the claim lock.
+var dmaClaimOwners [12]string
+
+// DMAClaim is a DMA channel claimed through piolib, see DMAClaims.
+type DMAClaim struct {
+	Channel uint8
+	// Owner is the type of the driver using the channel, empty if unknown.
+	Owner string
+}
+
+// DMAClaims returns the DMA channels claimed by piolib drivers and their owners,
+// to find which drivers hold the channels when no more are available.
+// Channels used by other packages are not listed.
+func DMAClaims() []DMAClaim {
+	var claims []DMAClaim
+	state := claimLock()
+	for i := uint8(0); i < 12; i++ {
+		if _DMA.claimedChannels&(1<<i) != 0 {
+			claims = append(claims, DMAClaim{Channel: i, Owner: dmaClaimOwners[i]})
+		}
+	}
+	claimUnlock(state)
+	return claims
+}
+
+// SetDMAClaimOwner renames the owner of claimed DMA channel ch, i.e. to tell
+// apart instances of a driver such as "ws2812-strip1", using the channels listed
+// by the driver Placement. It does nothing if ch is not claimed.
+func SetDMAClaimOwner(ch uint8, owner string) {
+	state := claimLock()
+	if ch < 12 && _DMA.claimedChannels&(1<<ch) != 0 {
+		dmaClaimOwners[ch] = owner
+	}
+	claimUnlock(state)
+}
+
+// ClaimDMAChannelFor claims a free DMA channel for use outside of piolib drivers,
+// such as DMA transfers of the application, and records owner as its owner in
+// DMAClaims. Drivers created afterwards won't use the channel. ok is false if all
+// channels are claimed.
+func ClaimDMAChannelFor(owner string) (ch uint8, ok bool) {
+	channel, ok := _DMA.ClaimChannelFor(owner)
+	return channel.idx, ok
+}
+
+// UnclaimDMAChannel releases channel ch claimed with ClaimDMAChannelFor.
+func UnclaimDMAChannel(ch uint8) {
+	_DMA.Channel(ch).Unclaim()
+}
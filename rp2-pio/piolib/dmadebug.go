@@ -0,0 +1,86 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"device/rp"
+	"strconv"
+	"time"
+)
+
+// Time DMAAbortAll waits for aborted channels to become idle.
+const dmaAbortAllTimeout = 10 * time.Millisecond
+
+// DMAChannelState is a snapshot of the registers of a DMA channel for debugging.
+type DMAChannelState struct {
+	Channel uint8
+	// Claimed is true if the channel is claimed by a piolib driver.
+	Claimed    bool
+	Ctrl       uint32
+	ReadAddr   uint32
+	WriteAddr  uint32
+	TransCount uint32
+}
+
+// Busy returns true if the channel had a transfer in progress.
+func (s DMAChannelState) Busy() bool {
+	return s.Ctrl&rp.DMA_CH0_CTRL_TRIG_BUSY != 0
+}
+
+// String returns the state in a single line, i.e:
+//
+//	ch3 claimed ctrl=0x00201c3d read=0x20001a40 write=0x50200010 count=12 busy
+func (s DMAChannelState) String() string {
+	b := append([]byte("ch"), strconv.Itoa(int(s.Channel))...)
+	if s.Claimed {
+		b = append(b, " claimed"...)
+	}
+	b = appendHex32(append(b, " ctrl="...), s.Ctrl)
+	b = appendHex32(append(b, " read="...), s.ReadAddr)
+	b = appendHex32(append(b, " write="...), s.WriteAddr)
+	b = strconv.AppendUint(append(b, " count="...), uint64(s.TransCount), 10)
+	if s.Busy() {
+		b = append(b, " busy"...)
+	}
+	return string(b)
+}
+
+// DMADump returns a snapshot of all DMA channels, for debugging stuck transfers.
+func DMADump() (states [12]DMAChannelState) {
+	for i := range states {
+		ch := _DMA.Channel(uint8(i))
+		hw := ch.HW()
+		states[i] = DMAChannelState{
+			Channel:    uint8(i),
+			Claimed:    ch.IsClaimed(),
+			Ctrl:       hw.CTRL_TRIG.Get(),
+			ReadAddr:   hw.READ_ADDR.Get(),
+			WriteAddr:  hw.WRITE_ADDR.Get(),
+			TransCount: hw.TRANS_COUNT.Get(),
+		}
+	}
+	return states
+}
+
+// DMAAbortAll aborts the transfers of all DMA channels, or only of those claimed by
+// piolib drivers if claimedOnly is set, to recover a stuck system. Channels are
+// aborted together so that no aborted channel triggers another through chaining.
+// It returns false if the channels did not become idle.
+func DMAAbortAll(claimedOnly bool) bool {
+	chMask := uint32(1<<12 - 1)
+	if claimedOnly {
+		state := claimLock()
+		chMask = uint32(_DMA.claimedChannels)
+		claimUnlock(state)
+	}
+	return dmaAbort(chMask, deadline{t: time.Now().Add(dmaAbortAllTimeout)})
+}
+
+func appendHex32(b []byte, v uint32) []byte {
+	const digits = "0123456789abcdef"
+	b = append(b, "0x"...)
+	for shift := 28; shift >= 0; shift -= 4 {
+		b = append(b, digits[v>>uint(shift)&0xf])
+	}
+	return b
+}
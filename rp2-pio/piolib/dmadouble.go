@@ -0,0 +1,177 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"math/bits"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errDoubleBufferLength = errors.New("piolib:double buffers must have the same, non zero length")
+
+// dmaIRQHandlers are called from the DMA_IRQ_1 interrupt for the channels that
+// completed. DMA_IRQ_0 is left to the machine package.
+var (
+	dmaIRQHandlers [12]func()
+	dmaIRQ         interrupt.Interrupt
+	dmaIRQEnabled  bool
+)
+
+// dmaSetIRQHandler sets the function called from an interrupt each time ch
+// completes, nil to disable the interrupt of ch.
+func dmaSetIRQHandler(ch dmaChannel, handler func()) {
+	state := interrupt.Disable()
+	dmaIRQHandlers[ch.idx] = handler
+	if !dmaIRQEnabled {
+		dmaIRQ = interrupt.New(rp.IRQ_DMA_IRQ_1, dmaIRQHandler)
+		dmaIRQ.Enable()
+		dmaIRQEnabled = true
+	}
+	mask := uint32(1) << ch.idx
+	if handler != nil {
+		rp.DMA.INTR.Set(mask) // Writing 1 clears the raw interrupt flag.
+		rp.DMA.INTE1.SetBits(mask)
+	} else {
+		rp.DMA.INTE1.ClearBits(mask)
+	}
+	interrupt.Restore(state)
+}
+
+func dmaIRQHandler(interrupt.Interrupt) {
+	ints := rp.DMA.INTS1.Get()
+	rp.DMA.INTS1.Set(ints) // Writing 1 clears the interrupt.
+	for ints != 0 {
+		i := bits.TrailingZeros32(ints)
+		ints &^= 1 << i
+		if handler := dmaIRQHandlers[i]; handler != nil {
+			handler()
+		}
+	}
+}
+
+// DMADoubleBuffer streams two buffers in turn to the TX FIFO of a state machine
+// without gaps, for audio, video and LED frames: while one buffer is transferred
+// the other is refilled. Two DMA channels are claimed, each writing one of the
+// buffers and chaining to the other when done, and the completion interrupt of
+// each calls the fill function with the buffer just freed.
+//
+// fill runs in an interrupt and must refill the buffer before the other one is
+// transferred. If it doesn't, the buffer is sent again, partly stale, and the
+// underrun is counted.
+type DMADoubleBuffer[T pio.Word] struct {
+	sm        pio.StateMachine
+	ch        [2]dmaChannel
+	buf       [2][]T
+	addr      [2]uint32
+	fill      func(buf []T)
+	underruns volatile.Register32
+	running   bool
+}
+
+// NewDMADoubleBuffer returns a double buffer streaming bufA and bufB, of the same
+// length, to the TX FIFO of sm, refilled by fill. The buffers must remain valid
+// until the double buffer is stopped.
+func NewDMADoubleBuffer[T pio.Word](sm pio.StateMachine, bufA, bufB []T, fill func(buf []T)) (*DMADoubleBuffer[T], error) {
+	if len(bufA) == 0 || len(bufA) != len(bufB) {
+		return nil, errDoubleBufferLength
+	}
+	d := &DMADoubleBuffer[T]{sm: sm, buf: [2][]T{bufA, bufB}, fill: fill}
+	for i, buf := range d.buf {
+		addr, err := dmaAddr(unsafe.Pointer(&buf[0]), uintptr(len(buf))*unsafe.Sizeof(buf[0]), false)
+		if err != nil {
+			return nil, err
+		}
+		d.addr[i] = addr
+	}
+	for i := range d.ch {
+		ch, ok := _DMA.ClaimChannelFor("DMADoubleBuffer")
+		if !ok {
+			if i > 0 {
+				d.ch[0].Unclaim()
+			}
+			return nil, errDMAUnavail
+		}
+		d.ch[i] = ch
+	}
+	return d, nil
+}
+
+// Start starts streaming with the first buffer, both buffers being sent as they
+// are. Fill them beforehand.
+func (d *DMADoubleBuffer[T]) Start() {
+	if d.running {
+		return
+	}
+	dst := d.sm.TxRegAddr()
+	dreq := dmaPIO_TxDREQ(d.sm)
+	for i, ch := range d.ch {
+		ch.checkOwner()
+		hw := ch.HW()
+		hw.READ_ADDR.Set(d.addr[i])
+		hw.WRITE_ADDR.Set(dst)
+		hw.TRANS_COUNT.Set(uint32(len(d.buf[i]))) // Reloaded on every trigger.
+		cc := dmaStreamTx(ch, dmaSize[T](), dreq)
+		cc.setChainTo(d.ch[1-i].idx)
+		hw.AL1_CTRL.Set(cc.CTRL) // Configure without triggering.
+		i := i
+		dmaSetIRQHandler(ch, func() { d.complete(i) })
+	}
+	d.running = true
+	dmaFence()
+	d.ch[0].record(DMAStarted, nil)
+	rp.DMA.MULTI_CHAN_TRIGGER.Set(1 << d.ch[0].idx)
+}
+
+// complete is called from the interrupt when channel i finished its buffer, the
+// other channel having started the other buffer.
+func (d *DMADoubleBuffer[T]) complete(i int) {
+	ch := d.ch[i]
+	// Rewind before the other channel chains back to ch.
+	ch.HW().READ_ADDR.Set(d.addr[i])
+	ch.record(DMACompleted, nil)
+	if d.fill != nil {
+		d.fill(d.buf[i])
+	}
+	dmaFence()
+	// ch was triggered again while fill ran: it sent the buffer before it was refilled.
+	if ch.busy() || rp.DMA.INTR.Get()&(1<<ch.idx) != 0 {
+		d.underruns.Set(d.underruns.Get() + 1)
+	}
+}
+
+// Underruns returns the number of buffers sent before fill refilled them.
+func (d *DMADoubleBuffer[T]) Underruns() uint32 {
+	return d.underruns.Get()
+}
+
+// Stop stops streaming. Words already in the TX FIFO are left to the state
+// machine.
+func (d *DMADoubleBuffer[T]) Stop() {
+	if !d.running {
+		return
+	}
+	for _, ch := range d.ch {
+		dmaSetIRQHandler(ch, nil)
+	}
+	// Aborting a channel aborts the one chained to it too.
+	d.ch[0].abort()
+	for _, ch := range d.ch {
+		ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	}
+	d.running = false
+}
+
+// Close stops streaming and releases the DMA channels.
+func (d *DMADoubleBuffer[T]) Close() error {
+	d.Stop()
+	for _, ch := range d.ch {
+		ch.Unclaim()
+	}
+	return nil
+}
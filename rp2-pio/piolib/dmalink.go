@@ -0,0 +1,106 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"time"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errStreamLinkLength = errors.New("piolib:linked stream writes differ in length")
+
+// StreamLink writes two DMA streams in lockstep, for drivers whose state machines
+// must consume parallel data together, such as pixel and sync streams of video or
+// left and right channels of audio on separate state machines.
+//
+// Guarantees: both channels are configured before either starts and are started
+// in the same bus cycle by a single write to the DMA multi channel trigger, and
+// each Write returns once both transfers completed, so the streams never drift by
+// more than the words of one Write. Each word is still paced by the DREQ of its own
+// state machine: for word by word lockstep the state machines must run at the same
+// clock, see pio.StartSynchronized, and consume equal lengths. Words waiting in the
+// FIFOs when Write returns run out at the same rate, so writes should hold whole
+// units, i.e. a line or a frame, and follow each other closely.
+type StreamLink[T pio.Word] struct {
+	a, b *DMATxStream[T]
+	dl   deadliner
+}
+
+// LinkStreams returns a link writing a and b together. The streams are still
+// usable on their own but must not be written while a linked Write is running.
+func LinkStreams[T pio.Word](a, b *DMATxStream[T]) *StreamLink[T] {
+	return &StreamLink[T]{a: a, b: b}
+}
+
+// SetTimeout sets the timeout of linked writes. Use 0 as argument to disable timeouts.
+func (l *StreamLink[T]) SetTimeout(timeout time.Duration) {
+	l.dl.setTimeout(timeout)
+}
+
+// Write writes pa to the first stream and pb to the second, which must be of equal
+// length, blocking until the last words of both are in the FIFOs.
+func (l *StreamLink[T]) Write(pa, pb []T) error {
+	if len(pa) != len(pb) {
+		return errStreamLinkLength
+	}
+	if len(pa) == 0 {
+		return nil
+	}
+	a, b := l.a.dma, l.b.dma
+	a.checkOwner()
+	b.checkOwner()
+	deadline := l.dl.newDeadline()
+	for a.busy() || b.busy() {
+		if deadline.expired() {
+			return a.fail(DMATimeout, errContentionTimeout)
+		}
+		gosched()
+	}
+	if err := dmaArmPush(a, l.a.sm.TxRegAddr(), pa, dmaPIO_TxDREQ(l.a.sm)); err != nil {
+		return err
+	}
+	if err := dmaArmPush(b, l.b.sm.TxRegAddr(), pb, dmaPIO_TxDREQ(l.b.sm)); err != nil {
+		return err
+	}
+	// Make src contents written by the CPU visible to the DMA before triggering.
+	dmaFence()
+	a.record(DMAStarted, nil)
+	b.record(DMAStarted, nil)
+	rp.DMA.MULTI_CHAN_TRIGGER.Set(1<<a.idx | 1<<b.idx)
+
+	deadline = l.dl.newDeadline()
+	for a.busy() || b.busy() {
+		if deadline.expired() {
+			a.abort()
+			b.abort()
+			b.fail(DMATimeout, errTimeout)
+			return a.fail(DMATimeout, errTimeout)
+		}
+		gosched()
+	}
+	a.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	b.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	a.record(DMACompleted, nil)
+	b.record(DMACompleted, nil)
+	return nil
+}
+
+// dmaArmPush configures ch to write src to the bus address dstPtr like dmaPush,
+// without triggering it.
+func dmaArmPush[T uint8 | uint16 | uint32](ch dmaChannel, dstPtr uint32, src []T, dreq uint32) error {
+	srcPtr, err := dmaAddr(unsafe.Pointer(&src[0]), uintptr(len(src))*unsafe.Sizeof(src[0]), false)
+	if err != nil {
+		return ch.fail(DMAError, err)
+	}
+	hw := ch.HW()
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	hw.READ_ADDR.Set(srcPtr)
+	hw.WRITE_ADDR.Set(dstPtr)
+	hw.TRANS_COUNT.Set(uint32(len(src)))
+	hw.AL1_CTRL.Set(dmaStreamTx(ch, dmaSize[T](), dreq).CTRL) // Configure without triggering.
+	return nil
+}
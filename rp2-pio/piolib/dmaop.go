@@ -0,0 +1,223 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"device/rp"
+	"unsafe"
+)
+
+// DMAOp is a DMA transfer started by a driver and running in the background, such
+// as returned by DMATxStream.StartWrite. It completes, times out or is canceled
+// through the same path: the blocking methods of the drivers are a start followed
+// by Wait.
+//
+// The buffer of the operation must not be used until it is done.
+type DMAOp struct {
+	ch dmaChannel
+	dl deadline
+	// stop tears down the transfer on timeout or cancellation, ch.abort if nil.
+	stop   func()
+	total  uint32
+	remain uint32
+	err    error
+	done   bool
+}
+
+// dmaStartPush starts writing src into dst by DMA, waiting for ch to be idle first.
+// The operation is done right away if src is empty or on error.
+func dmaStartPush[T uint8 | uint16 | uint32](ch dmaChannel, dst *T, src []T, dreq uint32) DMAOp {
+	dstPtr, err := dmaAddr(unsafe.Pointer(dst), unsafe.Sizeof(*dst), true)
+	if err != nil {
+		return DMAOp{ch: ch, done: true, err: ch.fail(DMAError, err)}
+	}
+	return dmaStartPushAddr(ch, dstPtr, src, dreq)
+}
+
+// dmaStartPushAddr is dmaStartPush to the bus address dstPtr, such as the TX FIFO
+// address of a state machine.
+func dmaStartPushAddr[T uint8 | uint16 | uint32](ch dmaChannel, dstPtr uint32, src []T, dreq uint32) DMAOp {
+	ch.checkOwner()
+	op := DMAOp{ch: ch, done: true}
+	if op.err = op.waitIdle(); op.err != nil || len(src) == 0 {
+		return op
+	}
+	srcPtr, err := dmaAddr(unsafe.Pointer(&src[0]), uintptr(len(src))*unsafe.Sizeof(src[0]), false)
+	if err != nil {
+		op.err = ch.fail(DMAError, err)
+		return op
+	}
+	hw := ch.HW()
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	hw.READ_ADDR.Set(srcPtr)
+	hw.WRITE_ADDR.Set(dstPtr)
+	hw.TRANS_COUNT.Set(uint32(len(src)))
+
+	// Make src contents written by the CPU visible to the DMA before triggering.
+	dmaFence()
+
+	cc := ch.CurrentConfig()
+	cc.setTREQ_SEL(dreq)
+	cc.setTransferDataSize(dmaSize[T]())
+	cc.setChainTo(ch.idx)
+	cc.setReadIncrement(true)
+	cc.setWriteIncrement(false)
+	cc.setEnable(true)
+	op.trigger(cc, uint32(len(src)))
+	return op
+}
+
+// dmaStartPull starts reading src into dst by DMA, waiting for ch to be idle first.
+// The operation is done right away if dst is empty or on error.
+func dmaStartPull[T uint8 | uint16 | uint32](ch dmaChannel, dst []T, src *T, dreq uint32) DMAOp {
+	srcPtr, err := dmaAddr(unsafe.Pointer(src), unsafe.Sizeof(*src), false)
+	if err != nil {
+		return DMAOp{ch: ch, done: true, err: ch.fail(DMAError, err)}
+	}
+	return dmaStartPullAddr(ch, dst, srcPtr, dreq)
+}
+
+// dmaStartPullAddr is dmaStartPull from the bus address srcPtr, such as the RX FIFO
+// address of a state machine.
+func dmaStartPullAddr[T uint8 | uint16 | uint32](ch dmaChannel, dst []T, srcPtr uint32, dreq uint32) DMAOp {
+	ch.checkOwner()
+	op := DMAOp{ch: ch, done: true}
+	if op.err = op.waitIdle(); op.err != nil || len(dst) == 0 {
+		return op
+	}
+	dstPtr, err := dmaAddr(unsafe.Pointer(&dst[0]), uintptr(len(dst))*unsafe.Sizeof(dst[0]), true)
+	if err != nil {
+		op.err = ch.fail(DMAError, err)
+		return op
+	}
+	hw := ch.HW()
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	hw.READ_ADDR.Set(srcPtr)
+	hw.WRITE_ADDR.Set(dstPtr)
+	hw.TRANS_COUNT.Set(uint32(len(dst)))
+
+	dmaFence()
+
+	cc := ch.CurrentConfig()
+	cc.setTREQ_SEL(dreq)
+	cc.setTransferDataSize(dmaSize[T]())
+	cc.setChainTo(ch.idx)
+	cc.setReadIncrement(false)
+	cc.setWriteIncrement(true)
+	cc.setEnable(true)
+	op.trigger(cc, uint32(len(dst)))
+	return op
+}
+
+// waitIdle waits until it is safe to edit the hardware registers of the channel.
+func (op *DMAOp) waitIdle() error {
+	deadline := op.ch.dl.newDeadline()
+	for op.ch.busy() {
+		if deadline.expired() {
+			return op.ch.fail(DMATimeout, errContentionTimeout)
+		}
+		gosched()
+	}
+	return nil
+}
+
+// trigger begins the transfer of n words configured by cc, the timeout of the
+// channel running from now.
+func (op *DMAOp) trigger(cc dmaChannelConfig, n uint32) {
+	op.total, op.remain, op.done = n, n, false
+	// We begin our DMA transfer here!
+	op.ch.record(DMAStarted, nil)
+	op.ch.HW().CTRL_TRIG.Set(cc.CTRL)
+	op.dl = op.ch.dl.newDeadline()
+}
+
+// Done returns true once the operation completed, failed or was canceled. It
+// times the operation out when the deadline expired, so polling it is enough.
+func (op *DMAOp) Done() bool {
+	if op.done {
+		return true
+	}
+	if op.ch.busy() {
+		if !op.dl.expired() {
+			return false
+		}
+		op.abort()
+		op.err = op.ch.fail(DMATimeout, errTimeout)
+		return true
+	}
+	// Don't let reads of the buffer be reordered before the completion check.
+	dmaFence()
+	op.ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	op.remain, op.done = 0, true
+	op.ch.record(DMACompleted, nil)
+	return true
+}
+
+// Wait blocks until the operation is done and returns its error.
+func (op *DMAOp) Wait() error {
+	for !op.Done() {
+		gosched()
+	}
+	return op.err
+}
+
+// WaitCancel is like Wait but cancels the operation when cancel is closed, such as
+// the channel of a context's Done method.
+func (op *DMAOp) WaitCancel(cancel <-chan struct{}) error {
+	for !op.Done() {
+		select {
+		case <-cancel:
+			op.Cancel()
+		default:
+			gosched()
+		}
+	}
+	return op.err
+}
+
+// Cancel aborts the operation if it is not done, its error becoming ErrCanceled.
+// The words transferred so far are given by Transferred.
+func (op *DMAOp) Cancel() {
+	if op.done {
+		return
+	}
+	op.abort()
+	op.err = ErrCanceled
+}
+
+// abort stops the transfer, keeping the count of words left.
+func (op *DMAOp) abort() {
+	op.remain = op.ch.HW().TRANS_COUNT.Get()
+	if op.stop != nil {
+		op.stop()
+	} else {
+		op.ch.abort()
+	}
+	op.ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	op.done = true
+}
+
+// Err returns the error of the operation once done, nil while it is in flight.
+func (op *DMAOp) Err() error {
+	return op.err
+}
+
+// Busy returns true while the channel of the operation is transferring, which
+// does not time the operation out unlike Done.
+func (op *DMAOp) Busy() bool {
+	return !op.done && op.ch.busy()
+}
+
+// Remaining returns the number of words left to transfer, which is the credit of
+// the data request handshake with the FIFO.
+func (op *DMAOp) Remaining() uint32 {
+	if op.done {
+		return op.remain
+	}
+	return op.ch.HW().TRANS_COUNT.Get()
+}
+
+// Transferred returns the number of words transferred.
+func (op *DMAOp) Transferred() uint32 {
+	return op.total - op.Remaining()
+}
@@ -0,0 +1,49 @@
+//go:build rp2040
+
+package piolib
+
+// DMA configuration profiles for the transfers drivers commonly set up. The
+// returned configurations are enabled and chain to themselves, i.e. don't chain.
+
+// dmaStreamTx returns the configuration for ch writing a buffer to a peripheral
+// register, such as a state machine TX FIFO, paced by dreq.
+func dmaStreamTx(ch dmaChannel, size dmaTxSize, dreq uint32) dmaChannelConfig {
+	cc := dmaDefaultConfig(ch.idx)
+	cc.setTREQ_SEL(dreq)
+	cc.setTransferDataSize(size)
+	cc.setReadIncrement(true)
+	cc.setWriteIncrement(false)
+	cc.setEnable(true)
+	return cc
+}
+
+// dmaStreamRx returns the configuration for ch reading a peripheral register,
+// such as a state machine RX FIFO, into a buffer, paced by dreq.
+func dmaStreamRx(ch dmaChannel, size dmaTxSize, dreq uint32) dmaChannelConfig {
+	cc := dmaDefaultConfig(ch.idx)
+	cc.setTREQ_SEL(dreq)
+	cc.setTransferDataSize(size)
+	cc.setReadIncrement(false)
+	cc.setWriteIncrement(true)
+	cc.setEnable(true)
+	return cc
+}
+
+// dmaMemCopy returns the configuration for ch copying memory at full speed.
+func dmaMemCopy(ch dmaChannel, size dmaTxSize) dmaChannelConfig {
+	cc := dmaDefaultConfig(ch.idx)
+	cc.setTransferDataSize(size)
+	cc.setReadIncrement(true)
+	cc.setWriteIncrement(true)
+	cc.setEnable(true)
+	return cc
+}
+
+// dmaControl returns the configuration for a control channel ch writing a single
+// word, such as an address or count to reload, to a register of another channel.
+func dmaControl(ch dmaChannel) dmaChannelConfig {
+	cc := dmaDefaultConfig(ch.idx)
+	cc.setReadIncrement(false)
+	cc.setEnable(true)
+	return cc
+}
@@ -0,0 +1,137 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+var (
+	errDMASelfTestData = errors.New("piolib:DMA self-test data mismatch")
+	errDMASelfTestSlow = errors.New("piolib:DMA self-test transfer too slow")
+)
+
+const (
+	// Words copied by the self-test of each channel.
+	dmaSelfTestWords = 64
+	// A copy of the self-test takes about 1us at 125MHz with the bus to itself. A
+	// channel much slower than that is starved by higher priority channels or the
+	// system clock is misconfigured.
+	dmaSelfTestMaxTime = 100 * time.Microsecond
+	// Time after which a copy that did not complete is aborted.
+	dmaSelfTestTimeout = 10 * time.Millisecond
+)
+
+// DMASelfTestReport is the result of DMASelfTest. Channel masks have bit i set for
+// channel i.
+type DMASelfTestReport struct {
+	// Tested is the mask of the channels tested, those unclaimed.
+	Tested uint16
+	// Failed is the mask of the channels which failed.
+	Failed uint16
+	// Errs holds the error of each failed channel.
+	Errs [12]error
+	// Times holds the duration of the copy of each tested channel.
+	Times [12]time.Duration
+}
+
+// OK returns true if no channel failed.
+func (r *DMASelfTestReport) OK() bool {
+	return r.Failed == 0
+}
+
+// String returns a line per failed channel, or "DMA self-test passed".
+func (r *DMASelfTestReport) String() string {
+	if r.OK() {
+		return "DMA self-test passed"
+	}
+	var b []byte
+	for i := uint8(0); i < 12; i++ {
+		if r.Failed&(1<<i) == 0 {
+			continue
+		}
+		if len(b) > 0 {
+			b = append(b, '\n')
+		}
+		b = append(b, "ch"...)
+		b = strconv.AppendUint(b, uint64(i), 10)
+		b = append(b, ": "...)
+		b = append(b, r.Errs[i].Error()...)
+	}
+	return string(b)
+}
+
+// DMASelfTest copies a pattern from memory to memory with each unclaimed DMA
+// channel and checks the copy and its duration, for power-on self-tests of boards.
+// Channels are claimed one at a time for their test, so it should run before
+// drivers claim channels for them all to be tested. A slow copy points to bus
+// priority or clock setups starving the channels.
+func DMASelfTest() DMASelfTestReport {
+	var report DMASelfTestReport
+	var src, dst [dmaSelfTestWords]uint32
+	for i := uint8(0); i < 12; i++ {
+		ch := _DMA.Channel(i)
+		if !ch.TryClaim() {
+			continue
+		}
+		report.Tested |= 1 << i
+		for j := range src {
+			src[j] = uint32(j)*0x9e3779b9 ^ uint32(i)<<24
+			dst[j] = ^src[j]
+		}
+		d, err := ch.selfTest(&src, &dst)
+		ch.Unclaim()
+		report.Times[i] = d
+		if err != nil {
+			report.Failed |= 1 << i
+			report.Errs[i] = err
+		}
+	}
+	return report
+}
+
+// selfTest copies src to dst with ch and checks the copy.
+func (ch dmaChannel) selfTest(src, dst *[dmaSelfTestWords]uint32) (time.Duration, error) {
+	srcPtr, err := dmaAddr(unsafe.Pointer(src), unsafe.Sizeof(*src), false)
+	if err != nil {
+		return 0, err
+	}
+	dstPtr, err := dmaAddr(unsafe.Pointer(dst), unsafe.Sizeof(*dst), true)
+	if err != nil {
+		return 0, err
+	}
+	hw := ch.HW()
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	hw.READ_ADDR.Set(srcPtr)
+	hw.WRITE_ADDR.Set(dstPtr)
+	hw.TRANS_COUNT.Set(dmaSelfTestWords)
+	dmaFence()
+	cc := dmaMemCopy(ch, dmaTxSize32)
+
+	ch.record(DMAStarted, nil)
+	start := time.Now()
+	hw.CTRL_TRIG.Set(cc.CTRL)
+	dl := deadline{t: start.Add(dmaSelfTestTimeout)}
+	for ch.busy() {
+		if dl.expired() {
+			ch.abort()
+			hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+			return time.Since(start), ch.fail(DMATimeout, errTimeout)
+		}
+	}
+	elapsed := time.Since(start)
+	dmaFence()
+	hw.CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+	if *dst != *src {
+		return elapsed, ch.fail(DMAError, errDMASelfTestData)
+	}
+	ch.record(DMACompleted, nil)
+	if elapsed > dmaSelfTestMaxTime {
+		return elapsed, errDMASelfTestSlow
+	}
+	return elapsed, nil
+}
@@ -0,0 +1,110 @@
+//go:build rp2040
+
+package piolib
+
+import "strconv"
+
+// DMAEventKind is the kind of a DMAEvent.
+type DMAEventKind uint8
+
+const (
+	// DMAStarted is reported when a transfer or an endless loop is started.
+	DMAStarted DMAEventKind = iota
+	// DMACompleted is reported when a blocking transfer finishes.
+	DMACompleted
+	// DMAAborted is reported for each channel aborted, including the channels
+	// chained to an aborted channel and those of DMAAbortAll.
+	DMAAborted
+	// DMATimeout is reported when a transfer or waiting for a busy channel timed out.
+	DMATimeout
+	// DMAError is reported when a transfer is refused, i.e. for a buffer the DMA can't access.
+	DMAError
+)
+
+// DMAEvent describes a transfer event of a DMA channel, see SetDMAObserver.
+type DMAEvent struct {
+	Channel uint8
+	Kind    DMAEventKind
+	// Err is set for DMATimeout and DMAError events.
+	Err error
+}
+
+// DMAChannelStats counts the transfer events of a DMA channel since startup or
+// the last ResetDMAStats.
+type DMAChannelStats struct {
+	Started   uint32
+	Completed uint32
+	Aborted   uint32
+	Timeouts  uint32
+	Errors    uint32
+	// LastErr is the error of the last DMATimeout or DMAError event.
+	LastErr error
+}
+
+// String returns the statistics in a single line, i.e:
+//
+//	started=120 completed=118 aborted=2 timeouts=2 errors=0 last error: piolib:timeout
+func (s DMAChannelStats) String() string {
+	b := strconv.AppendUint([]byte("started="), uint64(s.Started), 10)
+	b = strconv.AppendUint(append(b, " completed="...), uint64(s.Completed), 10)
+	b = strconv.AppendUint(append(b, " aborted="...), uint64(s.Aborted), 10)
+	b = strconv.AppendUint(append(b, " timeouts="...), uint64(s.Timeouts), 10)
+	b = strconv.AppendUint(append(b, " errors="...), uint64(s.Errors), 10)
+	if s.LastErr != nil {
+		b = append(append(b, " last error: "...), s.LastErr.Error()...)
+	}
+	return string(b)
+}
+
+var (
+	dmaStats    [12]DMAChannelStats
+	dmaObserver func(DMAEvent)
+)
+
+// SetDMAObserver sets a function called on every transfer event of the DMA channels
+// used by piolib drivers, to log them or export metrics. It is called synchronously
+// by the driver, possibly from interrupt handlers, so it must be short and not block.
+// Use nil to remove the observer.
+func SetDMAObserver(observer func(DMAEvent)) {
+	dmaObserver = observer
+}
+
+// DMAStats returns the event counters of DMA channel ch. Counters are updated
+// without synchronization and are meant for diagnostics.
+func DMAStats(ch uint8) DMAChannelStats {
+	return dmaStats[ch]
+}
+
+// ResetDMAStats clears the event counters of all DMA channels.
+func ResetDMAStats() {
+	dmaStats = [12]DMAChannelStats{}
+}
+
+// record counts an event of the channel and reports it to the observer.
+func (ch dmaChannel) record(kind DMAEventKind, err error) {
+	stats := &dmaStats[ch.idx]
+	switch kind {
+	case DMAStarted:
+		stats.Started++
+	case DMACompleted:
+		stats.Completed++
+	case DMAAborted:
+		stats.Aborted++
+	case DMATimeout:
+		stats.Timeouts++
+	case DMAError:
+		stats.Errors++
+	}
+	if err != nil {
+		stats.LastErr = err
+	}
+	if observer := dmaObserver; observer != nil {
+		observer(DMAEvent{Channel: ch.idx, Kind: kind, Err: err})
+	}
+}
+
+// fail records a failed transfer and returns err.
+func (ch dmaChannel) fail(kind DMAEventKind, err error) error {
+	ch.record(kind, err)
+	return err
+}
@@ -0,0 +1,228 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"runtime/volatile"
+	"time"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	_ pio.TxStream[uint32] = (*DMATxStream[uint32])(nil)
+	_ pio.RxStream[uint32] = (*DMARxStream[uint32])(nil)
+)
+
+// DMATxStream streams words to the TX FIFO of a state machine, writing slices by
+// DMA. Words narrower than 32 bits are written with narrow writes, which the FIFO
+// replicates across the 32 bit word: programs shifting right take them from the
+// low bits. It implements pio.TxStream. One DMA channel is claimed.
+type DMATxStream[T pio.Word] struct {
+	sm     pio.StateMachine
+	dma    dmaChannel
+	dl     deadliner
+	chunks dmaChunking
+}
+
+// NewDMATxStream returns a stream to the TX FIFO of sm.
+func NewDMATxStream[T pio.Word](sm pio.StateMachine) (*DMATxStream[T], error) {
+	dma, ok := _DMA.ClaimChannelFor("DMATxStream")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	return &DMATxStream[T]{sm: sm, dma: dma}, nil
+}
+
+// SetTimeout sets the timeout of blocking writes. Use 0 as argument to disable timeouts.
+func (s *DMATxStream[T]) SetTimeout(timeout time.Duration) {
+	s.dl.setTimeout(timeout)
+	s.dma.dl.setTimeout(timeout)
+}
+
+// Put writes v to the FIFO, blocking while it is full.
+func (s *DMATxStream[T]) Put(v T) error {
+	dl := s.dl.newDeadline()
+	for s.sm.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	fifoStore(s.sm.TxRegAddr(), v)
+	return nil
+}
+
+// TryPut writes v to the FIFO if it is not full.
+func (s *DMATxStream[T]) TryPut(v T) bool {
+	if s.sm.IsTxFIFOFull() {
+		return false
+	}
+	fifoStore(s.sm.TxRegAddr(), v)
+	return true
+}
+
+// SetChunking splits writes into transfers of at most chunkSize words, yielding
+// to the scheduler between them and waiting interChunkDelay if not 0. Between
+// chunks the channel leaves the bus to the other channels, so a large write such
+// as a framebuffer blit does not delay latency sensitive streams like audio, which
+// can also be given priority with SetHighPriority. Use 0 as chunkSize to write in
+// a single transfer, the default.
+func (s *DMATxStream[T]) SetChunking(chunkSize int, interChunkDelay time.Duration) {
+	s.chunks = dmaChunking{size: chunkSize, delay: interChunkDelay}
+}
+
+// SetHighPriority sets whether the DMA channel is scheduled before the normal
+// priority channels when several are ready to transfer.
+func (s *DMATxStream[T]) SetHighPriority(high bool) {
+	s.dma.setHighPriority(high)
+}
+
+// Write writes p to the FIFO by DMA, blocking until the last word is in the FIFO.
+// On error n is the number of words of the chunks written.
+func (s *DMATxStream[T]) Write(p []T) (n int, err error) {
+	return dmaPushChunked(s.dma, s.chunks, s.sm.TxRegAddr(), p, dmaPIO_TxDREQ(s.sm))
+}
+
+// StartWrite starts writing p to the FIFO by DMA in a single transfer and returns
+// without waiting, the operation timing out after the stream's timeout. p must not
+// be modified until the operation is done.
+func (s *DMATxStream[T]) StartWrite(p []T) *DMAOp {
+	op := dmaStartPushAddr(s.dma, s.sm.TxRegAddr(), p, dmaPIO_TxDREQ(s.sm))
+	return &op
+}
+
+// Close releases the DMA channel.
+func (s *DMATxStream[T]) Close() error {
+	s.dma.Unclaim()
+	return nil
+}
+
+// DMARxStream streams words from the RX FIFO of a state machine, reading slices
+// by DMA. Words narrower than 32 bits are read with narrow reads of the low bits,
+// which suits programs shifting left. It implements pio.RxStream. One DMA channel
+// is claimed.
+type DMARxStream[T pio.Word] struct {
+	sm  pio.StateMachine
+	dma dmaChannel
+	dl  deadliner
+}
+
+// NewDMARxStream returns a stream from the RX FIFO of sm.
+func NewDMARxStream[T pio.Word](sm pio.StateMachine) (*DMARxStream[T], error) {
+	dma, ok := _DMA.ClaimChannelFor("DMARxStream")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	return &DMARxStream[T]{sm: sm, dma: dma}, nil
+}
+
+// SetTimeout sets the timeout of blocking reads. Use 0 as argument to disable timeouts.
+func (s *DMARxStream[T]) SetTimeout(timeout time.Duration) {
+	s.dl.setTimeout(timeout)
+	s.dma.dl.setTimeout(timeout)
+}
+
+// Get reads a word from the FIFO, blocking until one is available.
+func (s *DMARxStream[T]) Get() (T, error) {
+	dl := s.dl.newDeadline()
+	for s.sm.IsRxFIFOEmpty() {
+		if dl.expired() {
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	return T(s.sm.RxGet()), nil
+}
+
+// TryGet reads a word from the FIFO if it is not empty.
+func (s *DMARxStream[T]) TryGet() (T, bool) {
+	if s.sm.IsRxFIFOEmpty() {
+		return 0, false
+	}
+	return T(s.sm.RxGet()), true
+}
+
+// SetHighPriority sets whether the DMA channel is scheduled before the normal
+// priority channels when several are ready to transfer.
+func (s *DMARxStream[T]) SetHighPriority(high bool) {
+	s.dma.setHighPriority(high)
+}
+
+// Read fills p from the FIFO by DMA, blocking until all of p is read.
+func (s *DMARxStream[T]) Read(p []T) (n int, err error) {
+	err = dmaPullAddr(s.dma, p, s.sm.RxRegAddr(), dmaPIO_RxDREQ(s.sm))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// StartRead starts filling p from the FIFO by DMA and returns without waiting, the
+// operation timing out after the stream's timeout. p must not be used until the
+// operation is done.
+func (s *DMARxStream[T]) StartRead(p []T) *DMAOp {
+	op := dmaStartPullAddr(s.dma, p, s.sm.RxRegAddr(), dmaPIO_RxDREQ(s.sm))
+	return &op
+}
+
+// Close releases the DMA channel.
+func (s *DMARxStream[T]) Close() error {
+	s.dma.Unclaim()
+	return nil
+}
+
+// dmaChunking splits DMA writes into transfers of at most size elements, all of
+// them in a single transfer if size is 0. See DMATxStream.SetChunking.
+type dmaChunking struct {
+	size  int
+	delay time.Duration
+}
+
+// dmaPushChunked writes src to the register at bus address dst by chunks on ch,
+// yielding or waiting c.delay between them. On error n is the number of elements
+// of the chunks written.
+func dmaPushChunked[T pio.Word](ch dmaChannel, c dmaChunking, dst uint32, src []T, dreq uint32) (n int, err error) {
+	for n < len(src) {
+		chunk := src[n:]
+		if c.size > 0 && len(chunk) > c.size {
+			chunk = chunk[:c.size]
+		}
+		if n > 0 {
+			if c.delay > 0 {
+				time.Sleep(c.delay)
+			} else {
+				gosched()
+			}
+		}
+		err = dmaPushAddr(ch, dst, chunk, dreq)
+		if err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// setHighPriority sets the priority of the transfers of ch, kept by dmaPush and
+// dmaPull which start from the current configuration. ch must be idle.
+func (ch dmaChannel) setHighPriority(high bool) {
+	cc := ch.CurrentConfig()
+	cc.setHighPriority(high)
+	cc.setEnable(false)
+	ch.HW().AL1_CTRL.Set(cc.CTRL)
+}
+
+// fifoStore writes v to the FIFO register at bus address addr with a write of the
+// size of T.
+func fifoStore[T pio.Word](addr uint32, v T) {
+	switch unsafe.Sizeof(v) {
+	case 1:
+		volatile.StoreUint8((*uint8)(unsafe.Pointer(uintptr(addr))), uint8(v))
+	case 2:
+		volatile.StoreUint16((*uint16)(unsafe.Pointer(uintptr(addr))), uint16(v))
+	default:
+		volatile.StoreUint32((*uint32)(unsafe.Pointer(uintptr(addr))), uint32(v))
+	}
+}
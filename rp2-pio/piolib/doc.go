@@ -0,0 +1,30 @@
+// Package piolib contains drivers built on the PIO of the RP2040.
+//
+// # API stability
+//
+// Drivers are stable or experimental. Stable drivers keep their API across
+// releases, except for removals announced by deprecation first. They are
+// Parallel8Tx, Pulsar, SPI, SPI3w, WS2812B and I2S, along with the shared
+// facilities: DMA streams, claims, statistics and debugging, Placement,
+// ClaimStateMachine and RecalibrateAll.
+//
+// All other drivers are experimental: their API may change in any release while
+// they iterate. Their files are built with the piolib_stable build tag unset, so
+// firmware built with
+//
+//	tinygo build -tags piolib_stable
+//
+// fails to compile if it depends on an experimental driver. Experimental drivers
+// are promoted by removing the tag from their file and listing them above, once
+// their API went through a release unchanged and they were tested on hardware.
+// Build tags don't apply to the stubs used on other targets, which declare all
+// drivers.
+//
+// # Deprecation
+//
+// Deprecated API in this package and in package pio is marked by a paragraph
+// starting with "Deprecated:" in its doc comment, which gopls, staticcheck and
+// pkg.go.dev report to its users. The paragraph names the replacement. Stable API
+// is removed no earlier than the release after the one deprecating it. The stubs
+// keep the doc comments of deprecated API so it is reported on all targets.
+package piolib
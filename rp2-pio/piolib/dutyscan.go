@@ -0,0 +1,116 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errDutyScanNoPins = errors.New("piolib:duty scanner needs at least one pin")
+	errDutyScanWindow = errors.New("piolib:duty scanner window too short")
+)
+
+// Cycles per sample of the dutyscan program.
+const dutyScanSampleCycles = 3
+
+// DutyScanner measures the fraction of time each pin of a bank is high, such as
+// PWM output temperature sensors or the outputs of a bank of comparators, with a
+// single state machine. The pins are measured one after the other for a window
+// each, the state machine being switched to the next pin between windows with
+// StateMachine.SetJmpPin.
+type DutyScanner struct {
+	sm      pio.StateMachine
+	offset  uint8
+	pins    []machine.Pin
+	samples uint32
+	dl      deadliner
+}
+
+// NewDutyScanner creates a scanner measuring pins round-robin for window each.
+// The window should span several periods of the slowest signal measured.
+func NewDutyScanner(sm pio.StateMachine, pins []machine.Pin, window time.Duration) (*DutyScanner, error) {
+	if len(pins) == 0 {
+		return nil, errDutyScanNoPins
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(dutyscanInstructions, dutyscanOrigin)
+	if err != nil {
+		return nil, err
+	}
+	d := &DutyScanner{sm: sm, offset: offset, pins: pins}
+	if err := d.SetWindow(window); err != nil {
+		freeProgram(Pio, offset, dutyscanInstructions)
+		return nil, err
+	}
+	for _, pin := range pins {
+		pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+		sm.SetPindirsConsecutive(pin, 1, false)
+	}
+	cfg := dutyscanProgramDefaultConfig(offset)
+	cfg.SetJmpPin(pins[0])
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return d, nil
+}
+
+// SetTimeout sets the timeout of Scan. Use 0 as argument to disable timeouts.
+func (d *DutyScanner) SetTimeout(timeout time.Duration) {
+	d.dl.setTimeout(timeout)
+}
+
+// SetWindow sets how long each pin is measured. It is rounded to 3 CPU cycles.
+func (d *DutyScanner) SetWindow(window time.Duration) error {
+	samples := durationToCycles(window) / dutyScanSampleCycles
+	if samples == 0 {
+		return errDutyScanWindow
+	}
+	d.samples = samples
+	return nil
+}
+
+// Scan measures each pin in turn and stores its duty cycle to the matching
+// element of duty, from 0 for a pin low during the whole window to 0xffff for
+// a pin high during the whole window. Pins past the length of duty are skipped.
+func (d *DutyScanner) Scan(duty []uint16) error {
+	dl := d.dl.newDeadline()
+	for i, pin := range d.pins {
+		if i >= len(duty) {
+			break
+		}
+		// The state machine is stalled waiting for the next window.
+		d.sm.SetJmpPin(pin)
+		d.sm.TxPut(d.samples - 1)
+		for d.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				d.restart()
+				return errTimeout
+			}
+			gosched()
+		}
+		high := d.sm.RxGet()
+		duty[i] = uint16(uint64(high) * 0xffff / uint64(d.samples))
+	}
+	return nil
+}
+
+// restart aborts the window being measured.
+func (d *DutyScanner) restart() {
+	d.sm.SetEnabled(false)
+	d.sm.ClearFIFOs()
+	d.sm.Restart()
+	d.sm.Jmp(d.offset, pio.JmpAlways)
+	d.sm.SetEnabled(true)
+}
+
+// Placement returns the state machine and program used by the scanner.
+func (d *DutyScanner) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, dutyscanInstructions)
+	return p
+}
@@ -0,0 +1,216 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"math"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Inactive gaps shorter than this are part of a pulse, as the ringing of an
+	// energizer pulse through a divider and optocoupler makes several edges.
+	fenceMergeGap = 5 * time.Millisecond
+	// Default allowed deviation of the interval from the nominal one.
+	fenceDefaultTolerance = 100 * time.Millisecond
+)
+
+// FenceAlertKind is the kind of a FenceAlert.
+type FenceAlertKind uint8
+
+const (
+	// FenceMissingPulse is raised once per nominal interval without pulse.
+	FenceMissingPulse FenceAlertKind = iota + 1
+	// FenceInterval is raised when the interval to the previous pulse is out of tolerance.
+	FenceInterval
+	// FenceWidth is raised when the pulse width is out of the range set by SetWidthRange.
+	FenceWidth
+)
+
+// FenceAlert reports a fault of the pulses. At is the time of the pulse since the
+// first one, measured by the state machine, or for missing pulses of the last one.
+type FenceAlert struct {
+	Kind     FenceAlertKind
+	At       time.Duration
+	Interval time.Duration
+	Width    time.Duration
+}
+
+// FenceStats holds the statistics of the pulses since the monitor was created or
+// the last ResetStats. Jitter is the standard deviation of the intervals.
+type FenceStats struct {
+	Pulses       uint64
+	Missing      uint32
+	Alerts       uint32
+	LastAt       time.Duration
+	LastWidth    time.Duration
+	MinInterval  time.Duration
+	MaxInterval  time.Duration
+	MeanInterval time.Duration
+	Jitter       time.Duration
+}
+
+// FenceMonitor checks the pulses of an electric fence energizer, picked up through a
+// high voltage divider and an optocoupler. The state machine times every edge at
+// the CPU frequency, so pulse timestamps, widths and intervals don't depend on how
+// often Update is called. Update detects missing pulses and intervals and widths
+// out of range and calls the alert handler.
+type FenceMonitor struct {
+	sm        pio.StateMachine
+	offset    uint8
+	pulses    pulseGrouper
+	nominal   time.Duration
+	tolerance time.Duration
+	minWidth  time.Duration
+	maxWidth  time.Duration
+	handler   func(FenceAlert)
+	// Whether a pulse was recorded, and the start of the last one in cycles since
+	// the first edge.
+	started   bool
+	lastStart uint64
+	lastPulse time.Time // When the last pulse of valid width was recorded.
+	reported  uint32    // Missing pulses reported since the last pulse.
+	stats     FenceStats
+	// Running mean and sum of squared deviations of the intervals in seconds.
+	mean, m2 float64
+}
+
+// NewFenceMonitor monitors pulses on pin expected every nominal interval, around
+// a second for energizers. activeLow is set if the input is low during pulses, as
+// with an optocoupler pulling the pin down, which is then pulled up.
+func NewFenceMonitor(sm pio.StateMachine, pin machine.Pin, activeLow bool, nominal time.Duration) (*FenceMonitor, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	if activeLow {
+		// The PIO reads the pin whatever its function, keep it as SIO input for the pull-up.
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	}
+	f := &FenceMonitor{
+		sm:        sm,
+		offset:    offset,
+		pulses:    newPulseGrouper(activeLow),
+		nominal:   nominal,
+		tolerance: fenceDefaultTolerance,
+		lastPulse: time.Now(),
+	}
+	f.pulses.gap = f.pulses.cycles(fenceMergeGap)
+	return f, nil
+}
+
+// SetTolerance sets how far an interval may deviate from the nominal one, 100ms by default.
+func (f *FenceMonitor) SetTolerance(tolerance time.Duration) {
+	f.tolerance = tolerance
+}
+
+// SetWidthRange sets the range of valid pulse widths. Use 0 for either bound to
+// disable it. Pulses out of range raise FenceWidth and, being noise or a failing
+// energizer, don't count as pulses for FenceMissingPulse.
+func (f *FenceMonitor) SetWidthRange(min, max time.Duration) {
+	f.minWidth, f.maxWidth = min, max
+}
+
+// SetAlertHandler sets the function called by Update for each alert. It is called
+// from Update, not from an interrupt.
+func (f *FenceMonitor) SetAlertHandler(handler func(FenceAlert)) {
+	f.handler = handler
+}
+
+// Update processes the measured edges and checks for missing pulses. It must be
+// called at least a few times per nominal interval.
+func (f *FenceMonitor) Update() {
+	f.pulses.update(f.sm, f.pulse)
+	if !f.started || f.nominal <= 0 {
+		return
+	}
+	late := time.Since(f.lastPulse) - f.tolerance
+	if f.pulses.inGroup || late < 0 {
+		late = 0
+	}
+	for missing := uint32(late / f.nominal); f.reported < missing; f.reported++ {
+		f.stats.Missing++
+		f.alert(FenceAlert{Kind: FenceMissingPulse, At: f.stats.LastAt})
+	}
+}
+
+// pulse records the pulse measured, its edges merged.
+func (f *FenceMonitor) pulse() {
+	p := &f.pulses
+	at := p.duration(p.start)
+	width := p.duration(p.end - p.start)
+	f.stats.Pulses++
+	f.stats.LastAt, f.stats.LastWidth = at, width
+	valid := (f.minWidth == 0 || width >= f.minWidth) && (f.maxWidth == 0 || width <= f.maxWidth)
+	if !valid {
+		f.alert(FenceAlert{Kind: FenceWidth, At: at, Width: width})
+	}
+	if f.started {
+		interval := p.duration(p.start - f.lastStart)
+		f.interval(interval)
+		// Intervals spanning reported missing pulses are not alerted twice.
+		if diff := interval - f.nominal; f.reported == 0 && (diff > f.tolerance || diff < -f.tolerance) {
+			f.alert(FenceAlert{Kind: FenceInterval, At: at, Interval: interval, Width: width})
+		}
+	}
+	f.started = true
+	f.lastStart = p.start
+	// Noise or a failing energizer doesn't restart the missing pulse timer.
+	if valid {
+		f.lastPulse = time.Now()
+		f.reported = 0
+	}
+}
+
+// interval adds an interval to the statistics.
+func (f *FenceMonitor) interval(d time.Duration) {
+	s := &f.stats
+	if s.MinInterval == 0 || d < s.MinInterval {
+		s.MinInterval = d
+	}
+	if d > s.MaxInterval {
+		s.MaxInterval = d
+	}
+	// Welford's online algorithm.
+	n := float64(s.Pulses - 1)
+	x := d.Seconds()
+	delta := x - f.mean
+	f.mean += delta / n
+	f.m2 += delta * (x - f.mean)
+	s.MeanInterval = time.Duration(f.mean * float64(time.Second))
+	if n > 1 {
+		s.Jitter = time.Duration(math.Sqrt(f.m2/(n-1)) * float64(time.Second))
+	}
+}
+
+func (f *FenceMonitor) alert(a FenceAlert) {
+	f.stats.Alerts++
+	if f.handler != nil {
+		f.handler(a)
+	}
+}
+
+// Stats returns the statistics as of the last Update.
+func (f *FenceMonitor) Stats() FenceStats {
+	return f.stats
+}
+
+// ResetStats clears the statistics. Timestamps keep counting from the first pulse.
+func (f *FenceMonitor) ResetStats() {
+	f.stats = FenceStats{}
+	f.mean, f.m2 = 0, 0
+	// The next pulse starts the interval statistics anew.
+	f.started = false
+}
+
+// Placement returns the state machine and program used by the monitor.
+func (f *FenceMonitor) Placement() Placement {
+	var p Placement
+	p.addSM(f.sm, f.offset, pulsewidthInstructions)
+	return p
+}
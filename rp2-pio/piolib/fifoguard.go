@@ -0,0 +1,52 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// fifoGuard checks the FIFOs of a driver for words silently lost by the hardware
+// after each batch of transfers, when enabled by the SetFIFOGuard method of the
+// driver. Writing to a full TX FIFO or reading an empty RX FIFO happens when words
+// are queued without checking for room, or from two contexts at once.
+type fifoGuard struct {
+	enabled bool
+	// Batches which lost words.
+	txOverflows  uint32
+	rxUnderflows uint32
+}
+
+// setEnabled enables or disables the guard of sms, clearing flags set beforehand.
+func (g *fifoGuard) setEnabled(enabled bool, sms ...pio.StateMachine) {
+	if enabled && !g.enabled {
+		for _, sm := range sms {
+			sm.ClearFIFOErrors()
+		}
+	}
+	g.enabled = enabled
+}
+
+// check returns a *pio.FIFOError if words of sm were lost since the last check,
+// words being the number of words of the batch checked, and counts the batch.
+func (g *fifoGuard) check(sm pio.StateMachine, words int) error {
+	if !g.enabled {
+		return nil
+	}
+	txOverflow, rxUnderflow := sm.ClearFIFOErrors()
+	if rxUnderflow {
+		g.rxUnderflows++
+	}
+	if txOverflow {
+		g.txOverflows++
+		return &pio.FIFOError{Err: pio.ErrTxOverflow, Words: words, Batches: g.txOverflows}
+	} else if rxUnderflow {
+		return &pio.FIFOError{Err: pio.ErrRxUnderflow, Words: words, Batches: g.rxUnderflows}
+	}
+	return nil
+}
+
+// counts returns the batches which lost words.
+func (g *fifoGuard) counts() (txOverflows, rxUnderflows uint32) {
+	return g.txOverflows, g.rxUnderflows
+}
@@ -0,0 +1,102 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"strconv"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// FIFOStats describes how full the FIFOs of a driver's state machines got and who
+// waited on whom, to tell whether a driver is FIFO-bound or CPU-bound when tuning
+// DMA thresholds and clock dividers. It is gathered while EnableFIFOStats is set.
+type FIFOStats struct {
+	// TxMax and RxMax are the highest TX and RX FIFO levels seen.
+	TxMax uint8
+	RxMax uint8
+	// TxFull counts the polls finding the TX FIFO full and RxEmpty those finding
+	// the RX FIFO empty: the CPU waited on the state machine.
+	TxFull  uint32
+	RxEmpty uint32
+	// TxStalls and RxStalls count the samples finding the state machine stalled on
+	// an empty TX FIFO or a full RX FIFO since the previous sample: the state
+	// machine waited on the CPU.
+	TxStalls uint32
+	RxStalls uint32
+}
+
+// String returns the statistics in a single line, i.e:
+//
+//	txmax=8 rxmax=3 txfull=1520 rxempty=0 txstalls=2 rxstalls=0
+func (s FIFOStats) String() string {
+	b := strconv.AppendUint([]byte("txmax="), uint64(s.TxMax), 10)
+	b = strconv.AppendUint(append(b, " rxmax="...), uint64(s.RxMax), 10)
+	b = strconv.AppendUint(append(b, " txfull="...), uint64(s.TxFull), 10)
+	b = strconv.AppendUint(append(b, " rxempty="...), uint64(s.RxEmpty), 10)
+	b = strconv.AppendUint(append(b, " txstalls="...), uint64(s.TxStalls), 10)
+	b = strconv.AppendUint(append(b, " rxstalls="...), uint64(s.RxStalls), 10)
+	return string(b)
+}
+
+var fifoStatsEnabled bool
+
+// EnableFIFOStats enables or disables gathering FIFOStats in the polling loops of
+// the UART, SPI, I2S and Parallel8Tx drivers, returned by their Stats method. It
+// is disabled by default, costing a single check per loop iteration.
+//
+// Sampling the stalls clears the sticky stall flags of the state machines, so
+// StateMachine.IsTxStalled and IsRxStalled should not be relied on meanwhile.
+func EnableFIFOStats(enabled bool) {
+	fifoStatsEnabled = enabled
+}
+
+// fifoMonitor gathers the FIFOStats of a driver from its polling loops.
+type fifoMonitor struct {
+	stats FIFOStats
+}
+
+// begin is called before a driver starts queuing data after being idle, so the
+// stall of the idle state machine isn't counted.
+func (m *fifoMonitor) begin(sm pio.StateMachine) {
+	if fifoStatsEnabled {
+		sm.ClearTxStalled()
+	}
+}
+
+// sample records the FIFO levels and stalls of sm.
+func (m *fifoMonitor) sample(sm pio.StateMachine) {
+	if !fifoStatsEnabled {
+		return
+	}
+	if level := uint8(sm.TxFIFOLevel()); level > m.stats.TxMax {
+		m.stats.TxMax = level
+	}
+	if level := uint8(sm.RxFIFOLevel()); level > m.stats.RxMax {
+		m.stats.RxMax = level
+	}
+	if sm.IsTxStalled() {
+		m.stats.TxStalls++
+		sm.ClearTxStalled()
+	}
+	if sm.IsRxStalled() {
+		m.stats.RxStalls++
+		sm.ClearRxStalled()
+	}
+}
+
+// txFull records a poll finding the TX FIFO of sm full.
+func (m *fifoMonitor) txFull(sm pio.StateMachine) {
+	if fifoStatsEnabled {
+		m.stats.TxFull++
+		m.sample(sm)
+	}
+}
+
+// rxEmpty records a poll finding the RX FIFO of sm empty.
+func (m *fifoMonitor) rxEmpty(sm pio.StateMachine) {
+	if fifoStatsEnabled {
+		m.stats.RxEmpty++
+		m.sample(sm)
+	}
+}
@@ -0,0 +1,329 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errFSKParity  = errors.New("piolib:FSK parity error")
+	errFSKFraming = errors.New("piolib:FSK framing error")
+)
+
+// The transmitter sends a byte as a burst of bit words with carrier before and
+// after, which can't be queued without blocking, so only receiving is a stream.
+var _ pio.RxStream[byte] = (*FSKModem)(nil)
+
+const (
+	fskBaud = 1200
+	// fsk_tx cycles per bit: 22 ticks of 32 cycles and the pull and set.
+	fskTxBitCycles = 22*32 + 2
+	// Half periods of the tones in ticks minus one, as expected by fsk_tx.
+	fskTxMark  = 10
+	fskTxSpace = 5
+	// Mark bits sent before the first frame of each write so the receiver locks
+	// on the carrier, and after the last one so it measures the whole stop bit.
+	fskLeadBits  = 8
+	fskTrailBits = 2
+	// Bits of a frame after the start bit: 8 data bits, odd parity and stop bit.
+	fskFrameBits = 10
+	// Received bytes kept until read.
+	fskRxBuffer = 32
+)
+
+// FSKModem is a Bell 202 modem, 1200 baud with a 1200Hz mark tone and a 2200Hz
+// space tone, as used by HART to talk to smart transmitters over a 4-20mA current
+// loop. Frames have 8 data bits, odd parity and a stop bit, like HART.
+//
+// The transmitter state machine generates the tones as a phase continuous square
+// wave, to be low-pass filtered and coupled into the loop by the interface
+// circuit, and is silent between writes. The receiver measures the half periods
+// of the received signal, squared up by a comparator, at the CPU frequency, from
+// which the CPU demodulates the bits. Received frames are decoded while ReadByte,
+// Read or Buffered run: the RX FIFO holds about 2ms of signal, so a reader should
+// be waiting when the remote device answers, as is the case with the request and
+// response exchanges of HART. The modem hears its own transmissions, which can be
+// discarded with DiscardInput after Flush.
+type FSKModem struct {
+	tx, rx   pio.StateMachine
+	txPin    machine.Pin
+	rxPin    machine.Pin
+	txOffset uint8
+	rxOffset uint8
+	dl       deadliner
+	// Demodulator state, in CPU cycles.
+	bitCycles uint64
+	threshold uint64 // Half periods longer than this are of the mark tone.
+	mark      bool   // Tone of the current run.
+	run       uint64 // Length of the current run of the same tone.
+	emitted   uint64 // Bits of the current run already passed to the framer.
+	// Framer state: bits received after the start bit, or -1 while idle.
+	nbits int
+	shift uint16
+	// Received bytes, with the error bits above the data.
+	buf        [fskRxBuffer]uint16
+	head, tail uint8
+}
+
+// Error bits stored with received bytes.
+const (
+	fskErrParity  = 1 << 8
+	fskErrFraming = 1 << 9
+)
+
+// NewFSKModem creates a modem transmitting on txPin with the txsm state machine
+// and receiving on rxPin with the rxsm state machine.
+func NewFSKModem(txsm, rxsm pio.StateMachine, txPin, rxPin machine.Pin) (*FSKModem, error) {
+	whole, frac, err := pio.ClkDivFromFrequency(fskBaud*fskTxBitCycles, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	txsm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	rxsm.TryClaim()
+	txOffset, err := txsm.PIO().AddProgram(fsk_txInstructions, fsk_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	rxOffset, err := rxsm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		freeProgram(txsm.PIO(), txOffset, fsk_txInstructions)
+		return nil, err
+	}
+	Pio := txsm.PIO()
+	txsm.SetPinsConsecutive(txPin, 1, false)
+	txsm.SetPindirsConsecutive(txPin, 1, true)
+	txPin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	cfg := fsk_txProgramDefaultConfig(txOffset)
+	cfg.SetOutPins(txPin, 1)
+	// The output is toggled by reading it back.
+	cfg.SetInPins(txPin)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	txsm.Init(txOffset, cfg)
+	trackClock(txsm, fskBaud*fskTxBitCycles)
+	txsm.SetEnabled(true)
+
+	pulsewidthInit(rxsm, rxOffset, rxPin)
+	bitCycles := uint64(machine.CPUFrequency()) / fskBaud
+	return &FSKModem{
+		tx:        txsm,
+		rx:        rxsm,
+		txPin:     txPin,
+		rxPin:     rxPin,
+		txOffset:  txOffset,
+		rxOffset:  rxOffset,
+		bitCycles: bitCycles,
+		// Halfway between the half periods of the tones, 417µs and 227µs.
+		threshold: bitCycles * 17 / 44,
+		nbits:     -1,
+	}, nil
+}
+
+// SetTimeout sets the timeout for reads and writes. Use 0 as argument to disable timeouts.
+func (m *FSKModem) SetTimeout(timeout time.Duration) {
+	m.dl.setTimeout(timeout)
+}
+
+// Write transmits p, framed by a few bit times of carrier, blocking until it has
+// been queued for transmission. HART frames start with their own preamble of 0xFF
+// bytes, which p should include.
+func (m *FSKModem) Write(p []byte) (n int, err error) {
+	dl := m.dl.newDeadline()
+	for i := 0; i < fskLeadBits; i++ {
+		if err := m.putBit(dl, true); err != nil {
+			return 0, err
+		}
+	}
+	for n < len(p) {
+		b := p[n]
+		frame := uint16(b)<<1 | uint16(fskOddParity(b))<<9 | 1<<10
+		for i := 0; i <= fskFrameBits; i++ {
+			if err := m.putBit(dl, frame&(1<<i) != 0); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	for i := 0; i < fskTrailBits; i++ {
+		if err := m.putBit(dl, true); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (m *FSKModem) putBit(dl deadline, mark bool) error {
+	for m.tx.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	if mark {
+		m.tx.TxPut(fskTxMark)
+	} else {
+		m.tx.TxPut(fskTxSpace)
+	}
+	return nil
+}
+
+// fskOddParity returns the parity bit making the number of set bits of b and parity odd.
+func fskOddParity(b byte) uint8 {
+	b ^= b >> 4
+	b ^= b >> 2
+	b ^= b >> 1
+	return ^b & 1
+}
+
+// Flush blocks until all queued bits have been transmitted and the carrier is off.
+func (m *FSKModem) Flush() error {
+	dl := m.dl.newDeadline()
+	for !m.tx.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// The transmitter stalls waiting for data at the end of the last bit.
+	m.tx.ClearTxStalled()
+	for !m.tx.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// Buffered returns the number of received bytes waiting to be read.
+func (m *FSKModem) Buffered() int {
+	m.demodulate()
+	return int(m.head - m.tail)
+}
+
+// ReadByte blocks until a byte is received. A parity or framing error is returned
+// as an error after the byte is consumed.
+func (m *FSKModem) ReadByte() (byte, error) {
+	dl := m.dl.newDeadline()
+	for m.Buffered() == 0 {
+		if dl.expired() {
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	v := m.buf[m.tail%fskRxBuffer]
+	m.tail++
+	switch {
+	case v&fskErrFraming != 0:
+		return byte(v), errFSKFraming
+	case v&fskErrParity != 0:
+		return byte(v), errFSKParity
+	}
+	return byte(v), nil
+}
+
+// Get blocks until a byte is received, like ReadByte. It implements pio.RxStream.
+func (m *FSKModem) Get() (byte, error) {
+	return m.ReadByte()
+}
+
+// TryGet returns a received byte if one is waiting. Bytes received with a parity
+// or framing error are dropped.
+func (m *FSKModem) TryGet() (byte, bool) {
+	for m.Buffered() > 0 {
+		b, err := m.ReadByte()
+		if err == nil {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+// Read blocks until at least one byte is received and reads up to len(p) bytes
+// without blocking further.
+func (m *FSKModem) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && m.Buffered() == 0 {
+			break
+		}
+		p[n], err = m.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DiscardInput drops the received bytes and the frame being received.
+func (m *FSKModem) DiscardInput() {
+	m.demodulate()
+	m.tail = m.head
+	m.nbits = -1
+}
+
+// demodulate decodes the half periods measured by the receiver. Runs of half
+// periods of the same tone are turned into bits by their length, each bit being
+// passed to the framer once the run is longer than half a bit time so a frame is
+// complete as soon as its stop bit started.
+func (m *FSKModem) demodulate() {
+	for !m.rx.IsRxFIFOEmpty() {
+		cycles, _ := pulsewidthDecode(m.rx.RxGet())
+		if cycles > m.bitCycles {
+			// No carrier.
+			m.run, m.emitted, m.nbits = 0, 0, -1
+			continue
+		}
+		mark := cycles > m.threshold
+		if mark != m.mark {
+			m.run, m.emitted, m.mark = 0, 0, mark
+		}
+		m.run += cycles
+		for bits := (m.run + m.bitCycles/2) / m.bitCycles; m.emitted < bits; m.emitted++ {
+			m.frameBit(mark)
+		}
+	}
+}
+
+// frameBit passes a received bit to the framer.
+func (m *FSKModem) frameBit(mark bool) {
+	if m.nbits < 0 {
+		if !mark {
+			m.nbits, m.shift = 0, 0 // Start bit.
+		}
+		return
+	}
+	if mark {
+		m.shift |= 1 << m.nbits
+	}
+	m.nbits++
+	if m.nbits < fskFrameBits {
+		return
+	}
+	m.nbits = -1
+	b := byte(m.shift)
+	v := uint16(b)
+	if m.shift&(1<<9) == 0 {
+		v |= fskErrFraming
+	} else if uint16(fskOddParity(b))<<8 != m.shift&(1<<8) {
+		v |= fskErrParity
+	}
+	if m.head-m.tail < fskRxBuffer {
+		m.buf[m.head%fskRxBuffer] = v
+		m.head++
+	}
+}
+
+// Placement returns the state machines and programs used by the modem.
+func (m *FSKModem) Placement() Placement {
+	var p Placement
+	p.addSM(m.tx, m.txOffset, fsk_txInstructions)
+	p.addSM(m.rx, m.rxOffset, pulsewidthInstructions)
+	return p
+}
@@ -0,0 +1,365 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"io"
+	"machine"
+	"strings"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errGPIBNoListener = errors.New("piolib:GPIB no listener on bus")
+
+const (
+	// State machine frequency of the GPIB handshakes: the 8 cycles between data
+	// and DAV give the 2us settling time required for open collector drivers.
+	gpibFreq = 4_000_000
+	// Time for devices to respond to ATN before the first command byte.
+	gpibATNSettle = 2 * time.Microsecond
+	// Duration of the interface clear pulse, at least 100us.
+	gpibIFCPulse = 150 * time.Microsecond
+	// Receive buffer growth step of Query.
+	gpibQueryChunk = 64
+
+	// Interface messages sent with ATN asserted, see IEEE 488.1.
+	gpibListenAddr = 0x20
+	gpibTalkAddr   = 0x40
+	gpibUnlisten   = 0x3f
+	gpibUntalk     = 0x5f
+	gpibSDC        = 0x04 // Selected device clear.
+	gpibGTL        = 0x01 // Go to local.
+
+	gpibEOI = 1 << 8
+)
+
+const (
+	gpibIdle = iota
+	gpibTalk
+	gpibListen
+)
+
+// GPIB is an IEEE 488 (GPIB, HP-IB) interface acting as system controller and
+// controller in charge, to remote control test equipment. The state machine runs
+// the three wire DAV/NRFD/NDAC handshake as talker or listener, switching the data
+// bus direction, while ATN, IFC and REN are driven by the CPU.
+//
+// All bus lines are open collector and the bus uses 5V levels: connect the pins
+// through GPIB transceivers or open drain buffers, never directly.
+type GPIB struct {
+	sm           pio.StateMachine
+	talkOffset   uint8
+	listenOffset uint8
+	talkCfg      pio.StateMachineConfig
+	listenCfg    pio.StateMachineConfig
+	base         machine.Pin
+	atn          machine.Pin
+	ifc          machine.Pin
+	ren          machine.Pin
+	mode         uint8
+	addr         uint8
+	dl           deadliner
+}
+
+// NewGPIB creates a GPIB controller with DIO1..DIO8 on pins base to base+7, EOI
+// on base+8, DAV on base+9, NRFD on base+10 and NDAC on base+11. atn, ifc and ren
+// are driven by the CPU; ren may be machine.NoPin if remote enable is wired
+// asserted. The controller takes address 0 and clears the interface, leaving REN
+// asserted so devices enter remote state when addressed.
+func NewGPIB(sm pio.StateMachine, base, atn, ifc, ren machine.Pin) (*GPIB, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	whole, frac, err := pio.ClkDivFromFrequency(gpibFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	talkOffset, err := Pio.AddProgram(gpib_talkInstructions, gpib_talkOrigin)
+	if err != nil {
+		return nil, err
+	}
+	listenOffset, err := Pio.AddProgram(gpib_listenInstructions, gpib_listenOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for pin := base; pin < base+12; pin++ {
+		pin.Configure(pinCfg)
+	}
+	// Lines are asserted by enabling the output of a low pin.
+	sm.SetPinsConsecutive(base, 12, false)
+	sm.SetPindirsConsecutive(base, 12, false)
+
+	talkCfg := gpib_talkProgramDefaultConfig(talkOffset)
+	talkCfg.SetInPins(base)
+	talkCfg.SetOutPins(base, 9)
+	talkCfg.SetSetPins(base+9, 1)
+	talkCfg.SetOutShift(true, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	talkCfg.SetFIFOJoin(pio.FifoJoinTx)
+	talkCfg.SetClkDivIntFrac(whole, frac)
+
+	listenCfg := gpib_listenProgramDefaultConfig(listenOffset)
+	listenCfg.SetInPins(base)
+	listenCfg.SetSetPins(base+10, 2)
+	listenCfg.SetInShift(false, false, 32)
+	// We only use Rx FIFO, so we set the join to Rx.
+	listenCfg.SetFIFOJoin(pio.FifoJoinRx)
+	listenCfg.SetClkDivIntFrac(whole, frac)
+
+	g := &GPIB{
+		sm:           sm,
+		talkOffset:   talkOffset,
+		listenOffset: listenOffset,
+		talkCfg:      talkCfg,
+		listenCfg:    listenCfg,
+		base:         base,
+		atn:          atn,
+		ifc:          ifc,
+		ren:          ren,
+		mode:         gpibIdle,
+	}
+	sm.Init(talkOffset, talkCfg)
+	trackClock(sm, gpibFreq)
+	gpibLine(atn, false)
+	gpibLine(ren, true)
+	g.InterfaceClear()
+	return g, nil
+}
+
+// SetTimeout sets the timeout of the handshake of each byte. Use 0 as argument to
+// disable timeouts, in which case a device not responding blocks forever.
+func (g *GPIB) SetTimeout(timeout time.Duration) {
+	g.dl.setTimeout(timeout)
+}
+
+// SetAddress sets the primary address of the controller, 0 by default, which must
+// differ from the addresses of all devices on the bus.
+func (g *GPIB) SetAddress(addr uint8) {
+	g.addr = addr & 0x1f
+}
+
+// InterfaceClear pulses IFC, returning all devices to the idle state: unaddressed
+// and with their talker and listener functions reset.
+func (g *GPIB) InterfaceClear() {
+	g.setMode(gpibIdle)
+	gpibLine(g.ifc, true)
+	time.Sleep(gpibIFCPulse)
+	gpibLine(g.ifc, false)
+}
+
+// Command sends interface messages, such as addresses, with ATN asserted.
+func (g *GPIB) Command(cmds ...byte) error {
+	return g.command(cmds, gpibIdle)
+}
+
+// Write sends data to the devices addressed to listen, asserting EOI with the last
+// byte. The controller must have been addressed to talk with Command, see Send.
+func (g *GPIB) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	g.setMode(gpibTalk)
+	err := g.talk(data, true)
+	g.setMode(gpibIdle)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Read receives bytes from the addressed talker until EOI or until buf is full.
+// It returns io.EOF with the last byte of a message, after which the controller
+// stops listening. Otherwise the controller keeps listening, holding off the
+// talker once the FIFO is full, until the next Read. The controller must have
+// been addressed to listen with Command, see Receive.
+func (g *GPIB) Read(buf []byte) (n int, err error) {
+	g.setMode(gpibListen)
+	dl := g.dl.newDeadline()
+	for n < len(buf) {
+		if g.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				g.setMode(gpibIdle)
+				return n, errTimeout
+			}
+			gosched()
+			continue
+		}
+		// Pins are low when asserted.
+		w := ^g.sm.RxGet()
+		buf[n] = byte(w)
+		n++
+		if w&gpibEOI != 0 {
+			g.setMode(gpibIdle)
+			return n, io.EOF
+		}
+		dl = g.dl.newDeadline()
+	}
+	return n, nil
+}
+
+// Send addresses the device at addr to listen and writes data to it.
+func (g *GPIB) Send(addr uint8, data []byte) error {
+	err := g.command([]byte{gpibUnlisten, gpibTalkAddr | g.addr, gpibListenAddr | addr&0x1f}, gpibIdle)
+	if err != nil {
+		return err
+	}
+	_, err = g.Write(data)
+	return err
+}
+
+// Receive addresses the device at addr to talk and reads its message into buf,
+// returning the number of bytes read. A message longer than buf is truncated.
+func (g *GPIB) Receive(addr uint8, buf []byte) (int, error) {
+	if err := g.addressTalker(addr); err != nil {
+		return 0, err
+	}
+	n, err := g.Read(buf)
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, g.command([]byte{gpibUntalk}, gpibIdle)
+}
+
+// Query sends cmd to the device at addr and returns its response without trailing
+// line terminators, as done with SCPI queries such as "*IDN?".
+func (g *GPIB) Query(addr uint8, cmd string) (string, error) {
+	if err := g.Send(addr, []byte(cmd)); err != nil {
+		return "", err
+	}
+	if err := g.addressTalker(addr); err != nil {
+		return "", err
+	}
+	var resp []byte
+	for {
+		resp = append(resp, make([]byte, gpibQueryChunk)...)
+		n, err := g.Read(resp[len(resp)-gpibQueryChunk:])
+		resp = resp[:len(resp)-gpibQueryChunk+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := g.command([]byte{gpibUntalk}, gpibIdle); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(resp), "\r\n"), nil
+}
+
+// Clear sends selected device clear to the device at addr, resetting its message
+// exchange.
+func (g *GPIB) Clear(addr uint8) error {
+	return g.command([]byte{gpibUnlisten, gpibListenAddr | addr&0x1f, gpibSDC, gpibUnlisten}, gpibIdle)
+}
+
+// Local returns the device at addr to local control of its front panel.
+func (g *GPIB) Local(addr uint8) error {
+	return g.command([]byte{gpibUnlisten, gpibListenAddr | addr&0x1f, gpibGTL, gpibUnlisten}, gpibIdle)
+}
+
+// addressTalker addresses the device at addr to talk and the controller to listen.
+func (g *GPIB) addressTalker(addr uint8) error {
+	return g.command([]byte{gpibUnlisten, gpibListenAddr | g.addr, gpibTalkAddr | addr&0x1f}, gpibListen)
+}
+
+// command sends cmds with ATN asserted and releases ATN once the state machine
+// is set to mode, so the controller is listening before the talker starts.
+func (g *GPIB) command(cmds []byte, mode uint8) error {
+	g.setMode(gpibTalk)
+	gpibLine(g.atn, true)
+	time.Sleep(gpibATNSettle)
+	err := g.talk(cmds, false)
+	if err != nil {
+		mode = gpibIdle
+	}
+	g.setMode(mode)
+	gpibLine(g.atn, false)
+	return err
+}
+
+// talk sends data with the talker program, asserting EOI with the last byte if
+// eoi is set, and waits for the last byte to be accepted.
+func (g *GPIB) talk(data []byte, eoi bool) error {
+	// Listeners assert NDAC, so with both lines released there is no one to accept data.
+	if g.line(10) && g.line(11) {
+		return errGPIBNoListener
+	}
+	dl := g.dl.newDeadline()
+	for i, b := range data {
+		w := uint32(b)
+		if eoi && i == len(data)-1 {
+			w |= gpibEOI
+		}
+		for g.sm.IsTxFIFOFull() {
+			if dl.expired() {
+				g.setMode(gpibIdle)
+				return errTimeout
+			}
+			gosched()
+		}
+		g.sm.TxPut(w)
+	}
+	// The state machine stalls on an empty FIFO once the last byte is accepted.
+	g.sm.ClearTxStalled()
+	for !g.sm.IsTxFIFOEmpty() || !g.sm.IsTxStalled() {
+		if dl.expired() {
+			g.setMode(gpibIdle)
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// setMode switches the state machine to the talker or listener program, or stops
+// it and releases all handshake and data lines for gpibIdle.
+func (g *GPIB) setMode(mode uint8) {
+	if g.mode == mode {
+		return
+	}
+	g.sm.SetEnabled(false)
+	g.sm.ClearFIFOs()
+	g.sm.SetPindirsConsecutive(g.base, 12, false)
+	switch mode {
+	case gpibTalk:
+		g.sm.Init(g.talkOffset, g.talkCfg)
+		g.sm.SetEnabled(true)
+	case gpibListen:
+		g.sm.Init(g.listenOffset, g.listenCfg)
+		g.sm.SetEnabled(true)
+	}
+	g.mode = mode
+}
+
+// line reports whether the line on base+i is released (high).
+func (g *GPIB) line(i machine.Pin) bool {
+	return (g.base + i).Get()
+}
+
+// gpibLine asserts or releases an open collector line driven by the CPU.
+func gpibLine(pin machine.Pin, asserted bool) {
+	if pin == machine.NoPin {
+		return
+	}
+	if asserted {
+		pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		pin.Low()
+	} else {
+		pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+}
+
+// Placement returns the state machine and programs used by the interface.
+func (g *GPIB) Placement() Placement {
+	var p Placement
+	p.addSM(g.sm, g.talkOffset, gpib_talkInstructions)
+	p.addSM(g.sm, g.listenOffset, gpib_listenInstructions)
+	return p
+}
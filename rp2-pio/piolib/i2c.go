@@ -0,0 +1,265 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errI2CNack = errors.New("piolib:I2C no acknowledge")
+	errI2CPins = errors.New("piolib:I2C SCL must be the pin after SDA")
+)
+
+// Fields of the TX FIFO words of the I2C program, see i2c.pio.
+const (
+	i2cIcountPos = 10
+	i2cFinal     = 1 << 9
+	i2cDataPos   = 1
+	i2cAck       = 1 << 0
+)
+
+// Indices of the i2c_set_scl_sda instructions setting each bus state.
+const (
+	i2cSCL0SDA0 = iota
+	i2cSCL0SDA1
+	i2cSCL1SDA0
+	i2cSCL1SDA1
+)
+
+// I2C is an I2C bus master with clock stretching support. Its methods match
+// those of machine.I2C so it can be used by drivers written for it. SDA and SCL
+// need pull-up resistors.
+type I2C struct {
+	sm     pio.StateMachine
+	offset uint8
+	sda    machine.Pin
+	dl     deadliner
+	reg    [1]byte
+	// Bytes shifted in to discard before reading into rx in the transfer in progress.
+	skip int
+	rx   []byte
+	susp suspender
+}
+
+// NewI2C returns an I2C bus master with data on sda and clock on scl, which must be
+// the pin after sda. baud is the SCL frequency, usually 100kHz or 400kHz.
+func NewI2C(sm pio.StateMachine, sda, scl machine.Pin, baud uint32) (*I2C, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if scl != sda+1 {
+		return nil, errI2CPins
+	}
+	// A bit takes 32 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(baud*32, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(i2cInstructions, i2cOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	sda.Configure(pinCfg)
+	scl.Configure(pinCfg)
+	// SDA and SCL are open drain: driven low when they are outputs, released otherwise.
+	sm.SetPinsConsecutive(sda, 2, false)
+	sm.SetPindirsConsecutive(sda, 2, false)
+	cfg := i2cProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(scl)
+	cfg.SetOutPins(sda, 1)
+	cfg.SetSetPins(sda, 1)
+	cfg.SetInPins(sda)
+	cfg.SetJmpPin(sda)
+	cfg.SetOutShift(false, true, 16)
+	cfg.SetInShift(false, true, 8)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset+i2coffset_entry_point, cfg)
+	trackClock(sm, baud*32)
+	sm.SetEnabled(true)
+	return &I2C{sm: sm, offset: offset, sda: sda}, nil
+}
+
+// SetTimeout sets the timeout for transfers. Use 0 as argument to disable timeouts.
+func (i2c *I2C) SetTimeout(timeout time.Duration) {
+	i2c.dl.setTimeout(timeout)
+}
+
+// Tx writes w to the device at address addr and then reads into r, with a repeated
+// start in between. Either may be empty. A transfer with both empty only sends the
+// address, which can be used to probe for devices.
+func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
+	dl := i2c.dl.newDeadline()
+	i2c.skip = 0
+	i2c.rx = r
+	err := i2c.exec(dl, i2cSCL1SDA0, i2cSCL0SDA0) // Start condition: SDA falls while SCL is high.
+	if err == nil && (len(w) > 0 || len(r) == 0) {
+		i2c.skip = 1 + len(w)
+		err = i2c.writeByte(dl, uint8(addr)<<1, false)
+		for i := 0; i < len(w) && err == nil; i++ {
+			// Like most masters, ignore a NAK of the last byte of a write.
+			err = i2c.writeByte(dl, w[i], i == len(w)-1 && len(r) == 0)
+		}
+		if err == nil && len(r) > 0 {
+			err = i2c.exec(dl, i2cSCL0SDA1, i2cSCL1SDA1, i2cSCL1SDA0, i2cSCL0SDA0) // Repeated start.
+		}
+	}
+	if err == nil && len(r) > 0 {
+		i2c.skip++
+		err = i2c.writeByte(dl, uint8(addr)<<1|1, false)
+		for i := 0; i < len(r) && err == nil; i++ {
+			// Acknowledge all bytes but the last one. SDA is released to read.
+			word := uint16(i2cAck)
+			if i == len(r)-1 {
+				word = i2cFinal
+			}
+			err = i2c.put(dl, word)
+		}
+	}
+	if err == errI2CNack {
+		// The state machine stopped on the NAK, resume it to send the stop condition.
+		i2c.resume()
+	}
+	if err == nil || err == errI2CNack {
+		stopErr := i2c.exec(dl, i2cSCL0SDA0, i2cSCL1SDA0, i2cSCL1SDA1) // Stop condition: SDA rises while SCL is high.
+		if stopErr == nil {
+			stopErr = i2c.wait(dl)
+		}
+		if stopErr != nil {
+			err = stopErr
+		}
+	}
+	if err == errTimeout {
+		i2c.reset()
+	}
+	i2c.rx = nil
+	return err
+}
+
+// ReadRegister reads len(buf) bytes from register r of the device at address addr.
+func (i2c *I2C) ReadRegister(addr uint8, r uint8, buf []byte) error {
+	i2c.reg[0] = r
+	return i2c.Tx(uint16(addr), i2c.reg[:], buf)
+}
+
+// WriteRegister writes buf to register r of the device at address addr.
+func (i2c *I2C) WriteRegister(addr uint8, r uint8, buf []byte) error {
+	data := make([]byte, len(buf)+1)
+	data[0] = r
+	copy(data[1:], buf)
+	return i2c.Tx(uint16(addr), data, nil)
+}
+
+func (i2c *I2C) writeByte(dl deadline, b byte, final bool) error {
+	word := uint16(^b) << i2cDataPos // A 1 bit releases SDA.
+	if final {
+		word |= i2cFinal
+	}
+	return i2c.put(dl, word)
+}
+
+// exec makes the state machine execute the i2c_set_scl_sda instructions at indices.
+func (i2c *I2C) exec(dl deadline, indices ...uint8) error {
+	err := i2c.put(dl, uint16(len(indices)-1)<<i2cIcountPos)
+	for i := 0; i < len(indices) && err == nil; i++ {
+		err = i2c.put(dl, i2c_set_scl_sdaInstructions[indices[i]])
+	}
+	return err
+}
+
+// put writes a word to the TX FIFO, reading received bytes while it is full.
+func (i2c *I2C) put(dl deadline, word uint16) error {
+	for i2c.sm.IsTxFIFOFull() {
+		if err := i2c.poll(dl); err != nil {
+			return err
+		}
+		gosched()
+	}
+	if err := i2c.poll(dl); err != nil {
+		return err
+	}
+	i2c.sm.TxPut(uint32(word) << 16) // Words are shifted out from the upper half.
+	return nil
+}
+
+// wait waits for the state machine to run out of words, reading the last bytes.
+func (i2c *I2C) wait(dl deadline) error {
+	i2c.sm.ClearTxStalled()
+	for !i2c.sm.IsTxStalled() {
+		if err := i2c.poll(dl); err != nil {
+			return err
+		}
+		gosched()
+	}
+	return i2c.poll(dl)
+}
+
+// poll reads received bytes and checks for a NAK or timeout.
+func (i2c *I2C) poll(dl deadline) error {
+	for !i2c.sm.IsRxFIFOEmpty() {
+		b := byte(i2c.sm.RxGet())
+		if i2c.skip > 0 {
+			i2c.skip-- // Byte written by us.
+		} else if len(i2c.rx) > 0 {
+			i2c.rx[0] = b
+			i2c.rx = i2c.rx[1:]
+		}
+	}
+	if i2c.nacked() {
+		return errI2CNack
+	}
+	if dl.expired() {
+		return errTimeout
+	}
+	return nil
+}
+
+// nacked returns true if the state machine stopped on an unexpected NAK.
+func (i2c *I2C) nacked() bool {
+	return i2c.sm.PIO().GetIRQ()&(1<<i2c.sm.StateMachineIndex()) != 0
+}
+
+// resume discards the queued words of the state machine stopped on a NAK and
+// returns it to the entry point.
+func (i2c *I2C) resume() {
+	for !i2c.sm.IsTxFIFOEmpty() {
+		i2c.sm.Exec(pio.EncodeOut(pio.SrcDestNull, 32))
+	}
+	for !i2c.sm.IsRxFIFOEmpty() {
+		i2c.sm.RxGet()
+	}
+	i2c.sm.Jmp(i2c.offset+i2coffset_entry_point, pio.JmpAlways)
+	i2c.sm.PIO().ClearIRQ(1 << i2c.sm.StateMachineIndex())
+}
+
+// reset aborts a transfer and releases the bus.
+func (i2c *I2C) reset() {
+	i2c.sm.ClearFIFOs()
+	i2c.sm.Restart()
+	i2c.sm.SetPindirsConsecutive(i2c.sda, 2, false)
+	i2c.sm.Jmp(i2c.offset+i2coffset_entry_point, pio.JmpAlways)
+	i2c.sm.PIO().ClearIRQ(1 << i2c.sm.StateMachineIndex())
+}
+
+// Suspend halts the state machine and releases SDA and SCL with pulls off, so
+// devices can be hot-plugged or power-cycled safely. The configuration is kept
+// for Resume. The bus must be idle and must not be used until resumed.
+func (i2c *I2C) Suspend() {
+	i2c.susp.suspend(i2c.sm, 0b11<<i2c.sda)
+}
+
+// Resume restores the pins and restarts the state machine halted by Suspend.
+func (i2c *I2C) Resume() {
+	i2c.susp.resume(i2c.sm, 0b11<<i2c.sda)
+}
+
+// Placement returns the state machine and program used by the bus master.
+func (i2c *I2C) Placement() Placement {
+	var p Placement
+	p.addSM(i2c.sm, i2c.offset, i2cInstructions)
+	return p
+}
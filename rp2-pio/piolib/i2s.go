@@ -9,12 +9,15 @@ import (
 	pio "github.com/tinygo-org/pio/rp2-pio"
 )
 
+var _ pio.TxStream[uint32] = (*I2S)(nil)
+
 // I2S is a wrapper around a PIO state machine that implements I2S.
 // Currently only supports writing to the I2S peripheral.
 type I2S struct {
 	sm      pio.StateMachine
 	offset  uint8
 	writing bool
+	stats   fifoMonitor
 }
 
 // NewI2S creates a new I2S peripheral using the given PIO state machine.
@@ -66,6 +69,7 @@ func (i2s *I2S) SetSampleFrequency(freq uint32) error {
 		return err
 	}
 	i2s.sm.SetClkDiv(whole, frac)
+	trackClock(i2s.sm, freq)
 	return nil
 }
 
@@ -79,6 +83,26 @@ func (i2s *I2S) WriteStereo(b []uint32) (int, error) {
 	return i2sWrite(i2s, b)
 }
 
+// Put writes a stereo sample, blocking while the TX FIFO is full. It implements
+// pio.TxStream, so audio mixers can be written against the stream.
+func (i2s *I2S) Put(v uint32) error {
+	for i2s.sm.IsTxFIFOFull() {
+		gosched()
+	}
+	i2s.sm.TxPut(v)
+	return nil
+}
+
+// TryPut writes a stereo sample if the TX FIFO is not full.
+func (i2s *I2S) TryPut(v uint32) bool {
+	return i2s.sm.TxPutFromISR(v)
+}
+
+// Write writes stereo samples like WriteStereo.
+func (i2s *I2S) Write(p []uint32) (int, error) {
+	return i2sWrite(i2s, p)
+}
+
 // ReadMono reads a mono audio buffer from the I2S peripheral.
 func (i2s *I2S) ReadMono(p []uint16) (n int, err error) {
 	return 0, errors.ErrUnsupported
@@ -98,14 +122,17 @@ func i2sWrite[T uint16 | uint32](i2s *I2S, b []T) (int, error) {
 	}
 	i2s.writing = true
 	i := 0
+	i2s.stats.begin(i2s.sm)
 	for i < len(b) {
 		if i2s.sm.IsTxFIFOFull() {
+			i2s.stats.txFull(i2s.sm)
 			gosched()
 			continue
 		} else if !i2s.writing {
 			return i, nil
 		}
 		i2s.sm.TxPut(uint32(b[i]))
+		i2s.stats.sample(i2s.sm)
 		i++
 	}
 	i2s.writing = false
@@ -116,3 +143,20 @@ func i2sWrite[T uint16 | uint32](i2s *I2S, b []T) (int, error) {
 func (i2s *I2S) Enable(enabled bool) {
 	i2s.sm.SetEnabled(enabled)
 }
+
+// Stats returns the FIFO statistics of writes gathered while EnableFIFOStats is set.
+func (i2s *I2S) Stats() FIFOStats {
+	return i2s.stats.stats
+}
+
+// ResetStats clears the FIFO statistics.
+func (i2s *I2S) ResetStats() {
+	i2s.stats.stats = FIFOStats{}
+}
+
+// Placement returns the state machine and program used by I2S.
+func (i2s *I2S) Placement() Placement {
+	var p Placement
+	p.addSM(i2s.sm, i2s.offset, i2sInstructions)
+	return p
+}
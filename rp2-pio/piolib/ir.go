@@ -0,0 +1,202 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errIRCarrier      = errors.New("piolib:IR carrier frequency out of range")
+	errIRFrameOverrun = errors.New("piolib:IR frame measurement lost")
+)
+
+// Default space after which a captured IR frame is considered complete. Long air
+// conditioner frames may be split in sections separated by up to ~35ms of space.
+const irDefaultGap = 50 * time.Millisecond
+
+// IRReceiver captures the raw mark and space timings of infrared remote control
+// frames from a demodulating receiver module such as the TSOP38238, whose output
+// is low during marks. Raw timings allow cloning remotes of any protocol, including
+// the long frames of air conditioners that protocol decoders reject.
+type IRReceiver struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	gap    time.Duration
+}
+
+// NewIRReceiver returns an IR receiver on pin. The state machine runs at the CPU frequency.
+func NewIRReceiver(sm pio.StateMachine, pin machine.Pin) (*IRReceiver, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	return &IRReceiver{sm: sm, offset: offset, gap: irDefaultGap}, nil
+}
+
+// SetTimeout sets the time CaptureRaw waits for a frame to start. Use 0 as
+// argument to disable timeouts.
+func (ir *IRReceiver) SetTimeout(timeout time.Duration) {
+	ir.dl.setTimeout(timeout)
+}
+
+// SetGap sets the space after which a frame is considered complete. It defaults to 50ms.
+func (ir *IRReceiver) SetGap(gap time.Duration) {
+	ir.gap = gap
+}
+
+// CaptureRaw waits for the next frame and stores its timings in buf, alternating
+// between mark and space durations and starting with a mark. It returns the number
+// of timings stored, which is odd unless buf filled up before the frame ended.
+// The result can be replayed with IRTransmitter.SendRaw.
+func (ir *IRReceiver) CaptureRaw(buf []time.Duration) (n int, err error) {
+	cpufreq := uint64(machine.CPUFrequency())
+	for !ir.sm.IsRxFIFOEmpty() {
+		ir.sm.RxGet() // Discard measurements preceding the call.
+	}
+	started := false
+	dl := ir.dl.newDeadline()
+	last := time.Now()
+	for n < len(buf) {
+		if ir.sm.IsRxFIFOEmpty() {
+			if started && time.Since(last) > ir.gap {
+				break // The last mark was followed by the frame gap.
+			}
+			if !started && dl.expired() {
+				return 0, errTimeout
+			}
+			gosched()
+			continue
+		}
+		last = time.Now()
+		cycles, high := pulsewidthDecode(ir.sm.RxGet())
+		d := time.Duration(cycles * uint64(time.Second) / cpufreq)
+		switch {
+		case high && d > ir.gap:
+			if started {
+				return n, nil
+			}
+			started = true // Idle line before the frame.
+		case !started:
+			// Joined a frame in progress, wait for the next one.
+		case high == (n%2 == 0):
+			// A measurement was dropped because the FIFO was full.
+			return n, errIRFrameOverrun
+		default:
+			buf[n] = d
+			n++
+		}
+	}
+	return n, nil
+}
+
+// IRTransmitter sends infrared remote control frames as raw mark and space timings,
+// modulating marks with a carrier. The IR LED is driven high during carrier pulses.
+type IRTransmitter struct {
+	sm      pio.StateMachine
+	offset  uint8
+	dl      deadliner
+	carrier uint32
+}
+
+// NewIRTransmitter returns an IR transmitter driving the IR LED on pin.
+func NewIRTransmitter(sm pio.StateMachine, pin machine.Pin) (*IRTransmitter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(ir_txInstructions, ir_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPinsConsecutive(pin, 1, false)
+	sm.SetPindirsConsecutive(pin, 1, true)
+	cfg := ir_txProgramDefaultConfig(offset)
+	cfg.SetSetPins(pin, 1)
+	cfg.SetOutShift(true, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return &IRTransmitter{sm: sm, offset: offset}, nil
+}
+
+// SetTimeout sets the timeout for SendRaw. Use 0 as argument to disable timeouts.
+func (ir *IRTransmitter) SetTimeout(timeout time.Duration) {
+	ir.dl.setTimeout(timeout)
+}
+
+// SendRaw transmits timings, which alternate between mark and space durations
+// starting with a mark, with marks modulated at carrierHz, usually 36kHz to 40kHz.
+// Durations are rounded to whole carrier periods. SendRaw returns once the
+// frame has been transmitted.
+func (ir *IRTransmitter) SendRaw(timings []time.Duration, carrierHz uint32) error {
+	if carrierHz == 0 || carrierHz > machine.CPUFrequency()/8 {
+		return errIRCarrier
+	}
+	if carrierHz != ir.carrier {
+		// A carrier period takes 8 cycles.
+		whole, frac, err := pio.ClkDivFromFrequency(carrierHz*8, machine.CPUFrequency())
+		if err != nil {
+			return err
+		}
+		ir.sm.SetClkDiv(whole, frac)
+		trackClock(ir.sm, carrierHz*8)
+		ir.carrier = carrierHz
+	}
+	dl := ir.dl.newDeadline()
+	for i, d := range timings {
+		periods := (uint64(d)*uint64(carrierHz) + uint64(time.Second)/2) / uint64(time.Second)
+		if periods == 0 {
+			periods = 1
+		} else if periods > 1<<31 {
+			periods = 1 << 31
+		}
+		word := uint32(periods-1) << 1
+		if i%2 == 0 {
+			word |= 1 // Mark.
+		}
+		for ir.sm.IsTxFIFOFull() {
+			if dl.expired() {
+				return errTimeout
+			}
+			gosched()
+		}
+		ir.sm.TxPut(word)
+	}
+	for !ir.sm.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// Done once the transmitter stalls waiting for data after the last timing.
+	ir.sm.ClearTxStalled()
+	for !ir.sm.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// Placement returns the state machine and program used by the receiver.
+func (ir *IRReceiver) Placement() Placement {
+	var p Placement
+	p.addSM(ir.sm, ir.offset, pulsewidthInstructions)
+	return p
+}
+
+// Placement returns the state machine and program used by the transmitter.
+func (ir *IRTransmitter) Placement() Placement {
+	var p Placement
+	p.addSM(ir.sm, ir.offset, ir_txInstructions)
+	return p
+}
@@ -0,0 +1,317 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Low time separating the segments of a transmission: the header of KeeLoq
+	// frames, 10 Te, and the sync of fixed codes, 31 units.
+	keyfobSyncUs = 2500
+	// Default width under which pulses are taken as noise spikes.
+	keyfobDefaultGlitch = 80 * time.Microsecond
+	// Bits of a KeeLoq frame: 32 bit hopping code, 28 bit serial number, 4 button
+	// bits, low battery and repeat flags.
+	keyfobRollingBits = 66
+	// Bit counts of fixed code frames, such as the 24 bits of EV1527 and the 12
+	// trits of PT2262 sent as pairs of bits.
+	keyfobFixedMinBits = 12
+	keyfobFixedMaxBits = 32
+	// Shortest preamble of equal pulses and lows, 23 on KeeLoq encoders.
+	keyfobPreamblePulses = 8
+	// Pulse width histogram of bins of 64us up to 2048us.
+	keyfobBinShift = 6
+	keyfobBins     = 32
+)
+
+// KeyfobKind is the kind of code of a keyfob frame.
+type KeyfobKind uint8
+
+const (
+	// KeyfobFixed is a fixed code frame such as sent by EV1527 and PT2262 encoders,
+	// a long pulse being a one.
+	KeyfobFixed KeyfobKind = iota
+	// KeyfobRolling is a KeeLoq rolling code frame, a short pulse being a one.
+	KeyfobRolling
+)
+
+// KeyfobFrame is a frame captured by KeyfobReceiver. The decryption of rolling
+// codes is left to upper layers.
+type KeyfobFrame struct {
+	Kind KeyfobKind
+	// Bits is the number of bits of Data.
+	Bits uint8
+	// Data holds the bits as sent: LSB first for rolling codes, as KeeLoq encoders
+	// send them, and MSB first for fixed codes.
+	Data [9]byte
+	// Te is the elemental period, the width of short pulses.
+	Te time.Duration
+	// Preamble is set if the frame followed a preamble, as KeeLoq frames do.
+	Preamble bool
+}
+
+// Rolling returns the fields of a KeeLoq frame: the encrypted hopping code, the
+// serial number and button bits sent in clear, and the low battery and repeat
+// flags.
+func (f *KeyfobFrame) Rolling() (hop, serial uint32, buttons uint8, lowBattery, repeat bool) {
+	d := f.Data
+	hop = uint32(d[0]) | uint32(d[1])<<8 | uint32(d[2])<<16 | uint32(d[3])<<24
+	serial = (uint32(d[4]) | uint32(d[5])<<8 | uint32(d[6])<<16 | uint32(d[7])<<24) & (1<<28 - 1)
+	buttons = d[7] >> 4
+	return hop, serial, buttons, d[8]&1 != 0, d[8]&2 != 0
+}
+
+// KeyfobReceiver is the front-end of a 315/433MHz keyfob receiver, decoding the
+// output of an OOK receiver module such as the RXB6, high while a carrier is
+// received. It captures KeeLoq rolling code frames and fixed code frames from
+// the pulse widths measured by the state machine.
+//
+// The automatic gain of receiver modules turns the idle band into random pulses.
+// Without an RSSI reading, noise is filtered by the pulse widths alone: spikes
+// are merged into the surrounding pulse, and a segment of pulses is only decoded
+// if the widths of nearly all its pulses fall in two clusters of widths, the short
+// and long pulses of a code.
+type KeyfobReceiver struct {
+	sm      pio.StateMachine
+	offset  uint8
+	dl      deadliner
+	glitch  uint32
+	cpufreq uint64
+	// Pulse widths in microseconds of the current segment, alternating between
+	// high and low times and starting high.
+	widths [2*keyfobRollingBits + 2]uint16
+	n      int
+	merge  bool
+	skip   bool
+	// Te of the preamble preceding the current segment, 0 if none.
+	preamble uint16
+}
+
+// NewKeyfobReceiver returns a keyfob receiver on pin. The state machine runs at the
+// CPU frequency.
+func NewKeyfobReceiver(sm pio.StateMachine, pin machine.Pin) (*KeyfobReceiver, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	r := &KeyfobReceiver{sm: sm, offset: offset, cpufreq: uint64(machine.CPUFrequency())}
+	r.SetGlitchFilter(keyfobDefaultGlitch)
+	return r, nil
+}
+
+// SetTimeout sets the time Receive waits for a frame. Use 0 as argument to disable
+// timeouts.
+func (r *KeyfobReceiver) SetTimeout(timeout time.Duration) {
+	r.dl.setTimeout(timeout)
+}
+
+// SetGlitchFilter sets the width under which pulses are taken as noise spikes, 80us
+// by default.
+func (r *KeyfobReceiver) SetGlitchFilter(min time.Duration) {
+	r.glitch = uint32(min / time.Microsecond)
+}
+
+// Receive waits for the next frame. Keyfobs repeat their frame while a button is
+// held, so a frame missed in noise is usually received on the next repeat.
+func (r *KeyfobReceiver) Receive() (KeyfobFrame, error) {
+	dl := r.dl.newDeadline()
+	for {
+		if r.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				return KeyfobFrame{}, errTimeout
+			}
+			gosched()
+			continue
+		}
+		cycles, high := pulsewidthDecode(r.sm.RxGet())
+		us := cycles * 1e6 / r.cpufreq
+		if us > 0xffff {
+			us = 0xffff
+		}
+		if r.pulse(uint16(us), high) {
+			var f KeyfobFrame
+			ok := r.classify(&f)
+			r.n = 0
+			if ok {
+				return f, nil
+			}
+		}
+	}
+}
+
+// pulse adds a measurement to the segment, returning true when a sync low ends it.
+func (r *KeyfobReceiver) pulse(us uint16, high bool) bool {
+	if r.merge {
+		// The rest of the pulse a spike split, of the level of the last width.
+		r.merge = false
+		r.widths[r.n-1] = satAdd16(r.widths[r.n-1], us)
+		return r.n%2 == 0 && r.widths[r.n-1] >= keyfobSyncUs
+	}
+	if uint32(us) < r.glitch {
+		if r.n > 0 && !r.skip {
+			r.widths[r.n-1] = satAdd16(r.widths[r.n-1], us)
+			r.merge = true
+		}
+		return false
+	}
+	if r.n > 0 && (r.n%2 == 0) != high {
+		// A measurement was dropped because the FIFO was full.
+		r.n, r.skip, r.preamble = 0, true, 0
+	}
+	if !high && us >= keyfobSyncUs {
+		if r.skip || r.n == 0 {
+			r.n, r.skip = 0, false
+			return false
+		}
+		r.widths[r.n] = us
+		r.n++
+		return true
+	}
+	if r.skip || r.n == 0 && !high {
+		return false
+	}
+	if r.n == len(r.widths) {
+		// Longer than any frame, the band is noisy.
+		r.n, r.skip, r.preamble = 0, true, 0
+		return false
+	}
+	r.widths[r.n] = us
+	r.n++
+	return false
+}
+
+// classify decodes the segment ended by a sync low into f, returning false if it
+// is a preamble or noise.
+func (r *KeyfobReceiver) classify(f *KeyfobFrame) bool {
+	seg := r.widths[:r.n-1] // Without the sync low.
+	pulses := (len(seg) + 1) / 2
+	if pulses >= keyfobPreamblePulses && pulses != keyfobRollingBits {
+		if te, ok := keyfobPreamble(seg); ok {
+			r.preamble = te
+			return false
+		}
+	}
+	preamble := r.preamble
+	r.preamble = 0
+	// The last bit of a KeeLoq frame ends in the guard time, while fixed codes
+	// end with the high of their sync, which is not a bit.
+	bits := pulses
+	if pulses != keyfobRollingBits {
+		bits = len(seg) / 2
+		if bits < keyfobFixedMinBits || bits > keyfobFixedMaxBits {
+			return false
+		}
+	}
+	short, long, ok := keyfobClusters(seg)
+	if !ok {
+		return false
+	}
+	threshold := (short + long) / 2
+	*f = KeyfobFrame{Kind: KeyfobFixed, Bits: uint8(bits), Te: time.Duration(short) * time.Microsecond, Preamble: preamble != 0}
+	if bits == keyfobRollingBits {
+		f.Kind = KeyfobRolling
+	}
+	for i := 0; i < bits; i++ {
+		one := uint32(seg[2*i]) >= threshold
+		if f.Kind == KeyfobRolling {
+			if !one {
+				f.Data[i/8] |= 1 << (i % 8)
+			}
+		} else if one {
+			f.Data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return true
+}
+
+// keyfobPreamble returns the mean width of seg if all its pulses and lows are
+// within 1/3 of it, as in a preamble.
+func keyfobPreamble(seg []uint16) (te uint16, ok bool) {
+	var sum uint32
+	for _, w := range seg {
+		sum += uint32(w)
+	}
+	mean := sum / uint32(len(seg))
+	for _, w := range seg {
+		if d := int32(w) - int32(mean); d > int32(mean/3) || -d > int32(mean/3) {
+			return 0, false
+		}
+	}
+	return uint16(mean), true
+}
+
+// keyfobClusters builds the histogram of the pulse widths of seg, the high times,
+// and returns the mean widths of its two main clusters. It fails if fewer than
+// 7/8 of the pulses are in the clusters, or if the long pulses are not 1.5 to 4.5
+// times as wide as the short ones.
+func keyfobClusters(seg []uint16) (short, long uint32, ok bool) {
+	var hist [keyfobBins]uint8
+	pulses := 0
+	for i := 0; i < len(seg); i += 2 {
+		bin := int(seg[i]) >> keyfobBinShift
+		if bin >= keyfobBins {
+			return 0, 0, false
+		}
+		hist[bin]++
+		pulses++
+	}
+	p1 := 0
+	for i := range hist {
+		if hist[i] > hist[p1] {
+			p1 = i
+		}
+	}
+	p2 := -1
+	for i := range hist {
+		if (i < p1-1 || i > p1+1) && (p2 < 0 || hist[i] > hist[p2]) {
+			p2 = i
+		}
+	}
+	if p2 < 0 || hist[p2] == 0 {
+		return 0, 0, false
+	}
+	var sum, count [2]uint32
+	for i := 0; i < len(seg); i += 2 {
+		bin := int(seg[i]) >> keyfobBinShift
+		for c, p := range [2]int{p1, p2} {
+			if bin >= p-1 && bin <= p+1 {
+				sum[c] += uint32(seg[i])
+				count[c]++
+				break
+			}
+		}
+	}
+	if 8*(count[0]+count[1]) < 7*uint32(pulses) {
+		return 0, 0, false
+	}
+	short, long = sum[0]/count[0], sum[1]/count[1]
+	if short > long {
+		short, long = long, short
+	}
+	if 2*long < 3*short || 2*long > 9*short {
+		return 0, 0, false
+	}
+	return short, long, true
+}
+
+// satAdd16 returns a+b, saturated to the range of uint16.
+func satAdd16(a, b uint16) uint16 {
+	if s := uint32(a) + uint32(b); s <= 0xffff {
+		return uint16(s)
+	}
+	return 0xffff
+}
+
+// Placement returns the state machine and program used by the receiver.
+func (r *KeyfobReceiver) Placement() Placement {
+	var p Placement
+	p.addSM(r.sm, r.offset, pulsewidthInstructions)
+	return p
+}
@@ -0,0 +1,154 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"testing"
+	"time"
+)
+
+// keyfobTrain is a synthetic pulse train, alternating high and low widths in
+// microseconds starting high.
+type keyfobTrain []uint16
+
+func (t *keyfobTrain) add(widths ...uint16) {
+	*t = append(*t, widths...)
+}
+
+// feed passes the train to r as measured by the state machine and returns the
+// frames decoded.
+func (t keyfobTrain) feed(r *KeyfobReceiver) []KeyfobFrame {
+	var frames []KeyfobFrame
+	for i, us := range t {
+		if r.pulse(us, i%2 == 0) {
+			var f KeyfobFrame
+			ok := r.classify(&f)
+			r.n = 0
+			if ok {
+				frames = append(frames, f)
+			}
+		}
+	}
+	return frames
+}
+
+func newTestKeyfobReceiver() *KeyfobReceiver {
+	r := &KeyfobReceiver{}
+	r.SetGlitchFilter(keyfobDefaultGlitch)
+	return r
+}
+
+// ev1527Train returns two repeats of the EV1527 frame of the 24 bits of code, MSB
+// first, each after a sync of a high of 1 Te and a low of 31 Te.
+func ev1527Train(code uint32, te uint16) keyfobTrain {
+	var t keyfobTrain
+	for repeat := 0; repeat < 2; repeat++ {
+		t.add(te, 31*te)
+		for i := 23; i >= 0; i-- {
+			if code&(1<<i) != 0 {
+				t.add(3*te, te)
+			} else {
+				t.add(te, 3*te)
+			}
+		}
+	}
+	t.add(te, 31*te)
+	return t
+}
+
+func TestKeyfobFixed(t *testing.T) {
+	const code = 0xA5C3F1
+	r := newTestKeyfobReceiver()
+	frames := ev1527Train(code, 350).feed(r)
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	for _, f := range frames {
+		if f.Kind != KeyfobFixed || f.Bits != 24 {
+			t.Errorf("kind %d, %d bits, want fixed code of 24 bits", f.Kind, f.Bits)
+		}
+		if got := uint32(f.Data[0])<<16 | uint32(f.Data[1])<<8 | uint32(f.Data[2]); got != code {
+			t.Errorf("code %#x, want %#x", got, code)
+		}
+		if f.Data[3] != 0 {
+			t.Errorf("sync decoded as a bit: data %x", f.Data)
+		}
+		if f.Te != 350*time.Microsecond {
+			t.Errorf("Te %v, want 350µs", f.Te)
+		}
+		if f.Preamble {
+			t.Error("preamble set on a fixed code")
+		}
+	}
+}
+
+func TestKeyfobRolling(t *testing.T) {
+	const (
+		te         = 400
+		hop        = 0x12345678
+		serial     = 0xABCDEF1
+		buttons    = 0x5
+		lowBattery = true
+		repeat     = false
+	)
+	var bits [keyfobRollingBits]bool
+	for i := 0; i < 32; i++ {
+		bits[i] = hop&(1<<i) != 0
+	}
+	for i := 0; i < 28; i++ {
+		bits[32+i] = serial&(1<<i) != 0
+	}
+	for i := 0; i < 4; i++ {
+		bits[60+i] = buttons&(1<<i) != 0
+	}
+	bits[64], bits[65] = lowBattery, repeat
+
+	var train keyfobTrain
+	// Preamble of 23 pulses, the last low being the 10 Te header.
+	for i := 0; i < 22; i++ {
+		train.add(te, te)
+	}
+	train.add(te, 10*te)
+	// A one is a short pulse, the low of the last bit runs into the guard time.
+	for i, one := range bits {
+		high, low := uint16(2*te), uint16(te)
+		if one {
+			high, low = te, 2*te
+		}
+		if i == len(bits)-1 {
+			low += 39 * te
+		}
+		train.add(high, low)
+	}
+
+	r := newTestKeyfobReceiver()
+	frames := train.feed(r)
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(frames))
+	}
+	f := frames[0]
+	if f.Kind != KeyfobRolling || f.Bits != keyfobRollingBits {
+		t.Fatalf("kind %d, %d bits, want rolling code of %d bits", f.Kind, f.Bits, keyfobRollingBits)
+	}
+	if !f.Preamble {
+		t.Error("preamble not detected")
+	}
+	gotHop, gotSerial, gotButtons, gotLowBattery, gotRepeat := f.Rolling()
+	if gotHop != hop || gotSerial != serial || gotButtons != buttons || gotLowBattery != lowBattery || gotRepeat != repeat {
+		t.Errorf("Rolling() = %#x, %#x, %#x, %v, %v, want %#x, %#x, %#x, %v, %v",
+			gotHop, gotSerial, gotButtons, gotLowBattery, gotRepeat, hop, serial, buttons, lowBattery, repeat)
+	}
+}
+
+func TestKeyfobGlitch(t *testing.T) {
+	// A dip splitting the long pulse of a one is merged back into it.
+	train := ev1527Train(0x123456, 350)
+	train = append(train[:8:8], append(keyfobTrain{200, 30, 850}, train[9:]...)...)
+	frames := train.feed(newTestKeyfobReceiver())
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if got := uint32(frames[0].Data[0])<<16 | uint32(frames[0].Data[1])<<8 | uint32(frames[0].Data[2]); got != 0x123456 {
+		t.Errorf("code %#x, want 0x123456", got)
+	}
+}
@@ -0,0 +1,205 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"math/bits"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Full matrix scans per second.
+	keyMatrixScanHz = 1000
+	// State machine cycles per row, see keymatrix.pio.
+	keyMatrixRowCycles = 41
+	// Default number of identical scans before a key change is accepted.
+	keyMatrixDebounceScans = 5
+)
+
+var errKeyMatrixSize = errors.New("piolib:key matrix must have 1..16 rows and columns")
+
+// KeyEvent is a debounced change of a key of a KeyMatrix.
+type KeyEvent struct {
+	Row, Col uint8
+	Pressed  bool
+}
+
+// KeyMatrix scans a keyboard matrix of up to 16 rows by 16 columns without
+// CPU intervention and turns the scans into debounced key events. Rows are
+// driven low one at a time and columns are read with their pull-ups enabled, so
+// keys connect a row to a column with no diodes or with diodes pointing to the rows.
+//
+// Matrices without diodes show a phantom key at the fourth corner of a rectangle
+// of 3 pressed keys. KeyMatrix reports no new key presses while the pressed keys
+// form such a rectangle, see Ghosted, so phantom keys are never reported.
+//
+// KeyMatrix is independent of how the keys are used: a USB HID keyboard, a MIDI
+// controller or a keypad are built on top of the events returned by Poll.
+type KeyMatrix struct {
+	sm       pio.StateMachine
+	offset   uint8
+	rows     uint8
+	colMask  uint16
+	debounce uint8
+	ghosted  bool
+	// Per row: last scanned columns, number of identical scans since they
+	// changed, debounced columns and columns reported by Poll.
+	raw      [16]uint16
+	same     [16]uint8
+	stable   [16]uint16
+	reported [16]uint16
+}
+
+// NewKeyMatrix starts scanning a matrix with rows consecutive row pins from
+// rowBase and cols consecutive column pins from colBase, 1000 times per second.
+// Key changes are accepted after 5ms of stable scans, see SetDebounce.
+func NewKeyMatrix(sm pio.StateMachine, rowBase machine.Pin, rows uint8, colBase machine.Pin, cols uint8) (*KeyMatrix, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if rows == 0 || rows > 16 || cols == 0 || cols > 16 {
+		return nil, errKeyMatrixSize
+	}
+	smFreq := uint32(keyMatrixScanHz) * (keyMatrixRowCycles*uint32(rows) + 1)
+	whole, frac, err := pio.ClkDivFromFrequency(smFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(keymatrixInstructions, keymatrixOrigin)
+	if err != nil {
+		return nil, err
+	}
+	// Rows are open drain: low when selected, released otherwise.
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for pin := rowBase; pin < rowBase+machine.Pin(rows); pin++ {
+		pin.Configure(pinCfg)
+	}
+	// The PIO reads the columns whatever their function, keep them as SIO inputs
+	// for the pull-ups.
+	for pin := colBase; pin < colBase+machine.Pin(cols); pin++ {
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	}
+	sm.SetPinsConsecutive(rowBase, rows, false)
+	sm.SetPindirsConsecutive(rowBase, rows, false)
+
+	cfg := keymatrixProgramDefaultConfig(offset)
+	cfg.SetOutPins(rowBase, rows)
+	cfg.SetInPins(colBase)
+	cfg.SetOutShift(true, false, 32)
+	cfg.SetInShift(false, false, 32)
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, smFreq)
+	// X holds the mask past the last row. The TX FIFO is joined to RX so it can't
+	// be loaded with SetX: shift it into the ISR instead.
+	sm.Exec(pio.EncodeSet(pio.SrcDestX, 1))
+	sm.Exec(pio.EncodeMov(pio.SrcDestISR, pio.SrcDestX))
+	sm.Exec(pio.EncodeIn(pio.SrcDestNull, rows))
+	sm.Exec(pio.EncodeMov(pio.SrcDestX, pio.SrcDestISR))
+	sm.Exec(pio.EncodeMov(pio.SrcDestISR, pio.SrcDestNull))
+	sm.SetEnabled(true)
+	return &KeyMatrix{
+		sm:       sm,
+		offset:   offset,
+		rows:     rows,
+		colMask:  uint16(1<<cols - 1),
+		debounce: keyMatrixDebounceScans,
+	}, nil
+}
+
+// SetDebounce sets how long a key must be stable before its change is reported,
+// rounded to the 1ms scan period. Defaults to 5ms.
+func (m *KeyMatrix) SetDebounce(d time.Duration) {
+	scans := d / (time.Second / keyMatrixScanHz)
+	if scans < 1 {
+		scans = 1
+	} else if scans > 255 {
+		scans = 255
+	}
+	m.debounce = uint8(scans)
+}
+
+// Poll processes the scans since the last call and stores the resulting key
+// events in events, returning their number. Changes that don't fit in events
+// are returned by the next calls. The state machine keeps the last 8 row scans
+// and drops the others, so Poll should be called at least every 1ms per 8 rows
+// for debouncing to see every scan. Dropped scans only delay events.
+func (m *KeyMatrix) Poll(events []KeyEvent) int {
+	for !m.sm.IsRxFIFOEmpty() {
+		word := m.sm.RxGet()
+		row := bits.TrailingZeros16(uint16(word))
+		if row >= int(m.rows) {
+			continue
+		}
+		cols := ^uint16(word>>16) & m.colMask // Pressed keys pull their column low.
+		if cols != m.raw[row] {
+			m.raw[row] = cols
+			m.same[row] = 0
+		} else if m.same[row] < m.debounce {
+			m.same[row]++
+			if m.same[row] == m.debounce {
+				m.stable[row] = cols
+			}
+		}
+	}
+	m.ghosted = m.hasGhost()
+	n := 0
+	for row := uint8(0); row < m.rows; row++ {
+		changed := m.stable[row] ^ m.reported[row]
+		if m.ghosted {
+			changed &^= m.stable[row] // Only releases.
+		}
+		for changed != 0 && n < len(events) {
+			col := uint8(bits.TrailingZeros16(changed))
+			bit := uint16(1) << col
+			changed &^= bit
+			m.reported[row] ^= bit
+			events[n] = KeyEvent{Row: row, Col: col, Pressed: m.reported[row]&bit != 0}
+			n++
+		}
+	}
+	return n
+}
+
+// hasGhost reports whether two rows share two pressed columns, making it
+// impossible to tell which of the 4 keys of the rectangle are pressed. The rows
+// are debounced separately, so the phantom key may settle in its row before the
+// key completing the rectangle settles in another: keys count as pressed as soon
+// as they are scanned, not only once debounced.
+func (m *KeyMatrix) hasGhost() bool {
+	for i := uint8(0); i < m.rows; i++ {
+		ci := m.raw[i] | m.stable[i]
+		if ci == 0 {
+			continue
+		}
+		for j := i + 1; j < m.rows; j++ {
+			if bits.OnesCount16(ci&(m.raw[j]|m.stable[j])) >= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Ghosted reports whether the last Poll found pressed keys forming a rectangle, in
+// which case new key presses are withheld until keys of the rectangle are released.
+func (m *KeyMatrix) Ghosted() bool {
+	return m.ghosted
+}
+
+// IsPressed reports whether the key at row and col is pressed, as of the events
+// returned by Poll.
+func (m *KeyMatrix) IsPressed(row, col uint8) bool {
+	return row < 16 && col < 16 && m.reported[row]&(1<<col) != 0
+}
+
+// Placement returns the state machine and program used by the scanner.
+func (m *KeyMatrix) Placement() Placement {
+	var p Placement
+	p.addSM(m.sm, m.offset, keymatrixInstructions)
+	return p
+}
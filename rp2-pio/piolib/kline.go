@@ -0,0 +1,396 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errKLineEcho     = errors.New("piolib:K-line readback mismatch")
+	errKLineSync     = errors.New("piolib:K-line bad sync byte")
+	errKLineInit     = errors.New("piolib:K-line init not acknowledged")
+	errKLineChecksum = errors.New("piolib:K-line checksum mismatch")
+	errKLineFrame    = errors.New("piolib:K-line response too long for buffer")
+)
+
+const (
+	klineBaud = 10400
+	// kline_tx runs at 8 cycles per bit.
+	klineTickFreq = klineBaud * 8
+	// Bus idle time before an init, W5 and TIdle.
+	klineIdle = 300 * time.Millisecond
+	// 5 baud address bit time.
+	kline5BaudBit = 200 * time.Millisecond
+	// Maximum time from the 5 baud address to the sync byte, W1.
+	klineW1 = 300 * time.Millisecond
+	// Maximum time between the sync byte and key bytes, W2 and W3.
+	klineW2 = 20 * time.Millisecond
+	// Time from the last key byte to the inverted key byte, and maximum time to
+	// the inverted address, W4.
+	klineW4    = 30 * time.Millisecond
+	klineW4Max = 50 * time.Millisecond
+	// Fast init wake-up pattern, TiniL low and TWuP in total.
+	klineTiniL = 25 * time.Millisecond
+	klineTWuP  = 50 * time.Millisecond
+	// Default times between a response and the next request, P3, and between
+	// bytes of a request, P4.
+	klineDefaultP3 = 55 * time.Millisecond
+	klineDefaultP4 = 5 * time.Millisecond
+	// Maximum time between bytes of a response, P1, and to the first byte, P2.
+	klineP1 = 20 * time.Millisecond
+	klineP2 = 50 * time.Millisecond
+	// Default addresses of the engine ECU, functional OBD address, and of the tester.
+	klineDefaultTarget = 0x33
+	klineDefaultTester = 0xf1
+	// StartCommunication service of ISO 14230 and its positive response.
+	klineStartCommunication   = 0x81
+	klineStartCommunicationOK = 0xc1
+)
+
+// KLine is an OBD-II K-line interface for ISO 9141-2 and ISO 14230 (KWP2000)
+// diagnostics at 10400 baud, through a K-line transceiver such as the L9637D
+// which echoes everything sent back to the receiver.
+//
+// The transmitter state machine sends the bytes and also times the idle and
+// wake-up patterns of the inits and the gaps between bytes, so the line timing
+// doesn't depend on the CPU. The receiver is a PIO UART.
+type KLine struct {
+	sm     pio.StateMachine
+	offset uint8
+	uart   *UART
+	dl     deadliner
+	target uint8
+	tester uint8
+	p3, p4 time.Duration
+	// End of the last response, from which P3 is counted.
+	lastRx time.Time
+}
+
+// NewKLine creates a K-line interface transmitting on txPin with the txsm state
+// machine and receiving on rxPin with the rxsm state machine.
+func NewKLine(txsm, rxsm pio.StateMachine, txPin, rxPin machine.Pin) (*KLine, error) {
+	whole, frac, err := pio.ClkDivFromFrequency(klineTickFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	txsm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := txsm.PIO()
+	offset, err := Pio.AddProgram(kline_txInstructions, kline_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	uart, err := NewUART(rxsm, rxsm, machine.NoPin, rxPin, klineBaud)
+	if err != nil {
+		freeProgram(Pio, offset, kline_txInstructions)
+		return nil, err
+	}
+	// Idle high before the pin is handed to the PIO.
+	txsm.SetPinsConsecutive(txPin, 1, true)
+	txsm.SetPindirsConsecutive(txPin, 1, true)
+	txPin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	cfg := kline_txProgramDefaultConfig(offset)
+	cfg.SetOutPins(txPin, 1)
+	cfg.SetSidesetPins(txPin)
+	cfg.SetOutShift(true, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	txsm.Init(offset, cfg)
+	trackClock(txsm, klineTickFreq)
+	txsm.SetEnabled(true)
+	return &KLine{
+		sm:     txsm,
+		offset: offset,
+		uart:   uart,
+		target: klineDefaultTarget,
+		tester: klineDefaultTester,
+		p3:     klineDefaultP3,
+		p4:     klineDefaultP4,
+	}, nil
+}
+
+// SetTimeout sets the timeout for queuing bytes and patterns for transmission.
+// Use 0 as argument to disable timeouts. Responses are awaited for the times set
+// by the standards.
+func (k *KLine) SetTimeout(timeout time.Duration) {
+	k.dl.setTimeout(timeout)
+}
+
+// SetAddresses sets the address of the ECU, 0x33 by default for the functional
+// OBD address, and of the tester, 0xF1 by default. The target is the address sent
+// by SlowInit and both are used in the StartCommunication request of FastInit.
+func (k *KLine) SetAddresses(target, tester uint8) {
+	k.target, k.tester = target, tester
+}
+
+// SetTiming sets the minimum idle time between the end of a response and the next
+// request, P3, 55ms by default, and the gap between the bytes of a request, P4,
+// 5ms by default.
+func (k *KLine) SetTiming(p3, p4 time.Duration) {
+	k.p3, k.p4 = p3, p4
+}
+
+// SlowInit performs the ISO 9141-2 5 baud init: the target address is sent at 5
+// baud after the bus idled, the ECU answers with a sync byte and two key bytes,
+// the second of which is acknowledged inverted, and the ECU confirms with the
+// inverted address. It returns the key bytes, 0x08 0x08 or 0x94 0x94 for ISO
+// 9141-2, or 0x8F and a KWP2000 key byte for ISO 14230.
+func (k *KLine) SlowInit() (keyBytes [2]byte, err error) {
+	if err := k.hold(true, klineIdle); err != nil {
+		return keyBytes, err
+	}
+	// Start bit, address LSB first and stop bit, merged into runs of a level.
+	bits := uint16(k.target)<<1 | 1<<9
+	for i := 0; i < 10; {
+		level := bits&(1<<i) != 0
+		run := 0
+		for ; i < 10 && (bits&(1<<i) != 0) == level; i++ {
+			run++
+		}
+		if err := k.hold(level, time.Duration(run)*kline5BaudBit); err != nil {
+			return keyBytes, err
+		}
+	}
+	if err := k.flush(); err != nil {
+		return keyBytes, err
+	}
+	// The receiver saw the address as breaks and framing errors.
+	k.uart.DiscardInput()
+	sync, err := k.readByte(klineW1)
+	if err != nil {
+		return keyBytes, err
+	} else if sync != 0x55 {
+		return keyBytes, errKLineSync
+	}
+	for i := range keyBytes {
+		if keyBytes[i], err = k.readByte(klineW2); err != nil {
+			return keyBytes, err
+		}
+	}
+	if err := k.hold(true, klineW4); err != nil {
+		return keyBytes, err
+	}
+	if err := k.write([]byte{^keyBytes[1]}); err != nil {
+		return keyBytes, err
+	}
+	addr, err := k.readByte(klineW4Max)
+	if err != nil {
+		return keyBytes, err
+	} else if addr != ^k.target {
+		return keyBytes, errKLineInit
+	}
+	k.lastRx = time.Now()
+	return keyBytes, nil
+}
+
+// FastInit performs the ISO 14230 fast init: after the bus idled, the line is held
+// low for 25ms and released for 25ms, then a StartCommunication request is sent.
+// It returns the key bytes of the positive response.
+func (k *KLine) FastInit() (keyBytes [2]byte, err error) {
+	if err := k.hold(true, klineIdle); err != nil {
+		return keyBytes, err
+	}
+	if err := k.hold(false, klineTiniL); err != nil {
+		return keyBytes, err
+	}
+	if err := k.hold(true, klineTWuP-klineTiniL); err != nil {
+		return keyBytes, err
+	}
+	req := []byte{0xc1, k.target, k.tester, klineStartCommunication, 0}
+	req[4] = klineChecksum(req[:4])
+	if err := k.write(req); err != nil {
+		return keyBytes, err
+	}
+	var buf [16]byte
+	n, err := k.readResponse(buf[:])
+	if err != nil {
+		return keyBytes, err
+	}
+	data, err := klineFrameData(buf[:n])
+	if err != nil {
+		return keyBytes, err
+	}
+	if len(data) < 3 || data[0] != klineStartCommunicationOK {
+		return keyBytes, errKLineInit
+	}
+	return [2]byte{data[1], data[2]}, nil
+}
+
+// Exchange sends request, a whole frame including its header and checksum, once
+// P3 elapsed since the last response, with P4 between bytes, and reads the
+// response into response. The response ends when no byte was received for P1,
+// 20ms. It returns the number of response bytes.
+func (k *KLine) Exchange(request, response []byte) (n int, err error) {
+	if wait := k.p3 - time.Since(k.lastRx); wait > 0 {
+		if err := k.hold(true, wait); err != nil {
+			return 0, err
+		}
+	}
+	if err := k.write(request); err != nil {
+		return 0, err
+	}
+	return k.readResponse(response)
+}
+
+// readResponse reads bytes into buf until none came for P1, the first one within P2.
+func (k *KLine) readResponse(buf []byte) (n int, err error) {
+	timeout := klineP2
+	for {
+		b, err := k.readByte(timeout)
+		if err == errTimeout && n > 0 {
+			break
+		} else if err != nil {
+			return n, err
+		}
+		if n == len(buf) {
+			return n, errKLineFrame
+		}
+		buf[n] = b
+		n++
+		timeout = klineP1
+	}
+	k.lastRx = time.Now()
+	return n, nil
+}
+
+// write sends p with P4 between bytes and verifies it is read back unaltered. It
+// waits for the patterns queued before to be sent first, so the echo of the first
+// byte is not awaited during them.
+func (k *KLine) write(p []byte) error {
+	if err := k.flush(); err != nil {
+		return err
+	}
+	k.uart.DiscardInput()
+	checked := 0
+	for i, b := range p {
+		if i > 0 && k.p4 > 0 {
+			if err := k.hold(true, k.p4); err != nil {
+				return err
+			}
+		}
+		if err := k.put(uint32(b) << 1); err != nil {
+			return err
+		}
+		// Check the echoes received so far, so long requests don't overflow the RX FIFO.
+		for ; checked < i && k.uart.Buffered() > 0; checked++ {
+			if err := k.checkEcho(p[checked]); err != nil {
+				return err
+			}
+		}
+	}
+	for ; checked < len(p); checked++ {
+		if err := k.checkEcho(p[checked]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEcho reads the echo of a byte sent, which follows it by a bit time.
+func (k *KLine) checkEcho(want byte) error {
+	got, err := k.readByte(k.p4 + klineP1)
+	if err != nil {
+		return err
+	} else if got != want {
+		return errKLineEcho
+	}
+	return nil
+}
+
+// hold queues holding the line at level for d.
+func (k *KLine) hold(level bool, d time.Duration) error {
+	ticks := uint64(d) * klineTickFreq / uint64(time.Second)
+	if ticks == 0 {
+		return nil
+	}
+	word := uint32(ticks-1)<<2 | 1
+	if level {
+		word |= 1 << 1
+	}
+	return k.put(word)
+}
+
+func (k *KLine) put(word uint32) error {
+	dl := k.dl.newDeadline()
+	for k.sm.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	k.sm.TxPut(word)
+	return nil
+}
+
+// flush blocks until everything queued was sent.
+func (k *KLine) flush() error {
+	dl := k.dl.newDeadline()
+	for !k.sm.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// Idle once the transmitter stalls waiting for data after the last word.
+	k.sm.ClearTxStalled()
+	for !k.sm.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// readByte reads a byte received within timeout.
+func (k *KLine) readByte(timeout time.Duration) (byte, error) {
+	k.uart.SetTimeout(timeout)
+	return k.uart.ReadByte()
+}
+
+// klineChecksum returns the modulo 256 sum of p, the checksum of ISO 9141-2 and
+// ISO 14230 frames.
+func klineChecksum(p []byte) (sum uint8) {
+	for _, b := range p {
+		sum += b
+	}
+	return sum
+}
+
+// klineFrameData verifies the checksum of an ISO 14230 frame and returns its data.
+func klineFrameData(frame []byte) ([]byte, error) {
+	if len(frame) < 2 {
+		return nil, errKLineFrame
+	}
+	if klineChecksum(frame[:len(frame)-1]) != frame[len(frame)-1] {
+		return nil, errKLineChecksum
+	}
+	format := frame[0]
+	hdr, length := 1, int(format&0x3f)
+	if format&0xc0 != 0 {
+		hdr += 2 // Target and source addresses.
+	}
+	if length == 0 {
+		if len(frame) <= hdr {
+			return nil, errKLineFrame
+		}
+		length = int(frame[hdr])
+		hdr++
+	}
+	if hdr+length+1 != len(frame) {
+		return nil, errKLineFrame
+	}
+	return frame[hdr : hdr+length], nil
+}
+
+// Placement returns the state machines and programs used by the interface.
+func (k *KLine) Placement() Placement {
+	p := k.uart.Placement()
+	p.addSM(k.sm, k.offset, kline_txInstructions)
+	return p
+}
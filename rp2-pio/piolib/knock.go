@@ -0,0 +1,126 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// A knock rings the piezo for a few milliseconds, crossing the comparator
+	// threshold every period: a low time longer than this ends the burst.
+	knockDefaultGap = 2 * time.Millisecond
+	// Bursts longer than this are split, so continuous vibration of a machine
+	// is reported regularly.
+	knockDefaultWindow = 50 * time.Millisecond
+	// Pulses of a burst rated at full intensity.
+	knockDefaultFullScale = 32
+)
+
+// KnockEvent is a burst of pulses of the piezo. At is the time of its first pulse
+// since the first edge seen, measured by the state machine, so the rhythm of
+// knocks is accurate however often Update is called.
+type KnockEvent struct {
+	At       time.Duration
+	Duration time.Duration
+	// Pulses is the number of threshold crossings and HighTime their total length.
+	Pulses   uint32
+	HighTime time.Duration
+	// Intensity estimates the strength of the knock from 0 to 1, from the number
+	// of pulses relative to the full scale set by SetFullScale.
+	Intensity float32
+}
+
+// Velocity returns the intensity as a MIDI note velocity, 1 to 127.
+func (e KnockEvent) Velocity() uint8 {
+	return uint8(1 + e.Intensity*126)
+}
+
+// KnockSensor captures knocks and vibration bursts picked up by a piezo element
+// whose signal is squared up by a comparator, the output being high while the
+// piezo voltage is above the threshold. The ringing of the piezo after a knock
+// crosses the threshold once per period for longer the stronger the knock, so
+// the number of pulses of a burst estimates its strength. The state machine
+// times every edge at the CPU frequency and Update groups the pulses into bursts,
+// calling the handler for each.
+type KnockSensor struct {
+	sm        pio.StateMachine
+	offset    uint8
+	bursts    pulseGrouper
+	fullScale uint32
+	handler   func(KnockEvent)
+}
+
+// NewKnockSensor captures knocks from the comparator output on pin.
+func NewKnockSensor(sm pio.StateMachine, pin machine.Pin) (*KnockSensor, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	k := &KnockSensor{
+		sm:        sm,
+		offset:    offset,
+		bursts:    newPulseGrouper(false),
+		fullScale: knockDefaultFullScale,
+	}
+	k.SetTiming(knockDefaultGap, knockDefaultWindow)
+	return k, nil
+}
+
+// SetTiming sets the low time ending a burst, 2ms by default, and the window a
+// burst is split at, 50ms by default.
+func (k *KnockSensor) SetTiming(gap, window time.Duration) {
+	k.bursts.gap = k.bursts.cycles(gap)
+	k.bursts.window = k.bursts.cycles(window)
+}
+
+// SetFullScale sets the number of pulses of a burst of intensity 1, 32 by
+// default. It depends on the piezo, its mounting and the comparator threshold.
+func (k *KnockSensor) SetFullScale(pulses uint32) {
+	if pulses == 0 {
+		pulses = 1
+	}
+	k.fullScale = pulses
+}
+
+// SetHandler sets the function called by Update for each burst. It is called from
+// Update, not from an interrupt.
+func (k *KnockSensor) SetHandler(handler func(KnockEvent)) {
+	k.handler = handler
+}
+
+// Update processes the measured edges. It must be called at least every few
+// milliseconds during bursts, as the RX FIFO holds 8 edges.
+func (k *KnockSensor) Update() {
+	k.bursts.update(k.sm, k.burst)
+}
+
+// burst reports the burst measured.
+func (k *KnockSensor) burst() {
+	b := &k.bursts
+	intensity := float32(b.pulses) / float32(k.fullScale)
+	if intensity > 1 {
+		intensity = 1
+	}
+	if k.handler != nil {
+		k.handler(KnockEvent{
+			At:        b.duration(b.start),
+			Duration:  b.duration(b.end - b.start),
+			Pulses:    b.pulses,
+			HighTime:  b.duration(b.active),
+			Intensity: intensity,
+		})
+	}
+}
+
+// Placement returns the state machine and program used by the sensor.
+func (k *KnockSensor) Placement() Placement {
+	var p Placement
+	p.addSM(k.sm, k.offset, pulsewidthInstructions)
+	return p
+}
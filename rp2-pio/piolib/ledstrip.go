@@ -0,0 +1,385 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"image/color"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errLEDNotDetected = errors.New("piolib:LED strip protocol not detected")
+
+const (
+	// Default clock of APA102 strips, well below the 20MHz the LEDs accept so long
+	// strips and cables are not an issue.
+	apa102DefaultFreq = 4_000_000
+	// Global brightness bits of APA102 LED frames, set to full brightness.
+	apa102Frame = 0xe0 | 0x1f
+	// Pattern sent past the last APA102 LED when probing, 16 pulses.
+	apa102ProbeFrame = 0xaaaaaaaa
+	// Low time latching single-wire strips, above the 280us of recent WS2812B.
+	ledLatch = 300 * time.Microsecond
+)
+
+// LEDStrip is an addressable LED strip, as returned by DetectLEDStrip.
+type LEDStrip interface {
+	// WriteColors writes colors to the strip, one per LED, ignoring their alpha.
+	WriteColors(colors []color.RGBA) error
+	// Placement returns the state machines, programs and DMA channels used.
+	Placement() Placement
+}
+
+var (
+	_ LEDStrip = (*WS2812B)(nil)
+	_ LEDStrip = (*SK6812)(nil)
+	_ LEDStrip = (*APA102)(nil)
+)
+
+// LEDProtocol is the protocol of an addressable LED strip.
+type LEDProtocol uint8
+
+const (
+	// LEDUnknown is returned when the protocol could not be detected.
+	LEDUnknown LEDProtocol = iota
+	// LEDWS2812B is a single-wire strip of RGB LEDs, see WS2812B.
+	LEDWS2812B
+	// LEDSK6812 is a single-wire strip of RGBW LEDs, see SK6812.
+	LEDSK6812
+	// LEDAPA102 is a clocked strip of RGB LEDs, see APA102.
+	LEDAPA102
+)
+
+func (p LEDProtocol) String() string {
+	switch p {
+	case LEDWS2812B:
+		return "WS2812B"
+	case LEDSK6812:
+		return "SK6812"
+	case LEDAPA102:
+		return "APA102"
+	}
+	return "unknown"
+}
+
+// SK6812 is an RGBW LED strip controller. It runs the WS2812B program shifting
+// out 32 bits per LED, the timings being the same as common NeoPixel libraries use.
+type SK6812 struct {
+	ws WS2812B
+}
+
+// NewSK6812 creates an RGBW LED strip on pin.
+func NewSK6812(sm pio.StateMachine, pin machine.Pin) (*SK6812, error) {
+	offset, err := ws2812bInit(sm, pin, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &SK6812{ws: WS2812B{sm: sm, offset: offset}}, nil
+}
+
+// PutRGBW puts a color in the transmit queue. If the queue is full it is discarded.
+func (sk *SK6812) PutRGBW(r, g, b, w uint8) {
+	sk.ws.PutRaw(uint32(g)<<24 | uint32(r)<<16 | uint32(b)<<8 | uint32(w))
+}
+
+// WriteRaw writes raw GRBW values to the strip, created with:
+//
+//	color := uint32(g)<<24 | uint32(r)<<16 | uint32(b)<<8 | uint32(w)
+func (sk *SK6812) WriteRaw(rawGRBW []uint32) error {
+	return sk.ws.WriteRaw(rawGRBW)
+}
+
+// WriteColors writes colors to the strip, one per LED, with the white LEDs off.
+func (sk *SK6812) WriteColors(colors []color.RGBA) error {
+	dl := sk.ws.dma.dl.newDeadline()
+	for _, c := range colors {
+		for sk.ws.IsQueueFull() {
+			if dl.expired() {
+				return errTimeout
+			}
+			gosched()
+		}
+		sk.PutRGBW(c.R, c.G, c.B, 0)
+	}
+	return sk.ws.guard.check(sk.ws.sm, len(colors))
+}
+
+// EnableDMA enables DMA for WriteRaw.
+func (sk *SK6812) EnableDMA(enabled bool) error {
+	return sk.ws.EnableDMA(enabled)
+}
+
+// Placement returns the state machines, programs and DMA channels used by the LED strip.
+func (sk *SK6812) Placement() Placement {
+	return sk.ws.Placement()
+}
+
+// APA102 is a controller for clocked RGB LED strips, also known as DotStar.
+type APA102 struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+}
+
+// NewAPA102 creates an LED strip clocked on clock at freq Hz, 4MHz if 0, with data
+// on data.
+func NewAPA102(sm pio.StateMachine, clock, data machine.Pin, freq uint32) (*APA102, error) {
+	if freq == 0 {
+		freq = apa102DefaultFreq
+	}
+	// Two cycles per bit.
+	whole, frac, err := pio.ClkDivFromFrequency(2*freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(apa102Instructions, apa102Origin)
+	if err != nil {
+		return nil, err
+	}
+	pincfg := machine.PinConfig{Mode: Pio.PinMode()}
+	clock.Configure(pincfg)
+	data.Configure(pincfg)
+	sm.SetPinsConsecutive(clock, 1, false)
+	sm.SetPindirsConsecutive(clock, 1, true)
+	sm.SetPindirsConsecutive(data, 1, true)
+
+	cfg := apa102ProgramDefaultConfig(offset)
+	cfg.SetOutPins(data, 1)
+	cfg.SetSidesetPins(clock)
+	cfg.ConfigureTx(pio.MSBFirst, 32, true)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, 2*freq)
+	sm.SetEnabled(true)
+	return &APA102{sm: sm, offset: offset}, nil
+}
+
+// SetTimeout sets the timeout of writes. Use 0 as argument to disable timeouts.
+func (a *APA102) SetTimeout(timeout time.Duration) {
+	a.dl.setTimeout(timeout)
+}
+
+// WriteColors writes colors to the strip, one per LED, at full global brightness
+// and ignoring their alpha.
+func (a *APA102) WriteColors(colors []color.RGBA) error {
+	dl := a.dl.newDeadline()
+	err := a.put(dl, 0) // Start frame.
+	for i := 0; i < len(colors) && err == nil; i++ {
+		c := colors[i]
+		err = a.put(dl, apa102Frame<<24|uint32(c.B)<<16|uint32(c.G)<<8|uint32(c.R))
+	}
+	if err == nil {
+		err = a.end(dl, len(colors))
+	}
+	return err
+}
+
+// end sends the end frame, the clock edges needed for the data to reach the last
+// of n LEDs, each delaying it by half a clock.
+func (a *APA102) end(dl deadline, n int) (err error) {
+	for i := 0; i <= n/64 && err == nil; i++ {
+		err = a.put(dl, 0)
+	}
+	return err
+}
+
+func (a *APA102) put(dl deadline, w uint32) error {
+	for a.sm.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	a.sm.TxPut(w)
+	return nil
+}
+
+// Placement returns the state machine and program used by the LED strip.
+func (a *APA102) Placement() Placement {
+	var p Placement
+	p.addSM(a.sm, a.offset, apa102Instructions)
+	return p
+}
+
+// LEDProbeConfig describes the wiring of an LED strip to DetectLEDStrip.
+type LEDProbeConfig struct {
+	// Data is the data input of the strip.
+	Data machine.Pin
+	// Clock is the clock input of the strip, machine.NoPin for single-wire strips.
+	Clock machine.Pin
+	// Loopback is wired to the data output of the last LED, machine.NoPin if it
+	// can't be read back.
+	Loopback machine.Pin
+	// LEDs is the number of LEDs of the strip, which must be exact.
+	LEDs int
+}
+
+// DetectLEDStrip detects the protocol of the LED strip wired as cfg describes and
+// returns its driver on sm, with all its LEDs off.
+//
+// With a loopback pin, the strip is probed: a clocked strip forwards the pattern
+// sent past its last LED, and single-wire strips forward the bits past those
+// taken by their LEDs, 24 for WS2812B and 32 for SK6812. The pulses are counted
+// by the probe state machine, which is left disabled afterwards. Without a
+// loopback pin, a strip with a clock pin is taken as APA102 and a single-wire
+// strip as WS2812B.
+func DetectLEDStrip(sm, probe pio.StateMachine, cfg LEDProbeConfig) (LEDStrip, LEDProtocol, error) {
+	if cfg.Loopback == machine.NoPin {
+		if cfg.Clock != machine.NoPin {
+			a, err := NewAPA102(sm, cfg.Clock, cfg.Data, 0)
+			if err != nil {
+				return nil, LEDUnknown, err
+			}
+			return a, LEDAPA102, a.WriteColors(make([]color.RGBA, cfg.LEDs))
+		}
+		ws, err := NewWS2812B(sm, cfg.Data)
+		if err != nil {
+			return nil, LEDUnknown, err
+		}
+		return ws, LEDWS2812B, ws.WriteColors(make([]color.RGBA, cfg.LEDs))
+	}
+
+	probe.TryClaim()
+	probePio := probe.PIO()
+	probeOffset, err := probePio.AddProgram(ledprobeInstructions, ledprobeOrigin)
+	if err != nil {
+		return nil, LEDUnknown, err
+	}
+	defer freeProgram(probePio, probeOffset, ledprobeInstructions)
+	defer probe.SetEnabled(false)
+	pcfg := ledprobeProgramDefaultConfig(probeOffset)
+	pcfg.SetInPins(cfg.Loopback)
+	// We only use Rx FIFO, so we set the join to Rx.
+	pcfg.SetFIFOJoin(pio.FifoJoinRx)
+	probe.Init(probeOffset, pcfg)
+	count := func() uint32 {
+		probe.Exec(pio.EncodeMovNot(pio.SrcDestISR, pio.SrcDestX))
+		probe.Exec(pio.EncodePush(false, false))
+		return probe.RxGet()
+	}
+
+	if cfg.Clock != machine.NoPin {
+		a, err := NewAPA102(sm, cfg.Clock, cfg.Data, 0)
+		if err != nil {
+			return nil, LEDUnknown, err
+		}
+		probe.SetEnabled(true)
+		err = a.probe(cfg.LEDs)
+		if err == nil && count() == 16 {
+			return a, LEDAPA102, nil
+		}
+		ledRelease(sm, a.offset, apa102Instructions, cfg.Clock, cfg.Data)
+		if err != nil {
+			return nil, LEDUnknown, err
+		}
+	}
+
+	// Turn all LEDs off with enough 24 bit colors for RGBW LEDs, plus 2 to forward.
+	words := (32*cfg.LEDs+23)/24 + 2
+	ws, err := NewWS2812B(sm, cfg.Data)
+	if err != nil {
+		return nil, LEDUnknown, err
+	}
+	time.Sleep(ledLatch)
+	probe.SetEnabled(false)
+	probe.ClearFIFOs()
+	probe.Restart()
+	probe.Jmp(probeOffset, pio.JmpAlways)
+	probe.SetEnabled(true)
+	err = ws.WriteColors(make([]color.RGBA, words))
+	if err == nil {
+		err = ws.flush()
+	}
+	if err != nil {
+		ledRelease(sm, ws.offset, ws2812b_ledInstructions, cfg.Data)
+		return nil, LEDUnknown, err
+	}
+	// Let the last LED forward the last bits.
+	time.Sleep(ledLatch)
+	switch count() {
+	case uint32(24*words - 24*cfg.LEDs):
+		return ws, LEDWS2812B, nil
+	case uint32(24*words - 32*cfg.LEDs):
+		ledRelease(sm, ws.offset, ws2812b_ledInstructions)
+		sk, err := NewSK6812(sm, cfg.Data)
+		if err != nil {
+			return nil, LEDUnknown, err
+		}
+		return sk, LEDSK6812, nil
+	}
+	ledRelease(sm, ws.offset, ws2812b_ledInstructions, cfg.Data)
+	return nil, LEDUnknown, errLEDNotDetected
+}
+
+// probe turns n LEDs off and sends the probe pattern past them.
+func (a *APA102) probe(n int) error {
+	dl := a.dl.newDeadline()
+	err := a.put(dl, 0)
+	for i := 0; i < n && err == nil; i++ {
+		err = a.put(dl, apa102Frame<<24)
+	}
+	if err == nil {
+		err = a.put(dl, apa102ProbeFrame)
+	}
+	if err == nil {
+		err = a.end(dl, n)
+	}
+	if err == nil {
+		err = a.flush(dl)
+	}
+	return err
+}
+
+// flush blocks until the bits queued were sent.
+func (a *APA102) flush(dl deadline) error {
+	for !a.sm.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	a.sm.ClearTxStalled()
+	for !a.sm.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// flush blocks until the colors queued were sent.
+func (ws *WS2812B) flush() error {
+	dl := ws.dma.dl.newDeadline()
+	for !ws.sm.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	ws.sm.ClearTxStalled()
+	for !ws.sm.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// ledRelease stops a strip driver which turned out not to match, freeing its
+// program and turning pins to inputs.
+func ledRelease(sm pio.StateMachine, offset uint8, program []uint16, pins ...machine.Pin) {
+	sm.SetEnabled(false)
+	freeProgram(sm.PIO(), offset, program)
+	for _, pin := range pins {
+		sm.SetPindirsConsecutive(pin, 1, false)
+	}
+}
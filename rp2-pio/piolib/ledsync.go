@@ -0,0 +1,126 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errLEDSyncIRQ    = errors.New("piolib:LEDSync IRQ flag must be 0..7")
+	errLEDSyncFrames = errors.New("piolib:LEDSync needs one frame per strip")
+)
+
+// LEDSync writes frames to WS2812B strips driven by state machines of both PIO
+// blocks so they all start on the same cycle, i.e. for large LED walls split over
+// more strips than a block has state machines.
+//
+// Each strip is held on a WAIT IRQ instruction executed while it is idle, its
+// FIFO is primed with the start of its frame, then the strips are released
+// together by forcing the IRQ flag. On PIO version 1 (RP2350) the strips of PIO1
+// wait on the flag of PIO0 with the IRQ prev index mode, so a single write
+// releases both blocks. On the RP2040 the CPU relays the flag to PIO1 on the next
+// bus cycle, so its strips start a few system clock cycles later, well within a
+// bit of the protocol.
+//
+// The flag is set and cleared by LEDSync and must not be used by the programs of
+// other drivers on either block.
+type LEDSync struct {
+	strips []*WS2812B
+	irq    uint8
+	dl     deadliner
+}
+
+// NewLEDSync returns a synchronizer of strips using IRQ flag irq of the PIO blocks.
+func NewLEDSync(irq uint8, strips ...*WS2812B) (*LEDSync, error) {
+	if irq > 7 {
+		return nil, errLEDSyncIRQ
+	}
+	return &LEDSync{strips: strips, irq: irq}, nil
+}
+
+// SetTimeout sets the timeout for Write. Use 0 as argument to disable timeouts.
+func (s *LEDSync) SetTimeout(timeout time.Duration) {
+	s.dl.setTimeout(timeout)
+}
+
+// Write writes frames[i] to strip i, as raw GRB values like WS2812B.WriteRaw, and
+// starts all strips on the same cycle. The rest of the frames are fed by the CPU
+// round-robin from the FIFOs, so the strips must not have DMA writes in progress.
+// Write returns once all values are queued.
+func (s *LEDSync) Write(frames [][]uint32) error {
+	if len(frames) != len(s.strips) {
+		return errLEDSyncFrames
+	}
+	dl := s.dl.newDeadline()
+	mask := uint8(1) << s.irq
+	pio.PIO0.ClearIRQ(mask)
+	pio.PIO1.ClearIRQ(mask)
+	for _, ws := range s.strips {
+		// A WAIT executed mid-frame would stretch a bit, so wait for the strip
+		// to stall on its pull with nothing left to send.
+		for !ws.sm.IsTxFIFOEmpty() || uint8(ws.sm.HW().ADDR.Get()) != ws.offset {
+			if dl.expired() {
+				return errTimeout
+			}
+			gosched()
+		}
+	}
+	var blocks uint8
+	for _, ws := range s.strips {
+		block := ws.sm.PIO().BlockIndex()
+		mode := pio.IRQDirect
+		if block == 1 && ws.sm.PIO().Version() >= 1 {
+			mode, block = pio.IRQPrev, 0
+		}
+		ws.sm.Exec(pio.EncodeWaitIRQIndexed(true, mode, s.irq))
+		blocks |= 1 << block
+	}
+	// Prime the FIFOs so no strip underruns before the CPU feeds it.
+	sent := make([]int, len(frames))
+	for i, ws := range s.strips {
+		for sent[i] < len(frames[i]) && !ws.IsQueueFull() {
+			ws.sm.TxPut(frames[i][sent[i]])
+			sent[i]++
+		}
+	}
+	if blocks&1 != 0 {
+		pio.PIO0.ForceIRQ(mask)
+	}
+	if blocks&2 != 0 {
+		pio.PIO1.ForceIRQ(mask)
+	}
+	for {
+		done := true
+		for i, ws := range s.strips {
+			for sent[i] < len(frames[i]) && !ws.IsQueueFull() {
+				ws.sm.TxPut(frames[i][sent[i]])
+				sent[i]++
+			}
+			if sent[i] < len(frames[i]) {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+}
+
+// Placement returns the state machines, programs and DMA channels of the strips.
+func (s *LEDSync) Placement() Placement {
+	var p Placement
+	for _, ws := range s.strips {
+		wp := ws.Placement()
+		p.StateMachines = append(p.StateMachines, wp.StateMachines...)
+		p.DMAChannels = append(p.DMAChannels, wp.DMAChannels...)
+	}
+	return p
+}
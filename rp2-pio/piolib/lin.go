@@ -0,0 +1,226 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errLINChecksum = errors.New("piolib:LIN checksum mismatch")
+	errLINParity   = errors.New("piolib:LIN PID parity error")
+	errLINBitError = errors.New("piolib:LIN bus readback mismatch")
+	errLINSync     = errors.New("piolib:LIN bad sync byte")
+	errLINData     = errors.New("piolib:LIN data must be 1 to 8 bytes")
+)
+
+const (
+	// Break field length in bit times, LIN 2.2A requires at least 13.
+	linBreakBits = 13
+	linSync      = 0x55
+	// Diagnostic frames which always use the classic checksum.
+	linMasterRequestID = 0x3c
+	linSlaveResponseID = 0x3d
+	linDefaultTimeout  = 50 * time.Millisecond
+)
+
+// LIN is a Local Interconnect Network bus node running over a PIO UART connected to
+// a LIN transceiver. It can act as master, sending frame headers, and as slave,
+// answering headers from a response table. The transceiver echoes everything sent
+// back to the receiver, which is used to detect bus collisions.
+type LIN struct {
+	uart *UART
+	// classic selects the LIN 1.x checksum over data only instead of the LIN 2.x
+	// enhanced checksum which includes the protected identifier.
+	classic   bool
+	responses [64][]byte
+}
+
+// NewLIN creates a LIN node transmitting on txPin and receiving on rxPin at baud,
+// usually 19200. See NewUART for the use of the state machines.
+func NewLIN(txsm, rxsm pio.StateMachine, txPin, rxPin machine.Pin, baud uint32) (*LIN, error) {
+	uart, err := NewUART(txsm, rxsm, txPin, rxPin, baud)
+	if err != nil {
+		return nil, err
+	}
+	uart.SetTimeout(linDefaultTimeout)
+	return &LIN{uart: uart}, nil
+}
+
+// SetTimeout sets the timeout for each byte on the bus. It defaults to 50ms.
+// Use 0 as argument to disable timeouts.
+func (l *LIN) SetTimeout(timeout time.Duration) {
+	l.uart.SetTimeout(timeout)
+}
+
+// SetClassicChecksum selects the LIN 1.x classic checksum if classic is true,
+// or the LIN 2.x enhanced checksum which is the default. Diagnostic frames 0x3C
+// and 0x3D always use the classic checksum.
+func (l *LIN) SetClassicChecksum(classic bool) {
+	l.classic = classic
+}
+
+// MasterRequest sends a header for frame id followed by data and its checksum,
+// publishing data as the frame response.
+func (l *LIN) MasterRequest(id uint8, data []byte) error {
+	if len(data) == 0 || len(data) > 8 {
+		return errLINData
+	}
+	err := l.sendHeader(id)
+	if err != nil {
+		return err
+	}
+	return l.sendResponse(id, data)
+}
+
+// MasterReceive sends a header for frame id and reads the response published by a
+// slave into buf, whose length must match the frame's data length.
+func (l *LIN) MasterReceive(id uint8, buf []byte) error {
+	err := l.sendHeader(id)
+	if err != nil {
+		return err
+	}
+	return l.ReadResponse(id, buf)
+}
+
+// SetSlaveResponse sets the data published when a header for frame id is received
+// by SlavePoll. A nil data removes the response so the frame is not answered.
+func (l *LIN) SetSlaveResponse(id uint8, data []byte) error {
+	if data != nil && (len(data) == 0 || len(data) > 8) {
+		return errLINData
+	}
+	l.responses[id&0x3f] = data
+	return nil
+}
+
+// SlavePoll waits for a frame header and answers it if a response was set for the
+// frame with SetSlaveResponse. It returns the frame identifier and whether it was
+// answered. If it was not the response published by another node can be read
+// with ReadResponse.
+func (l *LIN) SlavePoll() (id uint8, responded bool, err error) {
+	// Wait for the break field, skipping anything else.
+	for {
+		_, err = l.uart.ReadByte()
+		if err == errUARTBreak {
+			break
+		} else if err == errTimeout {
+			return 0, false, err
+		}
+	}
+	sync, err := l.uart.ReadByte()
+	if err != nil {
+		return 0, false, err
+	} else if sync != linSync {
+		return 0, false, errLINSync
+	}
+	pid, err := l.uart.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	id = pid & 0x3f
+	if linPID(id) != pid {
+		return id, false, errLINParity
+	}
+	data := l.responses[id]
+	if data == nil {
+		return id, false, nil
+	}
+	return id, true, l.sendResponse(id, data)
+}
+
+// ReadResponse reads the data and checksum of frame id into buf, whose length must
+// match the frame's data length, and verifies the checksum.
+func (l *LIN) ReadResponse(id uint8, buf []byte) error {
+	if len(buf) == 0 || len(buf) > 8 {
+		return errLINData
+	}
+	for i := range buf {
+		b, err := l.uart.ReadByte()
+		if err != nil {
+			return err
+		}
+		buf[i] = b
+	}
+	sum, err := l.uart.ReadByte()
+	if err != nil {
+		return err
+	}
+	if l.checksum(id, buf) != sum {
+		return errLINChecksum
+	}
+	return nil
+}
+
+// sendHeader sends the break, sync and protected identifier fields.
+func (l *LIN) sendHeader(id uint8) error {
+	l.uart.DiscardInput()
+	err := l.uart.SendBreak(linBreakBits)
+	if err != nil {
+		return err
+	}
+	_, err = l.uart.ReadByte()
+	if err != errUARTBreak {
+		return errLINBitError
+	}
+	return l.write([]byte{linSync, linPID(id & 0x3f)})
+}
+
+// sendResponse sends data followed by its checksum.
+func (l *LIN) sendResponse(id uint8, data []byte) error {
+	err := l.write(data)
+	if err != nil {
+		return err
+	}
+	return l.write([]byte{l.checksum(id, data)})
+}
+
+// write sends p and verifies it is read back from the bus unaltered.
+func (l *LIN) write(p []byte) error {
+	_, err := l.uart.Write(p)
+	if err != nil {
+		return err
+	}
+	for _, want := range p {
+		got, err := l.uart.ReadByte()
+		if err != nil {
+			return err
+		} else if got != want {
+			return errLINBitError
+		}
+	}
+	return nil
+}
+
+// checksum returns the inverted eight bit sum with carry of data, including the
+// protected identifier for the enhanced checksum.
+func (l *LIN) checksum(id uint8, data []byte) uint8 {
+	var sum uint16
+	if !l.classic && id != linMasterRequestID && id != linSlaveResponseID {
+		sum = uint16(linPID(id))
+	}
+	for _, b := range data {
+		sum += uint16(b)
+		if sum > 0xff {
+			sum -= 0xff
+		}
+	}
+	return ^uint8(sum)
+}
+
+// linPID returns the protected identifier of frame id: the 6 bit identifier with
+// its two parity bits in bits 6 and 7.
+func linPID(id uint8) uint8 {
+	bit := func(n uint8) uint8 { return id >> n & 1 }
+	p0 := bit(0) ^ bit(1) ^ bit(2) ^ bit(4)
+	p1 := ^(bit(1) ^ bit(3) ^ bit(4) ^ bit(5)) & 1
+	return id&0x3f | p0<<6 | p1<<7
+}
+
+// Placement returns the state machines, programs and DMA channels used by the LIN bus UART.
+func (l *LIN) Placement() Placement {
+	return l.uart.Placement()
+}
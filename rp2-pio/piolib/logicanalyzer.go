@@ -0,0 +1,213 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"machine"
+	"time"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// LogicAnalyzerClock selects what paces the samples of a LogicAnalyzer.
+type LogicAnalyzerClock uint8
+
+const (
+	// LogicAnalyzerInternalClock samples at the rate set with SetSampleRate.
+	LogicAnalyzerInternalClock LogicAnalyzerClock = iota
+	// LogicAnalyzerRisingEdge samples on the rising edges of an external clock
+	// on pin base+8, such as the pixel clock of a camera.
+	LogicAnalyzerRisingEdge
+	// LogicAnalyzerDualEdge samples on both edges of the external clock for DDR
+	// buses, doubling the data rate for the same clock.
+	LogicAnalyzerDualEdge
+)
+
+// LogicAnalyzer samples 8 consecutive pins at a fixed rate into memory, one byte
+// per sample, with an optional trigger on the pin levels. Samples are moved to
+// memory by DMA so the sample rate doesn't depend on the CPU. One DMA channel is
+// claimed. See SUMPServer to use it from PulseView or other sigrok frontends.
+//
+// Samples can also be clocked by the device on the bus, see SetClock, to capture
+// parallel buses such as the pixel bus of a camera.
+type LogicAnalyzer struct {
+	sm           pio.StateMachine
+	offset       uint8
+	instructions []uint16
+	base         machine.Pin
+	dma          dmaChannel
+	dl           deadliner
+	rate         uint32
+	clock        LogicAnalyzerClock
+}
+
+// NewLogicAnalyzer returns a logic analyzer sampling pins base to base+7 at 1MHz.
+// The pins are left as they are: the PIO reads pins whatever their function so
+// signals of other peripherals can be captured.
+func NewLogicAnalyzer(sm pio.StateMachine, base machine.Pin) (*LogicAnalyzer, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	dma, ok := _DMA.ClaimChannelFor("LogicAnalyzer")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(logicanalyzerInstructions, logicanalyzerOrigin)
+	if err != nil {
+		dma.Unclaim()
+		return nil, err
+	}
+	la := &LogicAnalyzer{sm: sm, offset: offset, instructions: logicanalyzerInstructions, base: base, dma: dma}
+	la.init(logicanalyzerProgramDefaultConfig)
+	if err := la.SetSampleRate(1_000_000); err != nil {
+		freeProgram(Pio, offset, logicanalyzerInstructions)
+		dma.Unclaim()
+		return nil, err
+	}
+	return la, nil
+}
+
+// init configures the state machine with the program at la.offset, configured by cfger.
+func (la *LogicAnalyzer) init(cfger func(offset uint8) pio.StateMachineConfig) {
+	cfg := cfger(la.offset)
+	cfg.SetInPins(la.base)
+	cfg.SetInShift(false, true, 8)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	la.sm.Init(la.offset, cfg)
+}
+
+// SetClock selects what paces the samples, the internal sample rate by default.
+// With an external clock on pin base+8 the state machine runs at the system
+// clock: with dual edge sampling each clock phase must last at least 2 system
+// clock cycles, otherwise a clock period must last at least 3 cycles. Keep the
+// clock below about a sixth of the system clock for margin.
+func (la *LogicAnalyzer) SetClock(clock LogicAnalyzerClock) error {
+	if clock == la.clock {
+		return nil
+	}
+	instructions, origin := logicanalyzerInstructions, int8(logicanalyzerOrigin)
+	cfger := logicanalyzerProgramDefaultConfig
+	switch clock {
+	case LogicAnalyzerRisingEdge:
+		instructions, origin = logicanalyzer_sdrInstructions, logicanalyzer_sdrOrigin
+		cfger = logicanalyzer_sdrProgramDefaultConfig
+	case LogicAnalyzerDualEdge:
+		instructions, origin = logicanalyzer_ddrInstructions, logicanalyzer_ddrOrigin
+		cfger = logicanalyzer_ddrProgramDefaultConfig
+	}
+	Pio := la.sm.PIO()
+	offset, err := Pio.AddProgram(instructions, origin)
+	if err != nil {
+		return err
+	}
+	la.sm.SetEnabled(false)
+	freeProgram(Pio, la.offset, la.instructions)
+	la.offset, la.instructions, la.clock = offset, instructions, clock
+	la.init(cfger)
+	if clock == LogicAnalyzerInternalClock {
+		return la.SetSampleRate(la.rate)
+	}
+	trackClock(la.sm, 0)
+	return nil
+}
+
+// MaxSampleRate returns the highest sample rate, half the CPU frequency so the
+// DMA keeps up with the state machine while sharing the bus.
+func (la *LogicAnalyzer) MaxSampleRate() uint32 {
+	return machine.CPUFrequency() / 2
+}
+
+// SetSampleRate sets the number of samples per second, from about 2kHz to
+// MaxSampleRate. It applies to the internal clock, see SetClock.
+func (la *LogicAnalyzer) SetSampleRate(hz uint32) error {
+	if hz > la.MaxSampleRate() {
+		hz = la.MaxSampleRate()
+	}
+	whole, frac, err := pio.ClkDivFromFrequency(hz, machine.CPUFrequency())
+	if err != nil {
+		return err
+	}
+	la.rate = hz
+	if la.clock != LogicAnalyzerInternalClock {
+		return nil
+	}
+	la.sm.SetClkDiv(whole, frac)
+	trackClock(la.sm, hz)
+	return nil
+}
+
+// SampleRate returns the sample rate set with SetSampleRate.
+func (la *LogicAnalyzer) SampleRate() uint32 {
+	return la.rate
+}
+
+// SetTimeout sets the timeout for captures, including the wait for the trigger.
+// Use 0 as argument to disable timeouts.
+func (la *LogicAnalyzer) SetTimeout(timeout time.Duration) {
+	la.dl.setTimeout(timeout)
+}
+
+// Capture fills buf with samples, bit n of each sample being the level of pin
+// base+n. If trigMask is not zero, sampling starts once the pins in trigMask
+// match trigValue. The trigger is detected by the CPU polling the pins, which
+// delays the first sample by about 100ns from the trigger condition. With an
+// external clock sampling starts at the next rising edge, and with dual edge
+// sampling even samples are taken on rising edges and odd samples on falling
+// edges.
+func (la *LogicAnalyzer) Capture(buf []byte, trigMask, trigValue uint8) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	dst, err := dmaAddr(unsafe.Pointer(&buf[0]), uintptr(len(buf)), true)
+	if err != nil {
+		return la.dma.fail(DMAError, err)
+	}
+	sm := la.sm
+	sm.SetEnabled(false)
+	sm.ClearFIFOs()
+	sm.Restart()
+	sm.Jmp(la.offset, pio.JmpAlways)
+
+	// Arm the DMA before sampling starts so the FIFO never fills up, which would
+	// stall the state machine and skew the sample timing.
+	hw := la.dma.HW()
+	hw.READ_ADDR.Set(sm.RxRegAddr()) // 8 bit reads return the sample in bits 0..7.
+	hw.WRITE_ADDR.Set(dst)
+	hw.TRANS_COUNT.Set(uint32(len(buf)))
+	cc := dmaStreamRx(la.dma, dmaTxSize8, dmaPIO_RxDREQ(sm))
+	la.dma.record(DMAStarted, nil)
+	hw.CTRL_TRIG.Set(cc.CTRL)
+
+	dl := la.dl.newDeadline()
+	trigValue &= trigMask
+	for i := 0; trigMask != 0 && uint8(rp.SIO.GPIO_IN.Get()>>la.base)&trigMask != trigValue; i++ {
+		// Checking the deadline is slow, do it rarely to keep the trigger latency low.
+		if i%1024 == 0 && dl.expired() {
+			stopStreaming(sm, la.dma)
+			return la.dma.fail(DMATimeout, errTimeout)
+		}
+	}
+	sm.SetEnabled(true)
+	for la.dma.busy() {
+		if dl.expired() {
+			stopStreaming(sm, la.dma)
+			return la.dma.fail(DMATimeout, errTimeout)
+		}
+		gosched()
+	}
+	// Don't let reads of buf be reordered before the completion check.
+	dmaFence()
+	sm.SetEnabled(false)
+	la.dma.record(DMACompleted, nil)
+	return nil
+}
+
+// Placement returns the state machine, program and DMA channel used by the logic analyzer.
+func (la *LogicAnalyzer) Placement() Placement {
+	var p Placement
+	p.addSM(la.sm, la.offset, la.instructions)
+	p.addDMA(la.dma)
+	return p
+}
@@ -0,0 +1,446 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"math/bits"
+	"strconv"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errLTCSampleRate = errors.New("piolib:LTC sample clock too slow for frame rate")
+
+const (
+	ltcBits = 80
+	// Sync word of bits 64 to 79 in order of transmission, LSB first.
+	ltcSync = 0xbffc
+	// Zero bits of the sync word, counted for the polarity correction bit.
+	ltcSyncZeros = 3
+	// ltc_tx cycles per half-bit and ltc_rx cycles per bit.
+	ltcTxCycles = 32
+	ltcRxCycles = 32
+	// Bits per word autopushed by ltc_rx.
+	ltcRxPush = 16
+	// Frames of a 10 minute cycle of drop frame timecode, and of the minutes in it
+	// dropping 2 frames.
+	ltcDropFrames10Min = 17982
+	ltcDropFramesMin   = 1798
+)
+
+// LTCFrameRate is the frame rate of linear timecode.
+type LTCFrameRate uint8
+
+const (
+	LTC24 LTCFrameRate = iota
+	LTC25
+	// LTC2997DF is 29.97 frames per second with drop frame numbering, which skips
+	// frames 0 and 1 at the start of every minute but every tenth to keep the
+	// timecode in step with the clock.
+	LTC2997DF
+	LTC30
+)
+
+// fps returns the frame rate as the fraction num/den and the frames per second
+// the timecode counts.
+func (r LTCFrameRate) fps() (num, den uint32, nominal uint8) {
+	switch r {
+	case LTC24:
+		return 24, 1, 24
+	case LTC25:
+		return 25, 1, 25
+	case LTC2997DF:
+		return 30000, 1001, 30
+	default:
+		return 30, 1, 30
+	}
+}
+
+// frameDuration returns the duration of a frame.
+func (r LTCFrameRate) frameDuration() time.Duration {
+	num, den, _ := r.fps()
+	return time.Second * time.Duration(den) / time.Duration(num)
+}
+
+// cycleFreq returns the frequency of n state machine cycles per bit.
+func (r LTCFrameRate) cycleFreq(n uint32) uint32 {
+	num, den, _ := r.fps()
+	return uint32((uint64(num)*ltcBits*uint64(n) + uint64(den)/2) / uint64(den))
+}
+
+// Timecode is the time of a frame as carried by SMPTE linear timecode.
+type Timecode struct {
+	Hours   uint8
+	Minutes uint8
+	Seconds uint8
+	Frames  uint8
+	// DropFrame is set for drop frame numbering, see LTC2997DF.
+	DropFrame bool
+	// UserBits holds the 8 groups of 4 user bits, the first in the low bits.
+	UserBits uint32
+}
+
+// String returns the timecode as hh:mm:ss:ff, with a semicolon before the frames
+// for drop frame numbering.
+func (t Timecode) String() string {
+	b := appendTwoDigits(nil, t.Hours)
+	b = appendTwoDigits(append(b, ':'), t.Minutes)
+	b = appendTwoDigits(append(b, ':'), t.Seconds)
+	sep := byte(':')
+	if t.DropFrame {
+		sep = ';'
+	}
+	return string(appendTwoDigits(append(b, sep), t.Frames))
+}
+
+func appendTwoDigits(b []byte, v uint8) []byte {
+	if v < 10 {
+		b = append(b, '0')
+	}
+	return strconv.AppendUint(b, uint64(v), 10)
+}
+
+// add returns t advanced by n frames, wrapping at 24 hours.
+func (t Timecode) add(n int, nominal uint8) Timecode {
+	fps := int(nominal)
+	perDay := 86400 * fps
+	if t.DropFrame {
+		perDay = 24 * 6 * ltcDropFrames10Min
+	}
+	// Frame number since midnight.
+	minutes := 60*int(t.Hours) + int(t.Minutes)
+	frame := (60*minutes+int(t.Seconds))*fps + int(t.Frames)
+	if t.DropFrame {
+		frame -= 2 * (minutes - minutes/10)
+	}
+	frame = ((frame+n)%perDay + perDay) % perDay
+	if t.DropFrame {
+		// Back to the frame count of non drop frame numbering.
+		d, m := frame/ltcDropFrames10Min, frame%ltcDropFrames10Min
+		frame += 18 * d
+		if m >= 2 {
+			frame += 2 * ((m - 2) / ltcDropFramesMin)
+		}
+	}
+	t.Frames = uint8(frame % fps)
+	t.Seconds = uint8(frame / fps % 60)
+	t.Minutes = uint8(frame / fps / 60 % 60)
+	t.Hours = uint8(frame / fps / 3600)
+	return t
+}
+
+// ltcEncode returns bits 0 to 63 of the frame of t. The polarity correction bit
+// at polarityBit is set so the frame has an even number of zeros, making every
+// frame start with the same polarity.
+func (t Timecode) ltcEncode(polarityBit uint8) uint64 {
+	b := uint64(t.Frames%10) | uint64(t.Frames/10)<<8 |
+		uint64(t.Seconds%10)<<16 | uint64(t.Seconds/10)<<24 |
+		uint64(t.Minutes%10)<<32 | uint64(t.Minutes/10)<<40 |
+		uint64(t.Hours%10)<<48 | uint64(t.Hours/10)<<56
+	if t.DropFrame {
+		b |= 1 << 10
+	}
+	for i := 0; i < 8; i++ {
+		b |= uint64(t.UserBits>>(4*i)&0xf) << (4 + 8*i)
+	}
+	if (64-bits.OnesCount64(b)+ltcSyncZeros)%2 != 0 {
+		b |= 1 << polarityBit
+	}
+	return b
+}
+
+// ltcDecode returns the timecode of bits 0 to 63 of a frame.
+func ltcDecode(b uint64) (t Timecode) {
+	t.Frames = uint8(b&0xf + 10*(b>>8&0x3))
+	t.Seconds = uint8(b>>16&0xf + 10*(b>>24&0x7))
+	t.Minutes = uint8(b>>32&0xf + 10*(b>>40&0x7))
+	t.Hours = uint8(b>>48&0xf + 10*(b>>56&0x3))
+	t.DropFrame = b&(1<<10) != 0
+	for i := 0; i < 8; i++ {
+		t.UserBits |= uint32(b>>(4+8*i)&0xf) << (4 * i)
+	}
+	return t
+}
+
+// LTCGenerator generates SMPTE linear timecode, the biphase mark encoded frames
+// recorded on an audio track to synchronize film, video and audio equipment. The
+// pin outputs a logic level signal which usually needs attenuating to line level.
+//
+// The generator runs either from its own clock or locked to a sample clock, such
+// as the word clock of the audio converter of a recorder, so the timecode doesn't
+// drift from the audio.
+type LTCGenerator struct {
+	sm          pio.StateMachine
+	offset      uint8
+	prog        []uint16
+	rate        LTCFrameRate
+	polarityBit uint8
+	tc          Timecode
+	level       bool
+	// Sample clock edges per half-bit of a locked generator, as base and the
+	// fraction step/div accumulated in acc.
+	locked    bool
+	base      uint64
+	step, div uint64
+	acc       uint64
+	// Words of the frame being queued.
+	words  [10]uint32
+	n      int
+	queued int
+}
+
+// NewLTCGenerator creates a generator on pin at the frame rate, timed by the state
+// machine clock.
+func NewLTCGenerator(sm pio.StateMachine, pin machine.Pin, rate LTCFrameRate) (*LTCGenerator, error) {
+	freq := rate.cycleFreq(2 * ltcTxCycles)
+	whole, frac, err := pio.ClkDivFromFrequency(freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(ltc_txInstructions, ltc_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	cfg := ltc_txProgramDefaultConfig(offset)
+	cfg.SetClkDivIntFrac(whole, frac)
+	g := newLTCGenerator(sm, offset, ltc_txInstructions, pin, cfg, rate)
+	trackClock(sm, freq)
+	sm.SetEnabled(true)
+	return g, nil
+}
+
+// NewLTCGeneratorLocked creates a generator on pin at the frame rate, timed by the
+// falling edges of the sample clock at sampleRate on clock. Frames may last a
+// fractional number of sample clock periods, i.e. 1601.6 at 29.97 frames per
+// second and 48kHz, the fraction being spread over their half-bits.
+func NewLTCGeneratorLocked(sm pio.StateMachine, pin, clock machine.Pin, sampleRate uint32, rate LTCFrameRate) (*LTCGenerator, error) {
+	num, den, _ := rate.fps()
+	step := uint64(sampleRate) * uint64(den)
+	div := 2 * ltcBits * uint64(num)
+	base := step / div
+	if base == 0 {
+		return nil, errLTCSampleRate
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(ltc_tx_syncInstructions, ltc_tx_syncOrigin)
+	if err != nil {
+		return nil, err
+	}
+	clock.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPindirsConsecutive(clock, 1, false)
+	cfg := ltc_tx_syncProgramDefaultConfig(offset)
+	cfg.SetInPins(clock)
+	g := newLTCGenerator(sm, offset, ltc_tx_syncInstructions, pin, cfg, rate)
+	g.locked, g.base, g.step, g.div = true, base, step, div
+	// ISR holds the edges per half-bit minus one, loaded through the OSR.
+	sm.TxPut(uint32(base - 1))
+	sm.Exec(pio.EncodePull(false, true))
+	sm.Exec(pio.EncodeMov(pio.SrcDestISR, pio.SrcDestOSR))
+	sm.SetEnabled(true)
+	return g, nil
+}
+
+// newLTCGenerator initializes sm for the generator program loaded at offset, with
+// cfg set up for its clock. The state machine is left disabled.
+func newLTCGenerator(sm pio.StateMachine, offset uint8, prog []uint16, pin machine.Pin, cfg pio.StateMachineConfig, rate LTCFrameRate) *LTCGenerator {
+	pin.Configure(machine.PinConfig{Mode: sm.PIO().PinMode()})
+	sm.SetPinsConsecutive(pin, 1, false)
+	sm.SetPindirsConsecutive(pin, 1, true)
+	cfg.SetOutPins(pin, 1)
+	cfg.SetOutShift(true, true, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	sm.Init(offset, cfg)
+	g := &LTCGenerator{sm: sm, offset: offset, prog: prog, rate: rate, polarityBit: 27}
+	if rate == LTC25 {
+		g.polarityBit = 59
+	}
+	g.SetTimecode(Timecode{})
+	return g
+}
+
+// SetTimecode sets the timecode of the next frame encoded. Its drop frame flag is
+// set from the frame rate.
+func (g *LTCGenerator) SetTimecode(tc Timecode) {
+	tc.DropFrame = g.rate == LTC2997DF
+	g.tc = tc
+}
+
+// Update queues the next frames for transmission as the FIFO has room. It must be
+// called at least every 20ms for the timecode to be continuous, the generator
+// starting with the first call.
+func (g *LTCGenerator) Update() {
+	for !g.sm.IsTxFIFOFull() {
+		if g.queued == g.n {
+			g.encode()
+		}
+		g.sm.TxPut(g.words[g.queued])
+		g.queued++
+	}
+}
+
+// encode encodes the frame of the timecode to words and advances the timecode.
+func (g *LTCGenerator) encode() {
+	frame := g.tc.ltcEncode(g.polarityBit)
+	_, _, nominal := g.rate.fps()
+	g.tc = g.tc.add(1, nominal)
+	g.words = [10]uint32{}
+	pos := 0
+	for i := 0; i < ltcBits; i++ {
+		one := frame>>i&1 != 0
+		if i >= 64 {
+			one = ltcSync>>(i-64)&1 != 0
+		}
+		// A transition starts every bit and a one has another in its middle.
+		for half := 0; half < 2; half++ {
+			if half == 0 || one {
+				g.level = !g.level
+			}
+			if g.level {
+				g.words[pos/32] |= 1 << (pos % 32)
+			}
+			pos++
+			if g.locked {
+				g.acc += g.step
+				edges := g.acc / g.div
+				g.acc -= edges * g.div
+				if edges > g.base {
+					g.words[pos/32] |= 1 << (pos % 32)
+				}
+				pos++
+			}
+		}
+	}
+	g.n, g.queued = pos/32, 0
+}
+
+// Placement returns the state machine and program used by the generator.
+func (g *LTCGenerator) Placement() Placement {
+	var p Placement
+	p.addSM(g.sm, g.offset, g.prog)
+	return p
+}
+
+// LTCReader decodes SMPTE linear timecode from a logic level signal on a pin, i.e.
+// an LTC audio track squared up by a comparator. The state machine decodes the
+// biphase mark bits and the CPU finds the frames in them, so the reader is not
+// sensitive to the polarity of the signal. The speed may deviate from the frame
+// rate by up to 20%.
+type LTCReader struct {
+	sm        pio.StateMachine
+	offset    uint8
+	rate      LTCFrameRate
+	bitPeriod time.Duration
+	// The last 80 bits received, bits 0 to 63 in lo, and how many were received
+	// since the last frame up to 80.
+	lo    uint64
+	hi    uint16
+	count uint8
+	// Last frame decoded and the time it ended.
+	tc    Timecode
+	at    time.Time
+	valid bool
+}
+
+// NewLTCReader creates a reader for timecode at the frame rate on pin.
+func NewLTCReader(sm pio.StateMachine, pin machine.Pin, rate LTCFrameRate) (*LTCReader, error) {
+	freq := rate.cycleFreq(ltcRxCycles)
+	whole, frac, err := pio.ClkDivFromFrequency(freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(ltc_rxInstructions, ltc_rxOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPindirsConsecutive(pin, 1, false)
+	cfg := ltc_rxProgramDefaultConfig(offset)
+	cfg.SetInPins(pin)
+	cfg.SetJmpPin(pin)
+	cfg.SetInShift(true, true, ltcRxPush)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, freq)
+	sm.SetEnabled(true)
+	num, den, _ := rate.fps()
+	return &LTCReader{
+		sm:        sm,
+		offset:    offset,
+		rate:      rate,
+		bitPeriod: time.Second * time.Duration(den) / time.Duration(num*ltcBits),
+	}, nil
+}
+
+// Update decodes the bits received. It must be called at least every 40ms, as the
+// RX FIFO holds 128 bits.
+func (r *LTCReader) Update() {
+	now := time.Now()
+	for n := int(r.sm.RxFIFOLevel()); n > 0; n-- {
+		// Bits are pushed to the high half of words.
+		word := r.sm.RxGet() >> (32 - ltcRxPush)
+		for i := 0; i < ltcRxPush; i++ {
+			if !r.shift(word>>i&1 != 0) {
+				continue
+			}
+			// Bits received after the end of the frame: those of this word and of
+			// the words behind it, and on average half a word still being shifted in.
+			after := (n-1)*ltcRxPush + ltcRxPush - 1 - i + ltcRxPush/2
+			r.at = now.Add(-time.Duration(after) * r.bitPeriod)
+		}
+	}
+}
+
+// shift adds a bit received and returns true if it ended a frame.
+func (r *LTCReader) shift(one bool) bool {
+	r.lo = r.lo>>1 | uint64(r.hi&1)<<63
+	r.hi >>= 1
+	if one {
+		r.hi |= 1 << 15
+	}
+	if r.count < ltcBits {
+		r.count++
+	}
+	if r.hi != ltcSync || r.count < ltcBits {
+		return false
+	}
+	r.count = 0
+	r.tc = ltcDecode(r.lo)
+	r.valid = true
+	return true
+}
+
+// Now returns the timecode of the frame being received, extrapolated from the last
+// frame decoded by Update, so it is frame accurate between Updates. It keeps
+// counting from the last frame when the signal is lost, see Locked, and is zero
+// before a frame was decoded.
+func (r *LTCReader) Now() Timecode {
+	if !r.valid {
+		return Timecode{}
+	}
+	_, _, nominal := r.rate.fps()
+	// The frame after the one decoded started as it ended.
+	frames := 1 + int(time.Since(r.at)/r.rate.frameDuration())
+	return r.tc.add(frames, nominal)
+}
+
+// Locked returns true if a frame was decoded within the last 2 frames.
+func (r *LTCReader) Locked() bool {
+	return r.valid && time.Since(r.at) < 2*r.rate.frameDuration()
+}
+
+// Placement returns the state machine and program used by the reader.
+func (r *LTCReader) Placement() Placement {
+	var p Placement
+	p.addSM(r.sm, r.offset, ltc_rxInstructions)
+	return p
+}
@@ -0,0 +1,214 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errManchesterFrame   = errors.New("piolib:Manchester bad frame start")
+	errManchesterTooLong = errors.New("piolib:Manchester frame too long")
+)
+
+const (
+	// State machine cycles per bit of both programs.
+	manchesterBitCycles = 16
+	// First byte of frames, starting with a one as the decoder requires.
+	manchesterPreamble = 0x55
+	// Last byte of frames, leaving the line low.
+	manchesterTrailer = 0xff
+)
+
+// Manchester is a half-duplex codec sending and receiving frames of up to 255
+// bytes, Manchester encoded, over a single line. The code has no DC component,
+// so the line can be coupled through a pulse transformer or a capacitor for
+// galvanic isolation. A frame is a preamble byte, a length byte and the data, so
+// the decoder synchronizes on every frame.
+//
+// The pin is driven only while sending, and must idle low otherwise, i.e. with a
+// pull-down or the comparator of the line receiver. Both ends of the line must use
+// the same baud rate, within 10%.
+type Manchester struct {
+	tx, rx   pio.StateMachine
+	txOffset uint8
+	rxOffset uint8
+	pin      machine.Pin
+	dl       deadliner
+}
+
+// NewManchester creates a codec on pin sending with the txsm state machine and
+// receiving with the rxsm state machine, at baud bits per second.
+func NewManchester(txsm, rxsm pio.StateMachine, pin machine.Pin, baud uint32) (*Manchester, error) {
+	freq := baud * manchesterBitCycles
+	whole, frac, err := pio.ClkDivFromFrequency(freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	txsm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	rxsm.TryClaim()
+	Pio := txsm.PIO()
+	txOffset, err := Pio.AddProgram(manchester_txInstructions, manchester_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	rxOffset, err := rxsm.PIO().AddProgram(manchester_rxInstructions, manchester_rxOrigin)
+	if err != nil {
+		freeProgram(Pio, txOffset, manchester_txInstructions)
+		return nil, err
+	}
+	// The receiver reads the pin whichever PIO it is handed to.
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	txsm.SetPinsConsecutive(pin, 1, false)
+	txsm.SetPindirsConsecutive(pin, 1, false)
+
+	cfg := manchester_txProgramDefaultConfig(txOffset)
+	cfg.SetSidesetPins(pin)
+	cfg.ConfigureTx(pio.LSBFirst, 8, true)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	txsm.Init(txOffset, cfg)
+	trackClock(txsm, freq)
+	txsm.SetEnabled(true)
+
+	cfg = manchester_rxProgramDefaultConfig(rxOffset)
+	cfg.SetInPins(pin)
+	cfg.SetJmpPin(pin)
+	cfg.ConfigureRx(pio.LSBFirst, 8, true)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	rxsm.Init(rxOffset, cfg)
+	trackClock(rxsm, freq)
+	rxsm.SetEnabled(true)
+	return &Manchester{tx: txsm, rx: rxsm, txOffset: txOffset, rxOffset: rxOffset, pin: pin}, nil
+}
+
+// SetTimeout sets the timeout for sending a frame and for receiving one, from
+// the call to Receive. Use 0 as argument to disable timeouts.
+func (m *Manchester) SetTimeout(timeout time.Duration) {
+	m.dl.setTimeout(timeout)
+}
+
+// Send sends p as a frame, blocking until it is sent and the line released.
+func (m *Manchester) Send(p []byte) error {
+	if len(p) > 255 {
+		return errManchesterTooLong
+	}
+	dl := m.dl.newDeadline()
+	m.tx.SetPindirsConsecutive(m.pin, 1, true)
+	err := m.put(dl, manchesterPreamble)
+	if err == nil {
+		err = m.put(dl, byte(len(p)))
+	}
+	for i := 0; i < len(p) && err == nil; i++ {
+		err = m.put(dl, p[i])
+	}
+	if err == nil {
+		err = m.put(dl, manchesterTrailer)
+	}
+	if err == nil {
+		err = m.flush(dl)
+	}
+	if err != nil {
+		// Drop what is left and stop driving the line low.
+		m.tx.SetEnabled(false)
+		m.tx.ClearFIFOs()
+		m.tx.Restart()
+		m.tx.Jmp(m.txOffset, pio.JmpAlways)
+		m.tx.SetEnabled(true)
+	}
+	m.tx.SetPindirsConsecutive(m.pin, 1, false)
+	// The receiver decoded the frame sent, be ready for the answer.
+	m.rearm()
+	return err
+}
+
+func (m *Manchester) put(dl deadline, b byte) error {
+	for m.tx.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	m.tx.TxPut(uint32(b))
+	return nil
+}
+
+// flush blocks until the bits queued were sent, the encoder stalling on the
+// empty FIFO with the line low.
+func (m *Manchester) flush(dl deadline) error {
+	for !m.tx.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	m.tx.ClearTxStalled()
+	for !m.tx.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// Receive waits for a frame and stores its data to p, returning its length. The
+// frame is dropped if it is longer than p.
+func (m *Manchester) Receive(p []byte) (n int, err error) {
+	dl := m.dl.newDeadline()
+	// The decoder goes on decoding noise after a frame, resynchronize for the next.
+	defer m.rearm()
+	b, err := m.get(dl)
+	if err != nil {
+		return 0, err
+	} else if b != manchesterPreamble {
+		return 0, errManchesterFrame
+	}
+	length, err := m.get(dl)
+	if err != nil {
+		return 0, err
+	} else if int(length) > len(p) {
+		return 0, errManchesterTooLong
+	}
+	for n < int(length) {
+		if p[n], err = m.get(dl); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (m *Manchester) get(dl deadline) (byte, error) {
+	for m.rx.IsRxFIFOEmpty() {
+		if dl.expired() {
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	return byte(pio.LSBFirst.RxWord(8, m.rx.RxGet())), nil
+}
+
+// rearm restarts the decoder waiting for the start of a frame.
+func (m *Manchester) rearm() {
+	m.rx.SetEnabled(false)
+	m.rx.ClearFIFOs()
+	m.rx.Restart()
+	m.rx.Jmp(m.rxOffset, pio.JmpAlways)
+	m.rx.SetEnabled(true)
+}
+
+// Placement returns the state machines and programs used by the codec.
+func (m *Manchester) Placement() Placement {
+	var p Placement
+	p.addSM(m.tx, m.txOffset, manchester_txInstructions)
+	p.addSM(m.rx, m.rxOffset, manchester_rxInstructions)
+	return p
+}
@@ -0,0 +1,169 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errIsoSPILength = errors.New("piolib:isolated SPI transfer too long or lengths differ")
+	errIsoSPINoAck  = errors.New("piolib:isolated SPI transfer not acknowledged")
+)
+
+const (
+	// Frame header: the kind and sequence number, followed by the data and CRC16.
+	isoSPIRequest  = 0x00
+	isoSPIResponse = 0x80
+	isoSPISeqMask  = 0x7f
+	isoSPIHeader   = 1
+	isoSPICRC      = 2
+	// Longest transfer in a frame of up to 255 bytes.
+	isoSPIMaxLen = 255 - isoSPIHeader - isoSPICRC
+	// Default attempts of a transfer and time to wait for its response.
+	isoSPIDefaultRetries = 3
+	isoSPIDefaultTimeout = 20 * time.Millisecond
+)
+
+// IsolatedSPI tunnels SPI-like transfers over a single Manchester coded line, see
+// Manchester, for sensor pods behind a pulse transformer. The controller sends
+// the bytes to write in a request, and the pod answers with as many bytes read,
+// as a SPI peripheral would.
+//
+// Frames carry a sequence number and a CRC16. A response is the acknowledgement
+// of its request: if it is missing or corrupted the request is sent again, the
+// pod answering a repeated request with its previous response without handling
+// it again.
+type IsolatedSPI struct {
+	m       *Manchester
+	timeout time.Duration
+	retries uint8
+	seq     uint8
+	// Last request handled by a pod, to answer repeats of it.
+	lastSeq  uint8
+	lastResp []byte
+	buf      [255]byte
+}
+
+// NewIsolatedSPI creates an isolated SPI controller or pod on pin, using the txsm
+// and rxsm state machines for its Manchester codec at baud bits per second.
+func NewIsolatedSPI(txsm, rxsm pio.StateMachine, pin machine.Pin, baud uint32) (*IsolatedSPI, error) {
+	m, err := NewManchester(txsm, rxsm, pin, baud)
+	if err != nil {
+		return nil, err
+	}
+	return &IsolatedSPI{
+		m:       m,
+		timeout: isoSPIDefaultTimeout,
+		retries: isoSPIDefaultRetries,
+		lastSeq: 0xff, // Never a sequence number.
+	}, nil
+}
+
+// SetRetries sets how many times a transfer is attempted, 3 by default, and how
+// long the controller waits for each response after sending its request, 20ms by
+// default.
+func (s *IsolatedSPI) SetRetries(attempts uint8, timeout time.Duration) {
+	if attempts == 0 {
+		attempts = 1
+	}
+	s.retries, s.timeout = attempts, timeout
+}
+
+// Tx sends w to the pod and receives r in return, like the Tx method of a SPI
+// bus. w and r must have the same length, at most 252 bytes, and either may be
+// nil to only write or read, zeros being sent in place of w.
+func (s *IsolatedSPI) Tx(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	} else if r != nil && len(r) != n {
+		return errIsoSPILength
+	}
+	if n > isoSPIMaxLen {
+		return errIsoSPILength
+	}
+	s.seq = (s.seq + 1) & isoSPISeqMask
+	req := s.buf[:isoSPIHeader+n+isoSPICRC]
+	req[0] = isoSPIRequest | s.seq
+	if w != nil {
+		copy(req[isoSPIHeader:], w)
+	} else {
+		for i := isoSPIHeader; i < isoSPIHeader+n; i++ {
+			req[i] = 0
+		}
+	}
+	isoSPISeal(req)
+	var resp [255]byte
+	err := errIsoSPINoAck
+	for attempt := uint8(0); attempt < s.retries; attempt++ {
+		s.m.SetTimeout(0)
+		if err = s.m.Send(req); err != nil {
+			return err
+		}
+		s.m.SetTimeout(s.timeout)
+		var got int
+		got, err = s.m.Receive(resp[:])
+		if err == nil && (got != len(req) || !isoSPIValid(resp[:got]) || resp[0] != isoSPIResponse|s.seq) {
+			err = errIsoSPINoAck
+		}
+		if err == nil {
+			if r != nil {
+				copy(r, resp[isoSPIHeader:isoSPIHeader+n])
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+// Respond waits up to timeout for a request as a pod, calls handle with the bytes
+// written by the controller and a slice of the same length to fill with the bytes
+// it reads, and sends the response. A timeout of 0 waits forever.
+func (s *IsolatedSPI) Respond(timeout time.Duration, handle func(w, r []byte)) error {
+	s.m.SetTimeout(timeout)
+	var req [255]byte
+	n, err := s.m.Receive(req[:])
+	if err != nil {
+		return err
+	}
+	if n < isoSPIHeader+isoSPICRC || !isoSPIValid(req[:n]) || req[0]&^isoSPISeqMask != isoSPIRequest {
+		// The controller sends it again.
+		return nil
+	}
+	seq := req[0] & isoSPISeqMask
+	if seq != s.lastSeq {
+		resp := s.buf[:n]
+		resp[0] = isoSPIResponse | seq
+		for i := isoSPIHeader; i < n-isoSPICRC; i++ {
+			resp[i] = 0
+		}
+		handle(req[isoSPIHeader:n-isoSPICRC], resp[isoSPIHeader:n-isoSPICRC])
+		isoSPISeal(resp)
+		s.lastSeq, s.lastResp = seq, resp
+	}
+	// A new request, or a repeat whose response was lost.
+	s.m.SetTimeout(0)
+	return s.m.Send(s.lastResp)
+}
+
+// isoSPISeal sets the CRC16 of the frame in its last 2 bytes.
+func isoSPISeal(frame []byte) {
+	crc := sdCRC16(frame[:len(frame)-isoSPICRC])
+	frame[len(frame)-2], frame[len(frame)-1] = byte(crc>>8), byte(crc)
+}
+
+// isoSPIValid returns true if the CRC16 of the frame is correct.
+func isoSPIValid(frame []byte) bool {
+	crc := sdCRC16(frame[:len(frame)-isoSPICRC])
+	return frame[len(frame)-2] == byte(crc>>8) && frame[len(frame)-1] == byte(crc)
+}
+
+// Placement returns the state machines and programs used by the codec.
+func (s *IsolatedSPI) Placement() Placement {
+	return s.m.Placement()
+}
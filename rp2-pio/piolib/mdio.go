@@ -0,0 +1,199 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errMDIONoResponse = errors.New("piolib:MDIO no response from PHY")
+
+// MDIO frame fields, see IEEE 802.3 clauses 22.2.4.5 and 45.3.
+const (
+	mdioStartC22 = 0b01
+	mdioStartC45 = 0b00
+
+	mdioOpC22Write = 0b01
+	mdioOpC22Read  = 0b10
+	mdioOpC45Addr  = 0b00
+	mdioOpC45Write = 0b01
+	mdioOpC45Read  = 0b11
+
+	mdioTurnaround = 0b10
+	// Frame bits written before the PHY drives the bus on reads: start, op, phy and register address.
+	mdioReadHeaderBits = 14
+	// Bits read: second turnaround bit, driven low by the PHY, and the 16 data bits.
+	mdioReadBits = 17
+
+	// Loopback bit of the clause 22 basic mode control register 0 and the
+	// clause 45 PCS control 1 register of device 3, register 0.
+	mdioRegBMCR      = 0
+	mdioDevPCS       = 3
+	mdioCtrlLoopback = 1 << 14
+)
+
+// MDIOClause selects the MDIO frame format.
+type MDIOClause uint8
+
+const (
+	// MDIOClause22 frames address up to 32 registers per PHY directly. This is the default.
+	MDIOClause22 MDIOClause = iota
+	// MDIOClause45 frames address up to 65536 registers in each of 32 devices (MMDs)
+	// per port. Each access takes an address frame followed by a read or write frame.
+	MDIOClause45
+)
+
+// MDIO is a management data input/output bus master used to access Ethernet PHY
+// registers as defined in IEEE 802.3. MDIO needs an external pull-up resistor.
+type MDIO struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	clause MDIOClause
+}
+
+// NewMDIO creates an MDIO bus master with clock on mdc and data on mdio. baud is the
+// MDC frequency, which must not exceed 2.5MHz for compliant PHYs.
+func NewMDIO(sm pio.StateMachine, mdc, mdio machine.Pin, baud uint32) (*MDIO, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	// A write bit takes 4 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(baud*4, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(mdioInstructions, mdioOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	mdc.Configure(pinCfg)
+	mdio.Configure(pinCfg)
+	sm.SetPinsConsecutive(mdc, 1, false)
+	sm.SetPindirsConsecutive(mdc, 1, true)
+	sm.SetPindirsConsecutive(mdio, 1, false)
+	cfg := mdioProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(mdc)
+	cfg.SetOutPins(mdio, 1)
+	cfg.SetSetPins(mdio, 1)
+	cfg.SetInPins(mdio)
+	cfg.SetOutShift(false, false, 32)
+	cfg.SetInShift(false, false, 32)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, baud*4)
+	sm.SetEnabled(true)
+	return &MDIO{sm: sm, offset: offset}, nil
+}
+
+// SetTimeout sets the timeout for MDIO transactions. Use 0 as argument to disable timeouts.
+func (m *MDIO) SetTimeout(timeout time.Duration) {
+	m.dl.setTimeout(timeout)
+}
+
+// SetClause selects the frame format used by Read and Write. Clause 22 is the default.
+func (m *MDIO) SetClause(clause MDIOClause) {
+	m.clause = clause
+}
+
+// MDIOReg45 returns the register argument of MDIO.Read and MDIO.Write for a
+// clause 45 access of register reg of device devad.
+func MDIOReg45(devad uint8, reg uint16) uint32 {
+	return uint32(devad&0x1f)<<16 | uint32(reg)
+}
+
+// Read reads a register of the PHY at address phy. With clause 22 reg is the register
+// address in the range 0..31. With clause 45 phy is the port address and reg holds
+// the device and register address, see MDIOReg45.
+func (m *MDIO) Read(phy uint8, reg uint32) (uint16, error) {
+	start, op, regad := uint32(mdioStartC22), uint32(mdioOpC22Read), reg
+	if m.clause == MDIOClause45 {
+		regad = reg >> 16
+		_, err := m.transfer(mdioFrame(mdioStartC45, mdioOpC45Addr, phy, regad, uint16(reg)), 32, 0)
+		if err != nil {
+			return 0, err
+		}
+		start, op = mdioStartC45, mdioOpC45Read
+	}
+	frame := mdioFrame(start, op, phy, regad, 0)
+	data, err := m.transfer(frame, mdioReadHeaderBits, mdioReadBits)
+	if err != nil {
+		return 0, err
+	}
+	if data&(1<<16) != 0 {
+		// No PHY pulled the second turnaround bit low.
+		return 0, errMDIONoResponse
+	}
+	return uint16(data), nil
+}
+
+// Write writes value to a register of the PHY at address phy. See Read for
+// the meaning of phy and reg for each clause.
+func (m *MDIO) Write(phy uint8, reg uint32, value uint16) error {
+	start, op, regad := uint32(mdioStartC22), uint32(mdioOpC22Write), reg
+	if m.clause == MDIOClause45 {
+		regad = reg >> 16
+		_, err := m.transfer(mdioFrame(mdioStartC45, mdioOpC45Addr, phy, regad, uint16(reg)), 32, 0)
+		if err != nil {
+			return err
+		}
+		start, op = mdioStartC45, mdioOpC45Write
+	}
+	_, err := m.transfer(mdioFrame(start, op, phy, regad, value), 32, 0)
+	return err
+}
+
+// SetLoopback enables or disables the internal loopback of the PHY at address phy.
+// With loopback enabled the PHY returns transmitted frames to the MAC instead of
+// sending them on the wire, which helps telling PHY issues apart from wiring and
+// MAC issues during board bring-up. The link is usually dropped while enabled.
+// Sending and checking test frames is up to the MAC driver, piolib has no RMII
+// data path yet.
+func (m *MDIO) SetLoopback(phy uint8, enable bool) error {
+	reg := uint32(mdioRegBMCR)
+	if m.clause == MDIOClause45 {
+		reg = MDIOReg45(mdioDevPCS, mdioRegBMCR)
+	}
+	ctrl, err := m.Read(phy, reg)
+	if err != nil {
+		return err
+	}
+	if enable {
+		ctrl |= mdioCtrlLoopback
+	} else {
+		ctrl &^= mdioCtrlLoopback
+	}
+	return m.Write(phy, reg, ctrl)
+}
+
+// transfer writes the first writeBits bits of frame after the preamble and then reads readBits bits.
+func (m *MDIO) transfer(frame uint32, writeBits, readBits uint32) (uint32, error) {
+	m.sm.TxPut((writeBits-1)<<16 | readBits)
+	m.sm.TxPut(frame)
+	dl := m.dl.newDeadline()
+	for m.sm.IsRxFIFOEmpty() {
+		if dl.expired() {
+			m.sm.ClearFIFOs()
+			m.sm.Restart()
+			m.sm.Jmp(m.offset, pio.JmpAlways)
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	return m.sm.RxGet(), nil
+}
+
+func mdioFrame(start, op uint32, phy uint8, regad uint32, data uint16) uint32 {
+	return start<<30 | op<<28 | uint32(phy&0x1f)<<23 | (regad&0x1f)<<18 | mdioTurnaround<<16 | uint32(data)
+}
+
+// Placement returns the state machine and program used by the MDIO bus master.
+func (m *MDIO) Placement() Placement {
+	var p Placement
+	p.addSM(m.sm, m.offset, mdioInstructions)
+	return p
+}
@@ -0,0 +1,257 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// MIDI baud rate, fixed by the MIDI 1.0 specification.
+const midiBaud = 31250
+
+var errMIDIChannel = errors.New("piolib:MIDI channel must be 0..15")
+
+// MIDIEventKind is the kind of a MIDI message: the upper nibble of the status byte
+// of channel messages or the whole status byte of system messages.
+type MIDIEventKind byte
+
+// Channel messages.
+const (
+	MIDINoteOff         MIDIEventKind = 0x80
+	MIDINoteOn          MIDIEventKind = 0x90
+	MIDIPolyPressure    MIDIEventKind = 0xa0
+	MIDIControlChange   MIDIEventKind = 0xb0
+	MIDIProgramChange   MIDIEventKind = 0xc0
+	MIDIChannelPressure MIDIEventKind = 0xd0
+	MIDIPitchBend       MIDIEventKind = 0xe0
+)
+
+// System common and real-time messages.
+const (
+	MIDITimeCode      MIDIEventKind = 0xf1
+	MIDISongPosition  MIDIEventKind = 0xf2
+	MIDISongSelect    MIDIEventKind = 0xf3
+	MIDITuneRequest   MIDIEventKind = 0xf6
+	MIDITimingClock   MIDIEventKind = 0xf8
+	MIDIStart         MIDIEventKind = 0xfa
+	MIDIContinue      MIDIEventKind = 0xfb
+	MIDIStop          MIDIEventKind = 0xfc
+	MIDIActiveSensing MIDIEventKind = 0xfe
+	MIDIReset         MIDIEventKind = 0xff
+)
+
+// MIDIEvent is a MIDI message. Data1 and Data2 hold the data bytes the message
+// has, i.e. the note and velocity of note messages, and are zero otherwise.
+type MIDIEvent struct {
+	Status byte
+	Data1  byte
+	Data2  byte
+}
+
+// Kind returns the kind of message. Note on messages with a zero velocity are
+// reported as MIDINoteOff, as the MIDI specification defines them.
+func (ev MIDIEvent) Kind() MIDIEventKind {
+	if ev.Status >= 0xf0 {
+		return MIDIEventKind(ev.Status)
+	}
+	kind := MIDIEventKind(ev.Status & 0xf0)
+	if kind == MIDINoteOn && ev.Data2 == 0 {
+		return MIDINoteOff
+	}
+	return kind
+}
+
+// Channel returns the channel 0..15 of channel messages, shown as 1..16 to users.
+func (ev MIDIEvent) Channel() uint8 {
+	return ev.Status & 0xf
+}
+
+// PitchBend returns the value of a pitch bend message, from -8192 to 8191.
+func (ev MIDIEvent) PitchBend() int16 {
+	return int16(uint16(ev.Data2)<<7|uint16(ev.Data1)) - 8192
+}
+
+// MIDI is a MIDI port running over a PIO UART at 31.25kbaud, adding MIDI ports
+// beyond the two hardware UARTs. The input is parsed into MIDIEvents, handling
+// running status and real-time messages interleaved with other messages. System
+// exclusive messages are skipped.
+type MIDI struct {
+	uart *UART
+	// Receiver state: running status, data bytes received and expected.
+	status byte
+	data   [2]byte
+	n      uint8
+	sysex  bool
+	// Transmitter running status, 0 if disabled or not set.
+	txRunning    bool
+	txLastStatus byte
+}
+
+// NewMIDI creates a MIDI port with output on txPin and input on rxPin. Either pin
+// can be machine.NoPin for an input or output only port. See NewUART for the use
+// of the state machines.
+func NewMIDI(txsm, rxsm pio.StateMachine, txPin, rxPin machine.Pin) (*MIDI, error) {
+	uart, err := NewUART(txsm, rxsm, txPin, rxPin, midiBaud)
+	if err != nil {
+		return nil, err
+	}
+	return &MIDI{uart: uart}, nil
+}
+
+// UART returns the UART of the port, i.e. to set a read timeout.
+func (m *MIDI) UART() *UART {
+	return m.uart
+}
+
+// SetRunningStatus enables running status on output: the status byte is omitted
+// from channel messages with the same status as the previous one, saving a third
+// of the bandwidth for dense streams of notes or controller changes.
+func (m *MIDI) SetRunningStatus(enabled bool) {
+	m.txRunning = enabled
+	m.txLastStatus = 0
+}
+
+// ReadEvent blocks until a complete message is received.
+func (m *MIDI) ReadEvent() (MIDIEvent, error) {
+	for {
+		b, err := m.uart.ReadByte()
+		if err != nil {
+			// Drop the partial message, the sender resends its status soon.
+			m.status, m.n = 0, 0
+			return MIDIEvent{}, err
+		}
+		if ev, ok := m.parse(b); ok {
+			return ev, nil
+		}
+	}
+}
+
+// parse feeds a received byte to the parser, returning an event once complete.
+func (m *MIDI) parse(b byte) (ev MIDIEvent, ok bool) {
+	switch {
+	case b >= 0xf8:
+		// Real-time messages may come anywhere, even within other messages.
+		return MIDIEvent{Status: b}, true
+	case b == 0xf0:
+		m.sysex, m.status = true, 0
+		return ev, false
+	case b == 0xf7:
+		m.sysex = false
+		return ev, false
+	case b >= 0x80:
+		m.sysex = false
+		m.status, m.n = b, 0
+		if midiDataLen(b) == 0 {
+			m.status = 0 // System common messages cancel running status.
+			return MIDIEvent{Status: b}, b == byte(MIDITuneRequest)
+		}
+		return ev, false
+	case m.sysex || m.status == 0:
+		return ev, false // Data without status.
+	}
+	m.data[m.n] = b
+	m.n++
+	if m.n < midiDataLen(m.status) {
+		return ev, false
+	}
+	ev = MIDIEvent{Status: m.status, Data1: m.data[0]}
+	if m.n == 2 {
+		ev.Data2 = m.data[1]
+	}
+	m.n = 0
+	if m.status >= 0xf0 {
+		m.status = 0
+	}
+	return ev, true
+}
+
+// WriteEvent sends a message, omitting its status byte if running status is
+// enabled and it is the same as for the previous message.
+func (m *MIDI) WriteEvent(ev MIDIEvent) error {
+	var buf [3]byte
+	n := 0
+	switch {
+	case ev.Status >= 0xf8:
+		// Real-time messages don't affect running status.
+	case ev.Status >= 0xf0 || !m.txRunning:
+		m.txLastStatus = 0
+	case ev.Status == m.txLastStatus:
+		n = -1 // Omit the status byte.
+	default:
+		m.txLastStatus = ev.Status
+	}
+	if n == 0 {
+		buf[n] = ev.Status
+	}
+	n++
+	data := midiDataLen(ev.Status)
+	if data > 0 {
+		buf[n] = ev.Data1 & 0x7f
+		n++
+	}
+	if data > 1 {
+		buf[n] = ev.Data2 & 0x7f
+		n++
+	}
+	_, err := m.uart.Write(buf[:n])
+	return err
+}
+
+// NoteOn sends a note on message on channel 0..15.
+func (m *MIDI) NoteOn(channel, note, velocity uint8) error {
+	return m.writeChannel(MIDINoteOn, channel, note, velocity)
+}
+
+// NoteOff sends a note off message on channel 0..15.
+func (m *MIDI) NoteOff(channel, note, velocity uint8) error {
+	return m.writeChannel(MIDINoteOff, channel, note, velocity)
+}
+
+// ControlChange sends a control change message on channel 0..15.
+func (m *MIDI) ControlChange(channel, controller, value uint8) error {
+	return m.writeChannel(MIDIControlChange, channel, controller, value)
+}
+
+// ProgramChange sends a program change message on channel 0..15.
+func (m *MIDI) ProgramChange(channel, program uint8) error {
+	return m.writeChannel(MIDIProgramChange, channel, program, 0)
+}
+
+// PitchBend sends a pitch bend message on channel 0..15 with value from -8192 to 8191.
+func (m *MIDI) PitchBend(channel uint8, value int16) error {
+	v := uint16(value + 8192)
+	return m.writeChannel(MIDIPitchBend, channel, uint8(v&0x7f), uint8(v>>7&0x7f))
+}
+
+func (m *MIDI) writeChannel(kind MIDIEventKind, channel, data1, data2 uint8) error {
+	if channel > 15 {
+		return errMIDIChannel
+	}
+	return m.WriteEvent(MIDIEvent{Status: byte(kind) | channel, Data1: data1, Data2: data2})
+}
+
+// midiDataLen returns the number of data bytes following status.
+func midiDataLen(status byte) uint8 {
+	switch MIDIEventKind(status) {
+	case MIDITimeCode, MIDISongSelect:
+		return 1
+	case MIDISongPosition:
+		return 2
+	}
+	if status >= 0xf0 {
+		return 0
+	}
+	switch MIDIEventKind(status & 0xf0) {
+	case MIDIProgramChange, MIDIChannelPressure:
+		return 1
+	}
+	return 2
+}
+
+// Placement returns the state machines and programs used by the MIDI port UART.
+func (m *MIDI) Placement() Placement {
+	return m.uart.Placement()
+}
@@ -5,7 +5,7 @@ package piolib
 import (
 	"errors"
 	"machine"
-	"unsafe"
+	"time"
 
 	pio "github.com/tinygo-org/pio/rp2-pio"
 )
@@ -15,6 +15,13 @@ type Parallel8Tx struct {
 	sm     pio.StateMachine
 	offset uint8
 	dma    dmaChannel
+	chunks dmaChunking
+	high   bool        // DMA channel priority, see SetHighPriority.
+	te     machine.Pin // Tearing effect pin, NoPin if VSync disabled.
+	pins   uint32
+	susp   suspender
+	stats  fifoMonitor
+	guard  fifoGuard
 }
 
 // unused for now.
@@ -56,9 +63,11 @@ func NewParallel8Tx(sm pio.StateMachine, wr, dStart machine.Pin, baud uint32) (*
 	cfg.SetClkDivIntFrac(whole, frac)
 
 	sm.Init(offset, cfg)
+	trackClock(sm, baud)
 	sm.SetEnabled(true)
 
-	return &Parallel8Tx{sm: sm, offset: offset}, nil
+	pins := uint32(1)<<wr | 0xff<<dStart
+	return &Parallel8Tx{sm: sm, offset: offset, te: machine.NoPin, pins: pins}, nil
 }
 
 func (pl *Parallel8Tx) Write(data []uint8) error {
@@ -66,15 +75,70 @@ func (pl *Parallel8Tx) Write(data []uint8) error {
 		return pl.dmaWrite(data)
 	}
 	retries := int8(127)
+	pl.stats.begin(pl.sm)
 	for _, char := range data {
-		if !pl.sm.IsTxFIFOFull() {
-			pl.sm.TxPut(uint32(char))
-		} else if retries > 0 {
+		// Retry the same byte while the FIFO is full so none is dropped.
+		for pl.sm.IsTxFIFOFull() {
+			if retries == 0 {
+				return errTimeout
+			}
+			pl.stats.txFull(pl.sm)
 			gosched()
 			retries--
-		} else {
+		}
+		pl.sm.TxPut(uint32(char))
+		pl.stats.sample(pl.sm)
+	}
+	return pl.guard.check(pl.sm, len(data))
+}
+
+// EnableVSync enables synchronization of WriteSync with the rising edge of a display's
+// tearing effect (TE) signal on tePin, which marks the start of the vertical blanking period.
+// The display must have its TE output enabled. Use machine.NoPin to disable synchronization.
+func (pl *Parallel8Tx) EnableVSync(tePin machine.Pin) {
+	if tePin != machine.NoPin {
+		tePin.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+	pl.te = tePin
+}
+
+// WriteSync writes data after the next rising edge of the TE signal so that frame
+// updates do not tear. If VSync is not enabled WriteSync behaves like Write.
+func (pl *Parallel8Tx) WriteSync(data []uint8) error {
+	if pl.te != machine.NoPin {
+		// With DMA the FIFO is filled while the state machine waits on the edge,
+		// so data starts flowing as soon as the edge arrives.
+		err := pl.waitVSync(!pl.IsDMAEnabled())
+		if err != nil {
+			return err
+		}
+	}
+	return pl.Write(data)
+}
+
+// Time to wait for the TE signal, longer than the slowest display frame period.
+const vsyncTimeout = 100 * time.Millisecond
+
+// waitVSync stalls the state machine until the next rising edge on the TE pin using
+// WAIT GPIO instructions. If blocking is true it also waits until the edge occurs.
+func (pl *Parallel8Tx) waitVSync(blocking bool) error {
+	dl := deadline{t: time.Now().Add(vsyncTimeout)}
+	// Wait for TE low first so we catch the start of the next blanking period.
+	pl.sm.Exec(pio.EncodeWaitGPIO(false, uint8(pl.te)))
+	for pl.sm.IsExecStalled() {
+		if dl.expired() {
+			pl.sm.Restart() // Discards the stalled instruction.
+			return errTimeout
+		}
+		gosched()
+	}
+	pl.sm.Exec(pio.EncodeWaitGPIO(true, uint8(pl.te)))
+	for blocking && pl.sm.IsExecStalled() {
+		if dl.expired() {
+			pl.sm.Restart()
 			return errTimeout
 		}
+		gosched()
 	}
 	return nil
 }
@@ -96,7 +160,7 @@ func (pl *Parallel8Tx) EnableDMA(enabled bool) error {
 		return nil
 	}
 
-	channel, ok := _DMA.ClaimChannel()
+	channel, ok := _DMA.ClaimChannelFor("Parallel8Tx")
 	if !ok {
 		return errDMAUnavail
 	}
@@ -106,14 +170,35 @@ func (pl *Parallel8Tx) EnableDMA(enabled bool) error {
 	cc := pl.dma.CurrentConfig()
 	cc.setBSwap(false)
 	cc.setTransferDataSize(dmaTxSize8)
+	cc.setHighPriority(pl.high)
 	pl.dma.Init(cc)
 	return nil
 }
 
+// SetChunking splits DMA writes into transfers of at most chunkSize bytes,
+// yielding to the scheduler between them and waiting interChunkDelay if not 0, so
+// a framebuffer blit leaves the bus to latency sensitive streams such as audio.
+// Use 0 as chunkSize to write in a single transfer, the default. See
+// DMATxStream.SetChunking.
+func (pl *Parallel8Tx) SetChunking(chunkSize int, interChunkDelay time.Duration) {
+	pl.chunks = dmaChunking{size: chunkSize, delay: interChunkDelay}
+}
+
+// SetHighPriority sets whether the DMA channel is scheduled before the normal
+// priority channels when several are ready to transfer. It is kept when DMA is
+// enabled later.
+func (pl *Parallel8Tx) SetHighPriority(high bool) {
+	pl.high = high
+	if pl.IsDMAEnabled() {
+		pl.dma.setHighPriority(high)
+	}
+}
+
 func (pl *Parallel8Tx) dmaWrite(data []byte) error {
 	dreq := dmaPIO_TxDREQ(pl.sm)
-	err := pl.dma.Push8((*byte)(unsafe.Pointer(&pl.sm.TxReg().Reg)), data, dreq)
+	_, err := dmaPushChunked(pl.dma, pl.chunks, pl.sm.TxRegAddr(), data, dreq)
 	if err != nil {
+		pl.stopDMA()
 		return err
 	}
 
@@ -124,3 +209,69 @@ func (pl *Parallel8Tx) dmaWrite(data []byte) error {
 	}
 	return nil
 }
+
+// StartWrite starts writing data like Write by DMA in a single transfer and
+// returns without waiting. DMA must be enabled, see EnableDMA. The operation is
+// done once the last byte is in the FIFO, not yet on the bus. data must not be
+// modified until the operation is done. On timeout or cancellation the bytes
+// left are dropped.
+func (pl *Parallel8Tx) StartWrite(data []byte) *DMAOp {
+	if !pl.IsDMAEnabled() {
+		return &DMAOp{err: errDMAUnavail, done: true}
+	}
+	op := dmaStartPushAddr(pl.dma, pl.sm.TxRegAddr(), data, dmaPIO_TxDREQ(pl.sm))
+	op.stop = pl.stopDMA
+	return &op
+}
+
+// stopDMA aborts a DMA write and restarts the state machine with an empty FIFO.
+func (pl *Parallel8Tx) stopDMA() {
+	stopStreaming(pl.sm, pl.dma)
+	pl.sm.Restart()
+	pl.sm.Jmp(pl.offset, pio.JmpAlways)
+	pl.sm.SetEnabled(true)
+}
+
+// Suspend halts the state machine and turns WR and D0..D7 to inputs with pulls
+// off, so the display can be hot-plugged or power-cycled safely. The
+// configuration is kept for Resume. No write may be in progress and the bus
+// must not be used until resumed.
+func (pl *Parallel8Tx) Suspend() {
+	pl.susp.suspend(pl.sm, pl.pins)
+}
+
+// Resume restores the pins and restarts the state machine halted by Suspend.
+func (pl *Parallel8Tx) Resume() {
+	pl.susp.resume(pl.sm, pl.pins)
+}
+
+// SetFIFOGuard enables checking for words lost by the TX FIFO after each Write
+// without DMA, which then returns a *pio.FIFOError wrapping pio.ErrTxOverflow. It is disabled by default.
+func (pl *Parallel8Tx) SetFIFOGuard(enabled bool) {
+	pl.guard.setEnabled(enabled, pl.sm)
+}
+
+// FIFOErrors returns the number of writes which lost words while the guard was
+// enabled.
+func (pl *Parallel8Tx) FIFOErrors() (txOverflows, rxUnderflows uint32) {
+	return pl.guard.counts()
+}
+
+// Stats returns the FIFO statistics of writes without DMA gathered while
+// EnableFIFOStats is set.
+func (pl *Parallel8Tx) Stats() FIFOStats {
+	return pl.stats.stats
+}
+
+// ResetStats clears the FIFO statistics.
+func (pl *Parallel8Tx) ResetStats() {
+	pl.stats.stats = FIFOStats{}
+}
+
+// Placement returns the state machines, programs and DMA channels used by the parallel bus.
+func (pl *Parallel8Tx) Placement() Placement {
+	var p Placement
+	p.addSM(pl.sm, pl.offset, parallel8Instructions)
+	p.addDMA(pl.dma)
+	return p
+}
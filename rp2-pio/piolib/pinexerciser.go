@@ -0,0 +1,189 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// Default time for pins to settle after each step of a test.
+const pinExerciserSettle = 10 * time.Microsecond
+
+var errPinExerciserPin = errors.New("piolib:pin not in the exerciser pin set")
+
+// PinTestResult is the outcome of a pin test as bitmasks indexed by GPIO number.
+type PinTestResult struct {
+	// Tested has the bits of the pins exercised by the test set.
+	Tested uint32
+	// Failed has the bits of the pins that did not read as expected, or whose
+	// level was changed by another pin, such as both pins of a short.
+	Failed uint32
+}
+
+// OK returns true if no tested pin failed.
+func (r PinTestResult) OK() bool { return r.Failed == 0 }
+
+// Passed returns true if pin was tested and did not fail.
+func (r PinTestResult) Passed(pin machine.Pin) bool {
+	bit := uint32(1) << pin
+	return r.Tested&bit != 0 && r.Failed&bit == 0
+}
+
+// PinExerciser drives and samples a set of GPIOs in timed patterns for production
+// tests, i.e. on a bed-of-nails fixture, finding pins stuck high or low, shorts
+// between pins and open loopback connections. Each step drives the pins of the
+// set, waits for them to settle and samples all pins at once.
+//
+// Pins not in the set are not driven, but the state machine writes the outputs of
+// all pins with the PIO function of its block: other drivers of the same PIO
+// block must not run during tests.
+type PinExerciser struct {
+	sm     pio.StateMachine
+	offset uint8
+	mask   uint32
+	settle uint32
+	dl     deadliner
+}
+
+// NewPinExerciser returns an exerciser for pins, all turned to inputs.
+func NewPinExerciser(sm pio.StateMachine, pins ...machine.Pin) (*PinExerciser, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	var mask uint32
+	for _, pin := range pins {
+		if pin >= 30 {
+			return nil, errors.New("piolib:pin exerciser pins must be GPIO0..GPIO29")
+		}
+		mask |= 1 << pin
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(pinexerciserInstructions, pinexerciserOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for _, pin := range pins {
+		pin.Configure(pinCfg)
+	}
+	cfg := pinexerciserProgramDefaultConfig(offset)
+	cfg.SetOutPins(0, 32)
+	cfg.SetInPins(0)
+	cfg.SetOutShift(true, false, 32)
+	cfg.SetInShift(false, false, 32)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	e := &PinExerciser{sm: sm, offset: offset, mask: mask}
+	e.SetSettle(pinExerciserSettle)
+	if err := e.Release(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetTimeout sets the timeout of each step. Use 0 as argument to disable timeouts.
+func (e *PinExerciser) SetTimeout(timeout time.Duration) {
+	e.dl.setTimeout(timeout)
+}
+
+// SetSettle sets the time pins are given to settle after they are driven before
+// being sampled, which must cover the capacitance of the fixture and pull resistors.
+func (e *PinExerciser) SetSettle(d time.Duration) {
+	// The settle loop runs at the system clock, one cycle per iteration.
+	e.settle = uint32(uint64(d) * uint64(machine.CPUFrequency()) / uint64(time.Second))
+}
+
+// Step drives levels on the pins of the set whose bit is set in enables, turns
+// the others to inputs, and returns the levels of all pins once settled. Bits of
+// pins outside the set are ignored.
+func (e *PinExerciser) Step(levels, enables uint32) (uint32, error) {
+	e.sm.TxPut(levels)
+	e.sm.TxPut(enables & e.mask)
+	e.sm.TxPut(e.settle)
+	dl := e.dl.newDeadline()
+	for e.sm.IsRxFIFOEmpty() {
+		if dl.expired() {
+			e.reset()
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	return e.sm.RxGet(), nil
+}
+
+// Release turns all pins of the set to inputs.
+func (e *PinExerciser) Release() error {
+	_, err := e.Step(0, 0)
+	return err
+}
+
+// WalkingOnes drives a single pin of the set high at a time with all others of
+// the set driven low, then the reverse, and checks each pin reads as driven.
+// A pin stuck or shorted to another fails, as do the other pins of a short.
+// Pins are released at the end.
+func (e *PinExerciser) WalkingOnes() (PinTestResult, error) {
+	res := PinTestResult{Tested: e.mask}
+	for m := e.mask; m != 0; m &= m - 1 {
+		bit := m & -m
+		for _, levels := range [2]uint32{bit, e.mask &^ bit} {
+			in, err := e.Step(levels, e.mask)
+			if err != nil {
+				return res, err
+			}
+			res.Failed |= (in ^ levels) & e.mask
+		}
+	}
+	return res, e.Release()
+}
+
+// Loopback checks pins connected in pairs by the fixture: the first pin of each
+// pair is driven high then low with all other pins of the set as inputs, and the
+// second must follow. Both pins of a pair that doesn't follow fail, as does any
+// other pin of the set that follows, being shorted to the pair. Pins are
+// released at the end.
+func (e *PinExerciser) Loopback(pairs [][2]machine.Pin) (PinTestResult, error) {
+	var res PinTestResult
+	for _, pair := range pairs {
+		out, in := uint32(1)<<pair[0], uint32(1)<<pair[1]
+		if e.mask&out == 0 || e.mask&in == 0 {
+			return res, errPinExerciserPin
+		}
+		res.Tested |= out | in
+		var high, low uint32
+		var err error
+		if high, err = e.Step(out, out); err == nil {
+			low, err = e.Step(0, out)
+		}
+		if err != nil {
+			return res, err
+		}
+		if high&in == 0 || low&in != 0 {
+			res.Failed |= out | in
+		}
+		// Other pins toggling with the driven pin are shorted to it.
+		if shorted := (high &^ low) & e.mask &^ (out | in); shorted != 0 {
+			res.Failed |= shorted | out
+			res.Tested |= shorted
+		}
+	}
+	return res, e.Release()
+}
+
+// reset drops pending steps and turns all pins to inputs.
+func (e *PinExerciser) reset() {
+	e.sm.SetEnabled(false)
+	e.sm.ClearFIFOs()
+	e.sm.Restart()
+	e.sm.SetPindirsMasked(0, e.mask)
+	e.sm.Jmp(e.offset, pio.JmpAlways)
+	e.sm.SetEnabled(true)
+}
+
+// Placement returns the state machine and program used by the pin exerciser.
+func (e *PinExerciser) Placement() Placement {
+	var p Placement
+	p.addSM(e.sm, e.offset, pinexerciserInstructions)
+	return p
+}
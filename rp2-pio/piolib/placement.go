@@ -0,0 +1,131 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"errors"
+	"strconv"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// AnyBlock may be passed to ClaimStateMachine to accept a state machine of any PIO block.
+const AnyBlock = -1
+
+var errNoStateMachine = errors.New("piolib:no state machine available on PIO block")
+
+// ClaimStateMachine claims an unused state machine of PIO block 0 or 1 to pass to a
+// driver constructor, or of the first block with one available if block is AnyBlock.
+// Drivers run on the block of the state machine they are given, so this pins a
+// driver to a block, i.e. to keep it next to programs it shares IRQ flags with.
+func ClaimStateMachine(block int) (pio.StateMachine, error) {
+	blocks := []*pio.PIO{pio.PIO0, pio.PIO1}
+	switch block {
+	case AnyBlock:
+	case 0, 1:
+		blocks = blocks[block : block+1]
+	default:
+		return pio.StateMachine{}, errNoStateMachine
+	}
+	for _, Pio := range blocks {
+		if sm, err := Pio.ClaimStateMachine(); err == nil {
+			return sm, nil
+		}
+	}
+	return pio.StateMachine{}, errNoStateMachine
+}
+
+// ClaimStateMachineReserved is like ClaimStateMachine but claims a state machine
+// reserved by owner with pio.PIO.Reserve if one is available, for board support
+// drivers. See pio.PIO.ClaimStateMachineReserved.
+func ClaimStateMachineReserved(block int, owner string) (pio.StateMachine, error) {
+	blocks := []*pio.PIO{pio.PIO0, pio.PIO1}
+	switch block {
+	case AnyBlock:
+	case 0, 1:
+		blocks = blocks[block : block+1]
+	default:
+		return pio.StateMachine{}, errNoStateMachine
+	}
+	for _, Pio := range blocks {
+		if sm, err := Pio.ClaimStateMachineReserved(owner); err == nil {
+			return sm, nil
+		}
+	}
+	return pio.StateMachine{}, errNoStateMachine
+}
+
+// Placement describes the hardware resources used by a driver, for debugging and
+// resource audits. Drivers report it with their Placement method.
+type Placement struct {
+	StateMachines []SMPlacement
+	// DMAChannels holds the indices of the DMA channels claimed by the driver.
+	DMAChannels []uint8
+}
+
+// SMPlacement is a state machine used by a driver and the program it runs.
+type SMPlacement struct {
+	// Block is the index of the PIO block.
+	Block uint8
+	// StateMachine is the index of the state machine within the block.
+	StateMachine uint8
+	// ProgramOffset and ProgramLen locate the program in instruction memory.
+	ProgramOffset uint8
+	ProgramLen    uint8
+}
+
+// String returns the placement in a single line, i.e:
+//
+//	pio0 sm1 prog 24..31, dma 2
+func (p Placement) String() string {
+	var b []byte
+	for i, sm := range p.StateMachines {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, "pio"...)
+		b = strconv.AppendUint(b, uint64(sm.Block), 10)
+		b = append(b, " sm"...)
+		b = strconv.AppendUint(b, uint64(sm.StateMachine), 10)
+		b = append(b, " prog "...)
+		b = strconv.AppendUint(b, uint64(sm.ProgramOffset), 10)
+		b = append(b, ".."...)
+		b = strconv.AppendUint(b, uint64(sm.ProgramOffset+sm.ProgramLen-1), 10)
+	}
+	for _, ch := range p.DMAChannels {
+		if len(b) > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, "dma "...)
+		b = strconv.AppendUint(b, uint64(ch), 10)
+	}
+	return string(b)
+}
+
+func (p *Placement) addSM(sm pio.StateMachine, offset uint8, program []uint16) {
+	p.StateMachines = append(p.StateMachines, SMPlacement{
+		Block:         sm.PIO().BlockIndex(),
+		StateMachine:  sm.StateMachineIndex(),
+		ProgramOffset: offset,
+		ProgramLen:    uint8(len(program)),
+	})
+}
+
+// addDMA adds the channels in use, skipping invalid ones of drivers with DMA disabled.
+func (p *Placement) addDMA(chs ...dmaChannel) {
+	for _, ch := range chs {
+		if ch.IsValid() {
+			p.DMAChannels = append(p.DMAChannels, ch.ChannelIndex())
+		}
+	}
+}
+
+// freeProgram frees the instruction memory of a program a driver loaded, when its
+// constructor fails or it swaps programs. The program is not shared and the state
+// machines of the driver running it must be disabled beforehand, so no state
+// machine can be executing from the section: it is cleared without the check of
+// pio.PIO.ClearProgramSection, which could be refused by an unrelated state
+// machine whose wrap range merely spans the section and leak the memory.
+func freeProgram(Pio *pio.PIO, offset uint8, program []uint16) {
+	Pio.ForceClearProgramSection(offset, uint8(len(program)))
+}
@@ -0,0 +1,142 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"image/color"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errPOVSize = errors.New("piolib:POV display needs at least one column and LED")
+
+// POVDisplay is a persistence of vision display: a spinning strip of WS2812B LEDs
+// which shows a circular image by displaying one column of a framebuffer at each
+// angle of the rotation. A hall sensor on the rotor, active low as the A3144, gives
+// an index pulse once per revolution. The index pin is timed by a state machine
+// to measure the rotation period, from which the angular column slots are derived.
+// Enable DMA on the WS2812B driver so column transmission takes no CPU time.
+type POVDisplay struct {
+	ws      *WS2812B
+	index   pio.StateMachine
+	offset  uint8
+	dl      deadliner
+	columns int
+	leds    int
+	// raw holds the wire format of the framebuffer, column after column.
+	raw []uint32
+	// Rotation period and low time of the index signal in CPU cycles, 0 if unknown.
+	period uint64
+	low    uint64
+}
+
+// NewPOVDisplay returns a POV display of columns angular columns of leds pixels,
+// output through ws. index is the state machine measuring the index pin.
+func NewPOVDisplay(ws *WS2812B, index pio.StateMachine, indexPin machine.Pin, columns, leds int) (*POVDisplay, error) {
+	if columns <= 0 || leds <= 0 {
+		return nil, errPOVSize
+	}
+	index.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := index.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(index, offset, indexPin)
+	return &POVDisplay{
+		ws:      ws,
+		index:   index,
+		offset:  offset,
+		columns: columns,
+		leds:    leds,
+		raw:     make([]uint32, columns*leds),
+	}, nil
+}
+
+// SetTimeout sets the time Refresh waits for an index pulse. Use 0 as argument to disable timeouts.
+func (p *POVDisplay) SetTimeout(timeout time.Duration) {
+	p.dl.setTimeout(timeout)
+}
+
+// Size returns the number of columns and LEDs per column of the framebuffer.
+func (p *POVDisplay) Size() (columns, leds int) {
+	return p.columns, p.leds
+}
+
+// SetPixel sets the color of LED led of column. Column 0 is displayed at the
+// index pulse and columns follow in the direction of rotation. The alpha channel is ignored.
+func (p *POVDisplay) SetPixel(column, led int, c color.RGBA) {
+	p.raw[column*p.leds+led] = uint32(c.G)<<24 | uint32(c.R)<<16 | uint32(c.B)<<8
+}
+
+// Clear turns off all pixels.
+func (p *POVDisplay) Clear() {
+	for i := range p.raw {
+		p.raw[i] = 0
+	}
+}
+
+// Period returns the last measured rotation period or 0 if not known yet.
+func (p *POVDisplay) Period() time.Duration {
+	return time.Duration(p.period * uint64(time.Second) / uint64(machine.CPUFrequency()))
+}
+
+// Refresh waits for the next index pulse and displays one revolution of the
+// framebuffer, transmitting each column at the start of its angular slot. Columns
+// whose slot passed while a previous column was transmitted are skipped, so the
+// image stays in place when the strip is too long for the number of columns.
+// Call Refresh in a loop; nothing is displayed until the period is known, which
+// takes two index pulses. Refresh busy-waits for accurate timing and does not
+// yield to other goroutines until the revolution is displayed.
+func (p *POVDisplay) Refresh() error {
+	dl := p.dl.newDeadline()
+	for {
+		if p.index.IsRxFIFOEmpty() {
+			if dl.expired() {
+				return errTimeout
+			}
+			continue // Spin to timestamp the index pulse as early as possible.
+		}
+		cycles, high := pulsewidthDecode(p.index.RxGet())
+		if !high {
+			p.low = cycles
+			continue
+		}
+		// The high time ends at the falling edge of the index pulse.
+		if p.low == 0 {
+			continue
+		}
+		if p.index.IsRxFIFOEmpty() {
+			p.period = p.low + cycles
+			break // No backlog, this is the current index pulse.
+		}
+		p.period = p.low + cycles
+	}
+	start := time.Now()
+	period := p.Period()
+	for c := 0; c < p.columns; c++ {
+		slot := start.Add(period * time.Duration(c) / time.Duration(p.columns))
+		next := start.Add(period * time.Duration(c+1) / time.Duration(p.columns))
+		for time.Now().Before(slot) {
+		}
+		if time.Now().After(next) {
+			continue // Late for this column.
+		}
+		if err := p.ws.WriteRaw(p.raw[c*p.leds : (c+1)*p.leds]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Placement returns the state machines, programs and DMA channels used by the display: the index pulse measurement followed by the LED strip.
+func (p *POVDisplay) Placement() Placement {
+	var pl Placement
+	pl.addSM(p.index, p.offset, pulsewidthInstructions)
+	ws := p.ws.Placement()
+	pl.StateMachines = append(pl.StateMachines, ws.StateMachines...)
+	pl.DMAChannels = ws.DMAChannels
+	return pl
+}
@@ -0,0 +1,274 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"math/bits"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errPRBSPattern = errors.New("piolib:unknown PRBS pattern")
+
+// PRBS is a pseudo-random binary sequence of ITU-T O.150, named after the length
+// of its shift register: the sequence repeats every 2ⁿ-1 bits.
+type PRBS uint8
+
+const (
+	PRBS7  PRBS = 7  // x⁷+x⁶+1
+	PRBS15 PRBS = 15 // x¹⁵+x¹⁴+1
+	PRBS31 PRBS = 31 // x³¹+x²⁸+1
+)
+
+const (
+	// A received word with this many errors or more is counted as bad, random
+	// data having 16 errors per word on average.
+	prbsBadWordErrors = 8
+	// Consecutive bad words after which the checker resynchronizes.
+	prbsLossOfLockWords = 4
+)
+
+// prbsLFSR is a Fibonacci LFSR holding the last n bits of a sequence, the newest
+// at bit 0.
+type prbsLFSR struct {
+	state uint32
+	n     uint8
+	tap   uint8
+}
+
+func newPRBSLFSR(pattern PRBS) (prbsLFSR, error) {
+	l := prbsLFSR{n: uint8(pattern), state: 1<<pattern - 1}
+	switch pattern {
+	case PRBS7:
+		l.tap = 6
+	case PRBS15:
+		l.tap = 14
+	case PRBS31:
+		l.tap = 28
+	default:
+		return l, errPRBSPattern
+	}
+	return l, nil
+}
+
+// shift shifts bit into the register.
+func (l *prbsLFSR) shift(bit uint32) {
+	l.state = (l.state<<1 | bit) & (1<<l.n - 1)
+}
+
+// next returns the next bit of the sequence.
+func (l *prbsLFSR) next() uint32 {
+	bit := (l.state>>(l.n-1) ^ l.state>>(l.tap-1)) & 1
+	l.shift(bit)
+	return bit
+}
+
+// word returns the next 32 bits of the sequence, LSB first.
+func (l *prbsLFSR) word() (w uint32) {
+	for i := 0; i < 32; i++ {
+		w |= l.next() << i
+	}
+	return w
+}
+
+// PRBSGenerator outputs a PRBS pattern and a clock to test a link, such as level
+// shifters, cables or isolation barriers, with a PRBSChecker at the other end.
+// The pattern is computed by the CPU, which has to keep the TX FIFO filled by
+// calling Fill. The stream pauses with the clock low when the FIFO runs dry, so
+// a slow Fill lowers the throughput but doesn't cause errors.
+type PRBSGenerator struct {
+	sm     pio.StateMachine
+	offset uint8
+	lfsr   prbsLFSR
+	inject bool
+}
+
+// NewPRBSGenerator creates a generator of pattern outputting bitrate bits per
+// second on data, and a clock on clock which the checker samples data with.
+func NewPRBSGenerator(sm pio.StateMachine, data, clock machine.Pin, pattern PRBS, bitrate uint32) (*PRBSGenerator, error) {
+	lfsr, err := newPRBSLFSR(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// 2 cycles per bit.
+	whole, frac, err := pio.ClkDivFromFrequency(2*bitrate, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(prbs_txInstructions, prbs_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	data.Configure(pinCfg)
+	clock.Configure(pinCfg)
+	sm.SetPinsConsecutive(data, 1, false)
+	sm.SetPinsConsecutive(clock, 1, false)
+	sm.SetPindirsConsecutive(data, 1, true)
+	sm.SetPindirsConsecutive(clock, 1, true)
+	cfg := prbs_txProgramDefaultConfig(offset)
+	cfg.SetOutPins(data, 1)
+	cfg.SetSidesetPins(clock)
+	cfg.SetOutShift(true, true, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, 2*bitrate)
+	sm.SetEnabled(true)
+	g := &PRBSGenerator{sm: sm, offset: offset, lfsr: lfsr}
+	g.Fill()
+	return g, nil
+}
+
+// Fill queues the next words of the pattern until the TX FIFO is full. It has to
+// be called at least every 256 bit times for the stream not to pause.
+func (g *PRBSGenerator) Fill() {
+	for !g.sm.IsTxFIFOFull() {
+		w := g.lfsr.word()
+		if g.inject {
+			w ^= 1
+			g.inject = false
+		}
+		g.sm.TxPut(w)
+	}
+}
+
+// InjectError flips a bit of the next word queued by Fill, to check the checker
+// and the link see it.
+func (g *PRBSGenerator) InjectError() {
+	g.inject = true
+}
+
+// Placement returns the state machine and program used by the generator.
+func (g *PRBSGenerator) Placement() Placement {
+	var p Placement
+	p.addSM(g.sm, g.offset, prbs_txInstructions)
+	return p
+}
+
+// PRBSChecker counts the bit errors of a PRBS pattern received from a
+// PRBSGenerator across a link. It synchronizes to the pattern from the first bits
+// received, then compares the received bits to those it generates itself, and
+// resynchronizes if most bits are wrong for several words, as when the link drops.
+// Received bits are checked by Update, which has to be called at least every 256
+// bit times for no bits to be lost.
+type PRBSChecker struct {
+	sm     pio.StateMachine
+	offset uint8
+	lfsr   prbsLFSR
+	// filled is the number of bits shifted into the register while synchronizing.
+	filled   uint8
+	badWords uint8
+	bits     uint64
+	errors   uint64
+}
+
+// NewPRBSChecker creates a checker of pattern sampling data at the rising edges
+// of clock.
+func NewPRBSChecker(sm pio.StateMachine, data, clock machine.Pin, pattern PRBS) (*PRBSChecker, error) {
+	lfsr, err := newPRBSLFSR(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(prbs_rxInstructions, prbs_rxOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	data.Configure(pinCfg)
+	clock.Configure(pinCfg)
+	sm.SetPindirsConsecutive(data, 1, false)
+	sm.SetPindirsConsecutive(clock, 1, false)
+	cfg := prbs_rxProgramDefaultConfig(offset)
+	cfg.SetInPins(data)
+	cfg.SetJmpPin(clock)
+	cfg.SetInShift(true, true, 32)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return &PRBSChecker{sm: sm, offset: offset, lfsr: lfsr}, nil
+}
+
+// Update checks the bits received since the last call.
+func (c *PRBSChecker) Update() {
+	for !c.sm.IsRxFIFOEmpty() {
+		c.check(c.sm.RxGet())
+	}
+}
+
+func (c *PRBSChecker) check(w uint32) {
+	if c.filled < c.lfsr.n {
+		for i := 0; i < 32; i++ {
+			bit := w >> i & 1
+			if c.filled < c.lfsr.n {
+				c.lfsr.shift(bit)
+				c.filled++
+				if c.filled == c.lfsr.n && c.lfsr.state == 0 {
+					// A stuck low line, which the all zeros register would match.
+					c.filled = 0
+				}
+				continue
+			}
+			c.bits++
+			if bit != c.lfsr.next() {
+				c.errors++
+			}
+		}
+		return
+	}
+	errs := bits.OnesCount32(w ^ c.lfsr.word())
+	c.bits += 32
+	c.errors += uint64(errs)
+	if errs < prbsBadWordErrors {
+		c.badWords = 0
+		return
+	}
+	c.badWords++
+	if c.badWords >= prbsLossOfLockWords {
+		c.badWords = 0
+		c.filled = 0
+	}
+}
+
+// Locked returns true if the checker is synchronized to the pattern.
+func (c *PRBSChecker) Locked() bool {
+	c.Update()
+	return c.filled >= c.lfsr.n
+}
+
+// Counts returns the number of bits checked and of bit errors since the window
+// started, with the last call to Reset or the creation of the checker.
+func (c *PRBSChecker) Counts() (bits, errors uint64) {
+	c.Update()
+	return c.bits, c.errors
+}
+
+// BER returns the bit error rate over the window, 0 if no bits were checked.
+func (c *PRBSChecker) BER() float64 {
+	bits, errors := c.Counts()
+	if bits == 0 {
+		return 0
+	}
+	return float64(errors) / float64(bits)
+}
+
+// Reset starts a new window, keeping the checker synchronized.
+func (c *PRBSChecker) Reset() {
+	c.Update()
+	c.bits, c.errors = 0, 0
+}
+
+// Placement returns the state machine and program used by the checker.
+func (c *PRBSChecker) Placement() Placement {
+	var p Placement
+	p.addSM(c.sm, c.offset, prbs_rxInstructions)
+	return p
+}
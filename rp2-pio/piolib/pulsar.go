@@ -32,6 +32,7 @@ func NewPulsar(sm pio.StateMachine, pin machine.Pin) (*Pulsar, error) {
 	cfg := pulsarProgramDefaultConfig(offset)
 	cfg.SetSetPins(pin, 1)
 	sm.Init(offset, cfg)
+	trackClock(sm, 0) // Runs at the system clock until SetPeriod.
 	sm.SetEnabled(true)
 	return &Pulsar{sm: sm, offsetPlusOne: offset + 1}, nil
 }
@@ -67,6 +68,7 @@ func (p *Pulsar) SetPeriod(period time.Duration) error {
 		return err
 	}
 	p.sm.SetClkDiv(whole, frac)
+	trackClock(p.sm, uint32(time.Second/period))
 	return nil
 }
 
@@ -94,3 +96,10 @@ func (p *Pulsar) mustValid() {
 		panic("piolib: Pulsar not initialized")
 	}
 }
+
+// Placement returns the state machine and program used by the pulse generator.
+func (p *Pulsar) Placement() Placement {
+	var pl Placement
+	pl.addSM(p.sm, p.offsetPlusOne-1, pulsarInstructions)
+	return pl
+}
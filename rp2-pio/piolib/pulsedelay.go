@@ -0,0 +1,127 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Cycles from the trigger edge to the output pulse with a zero countdown:
+	// 2 cycles of input synchronization, the WAIT, the single JMP of the delay
+	// loop and the SET raising the pin.
+	pulseDelayOverhead = 5
+	// Cycles added to the Y countdown of the pulse width: the SET raising the
+	// pin, the MOV and the last JMP of the width loop.
+	pulseDelayWidthOverhead = 3
+	pulseDelayWidth         = time.Microsecond
+)
+
+var errPulseDelayShort = errors.New("piolib:pulse delay or width too short")
+
+// PulseDelay outputs a pulse a programmable delay after each rising edge on a
+// trigger pin, counted in system clock cycles for a resolution of 8ns at 125MHz
+// and a jitter of one cycle, for camera flash and shutter triggers, sonar and
+// radar experiments or test equipment. Delays are queued: each one arms the
+// generator for one trigger, so up to 8 triggers can be handled with different
+// delays without CPU intervention. Edges while no delay is queued are ignored.
+type PulseDelay struct {
+	sm     pio.StateMachine
+	offset uint8
+	output machine.Pin
+}
+
+// NewPulseDelay returns a delay generator triggered by trigger, outputting
+// pulses of 1µs on output, see SetPulseWidth. If fallingEdge is set, the input
+// of trigger is inverted at the pin so falling edges trigger, which affects all
+// peripherals reading the pin.
+func NewPulseDelay(sm pio.StateMachine, trigger, output machine.Pin, fallingEdge bool) (*PulseDelay, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(pulsedelayInstructions, pulsedelayOrigin)
+	if err != nil {
+		return nil, err
+	}
+	trigger.Configure(machine.PinConfig{Mode: machine.PinInput})
+	if fallingEdge {
+		pinCtrl(trigger).ReplaceBits(1, 0b11, rp.IO_BANK0_GPIO0_CTRL_INOVER_Pos) // Invert.
+	}
+	output.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPinsConsecutive(output, 1, false)
+	sm.SetPindirsConsecutive(output, 1, true)
+
+	cfg := pulsedelayProgramDefaultConfig(offset)
+	cfg.SetInPins(trigger)
+	cfg.SetSetPins(output, 1)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	sm.Init(offset, cfg)
+	d := &PulseDelay{sm: sm, offset: offset, output: output}
+	if err := d.SetPulseWidth(pulseDelayWidth); err != nil {
+		freeProgram(Pio, offset, pulsedelayInstructions)
+		return nil, err
+	}
+	return d, nil
+}
+
+// SetPulseWidth sets the length of the output pulses, of at least 3 system clock cycles.
+// Queued delays are discarded.
+func (d *PulseDelay) SetPulseWidth(width time.Duration) error {
+	cycles := durationToCycles(width)
+	if cycles < pulseDelayWidthOverhead {
+		return errPulseDelayShort
+	}
+	sm := d.sm
+	sm.SetEnabled(false)
+	sm.ClearFIFOs()
+	sm.Restart()
+	sm.SetPinsConsecutive(d.output, 1, false)
+	// Y holds the width countdown, loaded through the OSR as autopull is off.
+	sm.TxPut(cycles - pulseDelayWidthOverhead)
+	sm.Exec(pio.EncodePull(false, true))
+	sm.Exec(pio.EncodeMov(pio.SrcDestY, pio.SrcDestOSR))
+	sm.Jmp(d.offset, pio.JmpAlways)
+	sm.SetEnabled(true)
+	return nil
+}
+
+// Queue arms the generator to output a pulse delay after the next trigger edge.
+// The delay must be at least 5 system clock cycles, 40ns at 125MHz.
+func (d *PulseDelay) Queue(delay time.Duration) error {
+	return d.QueueCycles(durationToCycles(delay))
+}
+
+// QueueCycles is like Queue with the delay in system clock cycles, for full resolution.
+func (d *PulseDelay) QueueCycles(cycles uint32) error {
+	if cycles < pulseDelayOverhead {
+		return errPulseDelayShort
+	}
+	if d.sm.IsTxFIFOFull() {
+		return errQueueFull
+	}
+	d.sm.TxPut(cycles - pulseDelayOverhead)
+	return nil
+}
+
+// Queued returns the number of delays waiting for their trigger, not counting
+// the one the state machine is waiting on or counting down.
+func (d *PulseDelay) Queued() uint8 {
+	return uint8(d.sm.TxFIFOLevel())
+}
+
+// durationToCycles converts d to system clock cycles.
+func durationToCycles(d time.Duration) uint32 {
+	return uint32(uint64(d) * uint64(machine.CPUFrequency()) / uint64(time.Second))
+}
+
+// Placement returns the state machine and program used by the pulse delay generator.
+func (d *PulseDelay) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, pulsedelayInstructions)
+	return p
+}
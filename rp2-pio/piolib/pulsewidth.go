@@ -0,0 +1,105 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// pulsewidthInit starts sm measuring pin with the pulsewidth program loaded at offset.
+// The state machine runs at the CPU frequency.
+func pulsewidthInit(sm pio.StateMachine, offset uint8, pin machine.Pin) {
+	pin.Configure(machine.PinConfig{Mode: sm.PIO().PinMode()})
+	sm.SetPindirsConsecutive(pin, 1, false)
+	cfg := pulsewidthProgramDefaultConfig(offset)
+	cfg.SetInPins(pin)
+	cfg.SetJmpPin(pin)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+}
+
+// pulsewidthMaxCount is the start of the counts of the pulsewidth program, at which
+// measurements saturate: about 34s at 125MHz.
+const pulsewidthMaxCount = 1<<31 - 1
+
+// pulsewidthDecode decodes a word pushed by the pulsewidth program into the
+// duration in CPU cycles and whether it was a high or low time.
+func pulsewidthDecode(word uint32) (cycles uint64, high bool) {
+	high = word&(1<<31) == 0
+	if !high {
+		word = ^word
+	}
+	return 2 * uint64(pulsewidthMaxCount-word), high
+}
+
+// pulseGrouper groups the pulses timed by the pulsewidth program into groups of
+// pulses separated by inactive times shorter than a gap, such as the ringing of a
+// piezo after a knock or of an energizer pulse through an optocoupler.
+type pulseGrouper struct {
+	cpufreq   uint64
+	activeLow bool
+	// Inactive time ending a group and length a group is split at, 0 for none, in cycles.
+	gap, window uint64
+	// Cycles since the first edge, and the group being measured: the start of its
+	// first pulse, the end of its last one, its pulses and their total length.
+	pos        uint64
+	inGroup    bool
+	start, end uint64
+	pulses     uint32
+	active     uint64
+	lastRead   time.Time
+}
+
+func newPulseGrouper(activeLow bool) pulseGrouper {
+	return pulseGrouper{
+		cpufreq:   uint64(machine.CPUFrequency()),
+		activeLow: activeLow,
+		lastRead:  time.Now(),
+	}
+}
+
+// update reads the edges measured by sm and calls done for each group ended,
+// with the fields of the group set.
+func (g *pulseGrouper) update(sm pio.StateMachine, done func()) {
+	for !sm.IsRxFIFOEmpty() {
+		cycles, high := pulsewidthDecode(sm.RxGet())
+		g.lastRead = time.Now()
+		if high != g.activeLow {
+			if !g.inGroup {
+				g.inGroup, g.start = true, g.pos
+				g.pulses, g.active = 0, 0
+			}
+			g.pulses++
+			g.active += cycles
+			g.end = g.pos + cycles
+			if g.window > 0 && g.end-g.start >= g.window {
+				g.inGroup = false
+				done()
+			}
+		} else if g.inGroup && cycles >= g.gap {
+			g.inGroup = false
+			done()
+		}
+		g.pos += cycles
+	}
+	// The gap ending a group is pushed at the next pulse, end it once it is long enough.
+	if g.inGroup && time.Since(g.lastRead) >= g.duration(g.gap) {
+		g.inGroup = false
+		done()
+	}
+}
+
+// duration converts CPU cycles to a duration without overflowing for long runs.
+func (g *pulseGrouper) duration(cycles uint64) time.Duration {
+	return time.Duration(cycles/g.cpufreq)*time.Second +
+		time.Duration(cycles%g.cpufreq*uint64(time.Second)/g.cpufreq)
+}
+
+func (g *pulseGrouper) cycles(d time.Duration) uint64 {
+	return uint64(d) * g.cpufreq / uint64(time.Second)
+}
@@ -0,0 +1,174 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errPWMSensorOverrun = errors.New("piolib:PWM sensor measurement lost")
+
+// Default time to wait for a reading, above the 1s period of MH-Z19 sensors plus
+// the period to synchronize on.
+const pwmSensorDefaultTimeout = 3 * time.Second
+
+// ScaledReader is a sensor reading a physical value, such as a temperature in °C.
+type ScaledReader interface {
+	ReadScaled() (float64, error)
+}
+
+var _ ScaledReader = (*PWMSensor)(nil)
+
+// PWMScale converts the high and low times of a PWM period to a physical value.
+type PWMScale func(high, low time.Duration) float64
+
+// LinearDutyScale returns a scale interpolating linearly between value0 at duty
+// cycle duty0 and value1 at duty1, duty cycles being in the range 0 to 1.
+func LinearDutyScale(duty0, value0, duty1, value1 float64) PWMScale {
+	slope := (value1 - value0) / (duty1 - duty0)
+	return func(high, low time.Duration) float64 {
+		duty := float64(high) / float64(high+low)
+		return value0 + (duty-duty0)*slope
+	}
+}
+
+// LinearFrequencyScale returns a scale interpolating linearly between value0 at
+// hz0 and value1 at hz1, for sensors reporting their value as a frequency.
+func LinearFrequencyScale(hz0, value0, hz1, value1 float64) PWMScale {
+	slope := (value1 - value0) / (hz1 - hz0)
+	return func(high, low time.Duration) float64 {
+		hz := float64(time.Second) / float64(high+low)
+		return value0 + (hz-hz0)*slope
+	}
+}
+
+// TMP05Celsius is the scale of the TMP05 and TMP06 temperature sensors, whose
+// high to low time ratio encodes the temperature in °C.
+func TMP05Celsius(high, low time.Duration) float64 {
+	return 421 - 751*float64(high)/float64(low)
+}
+
+// SMT172Celsius is the scale of the SMT172 temperature sensor, whose duty cycle
+// encodes the temperature in °C.
+func SMT172Celsius(high, low time.Duration) float64 {
+	duty := float64(high) / float64(high+low)
+	return -1.43*duty*duty + 214.56*duty - 68.60
+}
+
+// MHZ19PPM returns the scale of the PWM output of MH-Z19 CO2 sensors in ppm, for
+// the range of the sensor, 2000 or 5000 ppm. Each 1004ms period starts with a 2ms
+// high time and ends with a 2ms low time.
+func MHZ19PPM(rangePPM float64) PWMScale {
+	const edge = 2 * time.Millisecond
+	return func(high, low time.Duration) float64 {
+		return rangePPM * float64(high-edge) / float64(high+low-2*edge)
+	}
+}
+
+// PWMSensor reads a sensor reporting its value as the duty cycle or frequency of
+// a PWM signal, measured by the state machine, and converts it with a PWMScale.
+type PWMSensor struct {
+	sm      pio.StateMachine
+	offset  uint8
+	dl      deadliner
+	scale   PWMScale
+	average uint8
+	cpufreq uint64
+}
+
+// NewPWMSensor returns a reader of the sensor on pin converting its readings with
+// scale. The state machine runs at the CPU frequency.
+func NewPWMSensor(sm pio.StateMachine, pin machine.Pin, scale PWMScale) (*PWMSensor, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	s := &PWMSensor{sm: sm, offset: offset, scale: scale, average: 1, cpufreq: uint64(machine.CPUFrequency())}
+	s.dl.setTimeout(pwmSensorDefaultTimeout)
+	return s, nil
+}
+
+// SetTimeout sets the time a reading waits for the signal, 3s by default. Use 0 as
+// argument to disable timeouts, in which case a reading of a stuck line blocks
+// forever.
+func (s *PWMSensor) SetTimeout(timeout time.Duration) {
+	s.dl.setTimeout(timeout)
+}
+
+// SetAveraging sets the number of periods averaged by each reading, 1 by default.
+func (s *PWMSensor) SetAveraging(periods uint8) {
+	if periods == 0 {
+		periods = 1
+	}
+	s.average = periods
+}
+
+// ReadPulse measures the signal and returns its high and low times, averaged over
+// the periods set by SetAveraging. Measurements preceding the call are discarded.
+func (s *PWMSensor) ReadPulse() (high, low time.Duration, err error) {
+	for !s.sm.IsRxFIFOEmpty() {
+		s.sm.RxGet()
+	}
+	dl := s.dl.newDeadline()
+	var sums [2]uint64
+	synced := false
+	for n := 0; n < 2*int(s.average); {
+		if s.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				return 0, 0, errTimeout
+			}
+			gosched()
+			continue
+		}
+		cycles, isHigh := pulsewidthDecode(s.sm.RxGet())
+		switch {
+		case !synced:
+			// The period starts with a high time, the first may have been cut.
+			synced = !isHigh
+		case isHigh != (n%2 == 0):
+			// A measurement was dropped because the FIFO was full.
+			return 0, 0, errPWMSensorOverrun
+		default:
+			sums[n%2] += cycles
+			n++
+		}
+	}
+	// Converted in floating point, long periods would overflow in integers.
+	div := float64(s.average) * float64(s.cpufreq)
+	high = time.Duration(float64(sums[0]) * float64(time.Second) / div)
+	low = time.Duration(float64(sums[1]) * float64(time.Second) / div)
+	return high, low, nil
+}
+
+// ReadDuty measures the signal and returns its duty cycle, in the range 0 to 1,
+// and its period.
+func (s *PWMSensor) ReadDuty() (duty float64, period time.Duration, err error) {
+	high, low, err := s.ReadPulse()
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(high) / float64(high+low), high + low, nil
+}
+
+// ReadScaled measures the signal and returns the value of the sensor converted by
+// its scale.
+func (s *PWMSensor) ReadScaled() (float64, error) {
+	high, low, err := s.ReadPulse()
+	if err != nil {
+		return 0, err
+	}
+	return s.scale(high, low), nil
+}
+
+// Placement returns the state machine and program used by the sensor.
+func (s *PWMSensor) Placement() Placement {
+	var p Placement
+	p.addSM(s.sm, s.offset, pulsewidthInstructions)
+	return p
+}
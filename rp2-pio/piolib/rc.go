@@ -0,0 +1,183 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Maximum number of channels decoded from a PPM signal.
+	rcMaxChannels = 16
+	// Pulses outside this range are discarded as glitches.
+	rcMinMicros = 500
+	rcMaxMicros = 2500
+	// PPM periods longer than this mark the start of a new frame.
+	rcPPMSyncMicros = 3000
+	// Default time without pulses after which a channel is considered stale.
+	rcDefaultFailsafe = 100 * time.Millisecond
+)
+
+// RCInput decodes the servo signals of a radio control receiver: either standard
+// PWM with one pin per channel or a PPM sum signal carrying all channels on one pin.
+// Each pin is measured by a state machine running at the CPU frequency so no CPU
+// time is used until channels are read. Channels must be read at least every
+// few tens of milliseconds for the failsafe detection to be accurate.
+type RCInput struct {
+	sms    [4]pio.StateMachine
+	pins   uint8
+	offset uint8
+	ppm    bool
+	// channels in use. Fixed for PWM, the largest frame seen for PPM.
+	channels uint8
+	failsafe time.Duration
+	micros   [rcMaxChannels]uint16
+	updated  [rcMaxChannels]time.Time
+	// PPM decoding state.
+	ppmHigh    uint64 // High time of the current period in cycles, 0 if not seen.
+	ppmChannel int8   // Index of the next PPM channel, -1 until synchronized.
+}
+
+// NewRCPWM creates an RC receiver input measuring the pulse width on each pin, one
+// channel per pin. Up to 4 pins can be used and each claims a state machine of Pio.
+func NewRCPWM(Pio *pio.PIO, pins ...machine.Pin) (*RCInput, error) {
+	if len(pins) == 0 || len(pins) > 4 {
+		return nil, errors.New("piolib:RC PWM needs 1 to 4 pins")
+	}
+	offset, err := Pio.AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	rc := &RCInput{pins: uint8(len(pins)), offset: offset, channels: uint8(len(pins)), failsafe: rcDefaultFailsafe}
+	for i := range pins {
+		sm, err := Pio.ClaimStateMachine()
+		if err != nil {
+			for _, claimed := range rc.sms[:i] {
+				claimed.SetEnabled(false)
+				claimed.Unclaim()
+			}
+			freeProgram(Pio, offset, pulsewidthInstructions)
+			return nil, err
+		}
+		rc.sms[i] = sm
+	}
+	for i, pin := range pins {
+		pulsewidthInit(rc.sms[i], offset, pin)
+	}
+	return rc, nil
+}
+
+// NewRCPPM creates an RC receiver input decoding a PPM sum signal of up to 16
+// channels on pin. Either signal polarity is supported.
+func NewRCPPM(sm pio.StateMachine, pin machine.Pin) (*RCInput, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := sm.PIO().AddProgram(pulsewidthInstructions, pulsewidthOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pulsewidthInit(sm, offset, pin)
+	return &RCInput{
+		sms:        [4]pio.StateMachine{sm},
+		pins:       1,
+		offset:     offset,
+		ppm:        true,
+		failsafe:   rcDefaultFailsafe,
+		ppmChannel: -1,
+	}, nil
+}
+
+// SetFailsafeTimeout sets the time without valid pulses after which a channel is
+// reported as stale. It defaults to 100ms.
+func (rc *RCInput) SetFailsafeTimeout(timeout time.Duration) {
+	rc.failsafe = timeout
+}
+
+// NumChannels returns the number of channels. For PPM it is the largest number of
+// channels seen in a frame so far.
+func (rc *RCInput) NumChannels() int {
+	rc.poll()
+	return int(rc.channels)
+}
+
+// ChannelMicros returns the last pulse width of channel i in microseconds, usually
+// in the range 1000..2000, or 0 if no valid pulse was received yet.
+func (rc *RCInput) ChannelMicros(i int) uint16 {
+	rc.poll()
+	if i < 0 || i >= int(rc.channels) {
+		return 0
+	}
+	return rc.micros[i]
+}
+
+// IsStale returns true if channel i received no valid pulse within the failsafe
+// timeout, which happens when the receiver loses the transmitter signal or is disconnected.
+func (rc *RCInput) IsStale(i int) bool {
+	rc.poll()
+	if i < 0 || i >= int(rc.channels) || rc.updated[i].IsZero() {
+		return true
+	}
+	return time.Since(rc.updated[i]) > rc.failsafe
+}
+
+// poll drains the measurements of all state machines.
+func (rc *RCInput) poll() {
+	now := time.Now()
+	cpufreq := uint64(machine.CPUFrequency())
+	for i, sm := range rc.sms[:rc.pins] {
+		for !sm.IsRxFIFOEmpty() {
+			cycles, high := pulsewidthDecode(sm.RxGet())
+			if rc.ppm {
+				rc.decodePPM(cycles, high, cpufreq, now)
+				continue
+			}
+			micros := cycles * 1_000_000 / cpufreq
+			if high && micros >= rcMinMicros && micros <= rcMaxMicros {
+				rc.micros[i] = uint16(micros)
+				rc.updated[i] = now
+			}
+		}
+	}
+}
+
+// decodePPM decodes PPM channels from the time between rising edges.
+func (rc *RCInput) decodePPM(cycles uint64, high bool, cpufreq uint64, now time.Time) {
+	if high != (rc.ppmHigh == 0) {
+		// A measurement was dropped because the FIFO was full, resynchronize.
+		rc.ppmChannel = -1
+	}
+	if high {
+		rc.ppmHigh = cycles
+		return
+	}
+	if rc.ppmHigh == 0 {
+		return
+	}
+	micros := (rc.ppmHigh + cycles) * 1_000_000 / cpufreq
+	rc.ppmHigh = 0
+	switch {
+	case micros > rcPPMSyncMicros:
+		rc.ppmChannel = 0
+	case rc.ppmChannel < 0 || int(rc.ppmChannel) >= rcMaxChannels || micros < rcMinMicros:
+		rc.ppmChannel = -1 // Lost synchronization.
+	default:
+		rc.micros[rc.ppmChannel] = uint16(micros)
+		rc.updated[rc.ppmChannel] = now
+		rc.ppmChannel++
+		if uint8(rc.ppmChannel) > rc.channels {
+			rc.channels = uint8(rc.ppmChannel)
+		}
+	}
+}
+
+// Placement returns the state machines and programs used by the RC input, one state machine per pin.
+func (rc *RCInput) Placement() Placement {
+	var p Placement
+	for _, sm := range rc.sms[:rc.pins] {
+		p.addSM(sm, rc.offset, pulsewidthInstructions)
+	}
+	return p
+}
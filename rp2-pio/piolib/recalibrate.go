@@ -0,0 +1,63 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// clockTarget is the clock frequency a driver runs a state machine at.
+type clockTarget struct {
+	sm   pio.StateMachine
+	freq uint32
+}
+
+// clockTargets is indexed by PIO block and state machine index. Entries with a
+// zero frequency are not tracked.
+var clockTargets [2][4]clockTarget
+
+func init() {
+	// A released state machine may be reclaimed by code not using trackClock.
+	pio.OnUnclaim(untrackClock)
+}
+
+// untrackClock stops tracking the frequency of sm.
+func untrackClock(sm pio.StateMachine) {
+	clockTargets[sm.PIO().BlockIndex()][sm.StateMachineIndex()] = clockTarget{}
+}
+
+// trackClock records the frequency in Hz the clock divider of sm was set for, so
+// RecalibrateAll can recalculate it. A zero freq stops tracking sm, for drivers
+// whose timing is not a plain state machine frequency.
+func trackClock(sm pio.StateMachine, freq uint32) {
+	clockTargets[sm.PIO().BlockIndex()][sm.StateMachineIndex()] = clockTarget{sm: sm, freq: freq}
+}
+
+// RecalibrateAll recalculates the clock dividers of the state machines of piolib
+// drivers for a new system clock frequency of cpuHz, so their baud rates and
+// timings stay correct after the system clock is changed, i.e. to overclock or
+// to save power. Call it right after changing the clock.
+//
+// Dividers are updated in place without stopping the state machines, so a
+// transfer in progress may see a glitch. All state machines are updated even if
+// some fail, the first error is returned, i.e. when a frequency can't be reached
+// from cpuHz. Driver timeouts and state machines run at the system clock, such
+// as those of ClockGen, are not affected: call ClockGen.SetFrequency again.
+func RecalibrateAll(cpuHz uint32) (err error) {
+	for block := range clockTargets {
+		for _, target := range clockTargets[block] {
+			if target.freq == 0 {
+				continue
+			}
+			whole, frac, e := pio.ClkDivFromFrequency(target.freq, cpuHz)
+			if e != nil {
+				if err == nil {
+					err = e
+				}
+				continue
+			}
+			target.sm.SetClkDiv(whole, frac)
+		}
+	}
+	return err
+}
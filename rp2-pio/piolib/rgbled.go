@@ -0,0 +1,165 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"image/color"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// State machine frequency of the RGB LED PWM.
+	rgbLEDFreq = 8_000_000
+	// PWM frequency, high enough for the LED not to flicker.
+	rgbLEDPWMHz = 1000
+	// Cycles per duty cycle step of 1/255.
+	rgbLEDStepCycles = rgbLEDFreq / rgbLEDPWMHz / 256
+)
+
+// RGBLED dims a discrete RGB LED with red, green and blue on 3 consecutive pins
+// using PWM. It runs the charlieplex program: a PWM period is split in 4 time slots,
+// all channels with a non-zero duty cycle are lit in the first slot and each
+// channel is turned off at the end of one of the next slots, in order of duty
+// cycle. The slots are streamed to the state machine by DMA so the LED takes no
+// CPU time once its color is set.
+type RGBLED struct {
+	sm      pio.StateMachine
+	offset  uint8
+	dma     dmaChannel
+	dmaCtrl dmaChannel
+	// invert is set for common anode LEDs, lit when their pin is low.
+	invert      bool
+	gamma       bool
+	duty        [3]uint8
+	pattern     []uint32
+	patternAddr uint32
+}
+
+// NewRGBLED creates an RGB LED driver with red on base, green on base+1 and blue on
+// base+2. commonAnode must be set for LEDs with a common anode, lit by pulling
+// their color pins low. The LED starts off. Two DMA channels are claimed.
+func NewRGBLED(sm pio.StateMachine, base machine.Pin, commonAnode bool) (*RGBLED, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	whole, frac, err := pio.ClkDivFromFrequency(rgbLEDFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	dma, ok := _DMA.ClaimChannelFor("RGBLED")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	dmaCtrl, ok := _DMA.ClaimChannelFor("RGBLED")
+	if !ok {
+		dma.Unclaim()
+		return nil, errDMAUnavail
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(charlieplexInstructions, charlieplexOrigin)
+	if err != nil {
+		dma.Unclaim()
+		dmaCtrl.Unclaim()
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for i := base; i < base+3; i++ {
+		i.Configure(pinCfg)
+	}
+	sm.SetPinsConsecutive(base, 3, commonAnode) // Off.
+	sm.SetPindirsConsecutive(base, 3, true)
+
+	cfg := charlieplexProgramDefaultConfig(offset)
+	cfg.SetOutPins(base, 3)
+	cfg.SetOutShift(true, true, 32)
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, rgbLEDFreq)
+
+	l := &RGBLED{
+		sm:      sm,
+		offset:  offset,
+		dma:     dma,
+		dmaCtrl: dmaCtrl,
+		invert:  commonAnode,
+		pattern: make([]uint32, 4),
+	}
+	l.update()
+	l.patternAddr = ptrAs(&l.pattern[0])
+	sm.SetEnabled(true)
+	dma.pushLoop32(dmaCtrl, &sm.TxReg().Reg, l.pattern, &l.patternAddr, dmaPIO_TxDREQ(sm))
+	return l, nil
+}
+
+// SetGamma enables gamma correction so color values are perceived as linear brightness.
+func (l *RGBLED) SetGamma(enabled bool) {
+	l.gamma = enabled
+	l.update()
+}
+
+// SetColor sets the color of the LED. The alpha channel is ignored, colors with
+// premultiplied alpha such as color.RGBA are dimmed by it.
+func (l *RGBLED) SetColor(c color.Color) {
+	r, g, b, _ := c.RGBA()
+	l.SetRGB(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
+
+// SetRGB sets the red, green and blue duty cycles of the LED, 0 being off and 255 fully on.
+func (l *RGBLED) SetRGB(r, g, b uint8) {
+	l.duty = [3]uint8{r, g, b}
+	l.update()
+}
+
+// update recalculates the slot words of a PWM period. The words are written in
+// place since DMA reads them atomically, which may show a mix of the old and
+// new color for one period.
+func (l *RGBLED) update() {
+	gamma := &ws2812bLinear
+	if l.gamma {
+		gamma = &ws2812bGamma
+	}
+	var duty [3]uint32
+	var lit uint32
+	for ch, d := range l.duty {
+		duty[ch] = uint32(gamma[d])
+		if duty[ch] > 0 {
+			lit |= 1 << ch
+		}
+	}
+	// Turn channels off in order of increasing duty cycle.
+	order := [3]uint8{0, 1, 2}
+	for i := 1; i < 3; i++ {
+		for j := i; j > 0 && duty[order[j]] < duty[order[j-1]]; j-- {
+			order[j], order[j-1] = order[j-1], order[j]
+		}
+	}
+	// Each slot lasts at least the slot overhead, so channels at full scale stay
+	// lit to the end of the period, last slot included, for 100% output.
+	var start uint32
+	for slot, ch := range order {
+		l.pattern[slot] = l.slot(lit, (duty[ch]-start)*rgbLEDStepCycles)
+		if duty[ch] < 255 {
+			lit &^= 1 << ch
+		}
+		start = duty[ch]
+	}
+	l.pattern[3] = l.slot(lit, (255-start)*rgbLEDStepCycles)
+}
+
+// slot returns the charlieplex slot word lighting the channels in lit for cycles.
+func (l *RGBLED) slot(lit, cycles uint32) uint32 {
+	pins := lit
+	if l.invert {
+		pins ^= 0b111
+	}
+	return pins | 0b111<<8 | charlieplexSlotDelay(cycles)<<16
+}
+
+// Placement returns the state machine, program and DMA channels used by the LED PWM.
+func (l *RGBLED) Placement() Placement {
+	var p Placement
+	p.addSM(l.sm, l.offset, charlieplexInstructions)
+	p.addDMA(l.dma, l.dmaCtrl)
+	return p
+}
@@ -0,0 +1,193 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"math/bits"
+	"unsafe"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errRingSize  = errors.New("piolib:ring buffer size must be a power of two from 2 to 32768 bytes")
+	errRingAlign = errors.New("piolib:ring buffer must be aligned to its size")
+	errRingElem  = errors.New("piolib:ring element size must be 1, 2 or 4 bytes")
+)
+
+// RingCapture captures the RX FIFO of a state machine continuously into a circular
+// buffer using the DMA write address ring: the DMA wraps around the buffer by
+// itself and never stops, the reader follows behind with ReadNew. It is the
+// receive path of continuous capture drivers such as UART RX, logic analyzers or
+// PDM microphones. Two DMA channels are claimed: one captures, the other reloads
+// its transfer count when exhausted.
+type RingCapture struct {
+	dma      dmaChannel
+	dmaCtrl  dmaChannel
+	buf      []byte
+	bufAddr  uint32
+	elemSize uint32
+	// reload is the transfer count, a multiple of the ring length so that
+	// positions derived from the remaining count wrap with the ring.
+	reload   uint32
+	tail     uint32 // Transfers read, modulo reload.
+	overflow bool
+}
+
+// NewRingBuffer allocates a buffer of size bytes aligned to its size, as RingCapture
+// requires. size must be a power of two.
+func NewRingBuffer(size int) []byte {
+	buf := make([]byte, 2*size)
+	misalign := int(uintptr(unsafe.Pointer(&buf[0])) & uintptr(size-1))
+	start := (size - misalign) & (size - 1)
+	return buf[start : start+size : start+size]
+}
+
+// NewRingCapture starts capturing the RX FIFO of sm into buf, which must be a
+// power of two long, up to 32768 bytes, and aligned to its length, see
+// NewRingBuffer. Each FIFO word is stored as elemSize bytes: its low bytes, or its
+// high bytes if leftJustified is set for programs shifting in to the right.
+func NewRingCapture(sm pio.StateMachine, buf []byte, elemSize uint8, leftJustified bool) (*RingCapture, error) {
+	var size dmaTxSize
+	switch elemSize {
+	case 1:
+		size = dmaTxSize8
+	case 2:
+		size = dmaTxSize16
+	case 4:
+		size = dmaTxSize32
+	default:
+		return nil, errRingElem
+	}
+	n := len(buf)
+	if n < 2 || n > 1<<15 || n&(n-1) != 0 || n < int(elemSize) {
+		return nil, errRingSize
+	}
+	bufAddr, err := dmaAddr(unsafe.Pointer(&buf[0]), uintptr(n), true)
+	if err != nil {
+		return nil, err
+	}
+	if bufAddr&uint32(n-1) != 0 {
+		return nil, errRingAlign
+	}
+	dma, ok := _DMA.ClaimChannelFor("RingCapture")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	dmaCtrl, ok := _DMA.ClaimChannelFor("RingCapture")
+	if !ok {
+		dma.Unclaim()
+		return nil, errDMAUnavail
+	}
+	src := sm.RxRegAddr()
+	if leftJustified {
+		src += 4 - uint32(elemSize) // Narrow reads of the FIFO pop the whole word.
+	}
+	rc := &RingCapture{
+		dma:      dma,
+		dmaCtrl:  dmaCtrl,
+		buf:      buf,
+		bufAddr:  bufAddr,
+		elemSize: uint32(elemSize),
+		reload:   uint32(n/int(elemSize)) << 16,
+	}
+
+	ctrlHW := dmaCtrl.HW()
+	ctrlHW.READ_ADDR.Set(ptrAs(&rc.reload))
+	ctrlHW.WRITE_ADDR.Set(ptrAs(&dma.HW().AL1_TRANS_COUNT_TRIG.Reg))
+	ctrlHW.TRANS_COUNT.Set(1)
+	ctrlHW.AL1_CTRL.Set(dmaControl(dmaCtrl).CTRL) // Configure without triggering.
+
+	hw := dma.HW()
+	hw.READ_ADDR.Set(src)
+	hw.WRITE_ADDR.Set(bufAddr)
+	hw.TRANS_COUNT.Set(rc.reload)
+	cc := dmaStreamRx(dma, size, dmaPIO_RxDREQ(sm))
+	cc.setChainTo(dmaCtrl.idx)
+	cc.setRing(true, uint32(bits.TrailingZeros(uint(n))))
+	dma.record(DMAStarted, nil)
+	hw.CTRL_TRIG.Set(cc.CTRL)
+	return rc, nil
+}
+
+// Head returns the byte offset in the buffer the DMA writes next.
+func (rc *RingCapture) Head() int {
+	return int(rc.dma.HW().WRITE_ADDR.Get() - rc.bufAddr)
+}
+
+// written returns the number of transfers done, modulo reload.
+func (rc *RingCapture) written() uint32 {
+	remaining := rc.dma.HW().TRANS_COUNT.Get()
+	// The count reads 0 while the control channel reloads it.
+	return (rc.reload - remaining) % rc.reload
+}
+
+// Buffered returns the number of bytes captured and not read yet, up to the
+// buffer length if the capture overflowed.
+func (rc *RingCapture) Buffered() int {
+	pending := (rc.written() - rc.tail + rc.reload) % rc.reload * rc.elemSize
+	if pending > uint32(len(rc.buf)) {
+		return len(rc.buf)
+	}
+	return int(pending)
+}
+
+// ReadNew copies the bytes captured since the last call into p and returns their
+// number, at most len(p) rounded down to whole elements. If more than a buffer
+// length was captured since the last call, the oldest data was overwritten: it is
+// skipped and Overflowed reports it. ReadNew must be called at least once every
+// 65536 buffer lengths for overflows to be detected.
+func (rc *RingCapture) ReadNew(p []byte) int {
+	length := uint32(len(rc.buf)) / rc.elemSize // In elements.
+	written := rc.written()
+	// Don't let reads of the buffer be reordered before reading the DMA position.
+	dmaFence()
+	pending := (written - rc.tail + rc.reload) % rc.reload
+	if pending > length {
+		rc.overflow = true
+		rc.tail = (written - length + rc.reload) % rc.reload
+		pending = length
+	}
+	count := pending
+	if room := uint32(len(p)) / rc.elemSize; count > room {
+		count = room
+	}
+	start := rc.tail % length * rc.elemSize
+	n := copy(p[:count*rc.elemSize], rc.buf[start:])
+	copy(p[n:count*rc.elemSize], rc.buf)
+	dmaFence()
+	// Data overwritten while copying is corrupt.
+	if (rc.written()-rc.tail+rc.reload)%rc.reload > length {
+		rc.overflow = true
+	}
+	rc.tail = (rc.tail + count) % rc.reload
+	return int(count * rc.elemSize)
+}
+
+// Overflowed reports whether captured data was lost since the last call, because
+// ReadNew was not called often enough.
+func (rc *RingCapture) Overflowed() bool {
+	overflow := rc.overflow
+	rc.overflow = false
+	return overflow
+}
+
+// Stop stops the capture and releases its DMA channels. The state machine is
+// left running.
+func (rc *RingCapture) Stop() {
+	// Aborting a channel aborts the one chained to it too.
+	rc.dma.abort()
+	for _, ch := range [...]dmaChannel{rc.dma, rc.dmaCtrl} {
+		ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+		ch.Unclaim()
+	}
+}
+
+// Placement returns the DMA channels used by the capture.
+func (rc *RingCapture) Placement() Placement {
+	var p Placement
+	p.addDMA(rc.dma, rc.dmaCtrl)
+	return p
+}
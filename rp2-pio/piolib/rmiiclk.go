@@ -0,0 +1,86 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"machine"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// RMII reference clock frequency.
+const rmiiRefClockHz = 50_000_000
+
+// GPIO function of the clock outputs.
+const funcselGPCK = 8
+
+var (
+	errRMIIRefClockPin  = errors.New("piolib:RMII reference clock needs a clock output pin: GPIO21, 23, 24 or 25")
+	errRMIIRefClockFreq = errors.New("piolib:RMII reference clock needs a system clock multiple of 50MHz")
+)
+
+// StartRMIIRefClock outputs the 50MHz RMII reference clock on pin, for PHYs such
+// as the LAN8720 strapped to take REF_CLK in from the MAC, removing the need for a
+// PHY module with its own oscillator. The same clock must feed the REF_CLK input
+// of the PHY and the state machines sampling RMII, so the PIO clock dividers of
+// an RMII MAC are set relative to the system clock.
+//
+// pin must be one of the clock outputs GPIO21, GPIO23, GPIO24 or GPIO25 and the
+// system clock an integer multiple of 50MHz, i.e. 100, 150, 200 or 250MHz: PHYs
+// don't tolerate the jitter of a fractional divider, so the default 125MHz can't
+// be used. RMII requires 50MHz ±50ppm, which the output has when the system clock
+// comes from the crystal through the PLL as it does by default, with the accuracy
+// of the crystal: the usual ±30ppm 12MHz crystals are within the limit. Don't run
+// the system clock from the ring oscillator while the PHY is in use.
+func StartRMIIRefClock(pin machine.Pin) error {
+	ctrl, div := gpoutRegs(pin)
+	if ctrl == nil {
+		return errRMIIRefClockPin
+	}
+	cpuFreq := machine.CPUFrequency()
+	if cpuFreq%rmiiRefClockHz != 0 {
+		return errRMIIRefClockFreq
+	}
+	ctrl.ClearBits(rp.CLOCKS_CLK_GPOUT0_CTRL_ENABLE)
+	div.Set(cpuFreq / rmiiRefClockHz << rp.CLOCKS_CLK_GPOUT0_DIV_INT_Pos)
+	// DC50 keeps a 50% duty cycle with odd dividers, as for 150MHz.
+	ctrl.Set(rp.CLOCKS_CLK_GPOUT0_CTRL_AUXSRC_CLK_SYS<<rp.CLOCKS_CLK_GPOUT0_CTRL_AUXSRC_Pos |
+		rp.CLOCKS_CLK_GPOUT0_CTRL_DC50 | rp.CLOCKS_CLK_GPOUT0_CTRL_ENABLE)
+
+	pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	pinCtrl(pin).Set(funcselGPCK)
+	return nil
+}
+
+// StopRMIIRefClock stops the clock output started by StartRMIIRefClock and
+// turns pin to a low output.
+func StopRMIIRefClock(pin machine.Pin) {
+	if ctrl, _ := gpoutRegs(pin); ctrl != nil {
+		ctrl.ClearBits(rp.CLOCKS_CLK_GPOUT0_CTRL_ENABLE)
+	}
+	pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	pin.Low()
+}
+
+// gpoutRegs returns the control and divider registers of the clock output on pin,
+// nil if pin has none.
+func gpoutRegs(pin machine.Pin) (ctrl, div *volatile.Register32) {
+	switch pin {
+	case machine.GPIO21:
+		return &rp.CLOCKS.CLK_GPOUT0_CTRL, &rp.CLOCKS.CLK_GPOUT0_DIV
+	case machine.GPIO23:
+		return &rp.CLOCKS.CLK_GPOUT1_CTRL, &rp.CLOCKS.CLK_GPOUT1_DIV
+	case machine.GPIO24:
+		return &rp.CLOCKS.CLK_GPOUT2_CTRL, &rp.CLOCKS.CLK_GPOUT2_DIV
+	case machine.GPIO25:
+		return &rp.CLOCKS.CLK_GPOUT3_CTRL, &rp.CLOCKS.CLK_GPOUT3_DIV
+	}
+	return nil, nil
+}
+
+// pinCtrl returns the IO_BANK0 control register of pin, holding its function.
+func pinCtrl(pin machine.Pin) *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rp.IO_BANK0.GPIO0_CTRL)) + uintptr(8*pin)))
+}
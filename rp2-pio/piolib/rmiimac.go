@@ -0,0 +1,62 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+)
+
+var errRMIIMACMulticast = errors.New("piolib:RMII MAC address is a multicast address")
+
+// MACStore persists the MAC address of a board, i.e. in a flash sector or an
+// EEPROM such as the 24AA02E48 which also comes with a MAC address programmed.
+type MACStore interface {
+	// LoadMAC returns the stored MAC address, ok is false if none is stored.
+	LoadMAC() (mac [6]byte, ok bool)
+	// StoreMAC stores mac.
+	StoreMAC(mac [6]byte) error
+}
+
+// RMIIMACAddress returns the MAC address an RMII interface should use: mac if it
+// isn't all zeros, else the address from store, else one derived from the unique
+// ID of the flash chip, which is then saved to store. Derived addresses are
+// locally administered unicast addresses, stable across resets, so DHCP servers
+// hand out the same lease to the board. store may be nil.
+func RMIIMACAddress(mac [6]byte, store MACStore) ([6]byte, error) {
+	if mac != ([6]byte{}) {
+		if mac[0]&0x01 != 0 {
+			return mac, errRMIIMACMulticast
+		}
+		return mac, nil
+	}
+	if store != nil {
+		if stored, ok := store.LoadMAC(); ok && stored != ([6]byte{}) && stored[0]&0x01 == 0 {
+			return stored, nil
+		}
+	}
+	mac = rmiiDeriveMAC(machine.DeviceID())
+	if store != nil {
+		if err := store.StoreMAC(mac); err != nil {
+			return mac, err
+		}
+	}
+	return mac, nil
+}
+
+// rmiiDeriveMAC hashes id with 64 bit FNV-1a into the lower 5 bytes of a MAC
+// address whose first byte has the locally administered bit set and the multicast
+// bit cleared.
+func rmiiDeriveMAC(id []byte) (mac [6]byte) {
+	h := uint64(14695981039346656037)
+	for _, b := range id {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	mac[0] = 0x02
+	for i := 5; i > 0; i-- {
+		mac[i] = byte(h)
+		h >>= 8
+	}
+	return mac
+}
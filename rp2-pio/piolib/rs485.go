@@ -0,0 +1,375 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errRS485NoPin     = errors.New("piolib:RS-485 TX and DE pins required")
+	errRS485Multidrop = errors.New("piolib:RS-485 address frames need multidrop mode")
+)
+
+var (
+	_ pio.TxStream[byte] = (*RS485)(nil)
+	_ pio.RxStream[byte] = (*RS485)(nil)
+)
+
+const (
+	// Cycles of the rs485_tx program around the lead and tail countdowns.
+	rs485LeadOverhead = 2
+	rs485TailOverhead = 1
+)
+
+// RS485 is a half-duplex RS-485 port implemented with one state machine for
+// transmission and one for reception, driving a transceiver such as the MAX485.
+// The transmitter asserts the driver enable (DE) pin by side-set, in the same
+// cycle as it starts the frame, and releases it right after the stop bit of the
+// last queued frame, so the bus is freed on time at any baud rate without CPU
+// involvement. DE should be tied to the active low /RE pin of the transceiver,
+// otherwise the frames sent are received back and must be discarded with
+// DiscardInput after Flush.
+//
+// In multidrop mode frames have 9 data bits, the 9th marking address frames as in
+// the 9-bit mode of many microcontroller UARTs, and the receiver can filter out
+// the data frames sent to other nodes.
+type RS485 struct {
+	tx, rx   pio.StateMachine
+	txPin    machine.Pin
+	rxPin    machine.Pin
+	dePin    machine.Pin
+	txOffset uint8
+	rxOffset uint8
+	baud     uint32
+	bits     uint8
+	lead     time.Duration
+	tail     time.Duration
+	dl       deadliner
+	// Address filter state, see SetAddressFilter.
+	filter   bool
+	addr     byte
+	selected bool
+}
+
+// NewRS485 creates an 8n1 RS-485 port transmitting on txPin with the txsm state
+// machine, enabling the driver with de, and receiving on rxPin with the rxsm state
+// machine. rxPin can be machine.NoPin for a transmit only port, in which case
+// rxsm is not used.
+func NewRS485(txsm, rxsm pio.StateMachine, txPin, rxPin, de machine.Pin, baud uint32) (*RS485, error) {
+	return newRS485(txsm, rxsm, txPin, rxPin, de, baud, 8)
+}
+
+// NewRS485Multidrop creates an RS-485 port like NewRS485 with 9 data bits per
+// frame for multidrop addressing, see WriteAddress and SetAddressFilter.
+func NewRS485Multidrop(txsm, rxsm pio.StateMachine, txPin, rxPin, de machine.Pin, baud uint32) (*RS485, error) {
+	return newRS485(txsm, rxsm, txPin, rxPin, de, baud, 9)
+}
+
+func newRS485(txsm, rxsm pio.StateMachine, txPin, rxPin, de machine.Pin, baud uint32, bits uint8) (*RS485, error) {
+	if txPin == machine.NoPin || de == machine.NoPin {
+		return nil, errRS485NoPin
+	}
+	// Both programs take 8 cycles per bit.
+	whole, frac, err := pio.ClkDivFromFrequency(baud*8, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	r := &RS485{tx: txsm, rx: rxsm, txPin: txPin, rxPin: rxPin, dePin: de, baud: baud, bits: bits}
+	txsm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := txsm.PIO()
+	r.txOffset, err = Pio.AddProgram(rs485_txInstructions, rs485_txOrigin)
+	if err != nil {
+		return nil, err
+	}
+	// Idle high with the driver disabled before the pins are handed to the PIO.
+	txsm.SetPinsConsecutive(txPin, 1, true)
+	txsm.SetPinsConsecutive(de, 1, false)
+	txsm.SetPindirsConsecutive(txPin, 1, true)
+	txsm.SetPindirsConsecutive(de, 1, true)
+	txPin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	de.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	cfg := rs485_txProgramDefaultConfig(r.txOffset)
+	cfg.SetOutPins(txPin, 1)
+	cfg.SetSetPins(txPin, 1)
+	cfg.SetSidesetPins(de)
+	// The shift threshold is the number of data bits, see the program.
+	cfg.SetOutShift(true, false, uint16(bits))
+	cfg.SetMovStatus(pio.MovStatusTxLessthan, 1)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	txsm.Init(r.txOffset, cfg)
+	trackClock(txsm, baud*8)
+	r.loadTurnaround()
+
+	if rxPin != machine.NoPin {
+		rxsm.TryClaim()
+		Pio := rxsm.PIO()
+		r.rxOffset, err = Pio.AddProgram(rs485_rxInstructions, rs485_rxOrigin)
+		if err != nil {
+			txsm.SetEnabled(false)
+			freeProgram(txsm.PIO(), r.txOffset, rs485_txInstructions)
+			return nil, err
+		}
+		// The receiver output of the transceiver floats while /RE is deasserted.
+		rxPin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+		rxsm.SetPindirsConsecutive(rxPin, 1, false)
+		cfg := rs485_rxProgramDefaultConfig(r.rxOffset)
+		cfg.SetInPins(rxPin)
+		cfg.SetJmpPin(rxPin)
+		cfg.SetInShift(true, false, 32)
+		// We only use Rx FIFO, so we set the join to Rx.
+		cfg.SetFIFOJoin(pio.FifoJoinRx)
+		cfg.SetClkDivIntFrac(whole, frac)
+		rxsm.Init(r.rxOffset, cfg)
+		trackClock(rxsm, baud*8)
+		// Y holds the data bit count minus one.
+		rxsm.Exec(pio.EncodeSet(pio.SrcDestY, bits-1))
+		rxsm.SetEnabled(true)
+	}
+	return r, nil
+}
+
+// SetTimeout sets the timeout for reads and writes. Use 0 as argument to disable timeouts.
+func (r *RS485) SetTimeout(timeout time.Duration) {
+	r.dl.setTimeout(timeout)
+}
+
+// SetTurnaround sets how long DE is asserted before the start bit of the first
+// frame and held after the stop bit of the last one, for transceivers slow to
+// enable their driver and remote nodes that must see the line driven high
+// after the last frame. Both default to 0, for which DE is asserted a quarter
+// bit before the start bit and released an eighth of a bit after the stop bit.
+// Queued frames are transmitted first.
+func (r *RS485) SetTurnaround(lead, tail time.Duration) error {
+	if err := r.Flush(); err != nil {
+		return err
+	}
+	r.lead, r.tail = lead, tail
+	r.loadTurnaround()
+	return nil
+}
+
+// loadTurnaround loads the turnaround countdowns in the idle transmitter and starts it.
+func (r *RS485) loadTurnaround() {
+	sm := r.tx
+	sm.SetEnabled(false)
+	// Y holds the lead and ISR the tail countdown, loaded through the OSR as autopull is off.
+	sm.TxPut(r.turnaroundCycles(r.lead, rs485LeadOverhead))
+	sm.Exec(pio.EncodePull(false, true))
+	sm.Exec(pio.EncodeMov(pio.SrcDestY, pio.SrcDestOSR))
+	sm.TxPut(r.turnaroundCycles(r.tail, rs485TailOverhead))
+	sm.Exec(pio.EncodePull(false, true))
+	sm.Exec(pio.EncodeMov(pio.SrcDestISR, pio.SrcDestOSR))
+	sm.Jmp(r.txOffset, pio.JmpAlways)
+	sm.SetEnabled(true)
+}
+
+func (r *RS485) turnaroundCycles(d time.Duration, overhead uint64) uint32 {
+	cycles := uint64(d) * uint64(r.baud) * 8 / uint64(time.Second)
+	if cycles < overhead {
+		return 0
+	}
+	return uint32(cycles - overhead)
+}
+
+// Write transmits p as data frames, blocking until it has been queued for transmission.
+func (r *RS485) Write(p []byte) (n int, err error) {
+	dl := r.dl.newDeadline()
+	for n < len(p) {
+		if err := r.put(dl, uint32(p[n])); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// WriteByte transmits a single data frame.
+func (r *RS485) WriteByte(b byte) error {
+	return r.put(r.dl.newDeadline(), uint32(b))
+}
+
+// Put transmits a single data frame, like WriteByte. It implements pio.TxStream.
+func (r *RS485) Put(b byte) error {
+	return r.WriteByte(b)
+}
+
+// TryPut queues b as a data frame if the TX FIFO is not full.
+func (r *RS485) TryPut(b byte) bool {
+	return r.tx.TxPutFromISR(uint32(b))
+}
+
+// WriteAddress transmits an address frame, with the 9th bit set, selecting the
+// nodes with address addr for the data frames that follow. It requires multidrop mode.
+func (r *RS485) WriteAddress(addr byte) error {
+	if r.bits != 9 {
+		return errRS485Multidrop
+	}
+	return r.put(r.dl.newDeadline(), 1<<8|uint32(addr))
+}
+
+func (r *RS485) put(dl deadline, frame uint32) error {
+	for r.tx.IsTxFIFOFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	r.tx.TxPut(frame)
+	return nil
+}
+
+// Flush blocks until all queued frames have been transmitted and DE is released,
+// after which the bus is free for the reply of a remote node.
+func (r *RS485) Flush() error {
+	dl := r.dl.newDeadline()
+	for !r.tx.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// The transmitter only stalls waiting for data once DE is released.
+	r.tx.ClearTxStalled()
+	for !r.tx.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// SetAddressFilter sets whether ReadByte and Read only return the data frames
+// following an address frame for addr, as a multidrop slave node. Address frames
+// are consumed by the filter. It requires multidrop mode to be enabled.
+func (r *RS485) SetAddressFilter(addr byte, enabled bool) error {
+	if enabled && r.bits != 9 {
+		return errRS485Multidrop
+	}
+	r.filter, r.addr, r.selected = enabled, addr, false
+	return nil
+}
+
+// Buffered returns the number of received frames waiting to be read, including
+// those the address filter discards.
+func (r *RS485) Buffered() int {
+	if r.rxPin == machine.NoPin {
+		return 0
+	}
+	return int(r.rx.RxFIFOLevel())
+}
+
+// ReadFrame blocks until a frame is received and returns its data and whether it
+// is an address frame, bypassing the address filter. A framing error or break is
+// returned as an error after the frame is consumed.
+func (r *RS485) ReadFrame() (b byte, address bool, err error) {
+	return r.readFrame(r.dl.newDeadline())
+}
+
+func (r *RS485) readFrame(dl deadline) (b byte, address bool, err error) {
+	if r.rxPin == machine.NoPin {
+		return 0, false, errUARTNoPin
+	}
+	for r.rx.IsRxFIFOEmpty() {
+		if dl.expired() {
+			return 0, false, errTimeout
+		}
+		gosched()
+	}
+	word := r.rx.RxGet()
+	// The data bits end at bit 30, followed by the stop bit.
+	data := word >> (31 - r.bits) & (1<<r.bits - 1)
+	if word&(1<<31) == 0 {
+		if data == 0 {
+			return 0, false, errUARTBreak
+		}
+		return byte(data), false, errUARTFraming
+	}
+	return byte(data), data&(1<<8) != 0, nil
+}
+
+// ReadByte blocks until a data frame passing the address filter is received.
+// Without filter, address frames are returned as well, use ReadFrame to tell them
+// apart. A framing error or break is returned as an error after the frame is consumed.
+func (r *RS485) ReadByte() (byte, error) {
+	if r.rxPin == machine.NoPin {
+		return 0, errUARTNoPin
+	}
+	dl := r.dl.newDeadline()
+	for {
+		b, address, err := r.readFrame(dl)
+		switch {
+		case !r.filter || err == errTimeout:
+			return b, err
+		case address:
+			r.selected = b == r.addr
+		case r.selected:
+			return b, err
+		}
+	}
+}
+
+// Get blocks until a data frame passing the address filter is received, like
+// ReadByte. It implements pio.RxStream.
+func (r *RS485) Get() (byte, error) {
+	return r.ReadByte()
+}
+
+// TryGet returns a received data frame passing the address filter if one is
+// waiting. Frames received with a framing error or break are dropped.
+func (r *RS485) TryGet() (byte, bool) {
+	for r.Buffered() > 0 {
+		b, address, err := r.readFrame(deadline{})
+		switch {
+		case err != nil:
+		case !r.filter:
+			return b, true
+		case address:
+			r.selected = b == r.addr
+		case r.selected:
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+// Read blocks until at least one byte is received and reads up to len(p) bytes
+// without blocking further, like ReadByte.
+func (r *RS485) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && r.Buffered() == 0 {
+			break
+		}
+		p[n], err = r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DiscardInput drops all received frames waiting to be read.
+func (r *RS485) DiscardInput() {
+	for r.Buffered() > 0 {
+		r.rx.RxGet()
+	}
+}
+
+// Placement returns the state machines and programs used by the port, omitting
+// the receiver without pin.
+func (r *RS485) Placement() Placement {
+	var p Placement
+	p.addSM(r.tx, r.txOffset, rs485_txInstructions)
+	if r.rxPin != machine.NoPin {
+		p.addSM(r.rx, r.rxOffset, rs485_rxInstructions)
+	}
+	return p
+}
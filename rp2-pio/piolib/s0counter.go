@@ -0,0 +1,149 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// State machine frequency of the S0 counter, a debounce loop iteration takes 2 cycles.
+	s0CounterFreq = 1_000_000
+	// S0 pulses last at least 30ms, contacts bounce for much less.
+	s0CounterDebounce = 10 * time.Millisecond
+)
+
+// S0Counter counts the pulses of the S0 output of an energy meter, or any meter
+// with an open collector pulse output, and accumulates them per interval. The
+// state machine debounces and counts the pulses by itself so none are lost while
+// the CPU is busy, i.e. during flash writes or WiFi activity. The count is read
+// by Update, which must be called at least once per interval.
+type S0Counter struct {
+	sm       pio.StateMachine
+	offset   uint8
+	interval time.Duration
+	// raw is the last count read from the state machine, which wraps around at 2³².
+	raw        uint32
+	total      uint64
+	start      time.Time // Start of the current interval.
+	current    uint32    // Pulses in the current interval.
+	last       uint32    // Pulses in the last complete interval.
+	lastClosed bool
+}
+
+// NewS0Counter starts counting pulses on pin, which is pulled up as S0 outputs only
+// pull low. Pulses are accumulated per interval, i.e. 15 minutes for the demand
+// periods of utility meters.
+func NewS0Counter(sm pio.StateMachine, pin machine.Pin, interval time.Duration) (*S0Counter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if interval <= 0 {
+		return nil, errors.New("piolib:S0 counter needs an interval")
+	}
+	offset, err := s0counterInit(sm, pin, s0CounterDebounce)
+	if err != nil {
+		return nil, err
+	}
+	return &S0Counter{
+		sm:       sm,
+		offset:   offset,
+		interval: interval,
+		start:    time.Now(),
+	}, nil
+}
+
+// s0counterInit loads the s0counter program and starts sm counting the low pulses
+// of pin lasting at least debounce, separated by as long.
+func s0counterInit(sm pio.StateMachine, pin machine.Pin, debounce time.Duration) (offset uint8, err error) {
+	whole, frac, err := pio.ClkDivFromFrequency(s0CounterFreq, machine.CPUFrequency())
+	if err != nil {
+		return 0, err
+	}
+	offset, err = sm.PIO().AddProgram(s0counterInstructions, s0counterOrigin)
+	if err != nil {
+		return 0, err
+	}
+	s0counterStart(sm, offset, pin, whole, frac, debounce)
+	return offset, nil
+}
+
+// s0counterStart starts sm counting pulses of pin with the s0counter program loaded
+// at offset, at the state machine frequency set by the clock divider.
+func s0counterStart(sm pio.StateMachine, offset uint8, pin machine.Pin, whole uint16, frac uint8, debounce time.Duration) {
+	// The PIO reads the pin whatever its function, keep it as SIO input for the pull-up.
+	pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+
+	cfg := s0counterProgramDefaultConfig(offset)
+	cfg.SetJmpPin(pin)
+	cfg.SetInPins(pin)
+	// The FIFOs are not joined: X and the debounce count are loaded through the TX
+	// FIFO, and the counts pushed are totals so a full RX FIFO loses no pulse.
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, s0CounterFreq)
+	sm.SetX(0xffffffff)
+	// Pulled by the first instruction.
+	sm.TxPut(uint32(debounce / (2 * time.Second / s0CounterFreq)))
+	sm.SetEnabled(true)
+}
+
+// Update reads the pulses counted by the state machine and closes the current
+// interval if it has ended. Pulses are attributed to the interval in which
+// Update reads them, so it should be called often for intervals to be accurate.
+// If Update was not called for a whole interval, the skipped intervals count as
+// empty and their pulses are attributed to the interval of the next call.
+func (c *S0Counter) Update() {
+	for !c.sm.IsRxFIFOEmpty() {
+		raw := c.sm.RxGet()
+		// Counts only increase: the difference is right across wrap around.
+		delta := raw - c.raw
+		c.raw = raw
+		c.total += uint64(delta)
+		c.current += delta
+	}
+	now := time.Now()
+	if elapsed := now.Sub(c.start); elapsed >= c.interval {
+		c.last = c.current
+		if elapsed >= 2*c.interval {
+			c.last = 0
+		}
+		c.current = 0
+		c.lastClosed = true
+		c.start = c.start.Add(elapsed - elapsed%c.interval)
+	}
+}
+
+// Total returns the number of pulses counted since the counter was created, as of the last Update.
+func (c *S0Counter) Total() uint64 {
+	return c.total
+}
+
+// Current returns the number of pulses counted in the current interval, as of the last Update.
+func (c *S0Counter) Current() uint32 {
+	return c.current
+}
+
+// LastInterval returns the number of pulses of the last complete interval. ok is
+// false until the first interval ends.
+func (c *S0Counter) LastInterval() (pulses uint32, ok bool) {
+	return c.last, c.lastClosed
+}
+
+// EnergyWh converts the total pulse count to energy in Wh for a meter with the
+// given pulse constant, printed on the meter as imp/kWh.
+func (c *S0Counter) EnergyWh(pulsesPerKWh uint32) float64 {
+	if pulsesPerKWh == 0 {
+		return 0
+	}
+	return float64(c.total) * 1000 / float64(pulsesPerKWh)
+}
+
+// Placement returns the state machine and program used by the counter.
+func (c *S0Counter) Placement() Placement {
+	var p Placement
+	p.addSM(c.sm, c.offset, s0counterInstructions)
+	return p
+}
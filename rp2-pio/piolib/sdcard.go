@@ -0,0 +1,587 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errSDNoCard      = errors.New("piolib:SD card not responding")
+	errSDUnsupported = errors.New("piolib:SD card version or voltage unsupported")
+	errSDCommand     = errors.New("piolib:SD card command failed")
+	errSDCRC         = errors.New("piolib:SD card CRC mismatch")
+	errSDWrite       = errors.New("piolib:SD card write rejected")
+	errSDOutOfRange  = errors.New("piolib:SD card access out of range")
+)
+
+const (
+	sdBlockSize = 512
+	// SCK frequency during initialization, at most 400kHz.
+	sdInitFreq = 400_000
+	// The SPI programs take 4 state machine cycles per bit.
+	sdCyclesPerBit = 4
+	// Timeout of commands and data transfers, covering the worst case write time.
+	sdTimeout = 500 * time.Millisecond
+	// Time for the card to leave the idle state after ACMD41 and to erase.
+	sdInitTimeout  = time.Second
+	sdEraseTimeout = 30 * time.Second
+
+	// Commands used in SPI mode.
+	sdCmdGoIdle          = 0
+	sdCmdSendIfCond      = 8
+	sdCmdSendCSD         = 9
+	sdCmdStop            = 12
+	sdCmdSetBlockLen     = 16
+	sdCmdReadBlock       = 17
+	sdCmdReadMultiple    = 18
+	sdCmdWriteBlock      = 24
+	sdCmdWriteMultiple   = 25
+	sdCmdEraseStart      = 32
+	sdCmdEraseEnd        = 33
+	sdCmdErase           = 38
+	sdCmdAppCmd          = 55
+	sdCmdReadOCR         = 58
+	sdCmdCRCOnOff        = 59
+	sdAppCmdSendOpCond   = 41
+	sdR1Idle             = 0x01
+	sdR1IllegalCommand   = 0x04
+	sdTokenStartBlock    = 0xfe
+	sdTokenStartMultiple = 0xfc
+	sdTokenStopMultiple  = 0xfd
+	sdDataAccepted       = 0x05
+	sdOCRHighCapacity    = 1 << 30
+)
+
+// SDCard is a block device on an SD card in SPI mode, on any pins through the PIO
+// SPI programs. Cards are initialized with CMD0, CMD8 and ACMD41, so SDSC, SDHC
+// and SDXC cards are supported, and CRC checking is enabled on both ends: commands
+// carry a CRC7 and data blocks a CRC16 verified on reads and by the card on writes.
+//
+// SDCard implements io.ReaderAt and io.WriterAt, with reads and writes not
+// aligned on blocks done by read-modify-write of a block. It is not safe for
+// concurrent use.
+type SDCard struct {
+	spi    *SPI
+	cs     machine.Pin
+	dl     deadliner
+	blocks int64
+	// highCapacity is set for SDHC and SDXC cards, addressed by block instead of byte.
+	highCapacity bool
+	cmd          [6]byte
+	block        [sdBlockSize]byte
+}
+
+// NewSDCard initializes the SD card with clock on sck, data from the card on miso,
+// data to the card on mosi and chip select on cs. Once initialized the clock is
+// raised to freq, which must not exceed 25MHz.
+func NewSDCard(sm pio.StateMachine, sck, mosi, miso, cs machine.Pin, freq uint32) (*SDCard, error) {
+	spi, err := NewSPI(sm, machine.SPIConfig{
+		Frequency: sdInitFreq * sdCyclesPerBit,
+		SCK:       sck,
+		SDO:       mosi,
+		SDI:       miso,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	cs.High()
+	sd := &SDCard{spi: spi, cs: cs}
+	sd.dl.setTimeout(sdTimeout)
+	if err := sd.init(); err != nil {
+		return nil, err
+	}
+	if err := sd.setFrequency(freq); err != nil {
+		return nil, err
+	}
+	return sd, nil
+}
+
+// SetTimeout sets the timeout of commands and data transfers, 500ms by default.
+// Use 0 as argument to disable timeouts.
+func (sd *SDCard) SetTimeout(timeout time.Duration) {
+	sd.dl.setTimeout(timeout)
+}
+
+// Size returns the capacity of the card in bytes.
+func (sd *SDCard) Size() int64 {
+	return sd.blocks * sdBlockSize
+}
+
+// WriteBlockSize returns the size of a block, the unit of writes of the card.
+func (sd *SDCard) WriteBlockSize() int64 {
+	return sdBlockSize
+}
+
+// EraseBlockSize returns the size of the unit of EraseBlocks, a block.
+func (sd *SDCard) EraseBlockSize() int64 {
+	return sdBlockSize
+}
+
+// ReadAt reads len(p) bytes from the card starting at byte offset off.
+func (sd *SDCard) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off+int64(len(p)) > sd.Size() {
+		return 0, errSDOutOfRange
+	}
+	for n < len(p) {
+		lba, skip := off/sdBlockSize, int(off%sdBlockSize)
+		if skip == 0 && len(p)-n >= sdBlockSize {
+			// Read whole blocks directly into p.
+			count := (len(p) - n) / sdBlockSize
+			if err := sd.ReadBlocks(p[n:n+count*sdBlockSize], lba); err != nil {
+				return n, err
+			}
+			n += count * sdBlockSize
+			off += int64(count) * sdBlockSize
+			continue
+		}
+		if err := sd.ReadBlocks(sd.block[:], lba); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], sd.block[skip:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes to the card starting at byte offset off.
+func (sd *SDCard) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off+int64(len(p)) > sd.Size() {
+		return 0, errSDOutOfRange
+	}
+	for n < len(p) {
+		lba, skip := off/sdBlockSize, int(off%sdBlockSize)
+		if skip == 0 && len(p)-n >= sdBlockSize {
+			count := (len(p) - n) / sdBlockSize
+			if err := sd.WriteBlocks(p[n:n+count*sdBlockSize], lba); err != nil {
+				return n, err
+			}
+			n += count * sdBlockSize
+			off += int64(count) * sdBlockSize
+			continue
+		}
+		// Partial block: read-modify-write.
+		if err := sd.ReadBlocks(sd.block[:], lba); err != nil {
+			return n, err
+		}
+		c := copy(sd.block[skip:], p[n:])
+		if err := sd.WriteBlocks(sd.block[:], lba); err != nil {
+			return n, err
+		}
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// ReadBlocks reads len(buf)/512 blocks starting at block lba. len(buf) must be
+// a multiple of 512. Several blocks are read with a single multiple block read.
+func (sd *SDCard) ReadBlocks(buf []byte, lba int64) error {
+	count := len(buf) / sdBlockSize
+	if count == 0 || len(buf)%sdBlockSize != 0 || lba < 0 || lba+int64(count) > sd.blocks {
+		return errSDOutOfRange
+	}
+	sd.cs.Low()
+	defer sd.release()
+	if count == 1 {
+		if r1, err := sd.command(sdCmdReadBlock, sd.address(lba)); err != nil || r1 != 0 {
+			return sdCommandErr(err)
+		}
+		return sd.readData(buf)
+	}
+	if r1, err := sd.command(sdCmdReadMultiple, sd.address(lba)); err != nil || r1 != 0 {
+		return sdCommandErr(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := sd.readData(buf[i*sdBlockSize : (i+1)*sdBlockSize]); err != nil {
+			sd.command(sdCmdStop, 0)
+			return err
+		}
+	}
+	if r1, err := sd.command(sdCmdStop, 0); err != nil || r1 != 0 {
+		return sdCommandErr(err)
+	}
+	return sd.waitReady()
+}
+
+// WriteBlocks writes len(buf)/512 blocks starting at block lba. len(buf) must be
+// a multiple of 512. Several blocks are written with a single multiple block write.
+func (sd *SDCard) WriteBlocks(buf []byte, lba int64) error {
+	count := len(buf) / sdBlockSize
+	if count == 0 || len(buf)%sdBlockSize != 0 || lba < 0 || lba+int64(count) > sd.blocks {
+		return errSDOutOfRange
+	}
+	sd.cs.Low()
+	defer sd.release()
+	if count == 1 {
+		if r1, err := sd.command(sdCmdWriteBlock, sd.address(lba)); err != nil || r1 != 0 {
+			return sdCommandErr(err)
+		}
+		return sd.writeData(sdTokenStartBlock, buf)
+	}
+	if r1, err := sd.command(sdCmdWriteMultiple, sd.address(lba)); err != nil || r1 != 0 {
+		return sdCommandErr(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := sd.writeData(sdTokenStartMultiple, buf[i*sdBlockSize:(i+1)*sdBlockSize]); err != nil {
+			sd.transfer([]byte{sdTokenStopMultiple}, nil)
+			sd.waitReady()
+			return err
+		}
+	}
+	if err := sd.transfer([]byte{sdTokenStopMultiple, 0xff}, nil); err != nil {
+		return err
+	}
+	return sd.waitReady()
+}
+
+// EraseBlocks erases count blocks starting at block start. Erased blocks read as
+// all zeros or all ones depending on the card.
+func (sd *SDCard) EraseBlocks(start, count int64) error {
+	if count <= 0 || start < 0 || start+count > sd.blocks {
+		return errSDOutOfRange
+	}
+	sd.cs.Low()
+	defer sd.release()
+	for _, c := range [...]struct {
+		cmd uint8
+		arg uint32
+	}{
+		{sdCmdEraseStart, sd.address(start)},
+		{sdCmdEraseEnd, sd.address(start + count - 1)},
+		{sdCmdErase, 0},
+	} {
+		if r1, err := sd.command(c.cmd, c.arg); err != nil || r1 != 0 {
+			return sdCommandErr(err)
+		}
+	}
+	var dl deadliner
+	dl.setTimeout(sdEraseTimeout)
+	return sd.waitReadyUntil(dl.newDeadline())
+}
+
+// init brings the card from power up to the transfer state and reads its capacity.
+func (sd *SDCard) init() error {
+	// At least 74 clocks with chip select and data high put the card in native mode.
+	var ff [10]byte
+	if err := sd.transfer(ff[:], nil); err != nil {
+		return err
+	}
+	sd.cs.Low()
+	defer sd.release()
+	// CMD0 with chip select low switches the card to SPI mode.
+	var r1 uint8
+	var err error
+	for retries := 0; retries < 10; retries++ {
+		if r1, err = sd.command(sdCmdGoIdle, 0); err == nil && r1 == sdR1Idle {
+			break
+		}
+	}
+	if err != nil || r1 != sdR1Idle {
+		return errSDNoCard
+	}
+	// CMD8 is illegal on version 1 cards, others echo the voltage range and pattern.
+	r1, err = sd.command(sdCmdSendIfCond, 0x1aa)
+	if err != nil {
+		return err
+	}
+	version2 := r1&sdR1IllegalCommand == 0
+	if version2 {
+		var r7 [4]byte
+		if err := sd.transfer(nil, r7[:]); err != nil {
+			return err
+		}
+		if r7[2]&0xf != 0x1 || r7[3] != 0xaa {
+			return errSDUnsupported
+		}
+	}
+	if r1, err = sd.command(sdCmdCRCOnOff, 1); err != nil || r1&^sdR1Idle != 0 {
+		return sdCommandErr(err)
+	}
+	var hcs uint32
+	if version2 {
+		hcs = sdOCRHighCapacity
+	}
+	start := time.Now()
+	for {
+		if r1, err = sd.appCommand(sdAppCmdSendOpCond, hcs); err != nil {
+			return err
+		}
+		if r1 == 0 {
+			break
+		}
+		if r1 != sdR1Idle || time.Since(start) > sdInitTimeout {
+			return errSDUnsupported
+		}
+	}
+	if version2 {
+		if r1, err = sd.command(sdCmdReadOCR, 0); err != nil || r1 != 0 {
+			return sdCommandErr(err)
+		}
+		var ocr [4]byte
+		if err := sd.transfer(nil, ocr[:]); err != nil {
+			return err
+		}
+		sd.highCapacity = ocr[0]&(sdOCRHighCapacity>>24) != 0
+	}
+	if !sd.highCapacity {
+		if r1, err = sd.command(sdCmdSetBlockLen, sdBlockSize); err != nil || r1 != 0 {
+			return sdCommandErr(err)
+		}
+	}
+	if r1, err = sd.command(sdCmdSendCSD, 0); err != nil || r1 != 0 {
+		return sdCommandErr(err)
+	}
+	var csd [16]byte
+	if err := sd.readData(csd[:]); err != nil {
+		return err
+	}
+	sd.blocks = sdCSDBlocks(&csd)
+	return nil
+}
+
+// sdCSDBlocks returns the number of 512 byte blocks from the card specific data register.
+func sdCSDBlocks(csd *[16]byte) int64 {
+	if csd[0]>>6 == 1 {
+		// CSD version 2: capacity is (C_SIZE+1) * 512KiB.
+		cSize := int64(csd[7]&0x3f)<<16 | int64(csd[8])<<8 | int64(csd[9])
+		return (cSize + 1) * 1024
+	}
+	// CSD version 1: capacity is (C_SIZE+1) * 2^(C_SIZE_MULT+2) * 2^READ_BL_LEN.
+	readBlLen := uint(csd[5] & 0xf)
+	cSize := int64(csd[6]&0x3)<<10 | int64(csd[7])<<2 | int64(csd[8]>>6)
+	cSizeMult := uint(csd[9]&0x3)<<1 | uint(csd[10]>>7)
+	return (cSize + 1) << (cSizeMult + 2 + readBlLen) / sdBlockSize
+}
+
+// address returns the command argument addressing block lba.
+func (sd *SDCard) address(lba int64) uint32 {
+	if sd.highCapacity {
+		return uint32(lba)
+	}
+	return uint32(lba * sdBlockSize)
+}
+
+// command sends a command with its CRC7 and returns the R1 response. Chip select
+// must be low. Bytes following R1 in longer responses are left to the caller.
+// CMD12 is sent while the card streams data, without waiting for it to be ready.
+func (sd *SDCard) command(cmd uint8, arg uint32) (r1 uint8, err error) {
+	if cmd != sdCmdGoIdle && cmd != sdCmdStop {
+		if err := sd.waitReady(); err != nil {
+			return 0, err
+		}
+	}
+	sd.cmd = [6]byte{0x40 | cmd, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+	sd.cmd[5] = sdCRC7(sd.cmd[:5])<<1 | 1
+	if err := sd.transfer(sd.cmd[:], nil); err != nil {
+		return 0, err
+	}
+	var b [1]byte
+	if cmd == sdCmdStop {
+		// Skip the stuff byte following CMD12.
+		if err := sd.transfer(nil, b[:]); err != nil {
+			return 0, err
+		}
+	}
+	// R1 comes within 8 bytes and has its MSB clear.
+	for i := 0; i < 9; i++ {
+		if err := sd.transfer(nil, b[:]); err != nil {
+			return 0, err
+		}
+		if b[0]&0x80 == 0 {
+			return b[0], nil
+		}
+	}
+	return 0, errSDNoCard
+}
+
+// appCommand sends an application specific command, prefixed by CMD55.
+func (sd *SDCard) appCommand(cmd uint8, arg uint32) (r1 uint8, err error) {
+	if r1, err = sd.command(sdCmdAppCmd, 0); err != nil || r1&^sdR1Idle != 0 {
+		return r1, err
+	}
+	return sd.command(cmd, arg)
+}
+
+// readData receives a data block of len(buf) bytes after its start token and checks its CRC16.
+func (sd *SDCard) readData(buf []byte) error {
+	dl := sd.dl.newDeadline()
+	var b [2]byte
+	for {
+		if err := sd.transfer(nil, b[:1]); err != nil {
+			return err
+		}
+		if b[0] == sdTokenStartBlock {
+			break
+		}
+		if b[0] != 0xff {
+			// Data error token.
+			return errSDCommand
+		}
+		if dl.expired() {
+			return errTimeout
+		}
+	}
+	if err := sd.transfer(nil, buf); err != nil {
+		return err
+	}
+	if err := sd.transfer(nil, b[:]); err != nil {
+		return err
+	}
+	if sdCRC16(buf) != uint16(b[0])<<8|uint16(b[1]) {
+		return errSDCRC
+	}
+	return nil
+}
+
+// writeData sends a data block with the start token and its CRC16 and waits for
+// the card to program it.
+func (sd *SDCard) writeData(token byte, buf []byte) error {
+	crc := sdCRC16(buf)
+	if err := sd.transfer([]byte{0xff, token}, nil); err != nil {
+		return err
+	}
+	if err := sd.transfer(buf, nil); err != nil {
+		return err
+	}
+	var resp [1]byte
+	if err := sd.transfer([]byte{byte(crc >> 8), byte(crc)}, nil); err != nil {
+		return err
+	}
+	if err := sd.transfer(nil, resp[:]); err != nil {
+		return err
+	}
+	switch resp[0] & 0x1f {
+	case sdDataAccepted:
+	case 0x0b:
+		return errSDCRC
+	default:
+		return errSDWrite
+	}
+	return sd.waitReady()
+}
+
+// waitReady waits for the card to release the data line, held low while busy.
+func (sd *SDCard) waitReady() error {
+	return sd.waitReadyUntil(sd.dl.newDeadline())
+}
+
+func (sd *SDCard) waitReadyUntil(dl deadline) error {
+	var b [1]byte
+	for {
+		if err := sd.transfer(nil, b[:]); err != nil {
+			return err
+		}
+		if b[0] == 0xff {
+			return nil
+		}
+		if dl.expired() {
+			return errTimeout
+		}
+	}
+}
+
+// release deasserts chip select followed by a byte of clocks for the card to
+// release the data line.
+func (sd *SDCard) release() {
+	sd.cs.High()
+	sd.transfer([]byte{0xff}, nil)
+}
+
+// transfer sends w, or 0xff bytes if w is nil, and receives into r if not nil.
+// If both are set they must have the same length.
+func (sd *SDCard) transfer(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	sm := sd.spi.sm
+	dl := sd.dl.newDeadline()
+	sent, received := 0, 0
+	for received < n {
+		progress := false
+		if sent < n && !sm.IsTxFIFOFull() {
+			b := byte(0xff)
+			if w != nil {
+				b = w[sent]
+			}
+			// Bytes are shifted out from bit 31.
+			sm.TxPut(uint32(b) << 24)
+			sent++
+			progress = true
+		}
+		if !sm.IsRxFIFOEmpty() {
+			b := byte(sm.RxGet())
+			if r != nil {
+				r[received] = b
+			}
+			received++
+			progress = true
+		}
+		if !progress {
+			if dl.expired() {
+				sm.ClearFIFOs()
+				return errTimeout
+			}
+			gosched()
+		}
+	}
+	return nil
+}
+
+// setFrequency sets the SCK frequency.
+func (sd *SDCard) setFrequency(freq uint32) error {
+	whole, frac, err := pio.ClkDivFromFrequency(freq*sdCyclesPerBit, machine.CPUFrequency())
+	if err != nil {
+		return err
+	}
+	sd.spi.sm.SetClkDiv(whole, frac)
+	trackClock(sd.spi.sm, freq*sdCyclesPerBit)
+	return nil
+}
+
+func sdCommandErr(err error) error {
+	if err != nil {
+		return err
+	}
+	return errSDCommand
+}
+
+// sdCRC7 calculates the CRC7 of a command (polynomial x^7+x^3+1).
+func sdCRC7(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (b^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+			b <<= 1
+		}
+	}
+	return crc & 0x7f
+}
+
+// sdCRC16 calculates the CRC16-CCITT of a data block (polynomial x^16+x^12+x^5+1, seed 0).
+func sdCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc>>8 | crc<<8
+		crc ^= uint16(b)
+		crc ^= crc & 0xff >> 4
+		crc ^= crc << 12
+		crc ^= (crc & 0xff) << 5
+	}
+	return crc
+}
+
+// Placement returns the state machine and program used by the card's SPI.
+func (sd *SDCard) Placement() Placement {
+	return sd.spi.Placement()
+}
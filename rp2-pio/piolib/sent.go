@@ -0,0 +1,196 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errSENTCRC = errors.New("piolib:SENT CRC mismatch")
+
+// SENT ticks per pulse as defined by SAE J2716.
+const (
+	sentCalibrationTicks = 56
+	sentNibbleTicks      = 12 // Ticks of a nibble with value 0.
+	sentNibbles          = 8  // Status, 6 data nibbles and CRC.
+	// Fixed per-period cycle overhead of the sent program, see sent.pio.
+	sentLoopOverhead = 5
+)
+
+// SENT is a receiver for the SAE J2716 Single Edge Nibble Transmission protocol
+// used by automotive sensors. The PIO measures the period between falling edges
+// and the decoding of the calibration pulse, nibbles and CRC is done in software.
+type SENT struct {
+	sm     pio.StateMachine
+	offset uint8
+	dl     deadliner
+	// Nominal tick in PIO cycles, used to discard pause pulses as calibration pulses.
+	tickCycles uint32
+
+	// Short serial message (slow channel) decoding state.
+	serialBits  uint16
+	serialCount uint8
+}
+
+// SENTFrame is a decoded SENT fast channel message.
+type SENTFrame struct {
+	// Status and communication nibble. Bits 2 and 3 carry the slow channel.
+	Status uint8
+	// Data nibbles in order of transmission.
+	Data [6]uint8
+	// Tick is the measured tick duration of the frame's calibration pulse.
+	Tick time.Duration
+}
+
+// FastChannels returns the two 12 bit fast channel signals of frame for the common
+// SAE J2716 format where channel 2 is transmitted in reverse nibble order.
+func (f SENTFrame) FastChannels() (ch1, ch2 uint16) {
+	ch1 = uint16(f.Data[0])<<8 | uint16(f.Data[1])<<4 | uint16(f.Data[2])
+	ch2 = uint16(f.Data[5])<<8 | uint16(f.Data[4])<<4 | uint16(f.Data[3])
+	return ch1, ch2
+}
+
+// SENTSerialMessage is a SENT short serial message (slow channel) which is transmitted
+// one bit at a time over 16 consecutive fast channel frames.
+type SENTSerialMessage struct {
+	ID   uint8
+	Data uint8
+	// Valid is true if a message finished with the frame it was returned with.
+	Valid bool
+}
+
+// NewSENT returns a SENT receiver on pin. tick is the nominal clock tick of the
+// sensor, usually 3µs. Measured ticks may deviate from tick by up to 25%.
+func NewSENT(sm pio.StateMachine, pin machine.Pin, tick time.Duration) (*SENT, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	cpufreq := machine.CPUFrequency()
+	tickCycles := uint32(uint64(tick) * uint64(cpufreq) / uint64(time.Second))
+	if tickCycles < 16 {
+		return nil, errors.New("piolib:SENT tick too short")
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(sentInstructions, sentOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPindirsConsecutive(pin, 1, false)
+	cfg := sentProgramDefaultConfig(offset)
+	cfg.SetInPins(pin)
+	cfg.SetJmpPin(pin)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return &SENT{sm: sm, offset: offset, tickCycles: tickCycles}, nil
+}
+
+// SetTimeout sets the timeout for ReadFrame. Use 0 as argument to disable timeouts.
+func (s *SENT) SetTimeout(timeout time.Duration) {
+	s.dl.setTimeout(timeout)
+}
+
+// ReadFrame blocks until a complete SENT frame is received and returns the fast
+// channel data. If the frame completed a short serial message it is returned in
+// slow with slow.Valid set to true.
+func (s *SENT) ReadFrame() (fast SENTFrame, slow SENTSerialMessage, err error) {
+	var nibbles [sentNibbles]uint8
+	var cal uint32 // Calibration pulse length in cycles. 0 while searching for it.
+	n := 0
+	dl := s.dl.newDeadline()
+	for {
+		if s.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				return fast, slow, errTimeout
+			}
+			gosched()
+			continue
+		}
+		period := 2*s.sm.RxGet() + sentLoopOverhead
+		if cal == 0 {
+			if s.isCalibration(period) {
+				cal = period
+			}
+			continue
+		}
+		// Round to nearest tick count.
+		ticks := (2*uint64(period)*sentCalibrationTicks + uint64(cal)) / (2 * uint64(cal))
+		if ticks < sentNibbleTicks || ticks > sentNibbleTicks+15 {
+			// Not a nibble, resynchronize. The pulse may be the next calibration pulse.
+			cal = 0
+			n = 0
+			if s.isCalibration(period) {
+				cal = period
+			}
+			continue
+		}
+		nibbles[n] = uint8(ticks - sentNibbleTicks)
+		n++
+		if n < sentNibbles {
+			continue
+		}
+		fast.Status = nibbles[0]
+		copy(fast.Data[:], nibbles[1:7])
+		fast.Tick = time.Duration(uint64(cal) * uint64(time.Second) / (sentCalibrationTicks * uint64(machine.CPUFrequency())))
+		if sentCRC4(fast.Data[:]) != nibbles[7] {
+			s.serialCount = 0 // Slow channel requires consecutive valid frames.
+			return fast, slow, errSENTCRC
+		}
+		slow = s.decodeSerial(fast.Status)
+		return fast, slow, nil
+	}
+}
+
+// isCalibration returns true if period is within 25% of the nominal calibration pulse length.
+func (s *SENT) isCalibration(period uint32) bool {
+	nominal := s.tickCycles * sentCalibrationTicks
+	return period >= nominal-nominal/4 && period <= nominal+nominal/4
+}
+
+// decodeSerial accumulates the short serial message bits carried in status bits 2 and 3.
+func (s *SENT) decodeSerial(status uint8) (msg SENTSerialMessage) {
+	bit := uint16(status>>2) & 1
+	switch {
+	case status&(1<<3) != 0:
+		// Bit 3 set marks the first frame of a message.
+		s.serialBits = bit
+		s.serialCount = 1
+	case s.serialCount > 0:
+		s.serialBits = s.serialBits<<1 | bit
+		s.serialCount++
+	}
+	if s.serialCount < 16 {
+		return msg
+	}
+	s.serialCount = 0
+	id := uint8(s.serialBits >> 12)
+	data := uint8(s.serialBits >> 4)
+	crc := uint8(s.serialBits & 0xf)
+	if sentCRC4([]uint8{id, data >> 4, data & 0xf}) != crc {
+		return msg
+	}
+	return SENTSerialMessage{ID: id, Data: data, Valid: true}
+}
+
+var sentCRC4Table = [16]uint8{0, 13, 7, 10, 14, 3, 9, 4, 1, 12, 6, 11, 15, 2, 8, 5}
+
+// sentCRC4 calculates the SAE J2716 CRC (polynomial x^4+x^3+x^2+1, seed 0b0101)
+// using the recommended implementation which augments the data with a zero nibble.
+func sentCRC4(nibbles []uint8) uint8 {
+	crc := uint8(5)
+	for _, nibble := range nibbles {
+		crc = nibble ^ sentCRC4Table[crc]
+	}
+	return sentCRC4Table[crc]
+}
+
+// Placement returns the state machine and program used by the SENT receiver.
+func (s *SENT) Placement() Placement {
+	var p Placement
+	p.addSM(s.sm, s.offset, sentInstructions)
+	return p
+}
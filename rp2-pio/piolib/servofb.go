@@ -0,0 +1,93 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errServoCommand    = errors.New("piolib:servo command pulse out of range")
+	errServoNoFeedback = errors.New("piolib:no servo feedback pulse")
+)
+
+const (
+	// servofb ticks are microseconds of 3 cycles.
+	servoTickFreq = 3 * 1000000
+	// Servos expect a command pulse every 20ms.
+	servoFrame = 20 * time.Millisecond
+)
+
+// ServoFeedback drives a servo with position feedback, such as the digital servos
+// outputting their measured position as a pulse width. A single state machine
+// outputs the command pulse and then measures the feedback pulse within the same
+// 20ms frame, so each command is matched with the position the servo reports.
+type ServoFeedback struct {
+	sm         pio.StateMachine
+	offset     uint8
+	frameStart time.Time
+}
+
+// NewServoFeedback creates a servo driver outputting command pulses on command and
+// measuring feedback pulses on feedback.
+func NewServoFeedback(sm pio.StateMachine, command, feedback machine.Pin) (*ServoFeedback, error) {
+	whole, frac, err := pio.ClkDivFromFrequency(servoTickFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(servofbInstructions, servofbOrigin)
+	if err != nil {
+		return nil, err
+	}
+	command.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	feedback.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+	sm.SetPinsConsecutive(command, 1, false)
+	sm.SetPindirsConsecutive(command, 1, true)
+	sm.SetPindirsConsecutive(feedback, 1, false)
+	cfg := servofbProgramDefaultConfig(offset)
+	cfg.SetSetPins(command, 1)
+	cfg.SetJmpPin(feedback)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, servoTickFreq)
+	sm.SetEnabled(true)
+	return &ServoFeedback{sm: sm, offset: offset}, nil
+}
+
+// SetAndRead outputs a command pulse of us microseconds, between 500 and 2500, and
+// returns the width of the feedback pulse which follows it in microseconds. It
+// waits for the previous frame to end so pulses are 20ms apart and blocks until the
+// end of the frame at the latest. The servo holds its position only as long as
+// SetAndRead is called every frame.
+func (s *ServoFeedback) SetAndRead(us uint16) (feedbackUs uint16, err error) {
+	if us < rcMinMicros || us > rcMaxMicros {
+		return 0, errServoCommand
+	}
+	if wait := servoFrame - time.Since(s.frameStart); wait > 0 {
+		time.Sleep(wait)
+	}
+	s.frameStart = time.Now()
+	window := uint32(servoFrame/time.Microsecond) - uint32(us)
+	s.sm.TxPut(uint32(us-1) | (window-1)<<16)
+	for s.sm.IsRxFIFOEmpty() {
+		gosched()
+	}
+	width := s.sm.RxGet()
+	if width == 0 {
+		return 0, errServoNoFeedback
+	}
+	return uint16(width), nil
+}
+
+// Placement returns the state machine and program used by the driver.
+func (s *ServoFeedback) Placement() Placement {
+	var p Placement
+	p.addSM(s.sm, s.offset, servofbInstructions)
+	return p
+}
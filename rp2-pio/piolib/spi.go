@@ -13,11 +13,14 @@ type SPI struct {
 	sm         pio.StateMachine
 	progOffset uint8
 	mode       uint8
+	pins       uint32
+	susp       suspender
+	stats      fifoMonitor
+	guard      fifoGuard
 }
 
 func NewSPI(sm pio.StateMachine, spicfg machine.SPIConfig) (*SPI, error) {
 	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
-	const nbits = 8
 	// https://github.com/raspberrypi/pico-examples/blob/eca13acf57916a0bd5961028314006983894fc84/pio/spi/spi.pio#L46
 	if !sm.IsValid() {
 		return nil, errors.New("invalid state machine")
@@ -28,10 +31,41 @@ func NewSPI(sm pio.StateMachine, spicfg machine.SPIConfig) (*SPI, error) {
 		return nil, err
 	}
 	Pio := sm.PIO()
-	var instructions []uint16
-	var origin int8
-	var cfger func(uint8) pio.StateMachineConfig
-	switch spicfg.Mode {
+	instructions, origin, cfger, err := spiProgram(spicfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := Pio.AddProgram(instructions, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := spiConfig(cfger(offset), spicfg, whole, frac)
+
+	// MOSI, SCK output are low, MISO is input.
+	outMask := uint32((1 << spicfg.SCK) | (1 << spicfg.SDO))
+	inMask := uint32(1 << spicfg.SDI)
+	sm.SetPinsMasked(0, outMask)
+	sm.SetPindirsMasked(outMask, outMask|inMask)
+
+	pincfg := machine.PinConfig{Mode: Pio.PinMode()}
+	spicfg.SCK.Configure(pincfg)
+	spicfg.SDO.Configure(pincfg)
+	spicfg.SDI.Configure(pincfg)
+	Pio.SetInputSyncBypassMasked(inMask, inMask)
+
+	sm.Init(offset, cfg)
+	trackClock(sm, spicfg.Frequency)
+	sm.SetEnabled(true)
+
+	spi := &SPI{sm: sm, progOffset: offset, mode: spicfg.Mode, pins: outMask | inMask}
+	return spi, nil
+}
+
+// spiProgram returns the program implementing SPI mode and its configuration function.
+func spiProgram(mode uint8) (instructions []uint16, origin int8, cfger func(uint8) pio.StateMachineConfig, err error) {
+	switch mode {
 	case 0b00:
 		instructions = spi_cpha0Instructions
 		origin = spi_cpha0Origin
@@ -46,18 +80,17 @@ func NewSPI(sm pio.StateMachine, spicfg machine.SPIConfig) (*SPI, error) {
 		origin = spi_cpha1Origin
 		cfger = spi_cpha1ProgramDefaultConfig
 	case 0b10, 0b11:
-		return nil, errors.New("unsupported mode")
+		return nil, 0, nil, errors.New("unsupported mode")
 	default:
 		panic("invalid mode")
 	}
+	return instructions, origin, cfger, nil
+}
 
-	offset, err := Pio.AddProgram(instructions, origin)
-	if err != nil {
-		return nil, err
-	}
-
-	cfg := cfger(offset)
-
+// spiConfig completes the program default configuration cfg with the pins of spicfg
+// and the clock divider.
+func spiConfig(cfg pio.StateMachineConfig, spicfg machine.SPIConfig, whole uint16, frac uint8) pio.StateMachineConfig {
+	const nbits = 8
 	cfg.SetOutPins(spicfg.SDO, 1)
 	cfg.SetInPins(spicfg.SDI)
 	cfg.SetSidesetPins(spicfg.SCK)
@@ -66,24 +99,7 @@ func NewSPI(sm pio.StateMachine, spicfg machine.SPIConfig) (*SPI, error) {
 	cfg.SetInShift(false, true, uint16(nbits))
 
 	cfg.SetClkDivIntFrac(whole, frac)
-
-	// MOSI, SCK output are low, MISO is input.
-	outMask := uint32((1 << spicfg.SCK) | (1 << spicfg.SDO))
-	inMask := uint32(1 << spicfg.SDI)
-	sm.SetPinsMasked(0, outMask)
-	sm.SetPindirsMasked(outMask, outMask|inMask)
-
-	pincfg := machine.PinConfig{Mode: Pio.PinMode()}
-	spicfg.SCK.Configure(pincfg)
-	spicfg.SDO.Configure(pincfg)
-	spicfg.SDI.Configure(pincfg)
-	Pio.SetInputSyncBypassMasked(inMask, inMask)
-
-	sm.Init(offset, cfg)
-	sm.SetEnabled(true)
-
-	spi := &SPI{sm: sm, progOffset: offset, mode: spicfg.Mode}
-	return spi, nil
+	return cfg
 }
 
 func (spi *SPI) Tx(w, r []byte) error {
@@ -92,14 +108,19 @@ func (spi *SPI) Tx(w, r []byte) error {
 		return errors.New("expect lengths to be equal")
 	}
 	retries := int8(32)
+	spi.stats.begin(spi.sm)
 	for rxRemain != 0 || txRemain != 0 {
 		stall := true
 		if txRemain != 0 && !spi.sm.IsTxFIFOFull() {
 			spi.sm.TxPut(uint32(w[len(w)-txRemain]))
+			spi.stats.sample(spi.sm)
 			txRemain--
 			stall = false
+		} else if txRemain != 0 {
+			spi.stats.txFull(spi.sm)
 		}
 		if txRemain != 0 && !spi.sm.IsRxFIFOEmpty() {
+			spi.stats.sample(spi.sm)
 			r[len(r)-rxRemain] = uint8(spi.sm.RxGet())
 			rxRemain--
 			stall = false
@@ -112,7 +133,7 @@ func (spi *SPI) Tx(w, r []byte) error {
 			gosched()
 		}
 	}
-	return nil
+	return spi.guard.check(spi.sm, len(w))
 }
 
 func (spi *SPI) Transfer(c byte) (rx byte, _ error) {
@@ -133,7 +154,30 @@ func (spi *SPI) Transfer(c byte) (rx byte, _ error) {
 			return 0, errors.New("pioSPI timeout")
 		}
 	}
-	return rx, nil
+	return rx, spi.guard.check(spi.sm, 1)
+}
+
+// SetFIFOGuard enables checking for words lost by the FIFOs after each Tx and
+// Transfer, which then return a *pio.FIFOError wrapping pio.ErrTxOverflow or pio.ErrRxUnderflow. It is
+// disabled by default.
+func (spi *SPI) SetFIFOGuard(enabled bool) {
+	spi.guard.setEnabled(enabled, spi.sm)
+}
+
+// FIFOErrors returns the number of calls which lost words while the guard was
+// enabled.
+func (spi *SPI) FIFOErrors() (txOverflows, rxUnderflows uint32) {
+	return spi.guard.counts()
+}
+
+// Stats returns the FIFO statistics of Tx gathered while EnableFIFOStats is set.
+func (spi *SPI) Stats() FIFOStats {
+	return spi.stats.stats
+}
+
+// ResetStats clears the FIFO statistics.
+func (spi *SPI) ResetStats() {
+	spi.stats.stats = FIFOStats{}
 }
 
 // SPI represents a SPI bus. It is implemented by the machine.SPI type.
@@ -147,3 +191,23 @@ type _SPI interface {
 	// If you want to transfer multiple bytes, it is more efficient to use Tx instead.
 	Transfer(b byte) (byte, error)
 }
+
+// Suspend halts the state machine and turns the SPI pins to inputs with pulls
+// off, so the device can be hot-plugged or power-cycled safely. The configuration
+// is kept for Resume. The SPI must be idle and must not be used until resumed.
+func (spi *SPI) Suspend() {
+	spi.susp.suspend(spi.sm, spi.pins)
+}
+
+// Resume restores the pins and restarts the state machine halted by Suspend.
+func (spi *SPI) Resume() {
+	spi.susp.resume(spi.sm, spi.pins)
+}
+
+// Placement returns the state machine and program used by SPI.
+func (spi *SPI) Placement() Placement {
+	var p Placement
+	instructions, _, _, _ := spiProgram(spi.mode)
+	p.addSM(spi.sm, spi.progOffset, instructions)
+	return p
+}
@@ -17,11 +17,14 @@ import (
 type SPI3w struct {
 	sm     pio.StateMachine
 	dma    dmaChannel
+	chunks dmaChunking
+	high   bool // DMA channel priority, see SetHighPriority.
 	offset uint8
 
 	statusEn   bool
 	lastStatus uint32
 	pinMask    uint32
+	susp       suspender
 }
 
 func NewSPI3w(sm pio.StateMachine, dio, clk machine.Pin, baud uint32) (*SPI3w, error) {
@@ -76,6 +79,7 @@ func NewSPI3w(sm pio.StateMachine, dio, clk machine.Pin, baud uint32) (*SPI3w, e
 
 	// Initialize state machine.
 	sm.Init(offset, cfg)
+	trackClock(sm, baud)
 	pinMask := uint32(1<<dio | 1<<clk)
 	sm.SetPindirsMasked(pinMask, pinMask)
 	sm.SetPinsMasked(0, pinMask)
@@ -274,19 +278,40 @@ func (spi *SPI3w) EnableDMA(enabled bool) error {
 		}
 		return nil
 	}
-	channel, ok := _DMA.ClaimChannel()
+	channel, ok := _DMA.ClaimChannelFor("SPI3w")
 	if !ok {
 		return errDMAUnavail
 	}
 	channel.dl = spi.dma.dl // Copy deadline.
 	spi.dma = channel
+	spi.dma.setHighPriority(spi.high)
 	return nil
 }
 
+// SetChunking splits DMA writes into transfers of at most chunkSize words,
+// yielding to the scheduler between them and waiting interChunkDelay if not 0, so
+// large writes leave the bus to latency sensitive streams such as audio. The
+// clock pauses between chunks. Use 0 as chunkSize to write in a single transfer,
+// the default. See DMATxStream.SetChunking.
+func (spi *SPI3w) SetChunking(chunkSize int, interChunkDelay time.Duration) {
+	spi.chunks = dmaChunking{size: chunkSize, delay: interChunkDelay}
+}
+
+// SetHighPriority sets whether the DMA channel is scheduled before the normal
+// priority channels when several are ready to transfer. It is kept when DMA is
+// enabled later.
+func (spi *SPI3w) SetHighPriority(high bool) {
+	spi.high = high
+	if spi.IsDMAEnabled() {
+		spi.dma.setHighPriority(high)
+	}
+}
+
 func (spi *SPI3w) readDMA(r []uint32) error {
 	dreq := dmaPIO_RxDREQ(spi.sm)
 	err := spi.dma.Pull32(r, &spi.sm.RxReg().Reg, dreq)
 	if err != nil {
+		stopStreaming(spi.sm, spi.dma) // Restarted by the next prepTx.
 		return err
 	}
 	return nil
@@ -294,13 +319,38 @@ func (spi *SPI3w) readDMA(r []uint32) error {
 
 func (spi *SPI3w) writeDMA(w []uint32) error {
 	dreq := dmaPIO_TxDREQ(spi.sm)
-	err := spi.dma.Push32(&spi.sm.TxReg().Reg, w, dreq)
+	_, err := dmaPushChunked(spi.dma, spi.chunks, spi.sm.TxRegAddr(), w, dreq)
 	if err != nil {
+		stopStreaming(spi.sm, spi.dma) // Restarted by the next prepTx.
 		return err
 	}
 	return nil
 }
 
+// StartWrite starts writing w to the bus like Tx32 with nothing to read, by DMA in
+// a single transfer, and returns without waiting. DMA must be enabled, see
+// EnableDMA. The operation is done once the last word is in the FIFO, not yet on
+// the bus. w must not be modified until the operation is done. On timeout or
+// cancellation the words left are dropped.
+func (spi *SPI3w) StartWrite(w []uint32) *DMAOp {
+	if !spi.IsDMAEnabled() {
+		return &DMAOp{err: errDMAUnavail, done: true}
+	}
+	var writeBits uint32
+	if len(w) > 0 {
+		writeBits = uint32(len(w)*32 - 1)
+	}
+	spi.prepTx(0, writeBits)
+	op := dmaStartPushAddr(spi.dma, spi.sm.TxRegAddr(), w, dmaPIO_TxDREQ(spi.sm))
+	op.stop = spi.stopDMA
+	return &op
+}
+
+// stopDMA aborts a DMA transfer, the state machine being restarted by the next prepTx.
+func (spi *SPI3w) stopDMA() {
+	stopStreaming(spi.sm, spi.dma)
+}
+
 func (spi *SPI3w) IsDMAEnabled() bool {
 	return spi.dma.IsValid()
 }
@@ -308,3 +358,23 @@ func (spi *SPI3w) IsDMAEnabled() bool {
 func pinPadCtrl(pin machine.Pin) *volatile.Register32 {
 	return (*volatile.Register32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rp.PADS_BANK0.GPIO0)) + uintptr(4*pin)))
 }
+
+// Suspend halts the state machine and turns DIO and CLK to inputs with pulls off,
+// so the device can be hot-plugged or power-cycled safely. The configuration is
+// kept for Resume. The bus must be idle and must not be used until resumed.
+func (spi *SPI3w) Suspend() {
+	spi.susp.suspend(spi.sm, spi.pinMask)
+}
+
+// Resume restores the pins and restarts the state machine halted by Suspend.
+func (spi *SPI3w) Resume() {
+	spi.susp.resume(spi.sm, spi.pinMask)
+}
+
+// Placement returns the state machines, programs and DMA channels used by the 3 wire SPI.
+func (spi *SPI3w) Placement() Placement {
+	var p Placement
+	p.addSM(spi.sm, spi.offset, spi3wInstructions)
+	p.addDMA(spi.dma)
+	return p
+}
@@ -0,0 +1,157 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// SPI9 drives displays wired for 9 bit serial transfers, where the D/C bit is
+// sent before each byte on the data line instead of on a separate pin, as with
+// the 3-wire serial interface of the ST7789 and ILI9163. Hardware SPI can't
+// produce these frames for a stream of bytes.
+type SPI9 struct {
+	sm     pio.StateMachine
+	offset uint8
+	cs     machine.Pin
+	pins   uint32
+	dl     deadliner
+	susp   suspender
+}
+
+// NewSPI9 returns a 9 bit SPI transmitter with clock on sck, data on sda and chip
+// select on cs, which may be machine.NoPin if the display is always selected.
+// cs is driven by the CPU, low for the duration of each Command and Data call.
+func NewSPI9(sm pio.StateMachine, sck, sda, cs machine.Pin, baud uint32) (*SPI9, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	// A bit takes 2 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(baud*2, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(spi9Instructions, spi9Origin)
+	if err != nil {
+		return nil, err
+	}
+	if cs != machine.NoPin {
+		cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		cs.High()
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	sck.Configure(pinCfg)
+	sda.Configure(pinCfg)
+	outMask := uint32(1<<sck | 1<<sda)
+	sm.SetPinsMasked(0, outMask)
+	sm.SetPindirsMasked(outMask, outMask)
+
+	cfg := spi9ProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(sck)
+	cfg.SetOutPins(sda, 1)
+	cfg.SetOutShift(false, true, 9)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, baud*2)
+	sm.SetEnabled(true)
+	return &SPI9{sm: sm, offset: offset, cs: cs, pins: outMask}, nil
+}
+
+// SetTimeout sets the timeout for transfers. Use 0 as argument to disable timeouts.
+func (spi *SPI9) SetTimeout(timeout time.Duration) {
+	spi.dl.setTimeout(timeout)
+}
+
+// Command sends a command byte, with the D/C bit low.
+func (spi *SPI9) Command(cmd byte) error {
+	return spi.write([]byte{cmd}, false)
+}
+
+// Data sends data bytes such as command parameters or pixels, each with the D/C bit high.
+func (spi *SPI9) Data(data []byte) error {
+	return spi.write(data, true)
+}
+
+// write sends the bytes of data as 9 bit frames with the D/C bit set to dc and
+// waits for the last frame to be shifted out.
+func (spi *SPI9) write(data []byte, dc bool) error {
+	var dcBit uint32
+	if dc {
+		dcBit = 1 << 8
+	}
+	spi.selectDevice(true)
+	defer spi.selectDevice(false)
+	dl := spi.dl.newDeadline()
+	for _, b := range data {
+		for spi.sm.IsTxFIFOFull() {
+			if dl.expired() {
+				spi.reset()
+				return errTimeout
+			}
+			gosched()
+		}
+		// Frames are shifted out from bit 31.
+		spi.sm.TxPut((dcBit | uint32(b)) << 23)
+	}
+	// The state machine stalls on an empty FIFO once the last bit is out.
+	spi.sm.ClearTxStalled()
+	for !spi.sm.IsTxFIFOEmpty() || !spi.sm.IsTxStalled() {
+		if dl.expired() {
+			spi.reset()
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+func (spi *SPI9) selectDevice(selected bool) {
+	if spi.cs != machine.NoPin {
+		spi.cs.Set(!selected)
+	}
+}
+
+// reset aborts a transfer, dropping the frames not sent yet.
+func (spi *SPI9) reset() {
+	spi.sm.ClearFIFOs()
+	spi.sm.Restart()
+	spi.sm.Jmp(spi.offset, pio.JmpAlways)
+}
+
+// Suspend halts the state machine and turns SCK, SDA and CS to inputs with pulls
+// off, so the display can be hot-plugged or power-cycled safely. The
+// configuration is kept for Resume. No Command or Data call must be in progress
+// and none must be made until resumed.
+func (spi *SPI9) Suspend() {
+	if spi.susp.suspended {
+		return
+	}
+	spi.susp.suspend(spi.sm, spi.pins)
+	if spi.cs != machine.NoPin {
+		spi.cs.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+}
+
+// Resume restores the pins, CS deasserted, and restarts the state machine halted
+// by Suspend.
+func (spi *SPI9) Resume() {
+	if !spi.susp.suspended {
+		return
+	}
+	if spi.cs != machine.NoPin {
+		spi.cs.High()
+		spi.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	}
+	spi.susp.resume(spi.sm, spi.pins)
+}
+
+// Placement returns the state machine and program used by the transmitter.
+func (spi *SPI9) Placement() Placement {
+	var p Placement
+	p.addSM(spi.sm, spi.offset, spi9Instructions)
+	return p
+}
@@ -0,0 +1,207 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"device/rp"
+	"errors"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errSPIADCFormat   = errors.New("piolib:SPI ADC frame must be 1..32 bits")
+	errSPIADCChannels = errors.New("piolib:SPI ADC needs channels to scan")
+)
+
+// SPIADCFormat describes the conversion frame of an SPI ADC.
+type SPIADCFormat struct {
+	// Bits is the number of clocks of a conversion frame, up to 32.
+	Bits uint8
+	// Command returns the Bits bits written during the conversion of channel,
+	// MSB first. It may be nil for ADCs converting on CS falling edge, which
+	// ignore SDO.
+	Command func(channel uint8) uint32
+	// The result is the ResultBits bits read ResultShift bits before the end of the frame.
+	ResultShift uint8
+	ResultBits  uint8
+}
+
+// SPIADCMCP3008 is the frame format of the MCP3004 and MCP3008 10 bit ADCs for
+// single ended conversions of channels 0..7. SCK must not exceed 1.35MHz at 2.7V
+// or 3.6MHz at 5V.
+var SPIADCMCP3008 = SPIADCFormat{
+	Bits: 24,
+	Command: func(channel uint8) uint32 {
+		// Start bit followed by single ended mode and the channel.
+		return 1<<16 | uint32(0b1000|channel&7)<<12
+	},
+	ResultBits: 10,
+}
+
+// SPIADC scans the channels of an external SPI ADC continuously without CPU
+// intervention: a looping DMA transfer feeds the conversion commands to the state
+// machine and another stores the results in a ring buffer holding the last
+// value of each channel. SPIADC claims four DMA channels.
+type SPIADC struct {
+	sm       pio.StateMachine
+	offset   uint8
+	dmaCmd   dmaChannel
+	dmaCmdCt dmaChannel
+	dmaRes   dmaChannel
+	dmaResCt dmaChannel
+	format   SPIADCFormat
+	channels []uint8
+	smFreq   uint32
+	// cmds holds a delay and command word per conversion, results the last
+	// result of each conversion.
+	cmds    []uint32
+	results []uint32
+	cmdAddr uint32
+	resAddr uint32
+}
+
+// NewSPIADC starts scanning channels of an ADC with clock on sck, data in and out
+// on sdi and sdo and chip select on cs. Channels are converted in the given order
+// at the maximum rate the SCK frequency sckFreq allows, see SetScanRate. A channel
+// may be listed several times to sample it more often.
+func NewSPIADC(sm pio.StateMachine, sck, sdo, sdi, cs machine.Pin, format SPIADCFormat, channels []uint8, sckFreq uint32) (*SPIADC, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if format.Bits == 0 || format.Bits > 32 {
+		return nil, errSPIADCFormat
+	}
+	if len(channels) == 0 {
+		return nil, errSPIADCChannels
+	}
+	// A bit takes 4 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(sckFreq*4, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	var dmas [4]dmaChannel
+	for i := range dmas {
+		var ok bool
+		dmas[i], ok = _DMA.ClaimChannelFor("SPIADC")
+		if !ok {
+			for _, ch := range dmas[:i] {
+				ch.Unclaim()
+			}
+			return nil, errDMAUnavail
+		}
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(spiadcInstructions, spiadcOrigin)
+	if err != nil {
+		for _, ch := range dmas {
+			ch.Unclaim()
+		}
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	sck.Configure(pinCfg)
+	sdo.Configure(pinCfg)
+	sdi.Configure(pinCfg)
+	cs.Configure(pinCfg)
+	sm.SetPinsConsecutive(sck, 1, false)
+	sm.SetPinsConsecutive(sdo, 1, false)
+	sm.SetPinsConsecutive(cs, 1, true)
+	sm.SetPindirsConsecutive(sck, 1, true)
+	sm.SetPindirsConsecutive(sdo, 1, true)
+	sm.SetPindirsConsecutive(cs, 1, true)
+	sm.SetPindirsConsecutive(sdi, 1, false)
+	Pio.SetInputSyncBypassMasked(1<<sdi, 1<<sdi)
+
+	cfg := spiadcProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(sck)
+	cfg.SetSetPins(cs, 1)
+	cfg.SetOutPins(sdo, 1)
+	cfg.SetInPins(sdi)
+	cfg.SetOutShift(false, false, 32)
+	cfg.SetInShift(false, true, uint16(format.Bits))
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, sckFreq*4)
+	sm.SetY(uint32(format.Bits) - 1)
+
+	adc := &SPIADC{
+		sm:       sm,
+		offset:   offset,
+		dmaCmd:   dmas[0],
+		dmaCmdCt: dmas[1],
+		dmaRes:   dmas[2],
+		dmaResCt: dmas[3],
+		format:   format,
+		channels: channels,
+		smFreq:   sckFreq * 4,
+		cmds:     make([]uint32, 2*len(channels)),
+		results:  make([]uint32, len(channels)),
+	}
+	for i, ch := range channels {
+		var cmd uint32
+		if format.Command != nil {
+			// Commands are shifted out from the MSB.
+			cmd = format.Command(ch) << (32 - format.Bits)
+		}
+		adc.cmds[2*i+1] = cmd
+	}
+	adc.cmdAddr = ptrAs(&adc.cmds[0])
+	adc.resAddr = ptrAs(&adc.results[0])
+	adc.dmaRes.pullLoop32(adc.dmaResCt, adc.results, &adc.resAddr, &sm.RxReg().Reg, dmaPIO_RxDREQ(sm))
+	sm.SetEnabled(true)
+	adc.dmaCmd.pushLoop32(adc.dmaCmdCt, &sm.TxReg().Reg, adc.cmds, &adc.cmdAddr, dmaPIO_TxDREQ(sm))
+	return adc, nil
+}
+
+// SetScanRate limits the number of scans of all channels per second. Scans run
+// as fast as SCK allows if the rate is 0 or too high. It returns the actual rate,
+// which may be lower due to the resolution of the pacing delay.
+func (adc *SPIADC) SetScanRate(scansPerSecond uint32) uint32 {
+	frameCycles := 7 + 4*uint32(adc.format.Bits)
+	var delay uint32
+	if scansPerSecond > 0 {
+		cycles := adc.smFreq / scansPerSecond / uint32(len(adc.channels))
+		if cycles > frameCycles {
+			delay = cycles - frameCycles
+		}
+	}
+	for i := range adc.channels {
+		adc.cmds[2*i] = delay // Picked up by the looping DMA on the next conversion.
+	}
+	return adc.smFreq / ((frameCycles + delay) * uint32(len(adc.channels)))
+}
+
+// Read returns the last conversion result of channel, or 0 if the channel is
+// not scanned or was not converted yet. For channels listed several times the
+// result of the first occurrence is returned.
+func (adc *SPIADC) Read(channel uint8) uint16 {
+	for i, ch := range adc.channels {
+		if ch == channel {
+			raw := adc.results[i] >> adc.format.ResultShift
+			return uint16(raw & (1<<adc.format.ResultBits - 1))
+		}
+	}
+	return 0
+}
+
+// Close stops scanning and releases the DMA channels. The state machine is left
+// disabled and results read afterwards are those of the last scan.
+func (adc *SPIADC) Close() error {
+	adc.sm.SetEnabled(false)
+	// Aborting a channel aborts the one chained to it too.
+	adc.dmaCmd.abort()
+	adc.dmaRes.abort()
+	for _, ch := range [...]dmaChannel{adc.dmaCmd, adc.dmaCmdCt, adc.dmaRes, adc.dmaResCt} {
+		ch.HW().CTRL_TRIG.ClearBits(rp.DMA_CH0_CTRL_TRIG_EN_Msk)
+		ch.Unclaim()
+	}
+	return nil
+}
+
+// Placement returns the state machine, program and DMA channels used by the scanner.
+func (adc *SPIADC) Placement() Placement {
+	var p Placement
+	p.addSM(adc.sm, adc.offset, spiadcInstructions)
+	p.addDMA(adc.dmaCmd, adc.dmaCmdCt, adc.dmaRes, adc.dmaResCt)
+	return p
+}
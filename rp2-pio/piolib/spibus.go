@@ -0,0 +1,166 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"sync"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// SPIBus shares a single PIO SPI state machine between several devices, each with
+// its own chip select pin, frequency and mode. The state machine is reconfigured
+// when a transfer targets a different device than the previous one.
+type SPIBus struct {
+	mu  sync.Mutex
+	spi *SPI
+	cfg machine.SPIConfig
+	// Program offsets for each supported mode, valid if the mode's loaded bit is set.
+	offsets [2]uint8
+	loaded  uint8
+	active  *SPIDevice
+	devices []*SPIDevice
+}
+
+// SPIDevice is a device on an SPIBus. It implements the same Tx and Transfer
+// methods as machine.SPI and asserts its chip select for the duration of each call.
+type SPIDevice struct {
+	bus  *SPIBus
+	cs   machine.Pin
+	mode uint8
+	freq uint32
+}
+
+// NewSPIBus returns an SPI bus using the SCK, SDO and SDI pins of spicfg. The
+// frequency and mode of spicfg are the initial state machine configuration,
+// devices added with Device use their own.
+func NewSPIBus(sm pio.StateMachine, spicfg machine.SPIConfig) (*SPIBus, error) {
+	spi, err := NewSPI(sm, spicfg)
+	if err != nil {
+		return nil, err
+	}
+	bus := &SPIBus{spi: spi, cfg: spicfg}
+	bus.offsets[spicfg.Mode] = spi.progOffset
+	bus.loaded = 1 << spicfg.Mode
+	return bus, nil
+}
+
+// Device adds a device with chip select on cs, driven low during transfers. Only
+// the Frequency and Mode fields of cfg are used. Modes 0 and 1 are supported, the
+// program of each mode in use is loaded into the PIO once.
+func (bus *SPIBus) Device(cs machine.Pin, cfg machine.SPIConfig) (*SPIDevice, error) {
+	_, _, err := pio.ClkDivFromFrequency(cfg.Frequency, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	instructions, origin, _, err := spiProgram(cfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.loaded&(1<<cfg.Mode) == 0 {
+		offset, err := bus.spi.sm.PIO().AddProgram(instructions, origin)
+		if err != nil {
+			return nil, err
+		}
+		bus.offsets[cfg.Mode] = offset
+		bus.loaded |= 1 << cfg.Mode
+	}
+	cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	cs.High()
+	d := &SPIDevice{bus: bus, cs: cs, mode: cfg.Mode, freq: cfg.Frequency}
+	bus.devices = append(bus.devices, d)
+	return d, nil
+}
+
+// Suspend waits for the transfer in progress, then halts the state machine and
+// turns the SPI pins and the chip selects of all devices to inputs with pulls off,
+// so devices can be hot-plugged or power-cycled safely. The configuration is kept
+// for Resume. The devices must not be used until resumed.
+func (bus *SPIBus) Suspend() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.spi.susp.suspended {
+		return
+	}
+	bus.spi.Suspend()
+	for _, d := range bus.devices {
+		d.cs.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+}
+
+// Resume restores the pins, chip selects deasserted, and restarts the state
+// machine halted by Suspend.
+func (bus *SPIBus) Resume() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if !bus.spi.susp.suspended {
+		return
+	}
+	for _, d := range bus.devices {
+		d.cs.High()
+		d.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	}
+	bus.spi.Resume()
+}
+
+// Tx transmits w and receives into r at the same time with the chip select asserted.
+// See SPI.Tx.
+func (d *SPIDevice) Tx(w, r []byte) error {
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	d.bus.selectDevice(d)
+	d.cs.Low()
+	err := d.bus.spi.Tx(w, r)
+	d.cs.High()
+	return err
+}
+
+// Transfer writes a single byte and receives a byte at the same time with the
+// chip select asserted. Use Tx for transactions longer than a byte.
+func (d *SPIDevice) Transfer(c byte) (byte, error) {
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	d.bus.selectDevice(d)
+	d.cs.Low()
+	rx, err := d.bus.spi.Transfer(c)
+	d.cs.High()
+	return rx, err
+}
+
+// selectDevice reconfigures the state machine for d if it was not the last device
+// used. Must be called with the bus lock held and the state machine idle.
+func (bus *SPIBus) selectDevice(d *SPIDevice) {
+	if bus.active == d {
+		return
+	}
+	_, _, cfger, _ := spiProgram(d.mode)
+	offset := bus.offsets[d.mode]
+	// Divider calculated on each switch to follow system clock changes, checked by Device.
+	whole, frac, _ := pio.ClkDivFromFrequency(d.freq, machine.CPUFrequency())
+	cfg := spiConfig(cfger(offset), bus.cfg, whole, frac)
+	sm := bus.spi.sm
+	sm.SetEnabled(false)
+	sm.Init(offset, cfg)
+	trackClock(sm, d.freq)
+	sm.SetEnabled(true)
+	bus.spi.progOffset = offset
+	bus.spi.mode = d.mode
+	bus.active = d
+}
+
+// Placement returns the state machine and the programs loaded for the modes in use by the bus.
+func (bus *SPIBus) Placement() Placement {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	var p Placement
+	for mode := uint8(0); mode < 2; mode++ {
+		if bus.loaded&(1<<mode) != 0 {
+			instructions, _, _, _ := spiProgram(mode)
+			p.addSM(bus.spi.sm, bus.offsets[mode], instructions)
+		}
+	}
+	return p
+}
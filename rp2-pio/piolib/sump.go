@@ -0,0 +1,182 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// SUMP protocol commands. Commands from 0x80 up are followed by 4 bytes of
+// little endian argument.
+const (
+	sumpReset       = 0x00
+	sumpRun         = 0x01
+	sumpID          = 0x02
+	sumpMetadata    = 0x04
+	sumpDivider     = 0x80
+	sumpReadDelay   = 0x81
+	sumpFlags       = 0x82
+	sumpTrigMask0   = 0xc0
+	sumpTrigValue0  = 0xc1
+	sumpLongCommand = 0x80
+	// Clock the divider of sumpDivider applies to.
+	sumpClock = 100_000_000
+	// Flags bits disabling channel groups 0..3 of 8 channels.
+	sumpFlagGroupsPos = 2
+)
+
+// SUMP metadata keys.
+const (
+	sumpMetaEnd          = 0x00
+	sumpMetaName         = 0x01
+	sumpMetaVersion      = 0x02
+	sumpMetaProbes       = 0x20
+	sumpMetaMemory       = 0x21
+	sumpMetaMaxRate      = 0x23
+	sumpMetaProtoVersion = 0x24
+)
+
+// SUMPServer lets desktop tools speaking the SUMP protocol of the Openbench Logic
+// Sniffer, such as PulseView and sigrok-cli with the "ols" driver, control a
+// LogicAnalyzer over a serial port such as machine.Serial.
+//
+// The trigger of the first stage is supported on the 8 channels, without
+// pre-trigger samples: captures start at the trigger.
+type SUMPServer struct {
+	la     *LogicAnalyzer
+	rw     io.ReadWriter
+	memory []byte
+	// Capture settings from the last commands: samples requested by the client
+	// and samples captured, which memory may limit.
+	readCount int
+	samples   int
+	trigMask  uint8
+	trigValue uint8
+	flags     uint32
+	cmd       [5]byte
+}
+
+// NewSUMPServer returns a server controlling la over rw. Captures are stored in
+// memory, whose length is the longest capture advertised to the client.
+func NewSUMPServer(la *LogicAnalyzer, rw io.ReadWriter, memory []byte) *SUMPServer {
+	return &SUMPServer{la: la, rw: rw, memory: memory, readCount: len(memory), samples: len(memory)}
+}
+
+// Serve handles commands until reading or writing rw fails, returning the error.
+func (s *SUMPServer) Serve() error {
+	for {
+		if err := s.read(s.cmd[:1]); err != nil {
+			return err
+		}
+		if s.cmd[0] >= sumpLongCommand {
+			if err := s.read(s.cmd[1:]); err != nil {
+				return err
+			}
+		}
+		if err := s.handle(s.cmd[0], binary.LittleEndian.Uint32(s.cmd[1:])); err != nil {
+			return err
+		}
+	}
+}
+
+// handle executes a command with its argument, ignored for short commands.
+func (s *SUMPServer) handle(cmd byte, arg uint32) error {
+	switch cmd {
+	case sumpReset:
+		s.trigMask, s.trigValue = 0, 0
+	case sumpID:
+		_, err := s.rw.Write([]byte("1ALS"))
+		return err
+	case sumpMetadata:
+		return s.writeMetadata()
+	case sumpRun:
+		return s.run()
+	case sumpDivider:
+		divider := arg&0xffffff + 1
+		// Rates below the slowest clock divider keep the previous rate, the
+		// protocol has no way to report the error.
+		s.la.SetSampleRate(sumpClock / divider)
+	case sumpReadDelay:
+		// The read count in bits 0..15 is the number of samples sent back and the
+		// delay count in bits 16..31 those after the trigger, both in units of 4
+		// samples minus one. Without pre-trigger samples, all the samples read are
+		// captured after the trigger.
+		s.readCount = int(arg&0xffff+1) * 4
+		s.samples = s.readCount
+		if s.samples > len(s.memory) {
+			s.samples = len(s.memory)
+		}
+	case sumpFlags:
+		s.flags = arg
+	case sumpTrigMask0:
+		s.trigMask = uint8(arg)
+	case sumpTrigValue0:
+		s.trigValue = uint8(arg)
+	}
+	// Other commands, i.e. trigger stages 1..3 and XON/XOFF, are ignored.
+	return nil
+}
+
+// run captures and sends the samples, last sample first as the protocol requires,
+// with a byte for each enabled channel group. The client waits for as many
+// samples as it asked for: those beyond the memory are sent as zeros.
+func (s *SUMPServer) run() error {
+	buf := s.memory[:s.samples]
+	if err := s.la.Capture(buf, s.trigMask, s.trigValue); err != nil {
+		return err
+	}
+	var sample [4]byte
+	for i := len(buf) - 1; i >= len(buf)-s.readCount; i-- {
+		n := 0
+		for group := 0; group < 4; group++ {
+			if s.flags&(1<<(sumpFlagGroupsPos+group)) != 0 {
+				continue // Disabled.
+			}
+			sample[n] = 0
+			if group == 0 && i >= 0 {
+				sample[n] = buf[i]
+			}
+			n++
+		}
+		if _, err := s.rw.Write(sample[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SUMPServer) writeMetadata() error {
+	b := make([]byte, 0, 64)
+	b = append(b, sumpMetaName)
+	b = append(b, "TinyGo PIO logic analyzer\x00"...)
+	b = append(b, sumpMetaVersion)
+	b = append(b, "piolib\x00"...)
+	b = append(b, sumpMetaProbes)
+	b = binary.BigEndian.AppendUint32(b, 8)
+	b = append(b, sumpMetaMemory)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s.memory)))
+	b = append(b, sumpMetaMaxRate)
+	b = binary.BigEndian.AppendUint32(b, s.la.MaxSampleRate())
+	b = append(b, sumpMetaProtoVersion)
+	b = binary.BigEndian.AppendUint32(b, 2)
+	b = append(b, sumpMetaEnd)
+	_, err := s.rw.Write(b)
+	return err
+}
+
+// read fills b from rw. USB CDC and UART reads return no data instead of blocking
+// when nothing was received, so empty reads are retried.
+func (s *SUMPServer) read(b []byte) error {
+	for len(b) > 0 {
+		n, err := s.rw.Read(b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			gosched()
+		}
+		b = b[n:]
+	}
+	return nil
+}
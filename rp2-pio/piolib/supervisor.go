@@ -0,0 +1,259 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// Default time a supervised output may go without progress.
+const supervisorDefaultTimeout = 100 * time.Millisecond
+
+// SupervisorFault is the reason a supervised output was tripped.
+type SupervisorFault uint8
+
+const (
+	// SupervisorOK is the state of an output without fault.
+	SupervisorOK SupervisorFault = iota
+	// SupervisorDisabled is a state machine found disabled.
+	SupervisorDisabled
+	// SupervisorStalled is a state machine whose program counter did not move for
+	// the timeout, i.e. blocked on a PULL or WAIT.
+	SupervisorStalled
+	// SupervisorUnderrun is a state machine that stalled on an empty TX FIFO.
+	SupervisorUnderrun
+	// SupervisorDMAIdle is a DMA feeder that did not transfer for the timeout.
+	SupervisorDMAIdle
+	// SupervisorTripped is an output tripped by a call to Trip.
+	SupervisorTripped
+)
+
+// String returns the name of the fault.
+func (f SupervisorFault) String() string {
+	switch f {
+	case SupervisorOK:
+		return "ok"
+	case SupervisorDisabled:
+		return "disabled"
+	case SupervisorStalled:
+		return "stalled"
+	case SupervisorUnderrun:
+		return "underrun"
+	case SupervisorDMAIdle:
+		return "dma idle"
+	case SupervisorTripped:
+		return "tripped"
+	}
+	return "unknown"
+}
+
+// SafePin is a pin driven to a level when its output is tripped.
+type SafePin struct {
+	Pin   machine.Pin
+	Level bool
+}
+
+// SupervisorConfig configures a supervised output.
+type SupervisorConfig struct {
+	// Safe holds the pins of the output and their safe levels, i.e. motor PWM low
+	// and a dimmer's triac gate off.
+	Safe []SafePin
+	// Timeout is how long a state machine or DMA feeder may go without progress,
+	// 100ms if 0. Check must be called several times per timeout.
+	Timeout time.Duration
+	// Underrun trips the output when a state machine stalls on an empty TX FIFO,
+	// for outputs which must be fed without gaps.
+	Underrun bool
+	// DMA trips the output when a DMA channel of its placement stops transferring,
+	// for outputs fed continuously by DMA. Drivers starting a transfer per write
+	// leave their channels idle in between and should not set it.
+	DMA bool
+}
+
+// supervisedOutput is an output watched by a Supervisor.
+type supervisedOutput struct {
+	cfg   SupervisorConfig
+	sms   []pio.StateMachine
+	dma   []dmaChannel
+	armed bool
+	fault SupervisorFault
+	// fixedPC has bit i set if state machine i runs a single instruction program.
+	fixedPC uint8
+	// Last program counter of each state machine and progress of each DMA
+	// channel, and when they last moved.
+	pcs      []uint8
+	pcMoved  []time.Time
+	dmaProg  []dmaProgress
+	dmaMoved []time.Time
+}
+
+// dmaProgress is a snapshot of the registers a DMA channel advances as it
+// transfers. A channel reading from a fixed address, such as a FIFO, only moves
+// its write address, and one writing to a FIFO only its read address, while
+// rings wrap their addresses back: the transfer count moves in all cases.
+type dmaProgress struct {
+	read, write, count uint32
+}
+
+// readDMAProgress returns the progress registers of ch.
+func readDMAProgress(ch dmaChannel) dmaProgress {
+	hw := ch.HW()
+	return dmaProgress{read: hw.READ_ADDR.Get(), write: hw.WRITE_ADDR.Get(), count: hw.TRANS_COUNT.Get()}
+}
+
+// Supervisor is a backstop for critical outputs driven by state machines, such as
+// motor PWM or mains dimmers. It checks that the state machines of each output are
+// enabled and running and that their DMA feeders keep transferring. On a fault it
+// drives the pins of the output to their safe levels from the CPU, halts its state
+// machines, aborts its DMA channels and calls the fault callback.
+//
+// Supervision is done by Check, which must be called regularly, i.e. from a
+// ticker in its own goroutine. It does not replace the hardware watchdog, which
+// should be fed after each Check for a hung CPU to reset the chip.
+type Supervisor struct {
+	outputs []supervisedOutput
+	onFault func(output int, fault SupervisorFault)
+}
+
+// NewSupervisor returns a supervisor calling onFault, if not nil, each time an
+// output is tripped.
+func NewSupervisor(onFault func(output int, fault SupervisorFault)) *Supervisor {
+	return &Supervisor{onFault: onFault}
+}
+
+// Watch adds the output of the driver with placement p, as returned by its
+// Placement method, and returns the index of the output. The output is armed.
+func (s *Supervisor) Watch(p Placement, cfg SupervisorConfig) int {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = supervisorDefaultTimeout
+	}
+	blocks := [2]*pio.PIO{pio.PIO0, pio.PIO1}
+	o := supervisedOutput{cfg: cfg}
+	for _, smp := range p.StateMachines {
+		if smp.ProgramLen <= 1 {
+			// A single instruction program never moves its program counter.
+			o.fixedPC |= 1 << len(o.sms)
+		}
+		o.sms = append(o.sms, blocks[smp.Block].StateMachine(smp.StateMachine))
+	}
+	if cfg.DMA {
+		for _, idx := range p.DMAChannels {
+			o.dma = append(o.dma, _DMA.Channel(idx))
+		}
+	}
+	o.pcs = make([]uint8, len(o.sms))
+	o.pcMoved = make([]time.Time, len(o.sms))
+	o.dmaProg = make([]dmaProgress, len(o.dma))
+	o.dmaMoved = make([]time.Time, len(o.dma))
+	s.outputs = append(s.outputs, o)
+	i := len(s.outputs) - 1
+	s.SetArmed(i, true)
+	return i
+}
+
+// SetArmed arms or disarms output i. Disarmed outputs are not checked, for drivers
+// stopped on purpose. Arming clears the fault of the output: a tripped driver must
+// be created again beforehand to take its pins back.
+func (s *Supervisor) SetArmed(i int, armed bool) {
+	o := &s.outputs[i]
+	o.armed = armed
+	if !armed {
+		return
+	}
+	o.fault = SupervisorOK
+	now := time.Now()
+	for j, sm := range o.sms {
+		sm.ClearTxStalled()
+		o.pcs[j], o.pcMoved[j] = uint8(sm.HW().ADDR.Get()), now
+	}
+	for j, ch := range o.dma {
+		o.dmaProg[j], o.dmaMoved[j] = readDMAProgress(ch), now
+	}
+}
+
+// Fault returns the fault of output i, SupervisorOK if it was not tripped.
+func (s *Supervisor) Fault(i int) SupervisorFault {
+	return s.outputs[i].fault
+}
+
+// Check checks the armed outputs and trips the faulty ones. It returns false if
+// any output is tripped, including outputs tripped by earlier calls.
+func (s *Supervisor) Check() bool {
+	now := time.Now()
+	ok := true
+	for i := range s.outputs {
+		o := &s.outputs[i]
+		if o.armed && o.fault == SupervisorOK {
+			if fault := o.check(now); fault != SupervisorOK {
+				s.trip(i, fault)
+			}
+		}
+		if o.fault != SupervisorOK {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Trip puts output i in its safe state, i.e. on an emergency stop or when the
+// controlling logic detects a fault of its own.
+func (s *Supervisor) Trip(i int) {
+	if s.outputs[i].fault == SupervisorOK {
+		s.trip(i, SupervisorTripped)
+	}
+}
+
+// trip puts output i in its safe state and reports fault.
+func (s *Supervisor) trip(i int, fault SupervisorFault) {
+	o := &s.outputs[i]
+	o.fault = fault
+	// Pins first: the level is set before the pin is taken from the PIO so it
+	// doesn't glitch.
+	for _, sp := range o.cfg.Safe {
+		sp.Pin.Set(sp.Level)
+		sp.Pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		sp.Pin.Set(sp.Level)
+	}
+	for _, sm := range o.sms {
+		sm.SetEnabled(false)
+	}
+	for _, ch := range o.dma {
+		ch.abort()
+	}
+	if s.onFault != nil {
+		s.onFault(i, fault)
+	}
+}
+
+// check returns the fault of the output, if any.
+func (o *supervisedOutput) check(now time.Time) SupervisorFault {
+	for i, sm := range o.sms {
+		if !sm.IsEnabled() {
+			return SupervisorDisabled
+		}
+		if o.cfg.Underrun && sm.IsTxStalled() {
+			return SupervisorUnderrun
+		}
+		if o.fixedPC&(1<<i) != 0 {
+			continue
+		}
+		if pc := uint8(sm.HW().ADDR.Get()); pc != o.pcs[i] {
+			o.pcs[i], o.pcMoved[i] = pc, now
+		} else if now.Sub(o.pcMoved[i]) >= o.cfg.Timeout {
+			// A running program could be sampled on the same instruction every
+			// time, but not over the many checks of a timeout.
+			return SupervisorStalled
+		}
+	}
+	for i, ch := range o.dma {
+		if prog := readDMAProgress(ch); prog != o.dmaProg[i] {
+			o.dmaProg[i], o.dmaMoved[i] = prog, now
+		} else if now.Sub(o.dmaMoved[i]) >= o.cfg.Timeout {
+			return SupervisorDMAIdle
+		}
+	}
+	return SupervisorOK
+}
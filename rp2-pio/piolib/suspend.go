@@ -0,0 +1,58 @@
+//go:build rp2040
+
+package piolib
+
+import (
+	"device/rp"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// suspender halts the state machine of a bus driver and tri-states its pins for
+// hot-plugging, keeping what is needed to restore them. The state machine
+// configuration is left untouched.
+type suspender struct {
+	suspended bool
+	enabled   bool
+	// dirs holds the pin directions set by the state machine.
+	dirs uint32
+	// pads holds the pad control register of each pin.
+	pads [30]uint8
+}
+
+// suspend halts sm and turns the pins in mask to inputs with pulls off.
+// Calling it again before resume does nothing.
+func (s *suspender) suspend(sm pio.StateMachine, mask uint32) {
+	if s.suspended {
+		return
+	}
+	s.enabled = sm.IsEnabled()
+	sm.SetEnabled(false)
+	s.dirs = sm.PIO().HW().DBG_PADOE.Get() & mask
+	sm.SetPindirsMasked(0, mask)
+	for pin := machine.Pin(0); pin < machine.Pin(len(s.pads)); pin++ {
+		if mask&(1<<pin) == 0 {
+			continue
+		}
+		pad := pinPadCtrl(pin)
+		s.pads[pin] = uint8(pad.Get())
+		pad.ClearBits(rp.PADS_BANK0_GPIO0_PUE | rp.PADS_BANK0_GPIO0_PDE)
+	}
+	s.suspended = true
+}
+
+// resume restores the pins in mask and restarts sm if it was running when suspended.
+func (s *suspender) resume(sm pio.StateMachine, mask uint32) {
+	if !s.suspended {
+		return
+	}
+	for pin := machine.Pin(0); pin < machine.Pin(len(s.pads)); pin++ {
+		if mask&(1<<pin) != 0 {
+			pinPadCtrl(pin).Set(uint32(s.pads[pin]))
+		}
+	}
+	sm.SetPindirsMasked(s.dirs, mask)
+	sm.SetEnabled(s.enabled)
+	s.suspended = false
+}
@@ -0,0 +1,255 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errThermalRaster = errors.New("piolib:raster size invalid")
+
+// State machine frequency of the thermal printer parallel port, see thermalprinter.pio.
+const thermalPrinterFreq = 2_000_000
+
+// ESC/POS command bytes.
+const (
+	escposESC = 0x1b
+	escposGS  = 0x1d
+	escposLF  = 0x0a
+)
+
+// ThermalAlign is the justification of printed text.
+type ThermalAlign uint8
+
+const (
+	ThermalAlignLeft ThermalAlign = iota
+	ThermalAlignCenter
+	ThermalAlignRight
+)
+
+// ThermalPrinter drives an ESC/POS thermal printer or printer mechanism, either
+// through a strobed 8 bit parallel port with BUSY handshake run by a state machine
+// or through a PIO UART. Raster images are sent a dot line at a time, by DMA on
+// the parallel port, with a minimum time per line so mechanisms without a BUSY
+// output are given time to heat and advance the paper.
+type ThermalPrinter struct {
+	sm       pio.StateMachine
+	offset   uint8
+	dma      dmaChannel
+	uart     *UART
+	dl       deadliner
+	lineTime time.Duration
+}
+
+// NewThermalPrinter creates a printer on a parallel port with D0..D7 on pins base
+// to base+7, the active low strobe on strobe and the BUSY input on busy. Each
+// byte waits for BUSY low, so the printer paces the transfer. One DMA channel is
+// claimed.
+func NewThermalPrinter(sm pio.StateMachine, base, strobe, busy machine.Pin) (*ThermalPrinter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	whole, frac, err := pio.ClkDivFromFrequency(thermalPrinterFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	dma, ok := _DMA.ClaimChannelFor("ThermalPrinter")
+	if !ok {
+		return nil, errDMAUnavail
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(thermalprinterInstructions, thermalprinterOrigin)
+	if err != nil {
+		dma.Unclaim()
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for pin := base; pin < base+8; pin++ {
+		pin.Configure(pinCfg)
+	}
+	strobe.Configure(pinCfg)
+	busy.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
+	sm.SetPinsConsecutive(strobe, 1, true)
+	sm.SetPindirsConsecutive(strobe, 1, true)
+	sm.SetPindirsConsecutive(base, 8, true)
+
+	cfg := thermalprinterProgramDefaultConfig(offset)
+	cfg.SetOutPins(base, 8)
+	cfg.SetSidesetPins(strobe)
+	cfg.SetInPins(busy)
+	cfg.SetOutShift(true, true, 8)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, thermalPrinterFreq)
+	sm.SetEnabled(true)
+	return &ThermalPrinter{sm: sm, offset: offset, dma: dma}, nil
+}
+
+// NewThermalPrinterUART creates a printer sending ESC/POS over u, for printers with
+// a serial interface. Flow control is left to the baud rate: the printer must keep
+// up with it or SetLineTime must slow raster printing down.
+func NewThermalPrinterUART(u *UART) *ThermalPrinter {
+	return &ThermalPrinter{uart: u}
+}
+
+// SetTimeout sets the timeout for the printer to take data. Use 0 as argument to
+// disable timeouts.
+func (tp *ThermalPrinter) SetTimeout(timeout time.Duration) {
+	tp.dl.setTimeout(timeout)
+	tp.dma.dl.setTimeout(timeout)
+	if tp.uart != nil {
+		tp.uart.SetTimeout(timeout)
+	}
+}
+
+// SetLineTime sets the minimum time per dot line of raster images, from the start
+// of a line to the start of the next. Use 0, the default, to send lines as fast as
+// the interface allows.
+func (tp *ThermalPrinter) SetLineTime(d time.Duration) {
+	tp.lineTime = d
+}
+
+// Write sends raw bytes, text or ESC/POS commands, to the printer.
+func (tp *ThermalPrinter) Write(p []byte) (int, error) {
+	if err := tp.send(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Reset sends ESC @, clearing the print buffer and restoring the default modes.
+func (tp *ThermalPrinter) Reset() error {
+	return tp.send([]byte{escposESC, '@'})
+}
+
+// Println prints s followed by a line feed.
+func (tp *ThermalPrinter) Println(s string) error {
+	if err := tp.send([]byte(s)); err != nil {
+		return err
+	}
+	return tp.send([]byte{escposLF})
+}
+
+// Feed prints the buffer and feeds the paper by lines text lines.
+func (tp *ThermalPrinter) Feed(lines uint8) error {
+	return tp.send([]byte{escposESC, 'd', lines})
+}
+
+// SetBold turns emphasized text on or off.
+func (tp *ThermalPrinter) SetBold(bold bool) error {
+	var n byte
+	if bold {
+		n = 1
+	}
+	return tp.send([]byte{escposESC, 'E', n})
+}
+
+// SetAlign sets the justification of the following lines.
+func (tp *ThermalPrinter) SetAlign(align ThermalAlign) error {
+	return tp.send([]byte{escposESC, 'a', uint8(align)})
+}
+
+// SetTextSize sets the character width and height multipliers, 1 to 8.
+func (tp *ThermalPrinter) SetTextSize(width, height uint8) error {
+	return tp.send([]byte{escposGS, '!', (width-1)&7<<4 | (height-1)&7})
+}
+
+// Cut cuts the paper on printers with an auto cutter.
+func (tp *ThermalPrinter) Cut() error {
+	return tp.send([]byte{escposGS, 'V', 0})
+}
+
+// PrintRaster prints a bitmap of width dots, a multiple of 8, with GS v 0. img holds
+// the dot lines one after the other, 1 bit per dot with the leftmost dot in the
+// most significant bit and set bits printed black.
+func (tp *ThermalPrinter) PrintRaster(img []byte, width int) error {
+	lineBytes := width / 8
+	if width <= 0 || width%8 != 0 || len(img)%lineBytes != 0 || lineBytes > 0xffff {
+		return errThermalRaster
+	}
+	lines := len(img) / lineBytes
+	if lines > 0xffff {
+		return errThermalRaster
+	}
+	err := tp.send([]byte{escposGS, 'v', '0', 0,
+		byte(lineBytes), byte(lineBytes >> 8), byte(lines), byte(lines >> 8)})
+	if err != nil {
+		return err
+	}
+	for i := 0; i < lines; i++ {
+		start := time.Now()
+		if err := tp.send(img[i*lineBytes : (i+1)*lineBytes]); err != nil {
+			return err
+		}
+		if tp.lineTime > 0 {
+			if d := tp.lineTime - time.Since(start); d > 0 {
+				time.Sleep(d)
+			}
+		}
+	}
+	return nil
+}
+
+// StartWrite starts sending p to the printer on the parallel port like Write, by
+// DMA, and returns without waiting, the printer pacing the transfer with BUSY. The
+// operation is done once the last byte is in the FIFO. p must not be modified
+// until the operation is done. On timeout or cancellation the bytes left are
+// dropped. Printers on a UART have no DMA channel: use Write.
+func (tp *ThermalPrinter) StartWrite(p []byte) *DMAOp {
+	if tp.uart != nil {
+		return &DMAOp{err: errDMAUnavail, done: true}
+	}
+	op := dmaStartPushAddr(tp.dma, tp.sm.TxRegAddr(), p, dmaPIO_TxDREQ(tp.sm))
+	op.stop = tp.reset
+	return &op
+}
+
+// send writes p to the UART, or by DMA to the parallel port and waits for the
+// state machine to take the last byte.
+func (tp *ThermalPrinter) send(p []byte) error {
+	if tp.uart != nil {
+		_, err := tp.uart.Write(p)
+		return err
+	}
+	if len(p) == 0 {
+		return nil
+	}
+	err := dmaPushAddr(tp.dma, tp.sm.TxRegAddr(), p, dmaPIO_TxDREQ(tp.sm))
+	if err != nil {
+		tp.reset()
+		return err
+	}
+	dl := tp.dl.newDeadline()
+	for !tp.sm.IsTxFIFOEmpty() {
+		if dl.expired() {
+			tp.reset()
+			return errTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// reset aborts a transfer, dropping the bytes not taken by the printer.
+func (tp *ThermalPrinter) reset() {
+	stopStreaming(tp.sm, tp.dma)
+	tp.sm.Restart()
+	tp.sm.Jmp(tp.offset, pio.JmpAlways)
+	tp.sm.SetEnabled(true)
+}
+
+// Placement returns the state machine, program and DMA channel used by the
+// parallel port, or the resources of the UART.
+func (tp *ThermalPrinter) Placement() Placement {
+	if tp.uart != nil {
+		return tp.uart.Placement()
+	}
+	var p Placement
+	p.addSM(tp.sm, tp.offset, thermalprinterInstructions)
+	p.addDMA(tp.dma)
+	return p
+}
@@ -0,0 +1,179 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errTM16xxNoAck   = errors.New("piolib:TM16xx no acknowledge")
+	errTM16xxAddress = errors.New("piolib:TM16xx display address out of range")
+)
+
+// Commands shared by the TM16xx family of LED drivers.
+const (
+	tm16xxCmdData      = 0x40 // Write display data with automatic address increment.
+	tm16xxCmdDataFixed = 0x44 // Write display data at a fixed address.
+	tm16xxCmdReadKeys  = 0x42
+	tm16xxCmdAddress   = 0xc0 // Lower bits hold the display address.
+	tm16xxCmdDisplay   = 0x80 // Bit 3 turns the display on, bits 0..2 set the brightness.
+	// TM16xx clock frequency. Both chips allow faster clocks but the TM1637
+	// relies on pull-up resistors to release DIO.
+	tm16xxBaud = 100_000
+)
+
+// tm16xxFont holds the segments of hexadecimal digits. Bits 0..6 map to segments a..g
+// and bit 7 to the decimal point or colon.
+var tm16xxFont = [16]byte{
+	0x3f, 0x06, 0x5b, 0x4f, 0x66, 0x6d, 0x7d, 0x07,
+	0x7f, 0x6f, 0x77, 0x7c, 0x39, 0x5e, 0x79, 0x71,
+}
+
+// TM1637 drives 7 segment displays with a TM1637 LED controller, commonly
+// found on 4 digit clock displays. DIO needs a pull-up resistor, which
+// most modules have on board.
+type TM1637 struct {
+	sm     pio.StateMachine
+	offset uint8
+	dio    machine.Pin
+	dl     deadliner
+	buf    [7]byte
+}
+
+// NewTM1637 returns a TM1637 driver with clock on clk and data on dio. The
+// display stays off until SetBrightness is called.
+func NewTM1637(sm pio.StateMachine, clk, dio machine.Pin) (*TM1637, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	// A bit takes 8 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(tm16xxBaud*8, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(tm1637Instructions, tm1637Origin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	clk.Configure(pinCfg)
+	dio.Configure(pinCfg)
+	sm.SetPinsConsecutive(clk, 1, true)
+	sm.SetPindirsConsecutive(clk, 1, true)
+	// DIO is open drain: driven low when it is an output, released otherwise.
+	sm.SetPinsConsecutive(dio, 1, false)
+	sm.SetPindirsConsecutive(dio, 1, false)
+	cfg := tm1637ProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(clk)
+	cfg.SetOutPins(dio, 1)
+	cfg.SetSetPins(dio, 1)
+	cfg.SetInPins(dio)
+	cfg.SetOutShift(true, false, 32)
+	cfg.SetInShift(false, false, 32)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, tm16xxBaud*8)
+	sm.SetEnabled(true)
+	return &TM1637{sm: sm, offset: offset, dio: dio}, nil
+}
+
+// SetTimeout sets the timeout for transfers. Use 0 as argument to disable timeouts.
+func (d *TM1637) SetTimeout(timeout time.Duration) {
+	d.dl.setTimeout(timeout)
+}
+
+// SetBrightness turns the display off for level 0 or sets the brightness in
+// levels 1 to 8, the maximum, turning the display on.
+func (d *TM1637) SetBrightness(level uint8) error {
+	return d.write(tm16xxDisplayCmd(level))
+}
+
+// SetSegments writes the segments of consecutive digits starting at digit pos.
+// Bits 0..6 of each byte map to segments a..g and bit 7 to the decimal point,
+// or the colon on clock displays. The TM1637 has 6 digit positions.
+func (d *TM1637) SetSegments(pos uint8, segments []byte) error {
+	if int(pos)+len(segments) > len(d.buf)-1 {
+		return errTM16xxAddress
+	}
+	if err := d.write(tm16xxCmdData); err != nil {
+		return err
+	}
+	d.buf[0] = tm16xxCmdAddress | pos
+	n := copy(d.buf[1:], segments)
+	return d.write(d.buf[:1+n]...)
+}
+
+// SetDigit displays the hexadecimal digit value in the range 0..15 at digit pos,
+// with the decimal point or colon if dot is set.
+func (d *TM1637) SetDigit(pos, value uint8, dot bool) error {
+	return d.SetSegments(pos, []byte{tm16xxDigit(value, dot)})
+}
+
+// Clear blanks all digits.
+func (d *TM1637) Clear() error {
+	var blank [6]byte
+	return d.SetSegments(0, blank[:])
+}
+
+// write sends data as a single frame between a start and a stop condition.
+func (d *TM1637) write(data ...byte) error {
+	var err error
+	dl := d.dl.newDeadline()
+	for i, b := range data {
+		word := uint32(^b) // A 1 bit releases DIO.
+		if i < len(data)-1 {
+			word |= 1 << 8
+		}
+		d.sm.TxPut(word)
+		for d.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				d.reset()
+				return errTimeout
+			}
+			gosched()
+		}
+		if d.sm.RxGet()&1 != 0 && err == nil {
+			// Finish the frame so the bus is left idle.
+			err = errTM16xxNoAck
+		}
+	}
+	return err
+}
+
+// reset aborts a transfer and releases the bus.
+func (d *TM1637) reset() {
+	d.sm.ClearFIFOs()
+	d.sm.Restart()
+	d.sm.SetPindirsConsecutive(d.dio, 1, false)
+	d.sm.Jmp(d.offset, pio.JmpAlways)
+}
+
+// tm16xxDisplayCmd returns the display control command for brightness level 0..8.
+func tm16xxDisplayCmd(level uint8) byte {
+	if level == 0 {
+		return tm16xxCmdDisplay
+	}
+	if level > 8 {
+		level = 8
+	}
+	return tm16xxCmdDisplay | 1<<3 | (level - 1)
+}
+
+func tm16xxDigit(value uint8, dot bool) byte {
+	segments := tm16xxFont[value&0xf]
+	if dot {
+		segments |= 1 << 7
+	}
+	return segments
+}
+
+// Placement returns the state machine and program used by the display.
+func (d *TM1637) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, tm1637Instructions)
+	return p
+}
@@ -0,0 +1,173 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// Display RAM layout of the TM1638: even addresses hold digit segments and odd
+// addresses drive LEDs, as on the common LED&KEY boards.
+const (
+	tm1638Digits   = 8
+	tm1638KeyBytes = 4
+)
+
+// TM1638 drives 7 segment displays and LEDs with a TM1638 LED controller and
+// scans its key matrix, as found on LED&KEY boards.
+type TM1638 struct {
+	sm     pio.StateMachine
+	offset uint8
+	stb    machine.Pin
+	dl     deadliner
+	buf    [2]byte
+}
+
+// NewTM1638 returns a TM1638 driver with strobe on stb, clock on clk and data on dio.
+// The display stays off until SetBrightness is called.
+func NewTM1638(sm pio.StateMachine, stb, clk, dio machine.Pin) (*TM1638, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	// A bit takes 8 cycles.
+	whole, frac, err := pio.ClkDivFromFrequency(tm16xxBaud*8, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(tm1638Instructions, tm1638Origin)
+	if err != nil {
+		return nil, err
+	}
+	stb.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	stb.High()
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	clk.Configure(pinCfg)
+	dio.Configure(pinCfg)
+	sm.SetPinsConsecutive(clk, 1, true)
+	sm.SetPindirsConsecutive(clk, 1, true)
+	sm.SetPindirsConsecutive(dio, 1, false)
+	cfg := tm1638ProgramDefaultConfig(offset)
+	cfg.SetSidesetPins(clk)
+	cfg.SetOutPins(dio, 1)
+	cfg.SetInPins(dio)
+	cfg.SetOutShift(true, false, 32)
+	cfg.SetInShift(true, false, 32)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, tm16xxBaud*8)
+	sm.SetEnabled(true)
+	return &TM1638{sm: sm, offset: offset, stb: stb}, nil
+}
+
+// SetTimeout sets the timeout for transfers. Use 0 as argument to disable timeouts.
+func (d *TM1638) SetTimeout(timeout time.Duration) {
+	d.dl.setTimeout(timeout)
+}
+
+// SetBrightness turns the display off for level 0 or sets the brightness in
+// levels 1 to 8, the maximum, turning the display on.
+func (d *TM1638) SetBrightness(level uint8) error {
+	d.buf[0] = tm16xxDisplayCmd(level)
+	return d.frame(d.buf[:1], nil)
+}
+
+// SetSegments writes the segments of consecutive digits starting at digit pos. Bits 0..6
+// of each byte map to segments a..g and bit 7 to the decimal point. LEDs are unaffected.
+func (d *TM1638) SetSegments(pos uint8, segments []byte) error {
+	if int(pos)+len(segments) > tm1638Digits {
+		return errTM16xxAddress
+	}
+	for i, seg := range segments {
+		if err := d.writeFixed(2*(pos+uint8(i)), seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetDigit displays the hexadecimal digit value in the range 0..15 at digit pos,
+// with the decimal point if dot is set.
+func (d *TM1638) SetDigit(pos, value uint8, dot bool) error {
+	return d.SetSegments(pos, []byte{tm16xxDigit(value, dot)})
+}
+
+// SetLED turns the LED next to digit i on or off.
+func (d *TM1638) SetLED(i uint8, on bool) error {
+	if i >= tm1638Digits {
+		return errTM16xxAddress
+	}
+	var value byte
+	if on {
+		value = 1
+	}
+	return d.writeFixed(2*i+1, value)
+}
+
+// Clear blanks all digits and turns off all LEDs.
+func (d *TM1638) Clear() error {
+	d.buf[0] = tm16xxCmdData
+	if err := d.frame(d.buf[:1], nil); err != nil {
+		return err
+	}
+	var blank [1 + 2*tm1638Digits]byte
+	blank[0] = tm16xxCmdAddress
+	return d.frame(blank[:], nil)
+}
+
+// Keys scans the key matrix and returns the 4 key bytes read from the TM1638 in
+// bits 0..31, the first byte in bits 0..7. Key K(n) on segment line KS(2i+1) is bit
+// 8i+3-n and on KS(2i+2) bit 8i+7-n. On LED&KEY boards, with all keys on K3,
+// button S(i+1) is bit 8i and S(i+5) is bit 8i+4 for i in 0..3.
+func (d *TM1638) Keys() (uint32, error) {
+	var keys [tm1638KeyBytes]byte
+	d.buf[0] = tm16xxCmdReadKeys
+	err := d.frame(d.buf[:1], keys[:])
+	return uint32(keys[0]) | uint32(keys[1])<<8 | uint32(keys[2])<<16 | uint32(keys[3])<<24, err
+}
+
+// writeFixed writes a single byte of display RAM at addr.
+func (d *TM1638) writeFixed(addr, value byte) error {
+	d.buf[0] = tm16xxCmdDataFixed
+	if err := d.frame(d.buf[:1], nil); err != nil {
+		return err
+	}
+	d.buf = [2]byte{tm16xxCmdAddress | addr, value}
+	return d.frame(d.buf[:], nil)
+}
+
+// frame writes w and then reads len(r) bytes into r with STB held low.
+func (d *TM1638) frame(w, r []byte) error {
+	d.stb.Low()
+	defer d.stb.High()
+	dl := d.dl.newDeadline()
+	for i := 0; i < len(w)+len(r); i++ {
+		var word uint32 // Reads release DIO.
+		if i < len(w) {
+			word = uint32(w[i])<<1 | 1
+		}
+		d.sm.TxPut(word)
+		for d.sm.IsRxFIFOEmpty() {
+			if dl.expired() {
+				d.sm.ClearFIFOs()
+				d.sm.Restart()
+				d.sm.Jmp(d.offset, pio.JmpAlways)
+				return errTimeout
+			}
+			gosched()
+		}
+		data := d.sm.RxGet()
+		if i >= len(w) {
+			r[i-len(w)] = byte(data >> 24)
+		}
+	}
+	return nil
+}
+
+// Placement returns the state machine and program used by the display.
+func (d *TM1638) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, tm1638Instructions)
+	return p
+}
@@ -0,0 +1,194 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+)
+
+var (
+	errToFModel     = errors.New("piolib:ToF sensor not found at default address")
+	errToFInterrupt = errors.New("piolib:ToF needs one interrupt pin per sensor")
+)
+
+// VL53L0X registers used by ToFArray.
+const (
+	tofDefaultAddr = 0x29
+	tofModelID     = 0xee
+
+	tofRegSysrangeStart        = 0x00
+	tofRegInterruptConfigGPIO  = 0x0a
+	tofRegInterruptClear       = 0x0b
+	tofRegResultInterrupt      = 0x13
+	tofRegResultRange          = 0x1e // 16 bit range in millimeters of the last measurement.
+	tofRegGPIOActiveHigh       = 0x84
+	tofRegDeviceAddress        = 0x8a
+	tofRegIdentificationModel  = 0xc0
+	tofSysrangeBackToBack      = 0x02
+	tofInterruptNewSampleReady = 0x04
+
+	// Time for a sensor to boot after XSHUT is released, 1.2ms max.
+	tofBootTime = 2 * time.Millisecond
+)
+
+// ToFArray manages several VL53L0X-style time-of-flight distance sensors sharing
+// an I2C bus. These sensors all boot with the same I2C address, so each one has
+// its XSHUT pin wired to a GPIO: the sensors are held in reset and released one
+// at a time to give each a unique address. Sensors are then polled round-robin,
+// optionally skipping those whose interrupt pin shows no new measurement.
+//
+// ToFArray only starts and reads continuous ranging with the configuration the
+// sensors boot with. For calibrated or tuned measurements, configure each sensor
+// at Address(i) with a full driver over the same bus before calling Start.
+type ToFArray struct {
+	bus        *I2C
+	xshut      []machine.Pin
+	interrupts []machine.Pin
+	firstAddr  uint8
+	distances  []uint16
+	next       int
+	err        error
+	buf        [2]byte
+}
+
+// NewToFArray resets the sensors with their XSHUT pin wired to xshut and assigns
+// them consecutive addresses starting at firstAddr, in the order of xshut.
+func NewToFArray(bus *I2C, xshut []machine.Pin, firstAddr uint8) (*ToFArray, error) {
+	t := &ToFArray{
+		bus:       bus,
+		xshut:     xshut,
+		firstAddr: firstAddr,
+		distances: make([]uint16, len(xshut)),
+	}
+	for _, pin := range xshut {
+		pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		pin.Low()
+	}
+	time.Sleep(tofBootTime)
+	for i, pin := range xshut {
+		pin.High()
+		time.Sleep(tofBootTime)
+		if err := bus.ReadRegister(tofDefaultAddr, tofRegIdentificationModel, t.buf[:1]); err != nil {
+			return nil, err
+		}
+		if t.buf[0] != tofModelID {
+			return nil, errToFModel
+		}
+		if err := t.writeReg(tofDefaultAddr, tofRegDeviceAddress, t.Address(i)&0x7f); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Address returns the I2C address assigned to sensor i.
+func (t *ToFArray) Address(i int) uint8 {
+	return t.firstAddr + uint8(i)
+}
+
+// SetInterruptPins sets the pins wired to the GPIO1 interrupt output of each sensor,
+// in the order of the XSHUT pins. Sensors are then only read when their interrupt
+// pin signals a new measurement, sparing most bus transfers. Must be called before Start.
+func (t *ToFArray) SetInterruptPins(pins ...machine.Pin) error {
+	if len(pins) != len(t.xshut) {
+		return errToFInterrupt
+	}
+	for i, pin := range pins {
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+		addr := t.Address(i)
+		err := t.writeReg(addr, tofRegInterruptConfigGPIO, tofInterruptNewSampleReady)
+		if err != nil {
+			return err
+		}
+		// Make the interrupt output active low.
+		err = t.bus.ReadRegister(addr, tofRegGPIOActiveHigh, t.buf[:1])
+		if err == nil {
+			err = t.writeReg(addr, tofRegGPIOActiveHigh, t.buf[0]&^(1<<4))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	t.interrupts = pins
+	return nil
+}
+
+// Start starts continuous back-to-back ranging on all sensors.
+func (t *ToFArray) Start() error {
+	for i := range t.xshut {
+		addr := t.Address(i)
+		err := t.writeReg(addr, tofRegInterruptClear, 1)
+		if err == nil {
+			err = t.writeReg(addr, tofRegSysrangeStart, tofSysrangeBackToBack)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Distances checks each sensor once for a new measurement and returns the last
+// distance measured by each in millimeters, 0 if none yet. The sensors report
+// about 8190 when nothing is in range. The returned slice is reused by later
+// calls. Sensors failing to respond keep their last distance, see Err.
+func (t *ToFArray) Distances() []uint16 {
+	for range t.distances {
+		i := t.next
+		t.next = (t.next + 1) % len(t.distances)
+		if err := t.poll(i); err != nil {
+			t.err = err
+		}
+	}
+	return t.distances
+}
+
+// Err returns the first error since the last call to Err, or nil.
+func (t *ToFArray) Err() error {
+	err := t.err
+	t.err = nil
+	return err
+}
+
+// Shutdown puts all sensors back in reset. They must be readdressed with
+// NewToFArray to be used again.
+func (t *ToFArray) Shutdown() {
+	for _, pin := range t.xshut {
+		pin.Low()
+	}
+}
+
+// Placement returns the state machine and program of the I2C bus the sensors
+// are polled over.
+func (t *ToFArray) Placement() Placement {
+	return t.bus.Placement()
+}
+
+// poll reads the measurement of sensor i if a new one is ready.
+func (t *ToFArray) poll(i int) error {
+	addr := t.Address(i)
+	if t.interrupts != nil {
+		if t.interrupts[i].Get() {
+			return nil
+		}
+	} else {
+		if err := t.bus.ReadRegister(addr, tofRegResultInterrupt, t.buf[:1]); err != nil {
+			return err
+		}
+		if t.buf[0]&0x07 == 0 {
+			return nil
+		}
+	}
+	if err := t.bus.ReadRegister(addr, tofRegResultRange, t.buf[:2]); err != nil {
+		return err
+	}
+	t.distances[i] = uint16(t.buf[0])<<8 | uint16(t.buf[1])
+	return t.writeReg(addr, tofRegInterruptClear, 1)
+}
+
+func (t *ToFArray) writeReg(addr, reg, value uint8) error {
+	t.buf[0] = value
+	return t.bus.WriteRegister(addr, reg, t.buf[:1])
+}
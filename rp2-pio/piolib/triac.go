@@ -0,0 +1,114 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// State machine frequency of the triac dimmer, the delay has 1µs resolution.
+	triacFreq = 1_000_000
+	// Cycles from the zero crossing to the gate pulse not counted by the delay, see triac.pio.
+	triacOverhead = 3
+	// Gate pulse length and bounds of the firing delay in µs. Near the zero
+	// crossings the voltage is too low for the triac to latch, and a pulse too
+	// close to the next crossing would fire into the next half cycle.
+	triacGatePulse = 64
+	triacMinDelay  = 200
+	triacEndMargin = 300
+)
+
+// TriacDimmer dims resistive or dimmable loads on mains with a triac by phase
+// angle control: after each zero crossing of the mains voltage the triac is
+// fired with a delay set by the level, the load being powered for the rest of the
+// half cycle. The state machine detects the zero crossings and times the gate
+// pulse by itself, so the firing angle doesn't jitter with interrupt latency or
+// CPU load, which would make the load flicker.
+type TriacDimmer struct {
+	sm         pio.StateMachine
+	offset     uint8
+	halfPeriod uint32 // In µs.
+	level      uint8
+}
+
+// NewTriacDimmer starts a dimmer with the zero-cross detector output on zeroCross,
+// which must rise at each zero crossing, and the triac gate driver, i.e. an
+// optotriac, on gate. mainsHz is the mains frequency, 50 or 60Hz. The load
+// starts off.
+func NewTriacDimmer(sm pio.StateMachine, zeroCross, gate machine.Pin, mainsHz uint32) (*TriacDimmer, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if mainsHz == 0 {
+		return nil, errors.New("piolib:triac dimmer needs the mains frequency")
+	}
+	whole, frac, err := pio.ClkDivFromFrequency(triacFreq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(triacInstructions, triacOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	zeroCross.Configure(pinCfg)
+	gate.Configure(pinCfg)
+	sm.SetPindirsConsecutive(zeroCross, 1, false)
+	sm.SetPinsConsecutive(gate, 1, false)
+	sm.SetPindirsConsecutive(gate, 1, true)
+
+	cfg := triacProgramDefaultConfig(offset)
+	cfg.SetInPins(zeroCross)
+	cfg.SetSetPins(gate, 1)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, triacFreq)
+	sm.SetX(0) // Off.
+	sm.SetEnabled(true)
+	return &TriacDimmer{
+		sm:         sm,
+		offset:     offset,
+		halfPeriod: triacFreq / (2 * mainsHz),
+	}, nil
+}
+
+// SetLevel sets the level in percent of the half cycle the load is powered, 0
+// being off and 100 fully on. The power delivered is not linear with the level:
+// it changes faster around 50%. The new level applies from the next half cycle.
+func (d *TriacDimmer) SetLevel(percent uint8) {
+	if percent > 100 {
+		percent = 100
+	}
+	d.level = percent
+	var delay uint32
+	if percent > 0 {
+		delay = d.halfPeriod * uint32(100-percent) / 100
+		maxDelay := d.halfPeriod - triacGatePulse - triacEndMargin
+		if delay > maxDelay {
+			delay = maxDelay
+		} else if delay < triacMinDelay {
+			delay = triacMinDelay
+		}
+		delay -= triacOverhead
+	}
+	if d.sm.IsTxFIFOFull() {
+		// Only the last level matters, drop those not picked up yet.
+		d.sm.ClearFIFOs()
+	}
+	d.sm.TxPut(delay)
+}
+
+// Level returns the level set by SetLevel.
+func (d *TriacDimmer) Level() uint8 {
+	return d.level
+}
+
+// Placement returns the state machine and program used by the dimmer.
+func (d *TriacDimmer) Placement() Placement {
+	var p Placement
+	p.addSM(d.sm, d.offset, triacInstructions)
+	return p
+}
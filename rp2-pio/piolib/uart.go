@@ -0,0 +1,404 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var (
+	errUARTFraming = errors.New("piolib:UART framing error")
+	errUARTBreak   = errors.New("piolib:UART break received")
+	errUARTNoPin   = errors.New("piolib:UART pin not configured")
+)
+
+var (
+	_ pio.TxStream[byte] = (*UART)(nil)
+	_ pio.RxStream[byte] = (*UART)(nil)
+)
+
+// Received bytes waiting in the RX FIFO from which RTS is deasserted, leaving room
+// for bytes sent before the remote device notices.
+const uartRTSThreshold = 4
+
+// UART is an 8n1 serial port implemented with one state machine for transmission
+// and one for reception. Unlike most hardware UARTs it can send breaks of any length
+// and tells breaks apart from other framing errors, as needed by LIN and similar buses.
+type UART struct {
+	tx, rx   pio.StateMachine
+	txPin    machine.Pin
+	rxPin    machine.Pin
+	rtsPin   machine.Pin
+	txOffset uint8
+	rxOffset uint8
+	txProg   []uint16
+	rxProg   []uint16
+	baud     uint32
+	dl       deadliner
+	txSusp   suspender
+	rxSusp   suspender
+	stats    fifoMonitor
+	guard    fifoGuard
+}
+
+// NewUART creates a UART transmitting on txPin with the txsm state machine and
+// receiving on rxPin with the rxsm state machine. Either pin can be machine.NoPin
+// for a receive or transmit only UART, in which case its state machine is not used.
+func NewUART(txsm, rxsm pio.StateMachine, txPin, rxPin machine.Pin, baud uint32) (*UART, error) {
+	return NewUARTFlowControl(txsm, rxsm, txPin, rxPin, machine.NoPin, machine.NoPin, baud)
+}
+
+// NewUARTFlowControl creates a UART like NewUART with RTS/CTS hardware flow control,
+// handled by the state machines: a byte is only sent while the CTS input is low and
+// the RTS output is held low while the RX FIFO has room for bytes. It keeps links
+// at 921600 baud and above to ESP-AT firmware and cellular modems from overrunning.
+// Either of cts and rts can be machine.NoPin to only control one direction.
+func NewUARTFlowControl(txsm, rxsm pio.StateMachine, txPin, rxPin, cts, rts machine.Pin, baud uint32) (*UART, error) {
+	u := &UART{tx: txsm, rx: rxsm, txPin: txPin, rxPin: rxPin, rtsPin: rts, baud: baud,
+		txProg: uart_txInstructions, rxProg: uart_rxInstructions}
+	if (cts != machine.NoPin && txPin == machine.NoPin) || (rts != machine.NoPin && rxPin == machine.NoPin) {
+		return nil, errUARTNoPin
+	}
+	whole, frac, err := u.clkDiv(baud)
+	if err != nil {
+		return nil, err
+	}
+	if txPin != machine.NoPin {
+		txsm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+		Pio := txsm.PIO()
+		origin := int8(uart_txOrigin)
+		if cts != machine.NoPin {
+			u.txProg, origin = uart_tx_ctsInstructions, uart_tx_ctsOrigin
+		}
+		u.txOffset, err = Pio.AddProgram(u.txProg, origin)
+		if err != nil {
+			return nil, err
+		}
+		// Idle high before the pin is handed to the PIO.
+		txsm.SetPinsConsecutive(txPin, 1, true)
+		txsm.SetPindirsConsecutive(txPin, 1, true)
+		txPin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+		cfg := uart_txProgramDefaultConfig(u.txOffset)
+		if cts != machine.NoPin {
+			// CTS idles high, deasserted, if the remote device is not connected.
+			cts.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+			cfg = uart_tx_ctsProgramDefaultConfig(u.txOffset)
+			cfg.SetInPins(cts)
+		}
+		cfg.SetOutPins(txPin, 1)
+		cfg.SetSidesetPins(txPin)
+		cfg.SetOutShift(true, false, 32)
+		// We only use Tx FIFO, so we set the join to Tx.
+		cfg.SetFIFOJoin(pio.FifoJoinTx)
+		cfg.SetClkDivIntFrac(whole, frac)
+		txsm.Init(u.txOffset, cfg)
+		trackClock(txsm, baud*8)
+		txsm.SetEnabled(true)
+	}
+	if rxPin != machine.NoPin {
+		rxsm.TryClaim()
+		Pio := rxsm.PIO()
+		origin := int8(uart_rxOrigin)
+		if rts != machine.NoPin {
+			u.rxProg, origin = uart_rx_rtsInstructions, uart_rx_rtsOrigin
+		}
+		u.rxOffset, err = Pio.AddProgram(u.rxProg, origin)
+		if err != nil {
+			if txPin != machine.NoPin {
+				txsm.SetEnabled(false)
+				freeProgram(txsm.PIO(), u.txOffset, u.txProg)
+			}
+			return nil, err
+		}
+		rxPin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+		rxsm.SetPindirsConsecutive(rxPin, 1, false)
+		cfg := uart_rxProgramDefaultConfig(u.rxOffset)
+		if rts != machine.NoPin {
+			// Deasserted until the state machine runs.
+			rxsm.SetPinsConsecutive(rts, 1, true)
+			rxsm.SetPindirsConsecutive(rts, 1, true)
+			rts.Configure(machine.PinConfig{Mode: Pio.PinMode()})
+			cfg = uart_rx_rtsProgramDefaultConfig(u.rxOffset)
+			cfg.SetSidesetPins(rts)
+			cfg.SetMovStatus(pio.MovStatusRxLessthan, uartRTSThreshold)
+		}
+		cfg.SetInPins(rxPin)
+		cfg.SetJmpPin(rxPin)
+		cfg.SetInShift(true, false, 32)
+		// We only use Rx FIFO, so we set the join to Rx.
+		cfg.SetFIFOJoin(pio.FifoJoinRx)
+		cfg.SetClkDivIntFrac(whole, frac)
+		rxsm.Init(u.rxOffset, cfg)
+		trackClock(rxsm, baud*8)
+		rxsm.SetEnabled(true)
+	}
+	return u, nil
+}
+
+// SetTimeout sets the timeout for reads and writes. Use 0 as argument to disable timeouts.
+func (u *UART) SetTimeout(timeout time.Duration) {
+	u.dl.setTimeout(timeout)
+}
+
+// SetBaudRate changes the baud rate. Bytes being transferred may be corrupted.
+func (u *UART) SetBaudRate(baud uint32) error {
+	whole, frac, err := u.clkDiv(baud)
+	if err != nil {
+		return err
+	}
+	u.baud = baud
+	if u.txPin != machine.NoPin {
+		u.tx.SetClkDiv(whole, frac)
+		trackClock(u.tx, baud*8)
+	}
+	if u.rxPin != machine.NoPin {
+		u.rx.SetClkDiv(whole, frac)
+		trackClock(u.rx, baud*8)
+	}
+	return nil
+}
+
+// Write transmits p, blocking until it has been queued for transmission.
+func (u *UART) Write(p []byte) (n int, err error) {
+	if u.txPin == machine.NoPin {
+		return 0, errUARTNoPin
+	}
+	dl := u.dl.newDeadline()
+	u.stats.begin(u.tx)
+	for n < len(p) {
+		if u.tx.IsTxFIFOFull() {
+			u.stats.txFull(u.tx)
+			if dl.expired() {
+				return n, errTimeout
+			}
+			gosched()
+			continue
+		}
+		u.tx.TxPut(uint32(p[n]))
+		u.stats.sample(u.tx)
+		n++
+	}
+	return n, u.guard.check(u.tx, n)
+}
+
+// WriteByte transmits a single byte.
+func (u *UART) WriteByte(b byte) error {
+	_, err := u.Write([]byte{b})
+	return err
+}
+
+// Put transmits a single byte, like WriteByte. It implements pio.TxStream.
+func (u *UART) Put(b byte) error {
+	return u.WriteByte(b)
+}
+
+// TryPut queues b for transmission if the TX FIFO is not full.
+func (u *UART) TryPut(b byte) bool {
+	return u.txPin != machine.NoPin && u.tx.TxPutFromISR(uint32(b))
+}
+
+// Flush blocks until all queued bytes have been transmitted including their stop bit.
+func (u *UART) Flush() error {
+	if u.txPin == machine.NoPin {
+		return errUARTNoPin
+	}
+	dl := u.dl.newDeadline()
+	for !u.tx.IsTxFIFOEmpty() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// Idle once the transmitter stalls waiting for data after the last byte.
+	u.tx.ClearTxStalled()
+	for !u.tx.IsTxStalled() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	// The stall happens at the start of the stop bit, wait it out.
+	time.Sleep(time.Second / time.Duration(u.baud))
+	return nil
+}
+
+// SendBreak holds the line low for bits bit times followed by a one bit time
+// delimiter at least. bits must be at least 9. Queued bytes are transmitted first.
+func (u *UART) SendBreak(bits uint8) error {
+	if bits < 9 {
+		return errors.New("piolib:UART break too short")
+	}
+	err := u.Flush()
+	if err != nil {
+		return err
+	}
+	// A zero byte is 9 low bit times including the start bit, stretch it by
+	// lowering the baud rate of the transmitter.
+	whole, frac, err := u.clkDiv(u.baud * 9 / uint32(bits))
+	if err != nil {
+		return err
+	}
+	u.tx.SetClkDiv(whole, frac)
+	u.tx.TxPut(0)
+	err = u.Flush()
+	whole, frac, _ = u.clkDiv(u.baud)
+	u.tx.SetClkDiv(whole, frac)
+	return err
+}
+
+// Buffered returns the number of received bytes waiting to be read.
+func (u *UART) Buffered() int {
+	if u.rxPin == machine.NoPin {
+		return 0
+	}
+	return int(u.rx.RxFIFOLevel())
+}
+
+// ReadByte blocks until a byte is received. A framing error or break is returned
+// as an error after the byte is consumed. An error of the FIFO guard is returned
+// before, leaving the byte to the next call.
+func (u *UART) ReadByte() (byte, error) {
+	if u.rxPin == machine.NoPin {
+		return 0, errUARTNoPin
+	}
+	dl := u.dl.newDeadline()
+	for u.rx.IsRxFIFOEmpty() {
+		u.stats.rxEmpty(u.rx)
+		if dl.expired() {
+			return 0, errTimeout
+		}
+		gosched()
+	}
+	// Words lost earlier don't make the waiting one invalid, report the loss
+	// without consuming it.
+	if err := u.guard.check(u.rx, 1); err != nil {
+		return 0, err
+	}
+	u.stats.sample(u.rx)
+	word := u.rx.RxGet()
+	b := byte(word >> 23)
+	if word&(1<<31) == 0 {
+		if b == 0 {
+			return 0, errUARTBreak
+		}
+		return b, errUARTFraming
+	}
+	return b, nil
+}
+
+// Get blocks until a byte is received, like ReadByte. It implements pio.RxStream.
+func (u *UART) Get() (byte, error) {
+	return u.ReadByte()
+}
+
+// TryGet returns a received byte if one is waiting. Bytes received with a framing
+// error or break are dropped.
+func (u *UART) TryGet() (byte, bool) {
+	for u.Buffered() > 0 {
+		b, err := u.ReadByte()
+		if err == nil {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+// Read blocks until at least one byte is received and reads up to len(p) bytes
+// without blocking further.
+func (u *UART) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && u.Buffered() == 0 {
+			break
+		}
+		p[n], err = u.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DiscardInput drops all received bytes waiting to be read.
+func (u *UART) DiscardInput() {
+	for u.Buffered() > 0 {
+		u.rx.RxGet()
+	}
+}
+
+func (u *UART) clkDiv(baud uint32) (whole uint16, frac uint8, err error) {
+	// Both programs take 8 cycles per bit.
+	return pio.ClkDivFromFrequency(baud*8, machine.CPUFrequency())
+}
+
+// Suspend halts the state machines and turns the UART pins to inputs with pulls
+// off, so the remote device can be hot-plugged or power-cycled safely. The
+// configuration is kept for Resume. Call Flush first so no byte is cut short,
+// bytes arriving while suspended are lost.
+func (u *UART) Suspend() {
+	if u.txPin != machine.NoPin {
+		u.txSusp.suspend(u.tx, 1<<u.txPin)
+	}
+	if u.rxPin != machine.NoPin {
+		u.rxSusp.suspend(u.rx, u.rxPinMask())
+	}
+}
+
+// Resume restores the pins and restarts the state machines halted by Suspend.
+func (u *UART) Resume() {
+	if u.txPin != machine.NoPin {
+		u.txSusp.resume(u.tx, 1<<u.txPin)
+	}
+	if u.rxPin != machine.NoPin {
+		u.rxSusp.resume(u.rx, u.rxPinMask())
+	}
+}
+
+// rxPinMask returns the pins driven or read by the receiver.
+func (u *UART) rxPinMask() uint32 {
+	mask := uint32(1) << u.rxPin
+	if u.rtsPin != machine.NoPin {
+		mask |= 1 << u.rtsPin
+	}
+	return mask
+}
+
+// SetFIFOGuard enables checking for words lost by the FIFOs after each Write and
+// ReadByte, which then return a *pio.FIFOError wrapping pio.ErrTxOverflow or pio.ErrRxUnderflow. It is
+// disabled by default.
+func (u *UART) SetFIFOGuard(enabled bool) {
+	u.guard.setEnabled(enabled, u.tx, u.rx)
+}
+
+// FIFOErrors returns the number of calls which lost words while the guard was
+// enabled.
+func (u *UART) FIFOErrors() (txOverflows, rxUnderflows uint32) {
+	return u.guard.counts()
+}
+
+// Stats returns the FIFO statistics gathered while EnableFIFOStats is set, the TX
+// fields being those of the transmitter and the RX fields those of the receiver.
+func (u *UART) Stats() FIFOStats {
+	return u.stats.stats
+}
+
+// ResetStats clears the FIFO statistics.
+func (u *UART) ResetStats() {
+	u.stats.stats = FIFOStats{}
+}
+
+// Placement returns the state machines and programs used by the UART, omitting the half without pin.
+func (u *UART) Placement() Placement {
+	var p Placement
+	if u.txPin != machine.NoPin {
+		p.addSM(u.tx, u.txOffset, u.txProg)
+	}
+	if u.rxPin != machine.NoPin {
+		p.addSM(u.rx, u.rxOffset, u.rxProg)
+	}
+	return p
+}
@@ -0,0 +1,204 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"math"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+// VU meter defaults, close to the PPM ballistics of analog meters.
+const (
+	vuMeterFloorDB = -40
+	vuMeterFall    = 1500 * time.Millisecond
+	vuMeterHold    = time.Second
+	// Shift clock of 74HC595 chains, slow enough for long ribbon cables. The
+	// program takes 4 cycles per bit.
+	vuMeter595Freq = 1000000
+)
+
+var errVUMeterLEDs = errors.New("piolib:VU meter must have 1..32 LEDs")
+
+// VUMeter shows an audio level on an LED bargraph of up to 32 LEDs on consecutive
+// pins, LED 0 being the lowest. Levels are shown in dB like an analog meter: the
+// bar rises instantly and falls back at a fixed rate, and a peak LED holds the
+// highest recent level before falling too. Frames are written to the pins by
+// the state machine so all LEDs change together.
+//
+// Levels typically come from the peak sample of each block of an I2S or ADC
+// capture, see Update.
+//
+// LEDs are driven directly from pins, see NewVUMeter, or through a chain of
+// 74HC595 shift registers for long bargraphs, see NewVUMeter595.
+type VUMeter struct {
+	sm      pio.StateMachine
+	offset  uint8
+	program []uint16
+	leds    uint8
+	// shift aligns frames to the MSB for the shift register program, 0 if direct.
+	shift   uint8
+	floorDB float64
+	fall    time.Duration // Time for the bar to fall through all LEDs.
+	hold    time.Duration
+	// bar and peak are in LEDs, fractional while falling.
+	bar    float64
+	peak   float64
+	peakAt time.Time
+	last   time.Time
+}
+
+// NewVUMeter returns a meter driving leds LEDs on consecutive pins from base,
+// lit when their pin is high. The meter shows -40dB to 0dB, see SetScale.
+func NewVUMeter(sm pio.StateMachine, base machine.Pin, leds uint8) (*VUMeter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if leds == 0 || leds > 32 {
+		return nil, errVUMeterLEDs
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(vumeterInstructions, vumeterOrigin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for pin := base; pin < base+machine.Pin(leds); pin++ {
+		pin.Configure(pinCfg)
+	}
+	sm.SetPinsConsecutive(base, leds, false)
+	sm.SetPindirsConsecutive(base, leds, true)
+
+	cfg := vumeterProgramDefaultConfig(offset)
+	cfg.SetOutPins(base, leds)
+	cfg.SetOutShift(true, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return newVUMeter(sm, offset, vumeterInstructions, leds, 0), nil
+}
+
+// NewVUMeter595 returns a meter driving leds LEDs through a chain of 74HC595 shift
+// registers with their serial input on data, shift clock on clock and latch clock
+// on latch. LED 0 is output QA of the register closest to the pins, LED 8 output
+// QA of the next one and so on. The meter shows -40dB to 0dB, see SetScale.
+func NewVUMeter595(sm pio.StateMachine, data, clock, latch machine.Pin, leds uint8) (*VUMeter, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	if leds == 0 || leds > 32 {
+		return nil, errVUMeterLEDs
+	}
+	whole, frac, err := pio.ClkDivFromFrequency(4*vuMeter595Freq, machine.CPUFrequency())
+	if err != nil {
+		return nil, err
+	}
+	Pio := sm.PIO()
+	offset, err := Pio.AddProgram(vumeter_595Instructions, vumeter_595Origin)
+	if err != nil {
+		return nil, err
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	for _, pin := range []machine.Pin{data, clock, latch} {
+		pin.Configure(pinCfg)
+		sm.SetPinsConsecutive(pin, 1, false)
+		sm.SetPindirsConsecutive(pin, 1, true)
+	}
+
+	cfg := vumeter_595ProgramDefaultConfig(offset)
+	cfg.SetOutPins(data, 1)
+	cfg.SetSidesetPins(clock)
+	cfg.SetSetPins(latch, 1)
+	// The last bit shifted ends up on LED 0, so shift the top LED first.
+	cfg.SetOutShift(false, false, 32)
+	// We only use Tx FIFO, so we set the join to Tx.
+	cfg.SetFIFOJoin(pio.FifoJoinTx)
+	cfg.SetClkDivIntFrac(whole, frac)
+	sm.Init(offset, cfg)
+	trackClock(sm, 4*vuMeter595Freq)
+	sm.TxPut(uint32(leds) - 1)
+	sm.SetEnabled(true)
+	return newVUMeter(sm, offset, vumeter_595Instructions, leds, 32-leds), nil
+}
+
+func newVUMeter(sm pio.StateMachine, offset uint8, program []uint16, leds, shift uint8) *VUMeter {
+	return &VUMeter{
+		sm:      sm,
+		offset:  offset,
+		program: program,
+		leds:    leds,
+		shift:   shift,
+		floorDB: vuMeterFloorDB,
+		fall:    vuMeterFall,
+		hold:    vuMeterHold,
+	}
+}
+
+// SetScale sets the level in dB, relative to full scale, of the lowest LED. The
+// top LED is full scale. floorDB must be negative.
+func (m *VUMeter) SetScale(floorDB float64) {
+	if floorDB < 0 {
+		m.floorDB = floorDB
+	}
+}
+
+// SetBallistics sets the time for the bar to fall from full scale to off and the
+// time the peak LED holds before falling. Defaults are 1.5s and 1s.
+func (m *VUMeter) SetBallistics(fall, hold time.Duration) {
+	m.fall = fall
+	m.hold = hold
+}
+
+// Update shows a new level, the peak absolute sample value of the last block of
+// samples with 65535 being full scale. The bar and peak fall according to the
+// time elapsed since the previous Update, which should be called at a steady
+// rate of 20 to 100 times per second for a smooth animation.
+func (m *VUMeter) Update(level uint16) {
+	now := time.Now()
+	target := m.levelLEDs(level)
+	// LEDs fallen since the last update.
+	fallen := float64(m.leds)
+	if m.fall > 0 {
+		fallen *= float64(now.Sub(m.last)) / float64(m.fall)
+	}
+	m.bar = math.Max(m.bar-fallen, 0)
+	if now.Sub(m.peakAt) > m.hold {
+		m.peak = math.Max(m.peak-fallen, 0)
+	}
+	m.last = now
+	if target > m.bar {
+		m.bar = target
+	}
+	if target >= m.peak {
+		m.peak = target
+		m.peakAt = now
+	}
+	m.sm.TxPut(m.frame() << m.shift)
+}
+
+// levelLEDs converts a level to the number of LEDs lit.
+func (m *VUMeter) levelLEDs(level uint16) float64 {
+	if level == 0 {
+		return 0
+	}
+	db := 20 * math.Log10(float64(level)/math.MaxUint16)
+	leds := float64(m.leds) * (1 - db/m.floorDB)
+	return math.Max(0, math.Min(leds, float64(m.leds)))
+}
+
+// frame returns the LEDs of the bar and peak as a bit per LED.
+func (m *VUMeter) frame() uint32 {
+	bar := uint(m.bar + 0.5)
+	frame := uint32(1)<<bar - 1 // All ones for 32 LEDs.
+	if peak := uint(m.peak + 0.5); peak > 0 {
+		frame |= 1 << (peak - 1)
+	}
+	return frame
+}
+
+// Placement returns the state machine and program used by the meter.
+func (m *VUMeter) Placement() Placement {
+	var p Placement
+	p.addSM(m.sm, m.offset, m.program)
+	return p
+}
@@ -0,0 +1,169 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+const (
+	// Cycles per period not accounted for by the wheelspeed count, see wheelspeed.pio.
+	wheelspeedOverhead = 8
+	// Default time without pulses after which a wheel is reported as stopped.
+	wheelspeedDefaultStop = time.Second
+)
+
+// WheelInput is the input of one wheel of a WheelSpeed sensor. Pulse is the
+// pulse output of the wheel's sensor. Direction is the second channel of an
+// A/B encoder used to infer the direction of rotation, or machine.NoPin.
+type WheelInput struct {
+	Pulse     machine.Pin
+	Direction machine.Pin
+}
+
+// WheelSpeed measures the speed of two wheels from the period of their pulse
+// signals, such as the left and right wheel of a robot. It suits low resolution
+// sensors where counting quadrature edges gives poor speed resolution at low
+// speeds. Each wheel is timed by a state machine at the CPU frequency. Pulses
+// shorter than the glitch filter are merged with the following period.
+type WheelSpeed struct {
+	wheels       [2]wheelChannel
+	pulsesPerRev uint32
+	glitch       uint64 // Minimum period in cycles.
+	stop         time.Duration
+}
+
+type wheelChannel struct {
+	sm       pio.StateMachine
+	offset   uint8
+	hasDir   bool
+	synced   bool      // Set once the first partial measurement was discarded.
+	period   uint64    // Last period in cycles, 0 if not measured yet.
+	pending  uint64    // Cycles of glitches merged into the next period.
+	reverse  bool      // Direction of the last period.
+	lastEdge time.Time // Time the last period was read.
+}
+
+// NewWheelSpeed returns a speed sensor for the left and right wheels measured by smLeft
+// and smRight. pulsesPerRev is the number of pulses per revolution of a wheel.
+func NewWheelSpeed(smLeft, smRight pio.StateMachine, left, right WheelInput, pulsesPerRev uint32) (*WheelSpeed, error) {
+	if pulsesPerRev == 0 {
+		return nil, errors.New("piolib:wheel speed needs pulses per revolution")
+	}
+	smLeft.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	smRight.TryClaim()
+	ws := &WheelSpeed{pulsesPerRev: pulsesPerRev, stop: wheelspeedDefaultStop}
+	offset, err := smLeft.PIO().AddProgram(wheelspeedInstructions, wheelspeedOrigin)
+	if err != nil {
+		return nil, err
+	}
+	rightOffset := offset
+	if smRight.PIO() != smLeft.PIO() {
+		rightOffset, err = smRight.PIO().AddProgram(wheelspeedInstructions, wheelspeedOrigin)
+		if err != nil {
+			freeProgram(smLeft.PIO(), offset, wheelspeedInstructions)
+			return nil, err
+		}
+	}
+	ws.wheels[0] = wheelspeedInit(smLeft, offset, left)
+	ws.wheels[1] = wheelspeedInit(smRight, rightOffset, right)
+	return ws, nil
+}
+
+func wheelspeedInit(sm pio.StateMachine, offset uint8, in WheelInput) wheelChannel {
+	Pio := sm.PIO()
+	dir := in.Direction
+	if dir == machine.NoPin {
+		dir = in.Pulse
+	}
+	pinCfg := machine.PinConfig{Mode: Pio.PinMode()}
+	in.Pulse.Configure(pinCfg)
+	dir.Configure(pinCfg)
+	sm.SetPindirsConsecutive(in.Pulse, 1, false)
+	sm.SetPindirsConsecutive(dir, 1, false)
+	cfg := wheelspeedProgramDefaultConfig(offset)
+	cfg.SetJmpPin(in.Pulse)
+	cfg.SetInPins(dir)
+	cfg.SetInShift(false, false, 32)
+	// We only use Rx FIFO, so we set the join to Rx.
+	cfg.SetFIFOJoin(pio.FifoJoinRx)
+	sm.Init(offset, cfg)
+	sm.SetEnabled(true)
+	return wheelChannel{sm: sm, offset: offset, hasDir: in.Direction != machine.NoPin}
+}
+
+// SetGlitchFilter sets the shortest valid period between pulses. Shorter periods,
+// caused by contact bounce or electrical noise, are merged into the next one.
+// It should be well below the period at the top speed. Use 0 to disable filtering.
+func (ws *WheelSpeed) SetGlitchFilter(minPeriod time.Duration) {
+	ws.glitch = uint64(minPeriod) * uint64(machine.CPUFrequency()) / uint64(time.Second)
+}
+
+// SetStopTimeout sets the time without pulses after which a wheel is reported
+// as stopped. It defaults to one second and limits the slowest measurable speed.
+func (ws *WheelSpeed) SetStopTimeout(timeout time.Duration) {
+	ws.stop = timeout
+}
+
+// Frequencies returns the pulse frequency of the left and right wheel in Hz.
+// Frequencies are negative for wheels turning backwards if their direction pin is set.
+func (ws *WheelSpeed) Frequencies() (left, right float64) {
+	ws.poll()
+	return ws.wheels[0].frequency(ws.stop), ws.wheels[1].frequency(ws.stop)
+}
+
+// Speeds returns the speed of the left and right wheel in revolutions per second.
+// Speeds are negative for wheels turning backwards if their direction pin is set.
+func (ws *WheelSpeed) Speeds() (left, right float64) {
+	left, right = ws.Frequencies()
+	return left / float64(ws.pulsesPerRev), right / float64(ws.pulsesPerRev)
+}
+
+// poll drains the measurements of both wheels.
+func (ws *WheelSpeed) poll() {
+	now := time.Now()
+	for i := range ws.wheels {
+		w := &ws.wheels[i]
+		for !w.sm.IsRxFIFOEmpty() {
+			word := w.sm.RxGet()
+			if !w.synced {
+				w.synced = true
+				continue
+			}
+			cycles := w.pending + 2*uint64(word&^(1<<31)) + wheelspeedOverhead
+			if cycles < ws.glitch {
+				w.pending = cycles
+				continue
+			}
+			w.pending = 0
+			w.period = cycles
+			// An A/B encoder turning forward has B low at the rising edge of A.
+			w.reverse = w.hasDir && word&(1<<31) != 0
+			w.lastEdge = now
+		}
+	}
+}
+
+func (w *wheelChannel) frequency(stop time.Duration) float64 {
+	if w.period == 0 || time.Since(w.lastEdge) > stop {
+		return 0
+	}
+	f := float64(machine.CPUFrequency()) / float64(w.period)
+	if w.reverse {
+		f = -f
+	}
+	return f
+}
+
+// Placement returns the state machines and programs used by the left and right wheel.
+func (ws *WheelSpeed) Placement() Placement {
+	var p Placement
+	for _, w := range ws.wheels {
+		p.addSM(w.sm, w.offset, wheelspeedInstructions)
+	}
+	return p
+}
@@ -0,0 +1,239 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import (
+	"errors"
+	"machine"
+	"time"
+
+	pio "github.com/tinygo-org/pio/rp2-pio"
+)
+
+var errWindVanePins = errors.New("piolib:Gray code vane needs 1 to 8 pins")
+
+const (
+	// Anemometer reed switches bounce for well under a millisecond, and close
+	// for several milliseconds per pulse even at gale speeds.
+	windDebounce = time.Millisecond
+	// Gusts are the highest average speed over 3 seconds, as defined by the WMO.
+	windSlot = 3 * time.Second
+	// Slots kept for gusts, 10 minutes of 3 seconds.
+	windSlots             = 200
+	windDefaultGustWindow = 10 * time.Minute
+)
+
+// SparkFunVaneResistances holds the resistances in ohms of the wind vane of the
+// SparkFun weather meter kit and the Argent Data and Misol vanes it derives from,
+// for its 16 directions starting north, to be used with NewLadderVane.
+var SparkFunVaneResistances = []uint32{
+	33000, 6570, 8200, 891, 1000, 688, 2200, 1410,
+	3900, 3140, 16000, 14120, 120000, 42120, 64900, 21880,
+}
+
+// WindVane reads the direction of a wind vane in degrees clockwise from north.
+// ok is false if the reading is invalid, i.e. the vane is disconnected.
+type WindVane interface {
+	Direction() (deg uint16, ok bool)
+}
+
+// GrayCodeVane is a wind vane with an absolute Gray code encoder, read from
+// parallel pins.
+type GrayCodeVane struct {
+	pins  []machine.Pin
+	north uint16
+}
+
+// NewGrayCodeVane returns a vane read from pins, least significant bit first,
+// which are pulled up for open collector outputs. north is the direction read
+// when the vane points north, to correct the mounting of the encoder.
+func NewGrayCodeVane(pins []machine.Pin, north uint16) (*GrayCodeVane, error) {
+	if len(pins) == 0 || len(pins) > 8 {
+		return nil, errWindVanePins
+	}
+	for _, pin := range pins {
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	}
+	return &GrayCodeVane{pins: pins, north: north % 360}, nil
+}
+
+// Direction reads the encoder. Gray codes change a single bit at a time, so a
+// reading while the vane moves is off by one step at most.
+func (v *GrayCodeVane) Direction() (deg uint16, ok bool) {
+	var gray uint32
+	for i, pin := range v.pins {
+		if pin.Get() {
+			gray |= 1 << i
+		}
+	}
+	bin := gray
+	for shift := gray >> 1; shift != 0; shift >>= 1 {
+		bin ^= shift
+	}
+	deg = uint16(bin * 360 >> len(v.pins))
+	return (deg + 360 - v.north) % 360, true
+}
+
+// LadderVane is a wind vane switching resistors of a ladder by reed switches,
+// read as a voltage divider with a pull-up resistor by an ADC.
+type LadderVane struct {
+	read func() uint16
+	// expected holds the ADC reading of each direction.
+	expected []uint16
+}
+
+// NewLadderVane returns a vane read by read, which returns a 16 bit ADC reading
+// such as machine.ADC.Get. resistances holds the resistance of the vane for each
+// direction, evenly spaced clockwise from north, and pullup the resistance of the
+// pull-up resistor from the reference voltage of the ADC, i.e. 10kΩ for the
+// SparkFun vane whose resistances are SparkFunVaneResistances.
+func NewLadderVane(read func() uint16, resistances []uint32, pullup uint32) *LadderVane {
+	expected := make([]uint16, len(resistances))
+	for i, r := range resistances {
+		expected[i] = uint16(uint64(r) * 0xffff / (uint64(r) + uint64(pullup)))
+	}
+	return &LadderVane{read: read, expected: expected}
+}
+
+// Direction reads the ADC and returns the direction with the closest expected
+// reading. Readings near the ends of the ADC range, as with an open or shorted
+// vane, are invalid.
+func (v *LadderVane) Direction() (deg uint16, ok bool) {
+	raw := v.read()
+	if raw < 0x400 || raw > 0xfc00 || len(v.expected) == 0 {
+		return 0, false
+	}
+	best, bestDiff := 0, 0x10000
+	for i, e := range v.expected {
+		diff := int(raw) - int(e)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	return uint16(best * 360 / len(v.expected)), true
+}
+
+// WindSensor is a weather station wind sensor combining a cup anemometer, whose
+// reed switch closes once or more per turn, and a wind vane. The state machine
+// debounces and counts the anemometer pulses so none are lost while the CPU is
+// busy: it pushes the running count after each pulse, so pulses whose count did
+// not fit in the RX FIFO are included in the count of the next one. Speeds are averaged over 3 seconds and the highest of these averages over
+// the gust window is the gust speed. Update must be called regularly, at least
+// every 3 seconds for accurate gusts.
+type WindSensor struct {
+	sm         pio.StateMachine
+	offset     uint8
+	vane       WindVane
+	speedPerHz float64
+	direction  uint16
+	// raw is the last count read from the state machine, which wraps around at 2³².
+	raw       uint32
+	slotStart time.Time
+	current   uint32 // Pulses in the slot being counted.
+	// slots holds the pulses of the last complete slots, the newest at head-1.
+	slots  [windSlots]uint16
+	head   int
+	filled int
+	window int // Slots of the gust window.
+}
+
+// NewWindSensor starts counting the anemometer pulses on pin, which is pulled up
+// as the reed switch pulls it low, and reads the direction from vane. speedPerHz
+// is the wind speed for one pulse per second, in the units the speeds are to be
+// returned in, i.e. 2.4 for the km/h of the SparkFun anemometer.
+func NewWindSensor(sm pio.StateMachine, pin machine.Pin, vane WindVane, speedPerHz float64) (*WindSensor, error) {
+	sm.TryClaim() // SM should be claimed beforehand, we just guarantee it's claimed.
+	offset, err := s0counterInit(sm, pin, windDebounce)
+	if err != nil {
+		return nil, err
+	}
+	return &WindSensor{
+		sm:         sm,
+		offset:     offset,
+		vane:       vane,
+		speedPerHz: speedPerHz,
+		slotStart:  time.Now(),
+		window:     int(windDefaultGustWindow / windSlot),
+	}, nil
+}
+
+// SetGustWindow sets the window over which the gust speed is the highest 3 second
+// average. It defaults to 10 minutes, which is also the longest window.
+func (w *WindSensor) SetGustWindow(window time.Duration) {
+	n := int(window / windSlot)
+	if n < 1 {
+		n = 1
+	} else if n > windSlots {
+		n = windSlots
+	}
+	w.window = n
+}
+
+// Update reads the pulses counted by the state machine and the vane. If Update
+// was not called for several 3 second slots, the pulses are spread evenly over
+// them.
+func (w *WindSensor) Update() {
+	for !w.sm.IsRxFIFOEmpty() {
+		raw := w.sm.RxGet()
+		// Counts only increase: the difference is right across wrap around.
+		w.current += raw - w.raw
+		w.raw = raw
+	}
+	if deg, ok := w.vane.Direction(); ok {
+		w.direction = deg
+	}
+	elapsed := time.Since(w.slotStart)
+	n := uint32(elapsed / windSlot)
+	if n == 0 {
+		return
+	}
+	per := w.current / n
+	if per > 0xffff {
+		per = 0xffff
+	}
+	for i := uint32(0); i < n && i < windSlots; i++ {
+		w.slots[w.head] = uint16(per)
+		w.head = (w.head + 1) % windSlots
+		if w.filled < windSlots {
+			w.filled++
+		}
+	}
+	w.current -= per * n
+	w.slotStart = w.slotStart.Add(time.Duration(n) * windSlot)
+}
+
+// Wind updates the sensor and returns the average speed of the last 3 seconds and
+// the direction, the last valid one read from the vane.
+func (w *WindSensor) Wind() (speed float64, directionDeg uint16) {
+	w.Update()
+	if w.filled == 0 {
+		return 0, w.direction
+	}
+	return w.slotSpeed(w.slots[(w.head+windSlots-1)%windSlots]), w.direction
+}
+
+// Gust returns the highest 3 second average speed over the gust window, as of the
+// last Update.
+func (w *WindSensor) Gust() float64 {
+	var max uint16
+	for i := 1; i <= w.window && i <= w.filled; i++ {
+		if p := w.slots[(w.head+windSlots-i)%windSlots]; p > max {
+			max = p
+		}
+	}
+	return w.slotSpeed(max)
+}
+
+func (w *WindSensor) slotSpeed(pulses uint16) float64 {
+	return float64(pulses) / windSlot.Seconds() * w.speedPerHz
+}
+
+// Placement returns the state machine and program used by the anemometer.
+func (w *WindSensor) Placement() Placement {
+	var p Placement
+	p.addSM(w.sm, w.offset, s0counterInstructions)
+	return p
+}
@@ -9,14 +9,28 @@ import (
 	pio "github.com/tinygo-org/pio/rp2-pio"
 )
 
+var _ pio.TxStream[uint32] = (*WS2812B)(nil)
+
 // WS2812B is an RGB LED strip controller implementation, also known as NeoPixel.
 type WS2812B struct {
 	sm     pio.StateMachine
 	dma    dmaChannel
 	offset uint8
+	guard  fifoGuard
 }
 
 func NewWS2812B(sm pio.StateMachine, pin machine.Pin) (*WS2812B, error) {
+	offset, err := ws2812bInit(sm, pin, 24)
+	if err != nil {
+		return nil, err
+	}
+	dev := &WS2812B{sm: sm, offset: offset}
+	return dev, nil
+}
+
+// ws2812bInit loads the program and starts sm shifting out bits per LED, which
+// is 24 for RGB LEDs and 32 for RGBW LEDs sharing the WS2812B timings.
+func ws2812bInit(sm pio.StateMachine, pin machine.Pin, bits uint8) (offset uint8, err error) {
 	// https://cdn-shop.adafruit.com/datasheets/WS2812B.pdf
 	const (
 		baseline      = 1250.
@@ -29,13 +43,13 @@ func NewWS2812B(sm pio.StateMachine, pin machine.Pin) (*WS2812B, error) {
 	// whole, frac, err := pio.ClkDivFromPeriod(period, cpufreq)
 	whole, frac, err := pio.ClkDivFromFrequency(freq, cpufreq)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	// We add the program to PIO memory and store it's offset.
 	Pio := sm.PIO()
-	offset, err := Pio.AddProgram(ws2812b_ledInstructions, ws2812b_ledOrigin)
+	offset, err = Pio.AddProgram(ws2812b_ledInstructions, ws2812b_ledOrigin)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	pin.Configure(machine.PinConfig{Mode: Pio.PinMode()})
 	sm.SetPindirsConsecutive(pin, 1, true)
@@ -44,11 +58,11 @@ func NewWS2812B(sm pio.StateMachine, pin machine.Pin) (*WS2812B, error) {
 	// We only use Tx FIFO, so we set the join to Tx.
 	cfg.SetFIFOJoin(pio.FifoJoinTx)
 	cfg.SetClkDivIntFrac(whole, frac)
-	cfg.SetOutShift(false, true, 24)
+	cfg.ConfigureTx(pio.MSBFirst, bits, true)
 	sm.Init(offset, cfg)
+	trackClock(sm, freq)
 	sm.SetEnabled(true)
-	dev := &WS2812B{sm: sm, offset: offset}
-	return dev, nil
+	return offset, nil
 }
 
 // PutRGB puts a RGB color in the transmit queue. If Queue if full will be discarded.
@@ -82,25 +96,83 @@ func (ws *WS2812B) PutColor(c color.Color) {
 //
 //	color := uint32(g)<<24 | uint32(r)<<16 | uint32(b)<<8
 func (ws *WS2812B) WriteRaw(rawGRB []uint32) error {
+	_, err := ws.Write(rawGRB)
+	return err
+}
+
+// Write writes raw GRB values like WriteRaw and returns the number of values
+// queued. It implements pio.TxStream, so effects engines can be written against
+// the stream.
+func (ws *WS2812B) Write(rawGRB []uint32) (n int, err error) {
 	if ws.IsDMAEnabled() {
-		return ws.writeDMA(rawGRB)
+		if err := ws.writeDMA(rawGRB); err != nil {
+			return 0, err
+		}
+		return len(rawGRB), nil
 	}
 	dl := ws.dma.dl.newDeadline()
-	i := 0
-	for i < len(rawGRB) {
+	for n < len(rawGRB) {
 		if ws.IsQueueFull() {
 			if dl.expired() {
-				return errTimeout
+				return n, errTimeout
 			}
 			gosched()
 			continue
 		}
-		ws.sm.TxPut(rawGRB[i])
-		i++
+		ws.sm.TxPut(rawGRB[n])
+		n++
 	}
+	return n, ws.guard.check(ws.sm, n)
+}
+
+// Put queues a raw GRB value, blocking while the queue is full.
+func (ws *WS2812B) Put(grb uint32) error {
+	dl := ws.dma.dl.newDeadline()
+	for ws.IsQueueFull() {
+		if dl.expired() {
+			return errTimeout
+		}
+		gosched()
+	}
+	ws.sm.TxPut(grb)
 	return nil
 }
 
+// TryPut queues a raw GRB value if the queue is not full.
+func (ws *WS2812B) TryPut(grb uint32) bool {
+	return ws.sm.TxPutFromISR(grb)
+}
+
+// WriteColors writes colors to the strip, one per LED, ignoring their alpha. It
+// implements LEDStrip.
+func (ws *WS2812B) WriteColors(colors []color.RGBA) error {
+	dl := ws.dma.dl.newDeadline()
+	for _, c := range colors {
+		for ws.IsQueueFull() {
+			if dl.expired() {
+				return errTimeout
+			}
+			gosched()
+		}
+		ws.PutRGB(c.R, c.G, c.B)
+	}
+	return ws.guard.check(ws.sm, len(colors))
+}
+
+// SetFIFOGuard enables checking for colors lost by the FIFO after each WriteRaw
+// without DMA, which then returns a *pio.FIFOError wrapping pio.ErrTxOverflow. This includes colors
+// discarded by PutRaw, PutRGB and PutColor since the previous check. It is
+// disabled by default.
+func (ws *WS2812B) SetFIFOGuard(enabled bool) {
+	ws.guard.setEnabled(enabled, ws.sm)
+}
+
+// FIFOErrors returns the number of checks which found lost colors while the guard
+// was enabled.
+func (ws *WS2812B) FIFOErrors() (txOverflows, rxUnderflows uint32) {
+	return ws.guard.counts()
+}
+
 // EnableDMA enables DMA for vectorized writes.
 func (ws *WS2812B) EnableDMA(enabled bool) error {
 	dmaAlreadyEnabled := ws.IsDMAEnabled()
@@ -111,7 +183,7 @@ func (ws *WS2812B) EnableDMA(enabled bool) error {
 		}
 		return nil
 	}
-	channel, ok := _DMA.ClaimChannel()
+	channel, ok := _DMA.ClaimChannelFor("WS2812B")
 	if !ok {
 		return errDMAUnavail
 	}
@@ -121,15 +193,38 @@ func (ws *WS2812B) EnableDMA(enabled bool) error {
 }
 
 func (ws *WS2812B) writeDMA(w []uint32) error {
-	dreq := dmaPIO_TxDREQ(ws.sm)
-	err := ws.dma.Push32(&ws.sm.TxReg().Reg, w, dreq)
-	if err != nil {
-		return err
+	return ws.StartWrite(w).Wait()
+}
+
+// StartWrite starts writing raw GRB values like WriteRaw by DMA and returns without
+// waiting. DMA must be enabled, see EnableDMA. rawGRB must not be modified until
+// the operation is done. On timeout or cancellation the colors left are dropped.
+func (ws *WS2812B) StartWrite(rawGRB []uint32) *DMAOp {
+	if !ws.IsDMAEnabled() {
+		return &DMAOp{err: errDMAUnavail, done: true}
 	}
-	return nil
+	op := dmaStartPushAddr(ws.dma, ws.sm.TxRegAddr(), rawGRB, dmaPIO_TxDREQ(ws.sm))
+	op.stop = ws.stopDMA
+	return &op
+}
+
+// stopDMA aborts a DMA write and restarts the state machine with an empty FIFO.
+func (ws *WS2812B) stopDMA() {
+	stopStreaming(ws.sm, ws.dma)
+	ws.sm.Restart()
+	ws.sm.Jmp(ws.offset, pio.JmpAlways)
+	ws.sm.SetEnabled(true)
 }
 
 // IsDMAEnabled returns true if DMA is enabled.
 func (ws *WS2812B) IsDMAEnabled() bool {
 	return ws.dma.IsValid()
 }
+
+// Placement returns the state machines, programs and DMA channels used by the LED strip.
+func (ws *WS2812B) Placement() Placement {
+	var p Placement
+	p.addSM(ws.sm, ws.offset, ws2812b_ledInstructions)
+	p.addDMA(ws.dma)
+	return p
+}
@@ -0,0 +1,138 @@
+//go:build rp2040 && !piolib_stable
+
+package piolib
+
+import "image/color"
+
+// WS2812BFrame is a framebuffer for a strip of WS2812B LEDs. The protocol requires
+// the whole strip to be retransmitted on every update, but only pixels changed since
+// the last flush are converted to the wire format, so updating a few LEDs of a
+// long strip is cheap.
+type WS2812BFrame struct {
+	ws     *WS2812B
+	pixels []color.RGBA
+	// raw holds the wire format of pixels as transmitted.
+	raw   []uint32
+	gamma bool
+	// Range of pixels changed since the last flush, empty if start >= end.
+	dirtyStart int
+	dirtyEnd   int
+}
+
+// NewWS2812BFrame creates a framebuffer of n pixels transmitted through ws. All
+// pixels start off and are transmitted on the first flush. Enable DMA on ws
+// for flushes to take no CPU time.
+func NewWS2812BFrame(ws *WS2812B, n int) *WS2812BFrame {
+	return &WS2812BFrame{
+		ws:       ws,
+		pixels:   make([]color.RGBA, n),
+		raw:      make([]uint32, n),
+		dirtyEnd: n,
+	}
+}
+
+// Len returns the number of pixels.
+func (f *WS2812BFrame) Len() int {
+	return len(f.pixels)
+}
+
+// SetPixel sets the color of pixel i. The alpha channel is ignored.
+func (f *WS2812BFrame) SetPixel(i int, c color.RGBA) {
+	if f.pixels[i] == c {
+		return
+	}
+	f.pixels[i] = c
+	f.markDirty(i, i+1)
+}
+
+// Pixel returns the color of pixel i.
+func (f *WS2812BFrame) Pixel(i int) color.RGBA {
+	return f.pixels[i]
+}
+
+// Fill sets all pixels to c.
+func (f *WS2812BFrame) Fill(c color.RGBA) {
+	for i := range f.pixels {
+		f.SetPixel(i, c)
+	}
+}
+
+// SetGamma enables gamma correction so color values are perceived as linear brightness.
+func (f *WS2812BFrame) SetGamma(enabled bool) {
+	if f.gamma != enabled {
+		f.gamma = enabled
+		f.markDirty(0, len(f.pixels))
+	}
+}
+
+// IsDirty returns true if pixels changed since the last flush.
+func (f *WS2812BFrame) IsDirty() bool {
+	return f.dirtyStart < f.dirtyEnd
+}
+
+// Flush converts the pixels changed since the last flush and transmits the whole strip.
+func (f *WS2812BFrame) Flush() error {
+	gamma := &ws2812bLinear
+	if f.gamma {
+		gamma = &ws2812bGamma
+	}
+	for i := f.dirtyStart; i < f.dirtyEnd; i++ {
+		c := f.pixels[i]
+		f.raw[i] = uint32(gamma[c.G])<<24 | uint32(gamma[c.R])<<16 | uint32(gamma[c.B])<<8
+	}
+	f.dirtyStart, f.dirtyEnd = 0, 0
+	return f.ws.WriteRaw(f.raw)
+}
+
+// FlushIfDirty flushes the frame only if pixels changed since the last flush.
+func (f *WS2812BFrame) FlushIfDirty() error {
+	if !f.IsDirty() {
+		return nil
+	}
+	return f.Flush()
+}
+
+func (f *WS2812BFrame) markDirty(start, end int) {
+	if !f.IsDirty() {
+		f.dirtyStart, f.dirtyEnd = start, end
+		return
+	}
+	if start < f.dirtyStart {
+		f.dirtyStart = start
+	}
+	if end > f.dirtyEnd {
+		f.dirtyEnd = end
+	}
+}
+
+var ws2812bLinear = func() (t [256]uint8) {
+	for i := range t {
+		t[i] = uint8(i)
+	}
+	return t
+}()
+
+// ws2812bGamma maps color values to LED brightness with a gamma of 2.8.
+var ws2812bGamma = [256]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2,
+	2, 3, 3, 3, 3, 3, 3, 3, 4, 4, 4, 4, 4, 5, 5, 5,
+	5, 6, 6, 6, 6, 7, 7, 7, 7, 8, 8, 8, 9, 9, 9, 10,
+	10, 10, 11, 11, 11, 12, 12, 13, 13, 13, 14, 14, 15, 15, 16, 16,
+	17, 17, 18, 18, 19, 19, 20, 20, 21, 21, 22, 22, 23, 24, 24, 25,
+	25, 26, 27, 27, 28, 29, 29, 30, 31, 32, 32, 33, 34, 35, 35, 36,
+	37, 38, 39, 39, 40, 41, 42, 43, 44, 45, 46, 47, 48, 49, 50, 50,
+	51, 52, 54, 55, 56, 57, 58, 59, 60, 61, 62, 63, 64, 66, 67, 68,
+	69, 70, 72, 73, 74, 75, 77, 78, 79, 81, 82, 83, 85, 86, 87, 89,
+	90, 92, 93, 95, 96, 98, 99, 101, 102, 104, 105, 107, 109, 110, 112, 114,
+	115, 117, 119, 120, 122, 124, 126, 127, 129, 131, 133, 135, 137, 138, 140, 142,
+	144, 146, 148, 150, 152, 154, 156, 158, 160, 162, 164, 167, 169, 171, 173, 175,
+	177, 180, 182, 184, 186, 189, 191, 193, 196, 198, 200, 203, 205, 208, 210, 213,
+	215, 218, 220, 223, 225, 228, 231, 233, 236, 239, 241, 244, 247, 249, 252, 255,
+}
+
+// Placement returns the state machines, programs and DMA channels used by the LED strip.
+func (f *WS2812BFrame) Placement() Placement {
+	return f.ws.Placement()
+}
@@ -0,0 +1,46 @@
+package pio
+
+// instrMemCapacity is the number of instruction memory slots the allocator can
+// track: the width of instrMask and the size of the mirror of instruction memory.
+// It is the size of the memory of all current parts; parts with more memory are
+// limited to it. See PIO.InstructionMemSize.
+const instrMemCapacity = 32
+
+// instrMask is a bitmask of instruction memory slots, bit i for slot i.
+type instrMask = uint32
+
+// smExtent is the instruction memory an enabled state machine may execute from:
+// its current instruction and its wrap range. It has no hardware dependencies so
+// the checks of ClearProgramSection can be run on the host.
+type smExtent struct {
+	addr       uint8
+	wrapBottom uint8
+	wrapTop    uint8
+}
+
+// overlaps returns true if the section of length instructions at offset holds the
+// current instruction of e or intersects its wrap range. A wrap bottom above the
+// wrap top is a range wrapping around the end of instruction memory, since the
+// program counter rolls over from the last slot to 0.
+func (e smExtent) overlaps(offset, length uint8) bool {
+	end := offset + length
+	if length == 0 {
+		return false
+	} else if e.addr >= offset && e.addr < end {
+		return true
+	}
+	if e.wrapBottom <= e.wrapTop {
+		return e.wrapBottom < end && e.wrapTop >= offset
+	}
+	return e.wrapTop >= offset || end > e.wrapBottom
+}
+
+// sectionInUse returns true if the section overlaps any of extents.
+func sectionInUse(offset, length uint8, extents []smExtent) bool {
+	for _, e := range extents {
+		if e.overlaps(offset, length) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+//go:build rp2040
+
+package pio
+
+import "testing"
+
+func TestSMExtentOverlaps(t *testing.T) {
+	tests := []struct {
+		name           string
+		e              smExtent
+		offset, length uint8
+		want           bool
+	}{
+		{"empty section", smExtent{addr: 4, wrapBottom: 0, wrapTop: 31}, 4, 0, false},
+		{"below wrap range", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 0, 10, false},
+		{"above wrap range", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 16, 16, false},
+		{"ends at wrap bottom", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 0, 11, true},
+		{"starts at wrap top", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 15, 1, true},
+		{"inside wrap range", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 12, 2, true},
+		{"spans wrap range", smExtent{addr: 10, wrapBottom: 10, wrapTop: 15}, 8, 10, true},
+		{"single instruction wrap", smExtent{addr: 7, wrapBottom: 7, wrapTop: 7}, 7, 1, true},
+		{"next to single instruction", smExtent{addr: 7, wrapBottom: 7, wrapTop: 7}, 8, 4, false},
+		// The program counter may sit outside the wrap range, i.e. after a jump
+		// or an exec'd instruction, until it reaches the wrap top.
+		{"addr outside wrap range", smExtent{addr: 20, wrapBottom: 0, wrapTop: 3}, 18, 4, true},
+		{"addr past section", smExtent{addr: 22, wrapBottom: 0, wrapTop: 3}, 18, 4, false},
+		// Wrap bottom above wrap top: the range rolls over from slot 31 to 0.
+		{"rolled over, high part", smExtent{addr: 30, wrapBottom: 28, wrapTop: 2}, 29, 3, true},
+		{"rolled over, low part", smExtent{addr: 30, wrapBottom: 28, wrapTop: 2}, 1, 1, true},
+		{"rolled over, gap", smExtent{addr: 30, wrapBottom: 28, wrapTop: 2}, 3, 25, false},
+		{"rolled over, spans gap", smExtent{addr: 30, wrapBottom: 28, wrapTop: 2}, 2, 27, true},
+	}
+	for _, tt := range tests {
+		if got := tt.e.overlaps(tt.offset, tt.length); got != tt.want {
+			t.Errorf("%s: %+v overlaps(%d, %d) = %v, want %v", tt.name, tt.e, tt.offset, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestSectionInUse(t *testing.T) {
+	// Enabled state machines of the block running two programs.
+	extents := []smExtent{
+		{addr: 2, wrapBottom: 0, wrapTop: 5},
+		{addr: 20, wrapBottom: 18, wrapTop: 23},
+	}
+	tests := []struct {
+		offset, length uint8
+		want           bool
+	}{
+		{6, 12, false}, // Free memory between the programs.
+		{24, 8, false}, // Free memory after the second program.
+		{0, 32, true},  // All memory.
+		{5, 1, true},   // Last instruction of the first program.
+		{17, 2, true},  // Overlapping the start of the second program.
+	}
+	for _, tt := range tests {
+		if got := sectionInUse(tt.offset, tt.length, extents); got != tt.want {
+			t.Errorf("sectionInUse(%d, %d) = %v, want %v", tt.offset, tt.length, got, tt.want)
+		}
+	}
+	if sectionInUse(0, 32, nil) {
+		t.Error("sectionInUse with no enabled state machine = true, want false")
+	}
+}
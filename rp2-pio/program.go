@@ -0,0 +1,302 @@
+package pio
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Program is an assembled PIO program along with the metadata pioasm generates for it.
+// Programs assembled at runtime can be persisted as JSON with MarshalJSON or
+// converted to Go source in the format generated by pioasm with EmitGoSource.
+type Program struct {
+	// Name of the program as given by the .program directive.
+	Name         string   `json:"name"`
+	Instructions []uint16 `json:"instructions"`
+	// Origin is the instruction memory offset the program must be loaded at,
+	// or -1 if it can be loaded anywhere.
+	Origin int8 `json:"origin"`
+	// WrapTarget and Wrap are instruction indices relative to the start of the program.
+	WrapTarget uint8 `json:"wrapTarget"`
+	Wrap       uint8 `json:"wrap"`
+	// SidesetBits is the number of side-set bits including the enable bit if
+	// SidesetOptional is set, as passed to StateMachineConfig.SetSidesetParams.
+	SidesetBits     uint8 `json:"sidesetBits"`
+	SidesetOptional bool  `json:"sidesetOptional"`
+	SidesetPindirs  bool  `json:"sidesetPindirs"`
+	// PublicLabels maps the names of public labels to instruction indices.
+	PublicLabels map[string]uint8 `json:"publicLabels,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler without the use of reflection. The result
+// can be decoded into a Program with encoding/json.
+func (p *Program) MarshalJSON() ([]byte, error) {
+	b := append([]byte(nil), `{"name":`...)
+	b = strconv.AppendQuote(b, p.Name)
+	b = append(b, `,"instructions":[`...)
+	for i, instr := range p.Instructions {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(instr), 10)
+	}
+	b = append(b, `],"origin":`...)
+	b = strconv.AppendInt(b, int64(p.Origin), 10)
+	b = append(b, `,"wrapTarget":`...)
+	b = strconv.AppendUint(b, uint64(p.WrapTarget), 10)
+	b = append(b, `,"wrap":`...)
+	b = strconv.AppendUint(b, uint64(p.Wrap), 10)
+	b = append(b, `,"sidesetBits":`...)
+	b = strconv.AppendUint(b, uint64(p.SidesetBits), 10)
+	b = append(b, `,"sidesetOptional":`...)
+	b = strconv.AppendBool(b, p.SidesetOptional)
+	b = append(b, `,"sidesetPindirs":`...)
+	b = strconv.AppendBool(b, p.SidesetPindirs)
+	if len(p.PublicLabels) > 0 {
+		b = append(b, `,"publicLabels":{`...)
+		for i, label := range p.sortedLabels() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = strconv.AppendQuote(b, label)
+			b = append(b, ':')
+			b = strconv.AppendUint(b, uint64(p.PublicLabels[label]), 10)
+		}
+		b = append(b, '}')
+	}
+	b = append(b, '}')
+	return b, nil
+}
+
+// EmitGoSource returns Go source for package pkg declaring the program the same way
+// pioasm does, so the output can replace a generated _pio.go file. Declarations
+// are prefixed with varName, i.e: varNameInstructions and varNameProgramDefaultConfig.
+func (p *Program) EmitGoSource(pkg, varName string) []byte {
+	b := append([]byte(nil), "// Code generated by pioasm; DO NOT EDIT.\n\n"...)
+	b = append(b, "//go:build rp2040\n"...)
+	b = append(b, "package "+pkg+"\n"...)
+	b = append(b, "import (\n    pio \"github.com/tinygo-org/pio/rp2-pio\"\n)\n"...)
+	b = append(b, "// "+varName+"\n\n"...)
+	b = appendConst(b, varName+"WrapTarget", int64(p.WrapTarget))
+	b = appendConst(b, varName+"Wrap", int64(p.Wrap))
+	b = append(b, '\n')
+	if len(p.PublicLabels) > 0 {
+		for _, label := range p.sortedLabels() {
+			b = appendConst(b, varName+"offset_"+label, int64(p.PublicLabels[label]))
+		}
+		b = append(b, '\n')
+	}
+	b = append(b, "var "+varName+"Instructions = []uint16{\n"...)
+	for i, instr := range p.Instructions {
+		if i == int(p.WrapTarget) {
+			b = append(b, "\t\t//     .wrap_target\n"...)
+		}
+		b = append(b, "\t\t0x"...)
+		b = appendPadded(b, strconv.FormatUint(uint64(instr), 16), 4, '0', true)
+		b = append(b, ", // "...)
+		b = appendPadded(b, strconv.Itoa(i), 2, ' ', true)
+		b = append(b, ": "...)
+		b = append(b, disassemble(instr, p.SidesetBits, p.SidesetOptional)...)
+		b = append(b, '\n')
+		if i == int(p.Wrap) {
+			b = append(b, "\t\t//     .wrap\n"...)
+		}
+	}
+	b = append(b, "}\n"...)
+	b = appendConst(b, varName+"Origin", int64(p.Origin))
+	b = append(b, "func "+varName+"ProgramDefaultConfig(offset uint8) pio.StateMachineConfig {\n"...)
+	b = append(b, "\tcfg := pio.DefaultStateMachineConfig()\n"...)
+	b = append(b, "\tcfg.SetWrap(offset+"+varName+"WrapTarget, offset+"+varName+"Wrap)\n"...)
+	if p.SidesetBits > 0 {
+		b = append(b, "\tcfg.SetSidesetParams("...)
+		b = strconv.AppendUint(b, uint64(p.SidesetBits), 10)
+		b = append(b, ", "...)
+		b = strconv.AppendBool(b, p.SidesetOptional)
+		b = append(b, ", "...)
+		b = strconv.AppendBool(b, p.SidesetPindirs)
+		b = append(b, ")\n"...)
+	}
+	b = append(b, "\treturn cfg;\n}\n\n"...)
+	return b
+}
+
+func (p *Program) sortedLabels() []string {
+	labels := make([]string, 0, len(p.PublicLabels))
+	for label := range p.PublicLabels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func appendConst(b []byte, name string, value int64) []byte {
+	b = append(b, "const "+name+" = "...)
+	b = strconv.AppendInt(b, value, 10)
+	return append(b, '\n')
+}
+
+// appendPadded appends s padded with pad up to width characters, to the left if left is set.
+func appendPadded(b []byte, s string, width int, pad byte, left bool) []byte {
+	if !left {
+		b = append(b, s...)
+	}
+	for i := len(s); i < width; i++ {
+		b = append(b, pad)
+	}
+	if left {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// InstrCycles returns the number of cycles an instruction takes when it does not
+// stall: 1 plus its delay. sidesetBits is the number of side-set bits including
+// the enable bit of optional side-set, which take the high bits of the delay field.
+func InstrCycles(instr uint16, sidesetBits uint8) int {
+	delay := uint8(instr>>8) & 0x1f
+	return 1 + int(delay&(1<<(5-sidesetBits)-1))
+}
+
+// ProgramCycleCount returns the number of cycles taken to execute the instructions
+// of instrs at the indices of path, in order, with the delays of each instruction
+// and none of the stalls. If path is empty every instruction is counted once, the
+// cycles of a program running straight through. sidesetBits is as for InstrCycles.
+//
+// It gives the cycles per loop of a program to derive its clock divider, i.e. for
+// a program outputting one period of a square wave per pass through instructions 0 to 3:
+//
+//	cycles := pio.ProgramCycleCount(blink, 0, 0, 1, 2, 3)
+//	whole, frac, err := pio.ClkDivFromFrequency(blinkHz*uint32(cycles), machine.CPUFrequency())
+func ProgramCycleCount(instrs []uint16, sidesetBits uint8, path ...uint8) int {
+	cycles := 0
+	if len(path) == 0 {
+		for _, instr := range instrs {
+			cycles += InstrCycles(instr, sidesetBits)
+		}
+		return cycles
+	}
+	for _, addr := range path {
+		cycles += InstrCycles(instrs[addr], sidesetBits)
+	}
+	return cycles
+}
+
+// LoopCycles returns the number of cycles of a pass through the wrapped loop of the
+// program, from WrapTarget to Wrap, assuming no jumps are taken and no stalls.
+func (p *Program) LoopCycles() int {
+	return ProgramCycleCount(p.Instructions[p.WrapTarget:p.Wrap+1], p.SidesetBits)
+}
+
+// disassemble returns the instruction in the format of pioasm's generated comments.
+// sidesetBits includes the enable bit if sidesetOpt is set.
+func disassemble(instr uint16, sidesetBits uint8, sidesetOpt bool) string {
+	arg1 := uint8(instr>>5) & 7
+	arg2 := uint8(instr) & 0x1f
+	var op, args string
+	switch majorInstrBits(instr) {
+	case _INSTR_BITS_JMP:
+		op = "jmp"
+		if cond := [8]string{"", "!x", "x--", "!y", "y--", "x!=y", "pin", "!osre"}[arg1]; cond != "" {
+			args = cond + ", "
+		}
+		args += strconv.Itoa(int(arg2))
+	case _INSTR_BITS_WAIT:
+		op = "wait"
+		args = strconv.Itoa(int(arg1>>2)) + " "
+		switch arg1 & 3 {
+		case 0:
+			args += "gpio, " + strconv.Itoa(int(arg2))
+		case 1:
+			args += "pin, " + strconv.Itoa(int(arg2))
+		case 2:
+			args += "irq, " + disassembleIRQ(arg2)
+		default:
+			op, args = "", "reserved"
+		}
+	case _INSTR_BITS_IN:
+		op = "in"
+		args = [8]string{"pins", "x", "y", "null", "", "", "isr", "osr"}[arg1] + ", " + disassembleBitCount(arg2)
+	case _INSTR_BITS_OUT:
+		op = "out"
+		args = [8]string{"pins", "x", "y", "null", "pindirs", "pc", "isr", "exec"}[arg1] + ", " + disassembleBitCount(arg2)
+	case _INSTR_BITS_PUSH:
+		if arg1&4 != 0 {
+			op = "pull"
+			if arg1&2 != 0 {
+				args = "ifempty "
+			}
+		} else {
+			op = "push"
+			if arg1&2 != 0 {
+				args = "iffull "
+			}
+		}
+		if arg1&1 != 0 {
+			args += "block"
+		} else {
+			args += "noblock"
+		}
+	case _INSTR_BITS_MOV:
+		dst := [8]string{"pins", "x", "y", "", "exec", "pc", "isr", "osr"}[arg1]
+		src := [8]string{"pins", "x", "y", "null", "", "status", "isr", "osr"}[arg2&7]
+		movOp := [4]string{"", "~", "::", ""}[arg2>>3&3]
+		if dst == "y" && src == "y" && arg2>>3 == 0 {
+			op = "nop"
+		} else {
+			op = "mov"
+			args = dst + ", " + movOp + src
+		}
+	case _INSTR_BITS_IRQ:
+		op = "irq"
+		switch {
+		case arg1&2 != 0:
+			args = "clear "
+		case arg1&1 != 0:
+			args = "wait "
+		default:
+			args = "nowait "
+		}
+		args += disassembleIRQ(arg2)
+	case _INSTR_BITS_SET:
+		op = "set"
+		args = [8]string{"pins", "x", "y", "", "pindirs", "", "", ""}[arg1] + ", " + strconv.Itoa(int(arg2))
+	}
+	b := appendPadded(nil, op, 7, ' ', false)
+	b = appendPadded(b, args, 16, ' ', false)
+	delay := uint8(instr>>8) & 0x1f
+	var side string
+	if sidesetBits > 0 && (!sidesetOpt || delay&0x10 != 0) {
+		value := delay
+		if sidesetOpt {
+			value &= 0xf
+		}
+		side = "side " + strconv.Itoa(int(value>>(5-sidesetBits)))
+	}
+	b = appendPadded(b, side, 7, ' ', false)
+	delay &= 1<<(5-sidesetBits) - 1
+	var delayStr string
+	if delay != 0 {
+		delayStr = "[" + strconv.Itoa(int(delay)) + "]"
+	}
+	b = appendPadded(b, delayStr, 4, ' ', false)
+	return string(b)
+}
+
+func disassembleIRQ(arg uint8) string {
+	s := strconv.Itoa(int(arg & 7))
+	switch IRQIndexMode(arg>>3) & 3 {
+	case IRQPrev:
+		s = "prev " + s
+	case IRQRel:
+		s += " rel"
+	case IRQNext:
+		s = "next " + s
+	}
+	return s
+}
+
+func disassembleBitCount(count uint8) string {
+	if count == 0 {
+		count = 32
+	}
+	return strconv.Itoa(int(count))
+}
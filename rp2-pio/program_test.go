@@ -0,0 +1,39 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestEmitGoSource(t *testing.T) {
+	// ws2812b_pio.go is generated by pioasm from piolib/ws2812b.pio.
+	want, err := os.ReadFile("piolib/ws2812b_pio.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Program{
+		Name:         "ws2812b_led",
+		Instructions: []uint16{0x80e0, 0xe001, 0x6041, 0x0065, 0x0206, 0xe200, 0xe000, 0x01e1},
+		Origin:       -1,
+		WrapTarget:   0,
+		Wrap:         7,
+		PublicLabels: map[string]uint8{"entry_point": 0},
+	}
+	got := p.EmitGoSource("piolib", "ws2812b_led")
+	if !bytes.Equal(got, want) {
+		gotLines, wantLines := bytes.Split(got, []byte("\n")), bytes.Split(want, []byte("\n"))
+		for i := range wantLines {
+			if i >= len(gotLines) || !bytes.Equal(gotLines[i], wantLines[i]) {
+				var line []byte
+				if i < len(gotLines) {
+					line = gotLines[i]
+				}
+				t.Fatalf("line %d: got %q, want %q", i+1, line, wantLines[i])
+			}
+		}
+		t.Fatalf("got %d lines, want %d", len(gotLines), len(wantLines))
+	}
+}
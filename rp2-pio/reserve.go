@@ -0,0 +1,171 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	errSMReserved      = errors.New("pio: state machine already reserved")
+	errInstrOverbudget = errors.New("pio: instruction budget exceeds program space")
+)
+
+// Reservation is a declaration of the resources of a PIO block used by a driver
+// or application, made with Reserve.
+type Reservation struct {
+	// Owner is a human readable name of who made the reservation.
+	Owner string
+	// SMMask has bit n set if state machine n is reserved.
+	SMMask uint8
+	// InstrBudget is the number of instruction memory slots reserved.
+	InstrBudget uint8
+}
+
+// Reserve declares that owner uses the state machines in smMask and up to instrBudget
+// instructions of the PIO block. It is meant to be called from package init functions
+// so resource conflicts between drivers of a large project show up at startup
+// instead of as allocation failures later on.
+//
+// An error is returned if a state machine is already reserved or if the instruction
+// budgets of all reservations exceed the slots of instruction memory.
+// Reserved state machines are skipped by ClaimStateMachine and must be claimed
+// by their owner by index with StateMachine and TryClaim, or with
+// ClaimStateMachineReserved. Instruction budgets are
+// checked only against each other, AddProgram does not enforce them.
+// Reserve is not safe for concurrent use.
+func (pio *PIO) Reserve(owner string, smMask uint8, instrBudget uint8) error {
+	if smMask > 0xf {
+		panic(badStateMachineIndex)
+	}
+	if pio.reservedSMMask&smMask != 0 {
+		return errSMReserved
+	}
+	if uint32(pio.reservedInstr)+uint32(instrBudget) > uint32(pio.InstructionMemSize()) {
+		return errInstrOverbudget
+	}
+	pio.reservedSMMask |= smMask
+	pio.reservedInstr += instrBudget
+	pio.reservations = append(pio.reservations, Reservation{Owner: owner, SMMask: smMask, InstrBudget: instrBudget})
+	return nil
+}
+
+// MustReserve is like Reserve but panics with a description of the conflicting
+// reservations if the resources can't be reserved.
+func (pio *PIO) MustReserve(owner string, smMask uint8, instrBudget uint8) {
+	err := pio.Reserve(owner, smMask, instrBudget)
+	if err == nil {
+		return
+	}
+	msg := err.Error() + ": " + owner + " on PIO" + strconv.Itoa(int(pio.BlockIndex()))
+	for _, r := range pio.reservations {
+		if r.SMMask&smMask != 0 || err == errInstrOverbudget {
+			msg += ", conflicts with " + r.Owner
+		}
+	}
+	panic(msg)
+}
+
+// Reservations returns the reservations made on the PIO block in order.
+func (pio *PIO) Reservations() []Reservation {
+	return pio.reservations
+}
+
+// IsReserved returns true if the state machine was reserved with Reserve.
+func (sm StateMachine) IsReserved() bool {
+	return sm.pio.reservedSMMask&(1<<sm.index) != 0
+}
+
+// ClaimStateMachineReserved returns an unused state machine reserved by owner with
+// Reserve, or an unreserved one if none is available. State machines reserved by
+// other owners are never returned. It is meant for board support drivers
+// initialized late, such as the wireless chip driver of the Pico W, which reserve
+// their state machine at init so applications claiming all state machines with
+// ClaimStateMachine leave one for them.
+// It is safe to call from interrupt handlers and from either core.
+func (pio *PIO) ClaimStateMachineReserved(owner string) (StateMachine, error) {
+	var mask uint8
+	for _, r := range pio.reservations {
+		if r.Owner == owner {
+			mask |= r.SMMask
+		}
+	}
+	if sm, err := pio.claimReservedStateMachine(mask); err == nil {
+		return sm, nil
+	}
+	for i := uint8(0); i < 4; i++ {
+		sm := pio.StateMachine(i)
+		if !sm.IsReserved() && sm.TryClaim() {
+			return sm, nil
+		}
+	}
+	return StateMachine{}, errStateMachineClaimed
+}
+
+// SetClaimReserved allows ClaimStateMachine to return reserved state machines
+// once all unreserved ones are claimed. It is the escape hatch for applications
+// that need every state machine and don't use the drivers they are reserved for.
+func (pio *PIO) SetClaimReserved(allow bool) {
+	pio.claimReserved = allow
+}
+
+// claimReservedStateMachine claims a reserved state machine in mask.
+func (pio *PIO) claimReservedStateMachine(mask uint8) (StateMachine, error) {
+	for i := uint8(0); i < 4; i++ {
+		sm := pio.StateMachine(i)
+		if mask&(1<<i) != 0 && sm.IsReserved() && sm.TryClaim() {
+			return sm, nil
+		}
+	}
+	return StateMachine{}, errStateMachineClaimed
+}
+
+// ReservationReport returns a human readable report of the reservations of both
+// PIO blocks, one line per reservation. For example:
+//
+//	PIO0: 3/4 state machines, 24/32 instructions reserved
+//	  SM0 SM1 16 instructions: display
+//	  SM2      8 instructions: leds
+//	PIO1: 0/4 state machines, 0/32 instructions reserved
+func ReservationReport() string {
+	var b []byte
+	for i, pio := range [2]*PIO{PIO0, PIO1} {
+		smCount := 0
+		for mask := pio.reservedSMMask; mask != 0; mask >>= 1 {
+			smCount += int(mask & 1)
+		}
+		b = append(b, "PIO"...)
+		b = strconv.AppendInt(b, int64(i), 10)
+		b = append(b, ": "...)
+		b = strconv.AppendInt(b, int64(smCount), 10)
+		b = append(b, "/4 state machines, "...)
+		b = strconv.AppendInt(b, int64(pio.reservedInstr), 10)
+		b = append(b, '/')
+		b = strconv.AppendInt(b, int64(pio.InstructionMemSize()), 10)
+		b = append(b, " instructions reserved\n"...)
+		for _, r := range pio.reservations {
+			b = append(b, ' ', ' ')
+			n := 0
+			for sm := 0; sm < 4; sm++ {
+				if r.SMMask&(1<<sm) != 0 {
+					b = append(b, "SM"...)
+					b = strconv.AppendInt(b, int64(sm), 10)
+					b = append(b, ' ')
+					n++
+				}
+			}
+			for ; n < 4; n++ {
+				b = append(b, "    "...)
+			}
+			if r.InstrBudget < 10 {
+				b = append(b, ' ')
+			}
+			b = strconv.AppendInt(b, int64(r.InstrBudget), 10)
+			b = append(b, " instructions: "...)
+			b = append(b, r.Owner...)
+			b = append(b, '\n')
+		}
+	}
+	return string(b)
+}
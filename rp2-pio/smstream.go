@@ -0,0 +1,66 @@
+//go:build rp2040
+
+package pio
+
+import "runtime"
+
+// TxStream returns the TX FIFO of the state machine as a stream of words. Blocking
+// methods yield to other goroutines while waiting and never time out.
+func (sm StateMachine) TxStream() TxStream[uint32] {
+	return smTxStream{sm: sm}
+}
+
+// RxStream returns the RX FIFO of the state machine as a stream of words. Blocking
+// methods yield to other goroutines while waiting and never time out.
+func (sm StateMachine) RxStream() RxStream[uint32] {
+	return smRxStream{sm: sm}
+}
+
+type smTxStream struct {
+	sm StateMachine
+}
+
+func (s smTxStream) Put(v uint32) error {
+	for s.sm.IsTxFIFOFull() {
+		runtime.Gosched()
+	}
+	s.sm.TxPut(v)
+	return nil
+}
+
+func (s smTxStream) TryPut(v uint32) bool {
+	return s.sm.TxPutFromISR(v)
+}
+
+func (s smTxStream) Write(p []uint32) (n int, err error) {
+	for _, v := range p {
+		s.Put(v)
+	}
+	return len(p), nil
+}
+
+type smRxStream struct {
+	sm StateMachine
+}
+
+func (s smRxStream) Get() (uint32, error) {
+	for s.sm.IsRxFIFOEmpty() {
+		runtime.Gosched()
+	}
+	return s.sm.RxGet(), nil
+}
+
+func (s smRxStream) TryGet() (uint32, bool) {
+	return s.sm.RxGetFromISR()
+}
+
+func (s smRxStream) Read(p []uint32) (n int, err error) {
+	for n < len(p) {
+		if n > 0 && s.sm.IsRxFIFOEmpty() {
+			break
+		}
+		p[n], _ = s.Get()
+		n++
+	}
+	return n, nil
+}
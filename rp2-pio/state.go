@@ -0,0 +1,150 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"device/rp"
+)
+
+// PIOState is a snapshot of a PIO block taken by SaveState.
+type PIOState struct {
+	instrMem        [instrMemCapacity]uint16
+	usedSpaceMask   instrMask
+	claimedSMMask   uint8
+	enabledMask     uint8
+	inputSyncBypass uint32
+	padOut, padOE   uint32
+	inte            [2]uint32
+	sm              [4]smState
+}
+
+// smState is the part of a PIOState of a single state machine.
+type smState struct {
+	clkdiv, execctrl, shiftctrl, pinctrl uint32
+	x, y                                 uint32
+	pc                                   uint8
+}
+
+// SaveState halts the state machines of the block and returns a snapshot of its
+// instruction memory, state machine configurations, program counters and X and
+// Y registers, output pin levels and directions, interrupt enables and claims.
+// With RestoreState it lets firmware power down or stop the clocks of the PIO,
+// i.e. in dormant mode, and resume all drivers on wake without running their
+// constructors again.
+//
+// The FIFOs and shift registers are not saved, the FIFOs are cleared: drivers
+// should be idle, with no transfer in progress, when the state is saved. The
+// DMA channels and GPIO functions of drivers are not part of the PIO block.
+func (pio *PIO) SaveState() PIOState {
+	hw := pio.HW()
+	s := PIOState{
+		enabledMask:     uint8(hw.CTRL.Get() & rp.PIO0_CTRL_SM_ENABLE_Msk >> rp.PIO0_CTRL_SM_ENABLE_Pos),
+		instrMem:        pio.instrMem,
+		usedSpaceMask:   pio.usedSpaceMask,
+		inputSyncBypass: hw.INPUT_SYNC_BYPASS.Get(),
+		padOut:          hw.DBG_PADOUT.Get(),
+		padOE:           hw.DBG_PADOE.Get(),
+	}
+	clearBits(&pio.hw.CTRL, rp.PIO0_CTRL_SM_ENABLE_Msk)
+	state := claimLock()
+	s.claimedSMMask = pio.claimedSMMask
+	claimUnlock(state)
+	for i := range s.inte {
+		s.inte[i] = hw.IRQ_INT[i].E.Get()
+	}
+	for i := range s.sm {
+		smhw := &hw.SM[i]
+		st := &s.sm[i]
+		st.clkdiv = smhw.CLKDIV.Get()
+		st.execctrl = smhw.EXECCTRL.Get()
+		st.shiftctrl = smhw.SHIFTCTRL.Get()
+		st.pinctrl = smhw.PINCTRL.Get()
+		st.pc = uint8(smhw.ADDR.Get())
+		// X and Y are pushed through the RX FIFO, unjoined for it, with no side-set
+		// so the instructions don't touch the pins.
+		smhw.SHIFTCTRL.Set(st.shiftctrl &^ (rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk | rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk))
+		// Toggling the join twice clears the FIFOs.
+		smhw.SHIFTCTRL.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+		smhw.SHIFTCTRL.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+		smhw.PINCTRL.Set(0)
+		st.x = pio.execRead(smhw, i, SrcDestX)
+		st.y = pio.execRead(smhw, i, SrcDestY)
+		smhw.PINCTRL.Set(st.pinctrl)
+		smhw.SHIFTCTRL.Set(st.shiftctrl)
+	}
+	return s
+}
+
+// execRead returns the content of src, X or Y, of a halted state machine.
+func (pio *PIO) execRead(smhw *statemachineHW, index int, src SrcDest) uint32 {
+	smhw.INSTR.Set(uint32(EncodeMov(SrcDestISR, src)))
+	smhw.INSTR.Set(uint32(EncodePush(false, false)))
+	return pio.HW().RXF[index].Get()
+}
+
+// RestoreState restores a snapshot taken by SaveState, after the block was reset
+// or powered down, and restarts the state machines which were running, in sync.
+// Programs and claims made since the snapshot are discarded. The OSR of each state
+// machine is left empty, so its program pulls its next word from the TX FIFO.
+func (pio *PIO) RestoreState(s PIOState) {
+	hw := pio.HW()
+	clearBits(&pio.hw.CTRL, rp.PIO0_CTRL_SM_ENABLE_Msk)
+	for i, instr := range s.instrMem {
+		if s.usedSpaceMask&(1<<i) != 0 {
+			pio.writeInstructionMemory(uint8(i), instr)
+		}
+	}
+	state := claimLock()
+	pio.usedSpaceMask = s.usedSpaceMask
+	pio.claimedSMMask = s.claimedSMMask
+	claimUnlock(state)
+	hw.INPUT_SYNC_BYPASS.Set(s.inputSyncBypass)
+
+	// Output levels and directions are set through state machine 0 one pin at a
+	// time, the SET instruction writing a single pin.
+	sm0 := &hw.SM[0]
+	sm0.EXECCTRL.Set(0)
+	for i := uint32(0); i < 32; i++ {
+		sm0.PINCTRL.Set(1<<rp.PIO0_SM0_PINCTRL_SET_COUNT_Pos | i<<rp.PIO0_SM0_PINCTRL_SET_BASE_Pos)
+		sm0.INSTR.Set(uint32(EncodeSet(SrcDestPins, uint8(s.padOut>>i&1))))
+		sm0.INSTR.Set(uint32(EncodeSet(SrcDestPinDirs, uint8(s.padOE>>i&1))))
+	}
+
+	var restart uint32
+	for i := range s.sm {
+		smhw := &hw.SM[i]
+		st := &s.sm[i]
+		smhw.CLKDIV.Set(st.clkdiv)
+		smhw.EXECCTRL.Set(st.execctrl &^ rp.PIO0_SM0_EXECCTRL_OUT_STICKY_Msk)
+		// X and Y are pulled through the TX FIFO, unjoined for it, with no side-set
+		// so the instructions don't touch the pins.
+		smhw.SHIFTCTRL.Set(st.shiftctrl &^ (rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk | rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk))
+		smhw.SHIFTCTRL.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+		smhw.SHIFTCTRL.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+		smhw.PINCTRL.Set(0)
+		setBits(&pio.hw.CTRL, 1<<(rp.PIO0_CTRL_SM_RESTART_Pos+i))
+		hw.TXF[i].Set(st.x)
+		smhw.INSTR.Set(uint32(EncodePull(false, true)))
+		smhw.INSTR.Set(uint32(EncodeMov(SrcDestX, SrcDestOSR)))
+		hw.TXF[i].Set(st.y)
+		smhw.INSTR.Set(uint32(EncodePull(false, true)))
+		smhw.INSTR.Set(uint32(EncodeMov(SrcDestY, SrcDestOSR)))
+		// The pulls left the OSR full of Y, which programs using autopull or
+		// PULL IFEMPTY would shift out: empty it.
+		smhw.INSTR.Set(uint32(EncodeOut(SrcDestNull, 32)))
+		smhw.INSTR.Set(uint32(EncodeJmp(st.pc, JmpAlways)))
+		// Changing the FIFO join clears the FIFOs.
+		smhw.SHIFTCTRL.Set(st.shiftctrl)
+		smhw.PINCTRL.Set(st.pinctrl)
+		smhw.EXECCTRL.Set(st.execctrl)
+		if s.enabledMask&(1<<i) != 0 {
+			restart |= 1 << (rp.PIO0_CTRL_CLKDIV_RESTART_Pos + i)
+		}
+	}
+	for i := range s.inte {
+		hw.IRQ_INT[i].E.Set(s.inte[i])
+	}
+	// Restart the clock dividers with the state machines so those which ran in
+	// sync before the snapshot do so again.
+	setBits(&pio.hw.CTRL, restart|uint32(s.enabledMask)<<rp.PIO0_CTRL_SM_ENABLE_Pos)
+}
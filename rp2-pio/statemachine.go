@@ -1,3 +1,5 @@
+//go:build rp2040
+
 package pio
 
 import (
@@ -21,17 +23,30 @@ type StateMachine struct {
 func (sm StateMachine) IsClaimed() bool { return sm.pio.claimedSMMask&(1<<sm.index) != 0 }
 
 // Unclaim releases the state machine for use by other code.
-func (sm StateMachine) Unclaim() { sm.pio.claimedSMMask &^= (1 << sm.index) }
+// It is safe to call from interrupt handlers and from either core.
+func (sm StateMachine) Unclaim() {
+	state := claimLock()
+	sm.pio.claimedSMMask &^= (1 << sm.index)
+	sm.clearOwner()
+	claimUnlock(state)
+	for _, fn := range unclaimHooks {
+		fn(sm)
+	}
+}
 
 // Claim attempts to claim the state machine for use by the caller and returns
 // true if successful, or false if StateMachine already claimed. Regardless of result
 // the state machine is guaranteed to be claimed after the call ends.
+// It is safe to call from interrupt handlers and from either core.
 func (sm StateMachine) TryClaim() bool {
-	if sm.IsClaimed() {
-		return false
+	state := claimLock()
+	claimed := sm.IsClaimed()
+	if !claimed {
+		sm.pio.claimedSMMask |= 1 << sm.index
+		sm.setOwner()
 	}
-	sm.pio.claimedSMMask |= 1 << sm.index
-	return true
+	claimUnlock(state)
+	return !claimed
 }
 
 // HW returns a pointer to the configuration hardware registers for this state machine.
@@ -88,8 +103,16 @@ func (sm StateMachine) Init(initialPC uint8, cfg StateMachineConfig) {
 }
 
 // SetEnabled controls whether the state machine is running.
+//
+// CTRL is shared by all state machines in the PIO so it is modified with atomic
+// set/clear aliases, making this safe to call from interrupt handlers and either core.
 func (sm StateMachine) SetEnabled(enabled bool) {
-	sm.pio.hw.CTRL.ReplaceBits(boolToBit(enabled), 0x1, sm.index)
+	mask := uint32(1) << (rp.PIO0_CTRL_SM_ENABLE_Pos + sm.index)
+	if enabled {
+		setBits(&sm.pio.hw.CTRL, mask)
+	} else {
+		clearBits(&sm.pio.hw.CTRL, mask)
+	}
 }
 
 // IsEnabled returns true if the state machine is running.
@@ -99,12 +122,43 @@ func (sm StateMachine) IsEnabled() bool {
 
 // Restart clears internal StateMachine state which may otherwise be difficult to access, e.g. shift counters.
 func (sm StateMachine) Restart() {
-	sm.pio.hw.CTRL.SetBits(1 << (rp.PIO0_CTRL_SM_RESTART_Pos + sm.index))
+	setBits(&sm.pio.hw.CTRL, 1<<(rp.PIO0_CTRL_SM_RESTART_Pos+sm.index))
 }
 
 // ClkDivRestart forces clock dividers to restart their count and clear fractional accumulators (phase is zeroed).
 func (sm StateMachine) ClkDivRestart() {
-	sm.pio.hw.CTRL.SetBits(1 << (rp.PIO0_CTRL_CLKDIV_RESTART_Pos + sm.index))
+	setBits(&sm.pio.hw.CTRL, 1<<(rp.PIO0_CTRL_CLKDIV_RESTART_Pos+sm.index))
+}
+
+// StartSynchronized starts the state machines in lockstep, i.e. the TX and RX state
+// machines of a duplex interface which sample relative to each other's clock.
+// The state machines are disabled, then their clock dividers are restarted and
+// they are enabled by a single write to CTRL, so state machines of the same PIO
+// block running at the same clock divider execute their first instruction on the
+// same cycle and stay in phase for as long as they run.
+//
+// CTRL is per block: state machines of PIO0 are started one bus write before those
+// of PIO1, so only state machines of the same block are guaranteed to be in phase.
+// The state machines should be initialized beforehand and keep their program counter.
+func StartSynchronized(sms ...StateMachine) {
+	var masks [2]uint32
+	var blocks [2]*PIO
+	for _, sm := range sms {
+		block := sm.PIO().BlockIndex()
+		blocks[block] = sm.pio
+		masks[block] |= 1 << sm.index
+	}
+	for i, pio := range blocks {
+		if pio != nil {
+			clearBits(&pio.hw.CTRL, masks[i]<<rp.PIO0_CTRL_SM_ENABLE_Pos)
+			pio.hw.CTRL.Get() // Make sure the state machines are stopped before restarting.
+		}
+	}
+	for i, pio := range blocks {
+		if pio != nil {
+			setBits(&pio.hw.CTRL, masks[i]<<rp.PIO0_CTRL_SM_ENABLE_Pos|masks[i]<<rp.PIO0_CTRL_CLKDIV_RESTART_Pos)
+		}
+	}
 }
 
 // SetConfig applies state machine configuration to a state machine
@@ -113,9 +167,13 @@ func (sm StateMachine) SetConfig(cfg StateMachineConfig) {
 	if sm.index > 3 {
 		panic(badStateMachineIndex)
 	}
+	if cfg.minVersion > sm.pio.Version() {
+		panic("pio:configuration unsupported by PIO version")
+	}
+	sm.checkOwner()
 	hw := sm.HW()
 	hw.CLKDIV.Set(cfg.ClkDiv)
-	hw.EXECCTRL.Set(cfg.ExecCtrl)
+	hw.EXECCTRL.Set(cfg.execCtrl(sm.pio.Version()))
 	hw.SHIFTCTRL.Set(cfg.ShiftCtrl)
 	hw.PINCTRL.Set(cfg.PinCtrl)
 }
@@ -124,6 +182,7 @@ func (sm StateMachine) SetConfig(cfg StateMachineConfig) {
 //
 //	Frequency = clock freq / (CLKDIV_INT + CLKDIV_FRAC / 256)
 func (sm StateMachine) SetClkDiv(whole uint16, frac uint8) {
+	sm.checkOwner()
 	sm.HW().CLKDIV.Set(clkDiv(whole, frac))
 }
 
@@ -136,6 +195,22 @@ func (sm StateMachine) TxPut(data uint32) {
 	reg.Set(data)
 }
 
+// TxPutFromISR puts a value into the state machine's TX FIFO if it is not full
+// and returns true on success. It never blocks or yields so it is safe to call from
+// interrupt handlers and from either core.
+//
+// FIFO accesses are single volatile 32 bit bus writes to the PIO which are not reordered
+// by the compiler nor the Cortex-M0+, so no additional memory barriers are required.
+// The fullness check is not atomic with the write: only one context may write to
+// a state machine's TX FIFO at a time.
+func (sm StateMachine) TxPutFromISR(data uint32) bool {
+	if sm.IsTxFIFOFull() {
+		return false
+	}
+	sm.TxPut(data)
+	return true
+}
+
 // RxGet reads a word of data from a state machine's RX FIFO.
 //
 // This function does not check for emptiness. If the FIFO is empty
@@ -145,7 +220,51 @@ func (sm StateMachine) RxGet() uint32 {
 	return reg.Get()
 }
 
+// RxGetFromISR reads a word of data from the state machine's RX FIFO if it is not empty.
+// ok is false if the FIFO was empty. Same concurrency rules as TxPutFromISR apply.
+func (sm StateMachine) RxGetFromISR() (data uint32, ok bool) {
+	if sm.IsRxFIFOEmpty() {
+		return 0, false
+	}
+	return sm.RxGet(), true
+}
+
+// TxPutUnsafe writes data to the TX FIFO like TxPut, inlined at the call site for
+// tight loops feeding the FIFO one word at a time. It does not check for fullness
+// nor the validity of sm, the caller is responsible for both.
+//
+//go:inline
+func (sm StateMachine) TxPutUnsafe(data uint32) {
+	(*volatile.Register32)(unsafe.Add(unsafe.Pointer(&sm.pio.hw.TXF0), uintptr(sm.index)*4)).Set(data)
+}
+
+// RxGetUnsafe reads a word from the RX FIFO like RxGet, inlined at the call site for
+// tight loops draining the FIFO. It does not check for emptiness nor the validity of sm.
+//
+//go:inline
+func (sm StateMachine) RxGetUnsafe() uint32 {
+	return (*volatile.Register32)(unsafe.Add(unsafe.Pointer(&sm.pio.hw.RXF0), uintptr(sm.index)*4)).Get()
+}
+
+// TxRegAddr returns the bus address of the TX FIFO register of the state machine,
+// to be written to the write address of a DMA channel feeding the state machine.
+// Narrow 8 and 16 bit DMA writes to this address are replicated across the 32 bit
+// word by the bus fabric.
+func (sm StateMachine) TxRegAddr() uint32 {
+	return uint32(uintptr(unsafe.Pointer(sm.TxReg())))
+}
+
+// RxRegAddr returns the bus address of the RX FIFO register of the state machine,
+// to be written to the read address of a DMA channel draining the state machine.
+func (sm StateMachine) RxRegAddr() uint32 {
+	return uint32(uintptr(unsafe.Pointer(sm.RxReg())))
+}
+
 // TxReg gets a pointer to the TX FIFO register for this state machine.
+//
+// Raw register access is meant for DMA helpers taking register pointers and may be
+// deprecated: use TxPut or TxPutUnsafe to write the FIFO and TxRegAddr for DMA
+// rather than converting the register with package unsafe.
 func (sm StateMachine) TxReg() *volatile.Register32 {
 	start := uintptr(unsafe.Pointer(&sm.pio.hw.TXF0)) // 0x10
 	offset := uintptr(sm.index) * 4
@@ -153,6 +272,8 @@ func (sm StateMachine) TxReg() *volatile.Register32 {
 }
 
 // RxReg gets a pointer to the RX FIFO register for this state machine.
+//
+// Same as TxReg, prefer RxGet or RxGetUnsafe to read the FIFO and RxRegAddr for DMA.
 func (sm StateMachine) RxReg() *volatile.Register32 {
 	start := uintptr(unsafe.Pointer(&sm.pio.hw.RXF0)) // 0x20
 	offset := uintptr(sm.index) * 4
@@ -197,18 +318,62 @@ func (sm StateMachine) IsRxFIFOFull() bool {
 
 // ClearFIFOs clears the TX and RX FIFOs of a state machine.
 func (sm StateMachine) ClearFIFOs() {
-	hw := sm.HW()
-	shiftctl := &hw.SHIFTCTRL
+	sm.checkOwner()
+	shiftctl := &sm.HW().SHIFTCTRL
 	// FIFOs are flushed when this bit is changed. Xoring twice returns bit to original state.
-	xorBits(shiftctl, rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
-	xorBits(shiftctl, rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+	shiftctl.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
+	shiftctl.XorBits(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk)
 }
 
 // Exec will immediately execute an instruction on the state machine
 func (sm StateMachine) Exec(instr uint16) {
+	sm.checkOwner()
 	sm.HW().INSTR.Set(uint32(instr))
 }
 
+// IsExecStalled returns true if an instruction written with Exec is stalled and latched
+// by the state machine, i.e: a WAIT instruction whose condition has not been met yet.
+// A stalled instruction is discarded by Restart.
+func (sm StateMachine) IsExecStalled() bool {
+	return sm.HW().EXECCTRL.HasBits(rp.PIO0_SM0_EXECCTRL_EXEC_STALLED_Msk)
+}
+
+// IsTxStalled returns true if the state machine stalled on an empty TX FIFO during a
+// blocking PULL, or an OUT with autopull enabled, since the flag was last cleared.
+// The flag is set again on every cycle the state machine remains stalled.
+func (sm StateMachine) IsTxStalled() bool {
+	return sm.pio.hw.FDEBUG.HasBits(1 << (rp.PIO0_FDEBUG_TXSTALL_Pos + sm.index))
+}
+
+// ClearTxStalled clears the flag returned by IsTxStalled.
+func (sm StateMachine) ClearTxStalled() {
+	sm.pio.hw.FDEBUG.Set(1 << (rp.PIO0_FDEBUG_TXSTALL_Pos + sm.index)) // Write 1 to clear.
+}
+
+// IsRxStalled returns true if the state machine stalled on a full RX FIFO during a
+// blocking PUSH, or an IN with autopush enabled, since the flag was last cleared.
+func (sm StateMachine) IsRxStalled() bool {
+	return sm.pio.hw.FDEBUG.HasBits(1 << (rp.PIO0_FDEBUG_RXSTALL_Pos + sm.index))
+}
+
+// ClearRxStalled clears the flag returned by IsRxStalled.
+func (sm StateMachine) ClearRxStalled() {
+	sm.pio.hw.FDEBUG.Set(1 << (rp.PIO0_FDEBUG_RXSTALL_Pos + sm.index)) // Write 1 to clear.
+}
+
+// ClearFIFOErrors returns whether a word was written to the full TX FIFO, and so
+// dropped, or the empty RX FIFO was read since Init or the last call, and clears
+// the sticky TXOVER and RXUNDER flags. The hardware doesn't report these otherwise.
+func (sm StateMachine) ClearFIFOErrors() (txOverflow, rxUnderflow bool) {
+	txover := uint32(1) << (rp.PIO0_FDEBUG_TXOVER_Pos + sm.index)
+	rxunder := uint32(1) << (rp.PIO0_FDEBUG_RXUNDER_Pos + sm.index)
+	flags := sm.pio.hw.FDEBUG.Get() & (txover | rxunder)
+	if flags != 0 {
+		sm.pio.hw.FDEBUG.Set(flags) // Write 1 to clear.
+	}
+	return flags&txover != 0, flags&rxunder != 0
+}
+
 // SetPindirsConsecutive sets a range of pins to either 'in' or 'out'. This must be done
 // for all used pins before the state machine is started, including SET, IN, OUT and SIDESET pins.
 func (sm StateMachine) SetPindirsConsecutive(pin machine.Pin, count uint8, isOut bool) {
@@ -289,6 +454,7 @@ func (sm StateMachine) SetWrap(target, wrap uint8) {
 	if wrap >= 32 || target >= 32 {
 		panic("pio:bad wrap")
 	}
+	sm.checkOwner()
 	hw := sm.HW()
 	hw.EXECCTRL.ReplaceBits(
 		(uint32(target)<<rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Pos)|
@@ -298,6 +464,66 @@ func (sm StateMachine) SetWrap(target, wrap uint8) {
 	)
 }
 
+// SetOutSticky enables or disables sticky output, the runtime counterpart of the
+// sticky argument of StateMachineConfig.SetOutSpecial. With sticky output the state
+// machine re-asserts its most recent OUT/SET pin values and directions on every
+// cycle, so a bus it drives keeps its state while other state machines of the
+// block also write the pins.
+//
+// A running state machine is paused for the update and resumed on the same
+// instruction, so the change takes effect between two instructions.
+func (sm StateMachine) SetOutSticky(sticky bool) {
+	sm.updateExecCtrl(boolToBit(sticky)<<rp.PIO0_SM0_EXECCTRL_OUT_STICKY_Pos, rp.PIO0_SM0_EXECCTRL_OUT_STICKY_Msk)
+}
+
+// SetInlineOutEnable enables or disables inline OUT enable, the runtime counterpart
+// of the enable arguments of StateMachineConfig.SetOutSpecial. When enabled, bit
+// enableBit of the data of each OUT instruction is an auxiliary write enable. It
+// does not apply to SET. An OUT whose enable bit is clear deasserts the pin write
+// of the state machine, so with sticky output it stops re-asserting its last
+// values and the pins are left to the writes of the other state machines of the
+// block, higher numbered ones taking priority. This makes overrides and masked
+// buses where a state machine only takes the pins for some of the words it
+// shifts out, i.e:
+//
+//	sm.SetOutSticky(true)
+//	sm.SetInlineOutEnable(true, 8) // Bit 8 of the words written to the bus gates bits 0..7.
+//
+// A running state machine is paused for the update as with SetOutSticky.
+func (sm StateMachine) SetInlineOutEnable(enabled bool, enableBit uint8) {
+	if enableBit > 31 {
+		panic("pio:bad OUT enable bit")
+	}
+	sm.updateExecCtrl(
+		boolToBit(enabled)<<rp.PIO0_SM0_EXECCTRL_INLINE_OUT_EN_Pos|uint32(enableBit)<<rp.PIO0_SM0_EXECCTRL_OUT_EN_SEL_Pos,
+		rp.PIO0_SM0_EXECCTRL_INLINE_OUT_EN_Msk|rp.PIO0_SM0_EXECCTRL_OUT_EN_SEL_Msk,
+	)
+}
+
+// SetJmpPin sets the pin tested by JMP PIN instructions, the runtime counterpart
+// of StateMachineConfig.SetJmpPin. It lets a program be multiplexed across several
+// input pins, such as a bank of sensors, without rewriting the configuration.
+//
+// A running state machine is paused for the update as with SetOutSticky.
+func (sm StateMachine) SetJmpPin(pin machine.Pin) {
+	checkPinBaseAndCount(pin, 1)
+	sm.updateExecCtrl(uint32(pin)<<rp.PIO0_SM0_EXECCTRL_JMP_PIN_Pos, rp.PIO0_SM0_EXECCTRL_JMP_PIN_Msk)
+}
+
+// updateExecCtrl replaces the mask bits of EXECCTRL with value, pausing the state
+// machine during the update if it is running.
+func (sm StateMachine) updateExecCtrl(value, mask uint32) {
+	sm.checkOwner()
+	enabled := sm.IsEnabled()
+	if enabled {
+		sm.SetEnabled(false)
+	}
+	sm.HW().EXECCTRL.ReplaceBits(value, mask, 0)
+	if enabled {
+		sm.SetEnabled(true)
+	}
+}
+
 // SetX sets the X register of a state machine. The state machine should be halted beforehand.
 func (sm StateMachine) SetX(value uint32) {
 	sm.setDst(SrcDestX, value)
@@ -320,6 +546,62 @@ func (sm StateMachine) GetY() uint32 {
 	return sm.getDst(SrcDestY)
 }
 
+// GetISR gets the input shift register of a state machine, for debugging. The
+// state machine should be halted beforehand with an empty RX FIFO, not joined
+// to the TX FIFO. The ISR is pushed to read it, which clears it and its shift count.
+func (sm StateMachine) GetISR() uint32 {
+	sm.Exec(EncodePush(false, false))
+	return sm.RxGet()
+}
+
+// GetOSR gets the output shift register of a state machine, for debugging. The
+// state machine should be halted beforehand with an empty RX FIFO. The OSR and
+// its shift count are unchanged but the ISR is overwritten: call GetISR first.
+func (sm StateMachine) GetOSR() uint32 {
+	sm.Exec(EncodeMov(SrcDestISR, SrcDestOSR))
+	return sm.GetISR()
+}
+
+// ShiftCounts gets the number of bits shifted into the ISR and out of the OSR
+// since they were last cleared, for debugging, i.e. to find residual bits after
+// a transfer. The state machine should be halted beforehand with empty FIFOs.
+//
+// The RP2040 has no register holding the counts: they are measured by shifting
+// single bits until a conditional PUSH or PULL fires at a threshold of 32, which
+// destroys the content of both shift registers. Read them first with GetISR and
+// GetOSR, and reinitialize the state machine afterwards.
+func (sm StateMachine) ShiftCounts() (inCount, outCount uint8) {
+	hw := sm.HW()
+	shiftctrl := hw.SHIFTCTRL.Get()
+	// Thresholds of 32, no autopush or autopull so only the instructions below
+	// shift, and unjoined FIFOs to see both the PUSH and the PULL.
+	hw.SHIFTCTRL.Set(shiftctrl &^ (rp.PIO0_SM0_SHIFTCTRL_PUSH_THRESH_Msk | rp.PIO0_SM0_SHIFTCTRL_PULL_THRESH_Msk |
+		rp.PIO0_SM0_SHIFTCTRL_AUTOPUSH_Msk | rp.PIO0_SM0_SHIFTCTRL_AUTOPULL_Msk |
+		rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX_Msk | rp.PIO0_SM0_SHIFTCTRL_FJOIN_TX_Msk))
+	inCount, outCount = 32, 32
+	for n := uint8(0); n <= 32; n++ {
+		sm.Exec(EncodePush(true, false))
+		if !sm.IsRxFIFOEmpty() {
+			sm.RxGet()
+			inCount = 32 - n
+			break
+		}
+		sm.Exec(EncodeIn(SrcDestNull, 1))
+	}
+	// PULL IFEMPTY takes the word from the TX FIFO once the threshold is reached.
+	sm.TxPut(0)
+	for n := uint8(0); n <= 32; n++ {
+		sm.Exec(EncodePull(true, false))
+		if sm.IsTxFIFOEmpty() {
+			outCount = 32 - n
+			break
+		}
+		sm.Exec(EncodeOut(SrcDestNull, 1))
+	}
+	hw.SHIFTCTRL.Set(shiftctrl)
+	return inCount, outCount
+}
+
 func (sm StateMachine) setDst(dst SrcDest, value uint32) {
 	const bitCount = 32
 	instr := EncodeOut(dst, bitCount)
@@ -368,3 +650,11 @@ func aliasReg(alias uintptr, reg *volatile.Register32) *volatile.Register32 {
 func xorBits(reg *volatile.Register32, bits uint32) {
 	aliasReg(regAliasXOR, reg).Set(bits)
 }
+
+func setBits(reg *volatile.Register32, bits uint32) {
+	aliasReg(regAliasSET, reg).Set(bits)
+}
+
+func clearBits(reg *volatile.Register32, bits uint32) {
+	aliasReg(regAliasCLR, reg).Set(bits)
+}
@@ -0,0 +1,33 @@
+package pio
+
+// Word is the type of the elements of a stream: a FIFO word, or a narrower value
+// for FIFOs accessed with 8 or 16 bit writes and reads.
+type Word interface {
+	uint8 | uint16 | uint32
+}
+
+// TxStream is a stream of words written to a peripheral, such as the TX FIFO of a
+// state machine or a driver's transmit path. Layers above drivers, i.e. effects
+// engines or audio mixers, can be written against it instead of the naming of
+// each driver. In piolib it is implemented by the byte streams of UART and RS485,
+// the colors of WS2812B, the samples of I2S and by DMATxStream.
+type TxStream[T Word] interface {
+	// Put writes v, blocking while the stream is full.
+	Put(v T) error
+	// TryPut writes v if there is room and returns false otherwise, without blocking.
+	TryPut(v T) bool
+	// Write writes all of p, blocking until it has been queued.
+	Write(p []T) (n int, err error)
+}
+
+// RxStream is a stream of words read from a peripheral, such as the RX FIFO of a
+// state machine or a driver's receive path. In piolib it is implemented by the
+// byte streams of UART, RS485 and FSKModem and by DMARxStream. See TxStream.
+type RxStream[T Word] interface {
+	// Get reads a word, blocking until one is available.
+	Get() (T, error)
+	// TryGet reads a word if one is available and returns false otherwise, without blocking.
+	TryGet() (T, bool)
+	// Read blocks until at least one word is available and reads up to len(p) words.
+	Read(p []T) (n int, err error)
+}
@@ -0,0 +1,94 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"device/rp"
+	"errors"
+)
+
+var errSwapNoBoundary = errors.New("pio: state machine did not reach its wrap target")
+
+// Attempts at catching a running state machine at its wrap target.
+const swapAttempts = 10000
+
+// SwapProgram switches a state machine to newProg without a full reinitialization,
+// i.e. for drivers alternating between modes such as the command and data phases
+// of SDIO. entry is the index of the instruction of newProg to start at.
+//
+// A running state machine is halted at a loop boundary: when it is about to run,
+// or stalls on, the wrap target of its current program, as drivers do while idle
+// waiting for their next word. newProg is then loaded, reusing a copy already in
+// the instruction memory, such as one loaded beforehand with PIO.LoadProgram or by
+// a previous swap, and the wrap and side-set settings of the state machine are
+// updated for it. Other settings, the FIFOs and registers are left untouched. The
+// state machine resumes at entry if it was running.
+//
+// The previous program stays loaded so swapping back to it is quick. An error is
+// returned if the state machine doesn't reach its wrap target or there is no
+// space for newProg, in which case the state machine keeps running its program.
+func (sm StateMachine) SwapProgram(newProg Program, entry uint8) error {
+	if int(entry) >= len(newProg.Instructions) {
+		panic(badProgramBounds)
+	}
+	sm.checkOwner()
+	hw := sm.HW()
+	enabled := sm.IsEnabled()
+	if enabled && !sm.haltAtWrapTarget() {
+		return errSwapNoBoundary
+	}
+	offset, err := sm.pio.findOrAddProgram(newProg)
+	if err != nil {
+		if enabled {
+			sm.SetEnabled(true)
+		}
+		return err
+	}
+	var cfg StateMachineConfig
+	cfg.SetWrap(offset+newProg.WrapTarget, offset+newProg.Wrap)
+	cfg.SetSidesetParams(newProg.SidesetBits, newProg.SidesetOptional, newProg.SidesetPindirs)
+	hw.EXECCTRL.ReplaceBits(cfg.ExecCtrl, rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Msk|rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Msk|
+		rp.PIO0_SM0_EXECCTRL_SIDE_EN_Msk|rp.PIO0_SM0_EXECCTRL_SIDE_PINDIR_Msk, 0)
+	hw.PINCTRL.ReplaceBits(cfg.PinCtrl, rp.PIO0_SM0_PINCTRL_SIDESET_COUNT_Msk, 0)
+	sm.Jmp(offset+entry, JmpAlways)
+	if enabled {
+		sm.SetEnabled(true)
+	}
+	return nil
+}
+
+// haltAtWrapTarget disables the running state machine when its program counter
+// is at its wrap target. It returns false, with the state machine running, if it
+// isn't caught there.
+func (sm StateMachine) haltAtWrapTarget() bool {
+	hw := sm.HW()
+	target := (hw.EXECCTRL.Get() & rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Msk) >> rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Pos
+	for i := 0; i < swapAttempts; i++ {
+		if hw.ADDR.Get() != target {
+			continue
+		}
+		sm.SetEnabled(false)
+		// The state machine may have moved on before it was disabled.
+		if hw.ADDR.Get() == target {
+			return true
+		}
+		sm.SetEnabled(true)
+	}
+	return false
+}
+
+// findOrAddProgram returns the offset of a copy of prog in instruction memory,
+// loading it if there is none.
+func (pio *PIO) findOrAddProgram(prog Program) (offset uint8, err error) {
+	n := len(prog.Instructions)
+	mask := instrMask(1)<<n - 1
+	for i := 0; i+n <= int(pio.InstructionMemSize()); i++ {
+		if prog.Origin >= 0 && i != int(prog.Origin) {
+			continue
+		}
+		if pio.usedSpaceMask&(mask<<i) == mask<<i && pio.VerifyProgram(uint8(i), prog.Instructions) == nil {
+			return uint8(i), nil
+		}
+	}
+	return pio.AddProgram(prog.Instructions, prog.Origin)
+}
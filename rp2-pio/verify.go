@@ -0,0 +1,94 @@
+//go:build rp2040
+
+package pio
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrProgramMismatch is returned by VerifyProgram when instruction memory doesn't
+// hold the expected program.
+var ErrProgramMismatch = errors.New("pio: instruction memory does not match program")
+
+// VerifyProgram checks instructions, as passed to AddProgram, are loaded at offset.
+// It detects programs accidentally overwritten or cleared by other code sharing
+// the PIO block.
+//
+// The instruction memory registers are write-only on the RP2040 so they are
+// compared against a copy of what was written. Upsets of the memory itself, i.e.
+// radiation induced, can't be detected: use RefreshProgram to correct them.
+func (pio *PIO) VerifyProgram(offset uint8, instructions []uint16) error {
+	if !pio.inBounds(offset, len(instructions)) {
+		panic(badProgramBounds)
+	}
+	for i, instr := range instructions {
+		if pio.instrMem[offset+uint8(i)] != relocate(instr, offset) {
+			return ErrProgramMismatch
+		}
+	}
+	return nil
+}
+
+// RefreshProgram writes instructions, as passed to AddProgram, at offset again
+// without changing the allocation of instruction memory. Rewriting a program
+// while state machines run it is harmless and corrects any upset of the memory,
+// which can't be read back to be verified.
+func (pio *PIO) RefreshProgram(offset uint8, instructions []uint16) {
+	if !pio.inBounds(offset, len(instructions)) {
+		panic(badProgramBounds)
+	}
+	for i, instr := range instructions {
+		pio.writeInstructionMemory(offset+uint8(i), relocate(instr, offset))
+	}
+}
+
+// Verify checks the program is still loaded. See PIO.VerifyProgram.
+func (lp LoadedProgram) Verify() error {
+	return lp.pio.VerifyProgram(lp.offset, lp.Instructions)
+}
+
+// ProgramGuard periodically verifies and refreshes loaded programs, for control
+// loops that must keep running correct code for a long time.
+type ProgramGuard struct {
+	programs []LoadedProgram
+}
+
+// NewProgramGuard returns a guard for programs.
+func NewProgramGuard(programs ...LoadedProgram) *ProgramGuard {
+	return &ProgramGuard{programs: programs}
+}
+
+// Check verifies each program and refreshes those that verify. A program that
+// fails verification is not refreshed, as other code owns its memory now, and
+// the first error is returned after checking all programs.
+func (g *ProgramGuard) Check() (err error) {
+	for _, lp := range g.programs {
+		e := lp.Verify()
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		lp.pio.RefreshProgram(lp.offset, lp.Instructions)
+	}
+	return err
+}
+
+// Run calls Check every interval until stop is closed, calling onError with the
+// errors returned. It is meant to run in its own goroutine.
+func (g *ProgramGuard) Run(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := g.Check(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}